@@ -0,0 +1,271 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// header of the user who made the request
+	actorHeader = "X-Actor"
+	// header of the ID of the request
+	requestIdHeader = "X-Request-Id"
+	// header making the retries of POST /todo safe
+	idempotencyKeyHeader = "Idempotency-Key"
+)
+
+// default wait before the first retry
+const defaultInitialBackoff = 100 * time.Millisecond
+
+var (
+	// matches any *Error with a 4xx status code (errors.Is)
+	ErrClient = errors.New("client error")
+	// matches any *Error with a 5xx status code (errors.Is)
+	ErrServer = errors.New("server error")
+)
+
+// Error returned when the API responds with 4xx or 5xx
+type Error struct {
+	StatusCode int
+	// body of the response
+	Message string
+	// details of the request that does not match the OpenAPI document
+	ValidationErrors []ValidationError
+	// X-Request-Id of the response, to find the request in the server logs
+	RequestId string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrClient:
+		return e.StatusCode >= 400 && e.StatusCode < 500
+	case ErrServer:
+		return e.StatusCode >= 500
+	default:
+		return false
+	}
+}
+
+// Settings of the client
+type Option struct {
+	// http.DefaultClient if nil
+	HTTPClient *http.Client
+	// sent as X-Actor unless the request object has its own actor
+	Actor string
+	// sent as "Authorization: Bearer" if not empty, for a gateway in front of the API (the API itself does not authenticate)
+	Token string
+	// number of retries of a failed request, 0 to disable
+	// only GET and the requests with an Idempotency-Key (POST /todo) are retried
+	Retries int
+	// wait before the first retry, doubled on each retry (100ms if 0)
+	InitialBackoff time.Duration
+	// upper limit of the wait, unlimited if 0
+	MaxBackoff time.Duration
+}
+
+// Client of the ToDo API
+type Client struct {
+	baseURL *url.URL
+	client  *http.Client
+	option  Option
+}
+
+func NewClient(baseURL string, option Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("base URL must be http or https: %s", baseURL)
+	}
+	if option.InitialBackoff <= 0 {
+		option.InitialBackoff = defaultInitialBackoff
+	}
+	client := option.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Client{
+		baseURL: u,
+		client:  client,
+		option:  option,
+	}, nil
+}
+
+// One call of the API
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	// nil if the request has no body
+	body        []byte
+	contentType string
+}
+
+// JSONのボディを持つリクエストを作る
+func newJSONRequest(method string, path string, body interface{}) (*request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &request{method: method, path: path, header: http.Header{}, body: data, contentType: "application/json"}, nil
+}
+
+// 操作者とリクエストIDをヘッダに設定する(空の場合はクライアントの設定を使う)
+func (req *request) setRequestInfo(actor string, requestId string) {
+	if req.header == nil {
+		req.header = http.Header{}
+	}
+	if actor != "" {
+		req.header.Set(actorHeader, actor)
+	}
+	if requestId != "" {
+		req.header.Set(requestIdHeader, requestId)
+	}
+}
+
+// 同じリクエストを送り直しても結果が変わらないか
+func (req *request) retryable() bool {
+	return req.method == http.MethodGet || req.method == http.MethodHead || req.header.Get(idempotencyKeyHeader) != ""
+}
+
+// Send the request and return the response if the status is 2xx, *Error otherwise
+// 一時的なエラーは間隔を倍にしながら送り直す
+func (c *Client) do(ctx context.Context, req *request) (*http.Response, error) {
+	backoff := c.option.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		res, err := c.send(ctx, req)
+		if err == nil && res.StatusCode < 400 {
+			return res, nil
+		}
+		if err == nil {
+			err = readError(res)
+		}
+		if attempt >= c.option.Retries || !req.retryable() || !temporary(ctx, err) {
+			return nil, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+		if c.option.MaxBackoff > 0 && backoff > c.option.MaxBackoff {
+			backoff = c.option.MaxBackoff
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, req *request) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	r, err := http.NewRequestWithContext(ctx, req.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range req.header {
+		r.Header[key] = values
+	}
+	if r.Header.Get(actorHeader) == "" && c.option.Actor != "" {
+		r.Header.Set(actorHeader, c.option.Actor)
+	}
+	if c.option.Token != "" {
+		r.Header.Set("Authorization", "Bearer "+c.option.Token)
+	}
+	if req.contentType != "" {
+		r.Header.Set("Content-Type", req.contentType)
+	}
+	return c.client.Do(r)
+}
+
+// Send the request and decode the JSON response into v
+func (c *Client) doJSON(ctx context.Context, req *request, v interface{}) error {
+	res, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// 4xx, 5xxのレスポンスをErrorにする
+func readError(res *http.Response) error {
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	apiError := &Error{
+		StatusCode: res.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+		RequestId:  res.Header.Get(requestIdHeader),
+	}
+	// OpenAPIの定義に合わないリクエストは詳細をJSONで返される
+	media, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if media == "application/json" {
+		var validation struct {
+			Message string            `json:"message"`
+			Errors  []ValidationError `json:"errors"`
+		}
+		if json.Unmarshal(body, &validation) == nil && validation.Message != "" {
+			apiError.Message = validation.Message
+			apiError.ValidationErrors = validation.Errors
+		}
+	}
+	return apiError
+}
+
+// 送り直せば成功する可能性があるエラーか
+func temporary(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiError *Error
+	if !errors.As(err, &apiError) {
+		// 接続できなかった場合など
+		return true
+	}
+	switch apiError.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusConflict:
+		// 同じIdempotency-Keyのリクエストが処理中
+		return true
+	default:
+		return false
+	}
+}
+
+// Random key for ToDoCreate.IdempotencyKey
+func NewIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
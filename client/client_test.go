@@ -0,0 +1,487 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/graphql"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/handler"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/openapi"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/router"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service/mock_service"
+)
+
+// 本物のルーターをhttptest.Serverで動かし、つないだクライアントを返す
+func newTestClient(t *testing.T, toDoService service.ToDoService, webhookService service.WebhookService) *Client {
+	validator, err := openapi.NewValidator(openapi.Spec)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	passThrough := func(h http.Handler) http.Handler { return h }
+	r := router.NewToDoRouter(
+		handler.NewToDoHandler(toDoService),
+		handler.NewWebhookHandler(webhookService),
+		handler.NewCalDAVHandler(toDoService),
+		graphql.NewHandler(toDoService),
+		passThrough,
+		handler.ValidateOpenAPI(validator, true, false),
+	)
+	server := httptest.NewServer(r.GetRouter())
+	t.Cleanup(server.Close)
+
+	c, err := NewClient(server.URL+"/", Option{Actor: "tester"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return c
+}
+
+func TestToDo(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	createdAt := time.Date(2021, 6, 15, 0, 35, 7, 0, time.UTC)
+	toDo := service.ToDoObject{Id: 1, Title: "Buy a new pencil", Status: "todo", CreatedAt: createdAt, UpdatedAt: createdAt}
+	expectedToDo := ToDo{Id: 1, Title: "Buy a new pencil", Status: "todo", CreatedAt: createdAt, UpdatedAt: createdAt}
+	title := "Buy a new eraser"
+	tests := []struct {
+		name               string
+		prepare            func(m *mock_service.MockToDoService)
+		act                func(c *Client) (interface{}, error)
+		expected           interface{}
+		expectedStatusCode int
+	}{
+		{
+			name: "01_ToDoを作成するケース",
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Create(&service.ToDoObject{Title: "Buy a new pencil", Actor: "tester", RequestId: "request-1"}).Return(&toDo, nil).Times(1)
+			},
+			act: func(c *Client) (interface{}, error) {
+				return c.Create(context.Background(), ToDoCreate{Title: "Buy a new pencil", RequestId: "request-1"})
+			},
+			expected: &expectedToDo,
+		},
+		{
+			name: "02_指定したフィールドだけ更新するケース",
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Update(&service.ToDoObject{Id: 1, Title: title, Actor: "someone", RequestId: "request-2"}).Return(&toDo, nil).Times(1)
+			},
+			act: func(c *Client) (interface{}, error) {
+				return c.Update(context.Background(), 1, ToDoUpdate{Title: &title, Actor: "someone", RequestId: "request-2"})
+			},
+			expected: &expectedToDo,
+		},
+		{
+			name: "03_条件を指定して一覧を取得するケース",
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().List(&service.ListOption{Done: "false", Include: "archived", AsOf: &createdAt}).Return([]service.ToDoObject{toDo}, nil).Times(1)
+			},
+			act: func(c *Client) (interface{}, error) {
+				return c.List(context.Background(), ListOption{Done: "false", Include: "archived", AsOf: &createdAt})
+			},
+			expected: []ToDo{expectedToDo},
+		},
+		{
+			name: "04_まとめてアーカイブするケース",
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().ArchiveDone(30*24*time.Hour).Return(int64(2), nil).Times(1)
+			},
+			act: func(c *Client) (interface{}, error) {
+				return c.ArchiveDone(context.Background(), 30)
+			},
+			expected: int64(2),
+		},
+		{
+			name: "05_バッチで操作するケース",
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Batch(gomock.Any()).Return([]service.BatchResultObject{{Index: 0, Op: "delete", Id: 1, Ok: true}}, nil).Times(1)
+			},
+			act: func(c *Client) (interface{}, error) {
+				return c.Batch(context.Background(), ToDoBatch{Operations: []BatchOperation{{Op: "delete", Id: 1}}})
+			},
+			expected: []BatchResult{{Index: 0, Op: "delete", Id: 1, Ok: true}},
+		},
+		{
+			name: "06_サービスのエラーをErrorで返すケース",
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Update(gomock.Any()).Return(nil, service.ErrInvalidTransition).Times(1)
+			},
+			act: func(c *Client) (interface{}, error) {
+				status := "todo"
+				return c.Update(context.Background(), 1, ToDoUpdate{Status: &status})
+			},
+			expected:           (*ToDo)(nil),
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name:    "07_OpenAPIの定義に合わないリクエストのケース",
+			prepare: func(m *mock_service.MockToDoService) {},
+			act: func(c *Client) (interface{}, error) {
+				return c.ArchiveDone(context.Background(), -1)
+			},
+			expected:           int64(0),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "08_インポートするデータにエラーがあるケース",
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Import(gomock.Any()).Return(&service.ImportResultObject{Total: 1, Ids: []int64{}, Errors: []service.ImportErrorObject{{Line: 2, Error: "title is required"}}}, nil).Times(1)
+			},
+			act: func(c *Client) (interface{}, error) {
+				return c.Import(context.Background(), ToDoImport{Format: "csv", Data: []byte("title\n\n")})
+			},
+			expected:           &ImportResult{Total: 1, Ids: []int64{}, Errors: []ImportError{{Line: 2, Error: "title is required"}}},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			tt.prepare(mockToDoService)
+			c := newTestClient(t, mockToDoService, nil)
+
+			// Act
+			actual, err := tt.act(c)
+
+			// Assert
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected: %+v, actual: %+v", tt.expected, actual)
+			}
+			if tt.expectedStatusCode == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var apiError *Error
+			if !errors.As(err, &apiError) || apiError.StatusCode != tt.expectedStatusCode {
+				t.Fatalf("expected: %d, actual: %v", tt.expectedStatusCode, err)
+			}
+			if !errors.Is(err, ErrClient) || errors.Is(err, ErrServer) {
+				t.Errorf("unexpected error class: %v", err)
+			}
+			if apiError.RequestId == "" {
+				t.Errorf("request ID is not set")
+			}
+		})
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	c := newTestClient(t, mock_service.NewMockToDoService(ctrl), nil)
+
+	// Act
+	_, err := c.Revert(context.Background(), 1, 0)
+
+	// Assert
+	var apiError *Error
+	if !errors.As(err, &apiError) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []ValidationError{{In: "query", Name: "revision", Message: "must be at least 1"}}
+	if !reflect.DeepEqual(apiError.ValidationErrors, expected) {
+		t.Errorf("expected: %+v, actual: %+v", expected, apiError.ValidationErrors)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	createdAt := time.Date(2021, 6, 15, 0, 35, 7, 0, time.UTC)
+	webhook := service.WebhookObject{Id: 1, Url: "https://example.com/hook", Events: []string{"completed"}, CreatedAt: createdAt, UpdatedAt: createdAt}
+	expected := Webhook{Id: 1, Url: "https://example.com/hook", Events: []string{"completed"}, CreatedAt: createdAt, UpdatedAt: createdAt}
+	mockWebhookService := mock_service.NewMockWebhookService(ctrl)
+	mockWebhookService.EXPECT().Update(&service.WebhookObject{Id: 1, Events: []string{"completed"}}).Return(&webhook, nil).Times(1)
+	mockWebhookService.EXPECT().List().Return([]service.WebhookObject{webhook}, nil).Times(1)
+	c := newTestClient(t, nil, mockWebhookService)
+
+	// Act
+	updated, err := c.UpdateWebhook(context.Background(), Webhook{Id: 1, Events: []string{"completed"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	webhooks, err := c.ListWebhooks(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Assert
+	if !reflect.DeepEqual(updated, &expected) {
+		t.Errorf("expected: %+v, actual: %+v", &expected, updated)
+	}
+	if !reflect.DeepEqual(webhooks, []Webhook{expected}) {
+		t.Errorf("expected: %+v, actual: %+v", []Webhook{expected}, webhooks)
+	}
+}
+
+func TestIterate(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	mockToDoService := mock_service.NewMockToDoService(ctrl)
+	// 2ページに分けて返す
+	gomock.InOrder(
+		mockToDoService.EXPECT().ListPage(&service.ListOption{Done: "true"}, int64(0), iteratePageSize).Return(&service.ToDoPageObject{
+			ToDo:    []service.ToDoObject{{Id: 1, Done: true, Status: "done"}, {Id: 2, Done: true, Status: "done"}},
+			HasNext: true,
+		}, nil),
+		mockToDoService.EXPECT().ListPage(&service.ListOption{Done: "true"}, int64(2), iteratePageSize).Return(&service.ToDoPageObject{
+			ToDo: []service.ToDoObject{{Id: 3, Done: true, Status: "done"}},
+		}, nil),
+	)
+	c := newTestClient(t, mockToDoService, nil)
+
+	// Act
+	it := c.Iterate(context.Background(), ListOption{Done: "true"})
+	defer it.Close()
+	var ids []int64
+	for it.Next() {
+		ids = append(ids, it.ToDo().Id)
+	}
+
+	// Assert
+	if err := it.Err(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2, 3}) {
+		t.Errorf("expected: %v, actual: %v", []int64{1, 2, 3}, ids)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	events := make(chan service.EventObject)
+	close(events)
+	mockToDoService := mock_service.NewMockToDoService(ctrl)
+	mockToDoService.EXPECT().Subscribe(int64(10)).Return(&service.Subscription{
+		Events: events,
+		Gap:    true,
+		Missed: []service.EventObject{{Id: 11, Type: "updated", ToDo: service.ToDoObject{Id: 1, Title: "Buy a new pencil"}}},
+	}).Times(1)
+	c := newTestClient(t, mockToDoService, nil)
+
+	// Act
+	stream, err := c.Events(context.Background(), "", 10)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer stream.Close()
+	var received []*Event
+	for {
+		event, err := stream.Next()
+		if err != nil {
+			break
+		}
+		received = append(received, event)
+	}
+
+	// Assert
+	expected := []*Event{
+		{Type: "reset"},
+		{Id: 11, Type: "updated", ToDo: ToDo{Id: 1, Title: "Buy a new pencil"}},
+	}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("expected: %+v, actual: %+v", expected, received)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	tests := []struct {
+		name             string
+		statusCodes      []int
+		act              func(c *Client) error
+		expectedAttempts int32
+		expectedError    error
+	}{
+		{
+			name:             "01_一時的なエラーの後に成功するケース",
+			statusCodes:      []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			act:              func(c *Client) error { _, err := c.Trash(context.Background()); return err },
+			expectedAttempts: 3,
+		},
+		{
+			name:             "02_回数の上限まで失敗するケース",
+			statusCodes:      []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			act:              func(c *Client) error { _, err := c.Trash(context.Background()); return err },
+			expectedAttempts: 3,
+			expectedError:    ErrServer,
+		},
+		{
+			name:             "03_一時的でないエラーは送り直さないケース",
+			statusCodes:      []int{http.StatusBadRequest, http.StatusOK},
+			act:              func(c *Client) error { _, err := c.Trash(context.Background()); return err },
+			expectedAttempts: 1,
+			expectedError:    ErrClient,
+		},
+		{
+			name:        "04_Idempotency-Keyのある作成は送り直すケース",
+			statusCodes: []int{http.StatusConflict, http.StatusOK},
+			act: func(c *Client) error {
+				_, err := c.Create(context.Background(), ToDoCreate{Title: "Buy a new pencil"})
+				return err
+			},
+			expectedAttempts: 2,
+		},
+		{
+			name:             "05_冪等でない操作は送り直さないケース",
+			statusCodes:      []int{http.StatusServiceUnavailable, http.StatusOK},
+			act:              func(c *Client) error { _, err := c.Archive(context.Background(), 1); return err },
+			expectedAttempts: 1,
+			expectedError:    ErrServer,
+		},
+		{
+			name:        "06_Iterateはページのリクエストを送り直すケース",
+			statusCodes: []int{http.StatusServiceUnavailable, http.StatusOK},
+			act: func(c *Client) error {
+				it := c.Iterate(context.Background(), ListOption{})
+				defer it.Close()
+				for it.Next() {
+				}
+				return it.Err()
+			},
+			expectedAttempts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			var attempts int32
+			keys := make(chan string, len(tt.statusCodes))
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := atomic.AddInt32(&attempts, 1) - 1
+				keys <- r.Header.Get(idempotencyKeyHeader)
+				if tt.statusCodes[i] != http.StatusOK {
+					http.Error(w, http.StatusText(tt.statusCodes[i]), tt.statusCodes[i])
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				if r.Method == http.MethodGet {
+					w.Write([]byte("[]"))
+				} else {
+					w.Write([]byte("{}"))
+				}
+			}))
+			t.Cleanup(server.Close)
+			c, err := NewClient(server.URL, Option{Retries: 2, InitialBackoff: time.Millisecond})
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			// Act
+			err = tt.act(c)
+
+			// Assert
+			if attempts != tt.expectedAttempts {
+				t.Errorf("expected: %d, actual: %d", tt.expectedAttempts, attempts)
+			}
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected: %v, actual: %v", tt.expectedError, err)
+			}
+			// 送り直したリクエストは同じIdempotency-Keyを使う
+			first := <-keys
+			for i := int32(1); i < attempts; i++ {
+				if key := <-keys; key != first {
+					t.Errorf("expected: %s, actual: %s", first, key)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	keys := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys <- r.Header.Get(idempotencyKeyHeader)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+	c, err := NewClient(server.URL, Option{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	key := NewIdempotencyKey()
+
+	// Act
+	_, err = c.Create(context.Background(), ToDoCreate{Title: "Buy a new pencil", IdempotencyKey: key})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = c.Create(context.Background(), ToDoCreate{Title: "Buy a new pencil", IdempotencyKey: key})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Assert
+	// 指定したキーは呼び出しをまたいで使われる
+	for i := 0; i < 2; i++ {
+		if actual := <-keys; key == "" || actual != key {
+			t.Errorf("expected: %s, actual: %s", key, actual)
+		}
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	c, err := NewClient(server.URL, Option{Retries: 10, InitialBackoff: time.Hour})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Act
+	_, err = c.Read(ctx, 1)
+
+	// Assert
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected: %v, actual: %v", context.DeadlineExceeded, err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected: 1, actual: %d", attempts)
+	}
+}
@@ -0,0 +1,196 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The types of the requests and the responses are defined here instead of reusing the server packages,
+// so that the client depends only on the HTTP API (see presentation/openapi/openapi.json)
+
+type ToDo struct {
+	Id         int64      `json:"id"`
+	Title      string     `json:"title"`
+	Status     string     `json:"status"`
+	Done       bool       `json:"done"`
+	Archived   bool       `json:"archived"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Fields of the ToDo to create
+type ToDoCreate struct {
+	Title string `json:"title"`
+	// derived from Done if empty
+	Status string `json:"status,omitempty"`
+	Done   bool   `json:"done"`
+
+	// who made the request, recorded in the history
+	Actor string `json:"-"`
+	// ID of the request, recorded in the history
+	RequestId string `json:"-"`
+	// sent as Idempotency-Key, a new key for each call if empty
+	// set a key from NewIdempotencyKey to send the same ToDo again later without creating it twice
+	IdempotencyKey string `json:"-"`
+}
+
+// Fields of the ToDo to update, nil fields are retained
+type ToDoUpdate struct {
+	Title  *string `json:"title,omitempty"`
+	Status *string `json:"status,omitempty"`
+	// ignored if Status is set
+	Done *bool `json:"done,omitempty"`
+
+	// who made the request, recorded in the history
+	Actor string `json:"-"`
+	// ID of the request, recorded in the history
+	RequestId string `json:"-"`
+}
+
+type ListOption struct {
+	// "true", "false" or "" for all ToDo
+	Done string
+	// comma separated, e.g. "archived"
+	Include string
+	// list ToDo as they were at the time if set (List only)
+	AsOf *time.Time
+}
+
+// Change history of a ToDo
+type History struct {
+	Id        int64     `json:"id"`
+	ToDoId    int64     `json:"todo_id"`
+	Operation string    `json:"operation"`
+	Changes   []Change  `json:"changes"`
+	Actor     string    `json:"actor"`
+	RequestId string    `json:"request_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Change struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Snapshot of a ToDo at a revision
+type Revision struct {
+	ToDoId    int64     `json:"todo_id"`
+	Revision  int64     `json:"revision"`
+	Title     string    `json:"title"`
+	Status    string    `json:"status"`
+	Done      bool      `json:"done"`
+	Archived  bool      `json:"archived"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Request of the bulk create, update and delete
+type ToDoBatch struct {
+	// "atomic" (default) or "best_effort"
+	Mode       string           `json:"mode"`
+	Operations []BatchOperation `json:"operations"`
+
+	// who made the request, recorded in the history
+	Actor string `json:"-"`
+	// ID of the request, recorded in the history
+	RequestId string `json:"-"`
+}
+
+// One operation of the batch, e.g. {Op: "update", Id: 1, Status: "done"}
+type BatchOperation struct {
+	// "create", "update" or "delete"
+	Op string `json:"op"`
+	// required for update and delete
+	Id     int64  `json:"id,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Status string `json:"status,omitempty"`
+	Done   bool   `json:"done"`
+}
+
+// Result of each operation of the batch
+type BatchResult struct {
+	Index int    `json:"index"`
+	Op    string `json:"op"`
+	Id    int64  `json:"id,omitempty"`
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Request of the bulk import
+type ToDoImport struct {
+	// "csv", "json", "todotxt" or "ics" (ignored by ImportICalendar)
+	Format string
+	Data   []byte
+	// only validate the data if true
+	DryRun bool
+
+	// who made the request, recorded in the history
+	Actor string
+	// ID of the request, recorded in the history
+	RequestId string
+}
+
+// Result of the bulk import
+type ImportResult struct {
+	DryRun bool `json:"dry_run"`
+	// number of ToDo in the data
+	Total int `json:"total"`
+	// number of ToDo without errors
+	Valid int `json:"valid"`
+	// number of ToDo created, 0 if dry run or if any ToDo has errors
+	Imported int `json:"imported"`
+	// number of ToDo updated by the iCalendar UID, 0 if dry run or if any ToDo has errors
+	Updated int `json:"updated"`
+	// IDs of the ToDo created or updated
+	Ids    []int64       `json:"ids"`
+	Errors []ImportError `json:"errors"`
+	// fields of the valid ToDo that are not stored, e.g. the priority of todo.txt
+	Warnings []ImportWarning `json:"warnings,omitempty"`
+}
+
+// Validation error of a ToDo in the imported data
+type ImportError struct {
+	// line number (CSV, todo.txt) or 1-based index of the array (JSON)
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// Fields of a ToDo in the imported data that are read but not stored
+type ImportWarning struct {
+	// line number (CSV, todo.txt) or 1-based index of the array (JSON)
+	Line    int    `json:"line"`
+	Warning string `json:"warning"`
+}
+
+type Webhook struct {
+	Id  int64  `json:"id"`
+	Url string `json:"url"`
+	// returned only when the webhook is created
+	Secret string `json:"secret,omitempty"`
+	// "created", "updated", "completed" or "deleted", all events if empty
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Payload that could not be delivered to a webhook
+type DeadLetter struct {
+	Id        int64           `json:"id"`
+	WebhookId int64           `json:"webhook_id"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Detail of the request that does not match the OpenAPI document
+type ValidationError struct {
+	// "path", "query", "header" or "body"
+	In string `json:"in"`
+	// name of the parameter or location in the body, e.g. "operations[0].op"
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,205 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// number of ToDo read by one request of Iterate
+const iteratePageSize = 100
+
+// Download the ToDo in the format ("csv", "ndjson", "md" or "todotxt"), the caller must close the body
+func (c *Client) Export(ctx context.Context, format string, option ListOption) (io.ReadCloser, error) {
+	query := listQuery(option)
+	query.Set("format", format)
+	res, err := c.do(ctx, &request{method: http.MethodGet, path: "/todo/export", query: query})
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// Download the ToDo as an iCalendar feed, the caller must close the body
+func (c *Client) ICalendar(ctx context.Context, option ListOption) (io.ReadCloser, error) {
+	res, err := c.do(ctx, &request{method: http.MethodGet, path: "/todo.ics", query: listQuery(option)})
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// Iterator over the ToDo read page by page in the order of the ID
+//
+//	it := c.Iterate(ctx, client.ListOption{})
+//	defer it.Close()
+//	for it.Next() {
+//		toDo := it.ToDo()
+//	}
+//	if err := it.Err(); err != nil {
+//		log.Fatal(err)
+//	}
+type ToDoIterator struct {
+	ctx    context.Context
+	client *Client
+	query  url.Values
+	// ToDo of the current page not returned by Next yet
+	page []ToDo
+	// the server has no page after the current one
+	last bool
+	// ID of the last ToDo read, the next page starts after it
+	after int64
+	toDo  *ToDo
+	err   error
+}
+
+// Iterate over the ToDo without loading all of them into memory
+// 1ページずつGET /todoで読むため、途中で失敗してもそのページのリクエストだけが送り直される(see Option.Retries)
+func (c *Client) Iterate(ctx context.Context, option ListOption) *ToDoIterator {
+	query := listQuery(option)
+	if option.AsOf != nil {
+		query.Set("as_of", option.AsOf.Format(time.RFC3339))
+	}
+	query.Set("limit", strconv.Itoa(iteratePageSize))
+	return &ToDoIterator{ctx: ctx, client: c, query: query}
+}
+
+// Read the next ToDo, false at the end or on an error
+func (it *ToDoIterator) Next() bool {
+	it.toDo = nil
+	if it.err != nil {
+		return false
+	}
+	if len(it.page) == 0 {
+		if it.last {
+			return false
+		}
+		it.err = it.fetch()
+		if it.err != nil || len(it.page) == 0 {
+			return false
+		}
+	}
+	it.toDo = &it.page[0]
+	it.page = it.page[1:]
+	return true
+}
+
+// 前のページの最後のIDより後の1ページを読む(次のページがなければLinkヘッダがない)
+func (it *ToDoIterator) fetch() error {
+	query := url.Values{}
+	for key, values := range it.query {
+		query[key] = values
+	}
+	if it.after > 0 {
+		query.Set("after", strconv.FormatInt(it.after, 10))
+	}
+	res, err := it.client.do(it.ctx, &request{method: http.MethodGet, path: "/todo", query: query})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	var page []ToDo
+	err = json.NewDecoder(res.Body).Decode(&page)
+	if err != nil {
+		return err
+	}
+	it.page = page
+	it.last = !strings.Contains(res.Header.Get("Link"), `rel="next"`)
+	if len(page) > 0 {
+		it.after = page[len(page)-1].Id
+	}
+	return nil
+}
+
+// ToDo read by the last Next
+func (it *ToDoIterator) ToDo() *ToDo {
+	return it.toDo
+}
+
+func (it *ToDoIterator) Err() error {
+	return it.err
+}
+
+// Stop the iteration, the pages are read one request at a time so nothing is kept open
+func (it *ToDoIterator) Close() error {
+	it.page = nil
+	it.last = true
+	return nil
+}
+
+// Change of a ToDo received from GET /todo/events
+type Event struct {
+	// pass it to Events as lastEventId to resume after reconnecting
+	Id int64
+	// "created", "updated", "deleted" or "reset" (some events were missed, list the ToDo again)
+	Type string
+	ToDo ToDo
+}
+
+// Stream of the changes of ToDo
+type EventStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+}
+
+// Subscribe to the changes of ToDo (done is "true", "false" or "" for all ToDo)
+// the events after lastEventId still kept by the server are sent first
+func (c *Client) Events(ctx context.Context, done string, lastEventId int64) (*EventStream, error) {
+	req := &request{method: http.MethodGet, path: "/todo/events", query: listQuery(ListOption{Done: done}), header: http.Header{}}
+	if lastEventId > 0 {
+		req.header.Set("Last-Event-ID", strconv.FormatInt(lastEventId, 10))
+	}
+	res, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &EventStream{body: res.Body, scanner: bufio.NewScanner(res.Body)}, nil
+}
+
+// Wait for the next event, io.EOF when the server closes the stream
+func (s *EventStream) Next() (*Event, error) {
+	event := &Event{}
+	var data []string
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		if line == "" {
+			// 空行でイベントが終わる(keep-aliveのコメントだけの場合は読み飛ばす)
+			if event.Type == "" && data == nil {
+				continue
+			}
+			if event.Type != "reset" {
+				err := json.Unmarshal([]byte(strings.Join(data, "\n")), &event.ToDo)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return event, nil
+		}
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "id":
+			event.Id, _ = strconv.ParseInt(value, 10, 64)
+		case "event":
+			event.Type = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
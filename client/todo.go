@@ -0,0 +1,215 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Create a ToDo
+// リクエストごとにIdempotency-Keyをつけるため、送り直しても二重に作成されない
+func (c *Client) Create(ctx context.Context, toDo ToDoCreate) (*ToDo, error) {
+	req, err := newJSONRequest(http.MethodPost, "/todo", toDo)
+	if err != nil {
+		return nil, err
+	}
+	req.setRequestInfo(toDo.Actor, toDo.RequestId)
+	key := toDo.IdempotencyKey
+	if key == "" {
+		key = NewIdempotencyKey()
+	}
+	req.header.Set(idempotencyKeyHeader, key)
+	return c.doToDo(ctx, req)
+}
+
+func (c *Client) Read(ctx context.Context, id int64) (*ToDo, error) {
+	return c.doToDo(ctx, &request{method: http.MethodGet, path: toDoPath(id, "")})
+}
+
+func (c *Client) Update(ctx context.Context, id int64, update ToDoUpdate) (*ToDo, error) {
+	req, err := newJSONRequest(http.MethodPatch, toDoPath(id, ""), update)
+	if err != nil {
+		return nil, err
+	}
+	req.setRequestInfo(update.Actor, update.RequestId)
+	return c.doToDo(ctx, req)
+}
+
+// Move the ToDo to the trash
+func (c *Client) Delete(ctx context.Context, id int64) (*ToDo, error) {
+	return c.doToDo(ctx, &request{method: http.MethodDelete, path: toDoPath(id, "")})
+}
+
+// List ToDo (use Iterate for a large number of ToDo)
+func (c *Client) List(ctx context.Context, option ListOption) ([]ToDo, error) {
+	query := listQuery(option)
+	if option.AsOf != nil {
+		query.Set("as_of", option.AsOf.Format(time.RFC3339))
+	}
+	var toDoList []ToDo
+	err := c.doJSON(ctx, &request{method: http.MethodGet, path: "/todo", query: query}, &toDoList)
+	if err != nil {
+		return nil, err
+	}
+	return toDoList, nil
+}
+
+// List ToDo in the trash
+func (c *Client) Trash(ctx context.Context) ([]ToDo, error) {
+	var toDoList []ToDo
+	err := c.doJSON(ctx, &request{method: http.MethodGet, path: "/trash"}, &toDoList)
+	if err != nil {
+		return nil, err
+	}
+	return toDoList, nil
+}
+
+// Restore the ToDo from the trash
+func (c *Client) Restore(ctx context.Context, id int64) (*ToDo, error) {
+	return c.doToDo(ctx, &request{method: http.MethodPost, path: toDoPath(id, "/restore")})
+}
+
+func (c *Client) Archive(ctx context.Context, id int64) (*ToDo, error) {
+	return c.doToDo(ctx, &request{method: http.MethodPost, path: toDoPath(id, "/archive")})
+}
+
+func (c *Client) Unarchive(ctx context.Context, id int64) (*ToDo, error) {
+	return c.doToDo(ctx, &request{method: http.MethodPost, path: toDoPath(id, "/unarchive")})
+}
+
+// Archive the ToDo done more than olderThanDays days ago and return the number of them
+func (c *Client) ArchiveDone(ctx context.Context, olderThanDays int) (int64, error) {
+	query := url.Values{}
+	query.Set("older_than_days", strconv.Itoa(olderThanDays))
+	var result struct {
+		Archived int64 `json:"archived"`
+	}
+	err := c.doJSON(ctx, &request{method: http.MethodPost, path: "/todo/archive", query: query}, &result)
+	if err != nil {
+		return 0, err
+	}
+	return result.Archived, nil
+}
+
+func (c *Client) History(ctx context.Context, id int64) ([]History, error) {
+	var historyList []History
+	err := c.doJSON(ctx, &request{method: http.MethodGet, path: toDoPath(id, "/history")}, &historyList)
+	if err != nil {
+		return nil, err
+	}
+	return historyList, nil
+}
+
+func (c *Client) Revisions(ctx context.Context, id int64) ([]Revision, error) {
+	var revisionList []Revision
+	err := c.doJSON(ctx, &request{method: http.MethodGet, path: toDoPath(id, "/revisions")}, &revisionList)
+	if err != nil {
+		return nil, err
+	}
+	return revisionList, nil
+}
+
+// Restore the title and the status of the ToDo at the revision
+func (c *Client) Revert(ctx context.Context, id int64, revision int64) (*ToDo, error) {
+	query := url.Values{}
+	query.Set("revision", strconv.FormatInt(revision, 10))
+	return c.doToDo(ctx, &request{method: http.MethodPost, path: toDoPath(id, "/revert"), query: query})
+}
+
+// Create, update and delete ToDo in one request
+func (c *Client) Batch(ctx context.Context, batch ToDoBatch) ([]BatchResult, error) {
+	if batch.Mode == "" {
+		// 空文字はmodeの値として受け付けられないため、サーバーの既定値を送る
+		batch.Mode = "atomic"
+	}
+	req, err := newJSONRequest(http.MethodPost, "/todo/bulk", batch)
+	if err != nil {
+		return nil, err
+	}
+	req.setRequestInfo(batch.Actor, batch.RequestId)
+	var result struct {
+		Results []BatchResult `json:"results"`
+	}
+	err = c.doJSON(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result.Results, nil
+}
+
+// Import ToDo from the data in the format ("csv", "json", "todotxt" or "ics")
+// データにエラーがある場合は、エラーの内容を含む結果と400の*Errorを両方返す
+func (c *Client) Import(ctx context.Context, toDoImport ToDoImport) (*ImportResult, error) {
+	query := url.Values{}
+	if toDoImport.Format != "" {
+		query.Set("format", toDoImport.Format)
+	}
+	if toDoImport.DryRun {
+		query.Set("dry_run", "true")
+	}
+	return c.doImport(ctx, toDoImport, &request{method: http.MethodPost, path: "/todo/import", query: query})
+}
+
+// Create or update ToDo by the UID of the VTODO components in the iCalendar data
+func (c *Client) ImportICalendar(ctx context.Context, toDoImport ToDoImport) (*ImportResult, error) {
+	query := url.Values{}
+	if toDoImport.DryRun {
+		query.Set("dry_run", "true")
+	}
+	return c.doImport(ctx, toDoImport, &request{method: http.MethodPost, path: "/todo.ics", query: query, contentType: "text/calendar"})
+}
+
+func (c *Client) doImport(ctx context.Context, toDoImport ToDoImport, req *request) (*ImportResult, error) {
+	req.body = toDoImport.Data
+	if req.body == nil {
+		req.body = []byte{}
+	}
+	req.setRequestInfo(toDoImport.Actor, toDoImport.RequestId)
+	result := &ImportResult{}
+	err := c.doJSON(ctx, req, result)
+	if apiError, ok := err.(*Error); ok && apiError.StatusCode == http.StatusBadRequest {
+		// 作成しなかった場合もボディは結果のJSON
+		if decodeImportResult(apiError.Message, result) {
+			return result, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ToDoをJSONで返す操作を送る
+func (c *Client) doToDo(ctx context.Context, req *request) (*ToDo, error) {
+	toDo := &ToDo{}
+	err := c.doJSON(ctx, req, toDo)
+	if err != nil {
+		return nil, err
+	}
+	return toDo, nil
+}
+
+// 結果のJSONとして読めた場合はresultに入れる
+func decodeImportResult(body string, result *ImportResult) bool {
+	return json.Unmarshal([]byte(body), result) == nil
+}
+
+func toDoPath(id int64, suffix string) string {
+	return fmt.Sprintf("/todo/%d%s", id, suffix)
+}
+
+// 一覧系のエンドポイントに共通のクエリパラメータ
+func listQuery(option ListOption) url.Values {
+	query := url.Values{}
+	if option.Done != "" {
+		query.Set("done", option.Done)
+	}
+	if option.Include != "" {
+		query.Set("include", option.Include)
+	}
+	return query
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Register a webhook, the secret is returned only here
+func (c *Client) CreateWebhook(ctx context.Context, webhook Webhook) (*Webhook, error) {
+	req, err := newJSONRequest(http.MethodPost, "/webhooks", webhook)
+	if err != nil {
+		return nil, err
+	}
+	return c.doWebhook(ctx, req)
+}
+
+func (c *Client) ReadWebhook(ctx context.Context, id int64) (*Webhook, error) {
+	return c.doWebhook(ctx, &request{method: http.MethodGet, path: webhookPath(id, "")})
+}
+
+// Update the webhook, empty fields are retained
+func (c *Client) UpdateWebhook(ctx context.Context, webhook Webhook) (*Webhook, error) {
+	req, err := newJSONRequest(http.MethodPatch, webhookPath(webhook.Id, ""), webhook)
+	if err != nil {
+		return nil, err
+	}
+	return c.doWebhook(ctx, req)
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id int64) (*Webhook, error) {
+	return c.doWebhook(ctx, &request{method: http.MethodDelete, path: webhookPath(id, "")})
+}
+
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var webhooks []Webhook
+	err := c.doJSON(ctx, &request{method: http.MethodGet, path: "/webhooks"}, &webhooks)
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// List the payloads that could not be delivered to the webhook
+func (c *Client) DeadLetters(ctx context.Context, id int64) ([]DeadLetter, error) {
+	var deadLetters []DeadLetter
+	err := c.doJSON(ctx, &request{method: http.MethodGet, path: webhookPath(id, "/dead-letters")}, &deadLetters)
+	if err != nil {
+		return nil, err
+	}
+	return deadLetters, nil
+}
+
+func (c *Client) doWebhook(ctx context.Context, req *request) (*Webhook, error) {
+	webhook := &Webhook{}
+	err := c.doJSON(ctx, req, webhook)
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func webhookPath(id int64, suffix string) string {
+	return fmt.Sprintf("/webhooks/%d%s", id, suffix)
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/client"
+)
+
+// Operations on ToDo used by the commands, implemented by the API client and the local cache
+type toDoStore interface {
+	Create(context.Context, client.ToDoCreate) (*client.ToDo, error)
+	Read(context.Context, int64) (*client.ToDo, error)
+	Update(context.Context, int64, client.ToDoUpdate) (*client.ToDo, error)
+	Delete(context.Context, int64) (*client.ToDo, error)
+	List(context.Context, client.ListOption) ([]client.ToDo, error)
+	History(context.Context, int64) ([]client.History, error)
+}
+
+// 設定がない場合のキャッシュの場所($XDG_CACHE_HOME/todo/cache.jsonなど)
+func defaultCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "todo", "cache.json")
+}
+
+// ToDo of the server kept in a JSON file with the changes made offline
+type toDoCache struct {
+	// base URL of the server the ToDo came from
+	Server string `json:"server"`
+	// time of the last todo sync
+	SyncedAt *time.Time   `json:"synced_at,omitempty"`
+	ToDo     []cachedToDo `json:"todo"`
+
+	path    string
+	changed bool
+}
+
+// ToDo in the cache, as it looks locally
+type cachedToDo struct {
+	client.ToDo
+	// updated_at on the server when it was cached, nil if created offline
+	BaseUpdatedAt *time.Time `json:"base_updated_at,omitempty"`
+	// Idempotency-Key of the ToDo created offline, sent on every sync until it is created
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	// changes not synchronized to the server yet
+	Change *localChange `json:"change,omitempty"`
+}
+
+// Changes made offline, nil fields were not changed
+type localChange struct {
+	Title   *string `json:"title,omitempty"`
+	Status  *string `json:"status,omitempty"`
+	Done    *bool   `json:"done,omitempty"`
+	Deleted bool    `json:"deleted,omitempty"`
+	// time of the last change, compared with updated_at of the server by last-writer-wins
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// Read the cache of the server, an empty cache if the file does not exist
+// 別のサーバーのキャッシュは、同期していない変更がなければ捨てる
+func loadCache(path string, server string) (*toDoCache, error) {
+	cache := &toDoCache{Server: server, path: path}
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	loaded := &toDoCache{}
+	err = json.Unmarshal(data, loaded)
+	if err != nil {
+		return nil, fmt.Errorf("broken cache %s: %w", path, err)
+	}
+	if loaded.Server != server {
+		if len(loaded.pending()) > 0 {
+			return nil, fmt.Errorf("the cache %s has changes not synchronized to %s, run todo -server %s sync", path, loaded.Server, loaded.Server)
+		}
+		cache.changed = true
+		return cache, nil
+	}
+	loaded.path = path
+	return loaded, nil
+}
+
+// Write the cache if it was changed
+// 書き込みの途中で壊れないよう、一時ファイルに書いてから置き換える
+func (c *toDoCache) save() error {
+	if !c.changed {
+		return nil
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(c.path), 0700)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), ".cache-*.json")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	err = os.Rename(tmp.Name(), c.path)
+	if err != nil {
+		return err
+	}
+	c.changed = false
+	return nil
+}
+
+func (c *toDoCache) find(id int64) *cachedToDo {
+	for i := range c.ToDo {
+		if c.ToDo[i].Id == id {
+			return &c.ToDo[i]
+		}
+	}
+	return nil
+}
+
+// ToDo with changes not synchronized yet
+func (c *toDoCache) pending() []*cachedToDo {
+	var result []*cachedToDo
+	for i := range c.ToDo {
+		if c.ToDo[i].Change != nil {
+			result = append(result, &c.ToDo[i])
+		}
+	}
+	return result
+}
+
+// Store the ToDo returned by the server, unless it has local changes
+func (c *toDoCache) put(toDo client.ToDo) {
+	updatedAt := toDo.UpdatedAt
+	cached := c.find(toDo.Id)
+	switch {
+	case cached == nil:
+		c.ToDo = append(c.ToDo, cachedToDo{ToDo: toDo, BaseUpdatedAt: &updatedAt})
+	case cached.Change == nil:
+		*cached = cachedToDo{ToDo: toDo, BaseUpdatedAt: &updatedAt}
+	default:
+		return
+	}
+	c.changed = true
+}
+
+func (c *toDoCache) remove(id int64) {
+	for i := range c.ToDo {
+		if c.ToDo[i].Id == id {
+			c.ToDo = append(c.ToDo[:i], c.ToDo[i+1:]...)
+			c.changed = true
+			return
+		}
+	}
+}
+
+// Replace the ToDo without local changes by all ToDo of the server
+func (c *toDoCache) replace(toDoList []client.ToDo) {
+	kept := []cachedToDo{}
+	for _, cached := range c.ToDo {
+		if cached.Change != nil {
+			kept = append(kept, cached)
+		}
+	}
+	c.ToDo = kept
+	for _, toDo := range toDoList {
+		c.put(toDo)
+	}
+	c.changed = true
+}
+
+// Works on the cache without the server, the changes are queued for todo sync
+type offlineStore struct {
+	cache *toDoCache
+	now   func() time.Time
+}
+
+// ToDo created offline get negative IDs until they are synchronized
+func (s *offlineStore) Create(ctx context.Context, toDo client.ToDoCreate) (*client.ToDo, error) {
+	id := int64(-1)
+	for _, cached := range s.cache.ToDo {
+		if cached.Id <= id {
+			id = cached.Id - 1
+		}
+	}
+	now := s.now().UTC()
+	title := toDo.Title
+	key := toDo.IdempotencyKey
+	if key == "" {
+		key = client.NewIdempotencyKey()
+	}
+	cached := cachedToDo{
+		ToDo:           client.ToDo{Id: id, Title: title, Status: toDo.Status, Done: toDo.Done, CreatedAt: now, UpdatedAt: now},
+		IdempotencyKey: key,
+		Change:         &localChange{Title: &title, ModifiedAt: now},
+	}
+	if toDo.Status != "" {
+		status := toDo.Status
+		cached.Change.Status = &status
+	}
+	if toDo.Done {
+		done := true
+		cached.Change.Done = &done
+	}
+	s.cache.ToDo = append(s.cache.ToDo, cached)
+	s.cache.changed = true
+	return &cached.ToDo, nil
+}
+
+func (s *offlineStore) Read(ctx context.Context, id int64) (*client.ToDo, error) {
+	cached, err := s.findLive(id)
+	if err != nil {
+		return nil, err
+	}
+	toDo := cached.ToDo
+	return &toDo, nil
+}
+
+// done and status are updated as they are, the server decides the status on sync
+func (s *offlineStore) Update(ctx context.Context, id int64, update client.ToDoUpdate) (*client.ToDo, error) {
+	cached, err := s.findLive(id)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	if cached.Change == nil {
+		cached.Change = &localChange{}
+	}
+	if update.Title != nil {
+		cached.Title = *update.Title
+		cached.Change.Title = update.Title
+	}
+	if update.Status != nil {
+		cached.Status = *update.Status
+		cached.Change.Status = update.Status
+	}
+	if update.Done != nil {
+		cached.Done = *update.Done
+		cached.Change.Done = update.Done
+	}
+	cached.Change.ModifiedAt = now
+	cached.UpdatedAt = now
+	s.cache.changed = true
+	toDo := cached.ToDo
+	return &toDo, nil
+}
+
+func (s *offlineStore) Delete(ctx context.Context, id int64) (*client.ToDo, error) {
+	cached, err := s.findLive(id)
+	if err != nil {
+		return nil, err
+	}
+	toDo := cached.ToDo
+	if cached.BaseUpdatedAt == nil {
+		// サーバーにまだないものはそのまま消す
+		s.cache.remove(id)
+		return &toDo, nil
+	}
+	if cached.Change == nil {
+		cached.Change = &localChange{}
+	}
+	cached.Change.Deleted = true
+	cached.Change.ModifiedAt = s.now().UTC()
+	s.cache.changed = true
+	return &toDo, nil
+}
+
+func (s *offlineStore) List(ctx context.Context, option client.ListOption) ([]client.ToDo, error) {
+	if option.AsOf != nil {
+		return nil, errors.New("as-of is not available offline")
+	}
+	var done *bool
+	if option.Done != "" {
+		d, err := strconv.ParseBool(option.Done)
+		if err != nil {
+			return nil, errors.New("done must be true or false")
+		}
+		done = &d
+	}
+	archived := option.Include == "archived"
+	result := []client.ToDo{}
+	for _, cached := range s.cache.ToDo {
+		if cached.Change != nil && cached.Change.Deleted {
+			continue
+		}
+		if done != nil && cached.Done != *done {
+			continue
+		}
+		if cached.Archived && !archived {
+			continue
+		}
+		result = append(result, cached.ToDo)
+	}
+	return result, nil
+}
+
+func (s *offlineStore) History(ctx context.Context, id int64) ([]client.History, error) {
+	return nil, errors.New("history is not available offline")
+}
+
+// 削除していないToDoを探す
+func (s *offlineStore) findLive(id int64) (*cachedToDo, error) {
+	cached := s.cache.find(id)
+	if cached == nil || (cached.Change != nil && cached.Change.Deleted) {
+		return nil, fmt.Errorf("ToDo %d is not in the local cache (run todo sync while online)", id)
+	}
+	return cached, nil
+}
+
+// Works on the server and keeps the results in the cache,
+// falls back to the cache while the server is unreachable
+type cachingStore struct {
+	online  *client.Client
+	offline *offlineStore
+	stderr  io.Writer
+	// the fallback was already reported
+	warned bool
+}
+
+// 接続できなかったエラーか(APIがエラーを返した場合は含まない)
+func unreachable(err error) bool {
+	var urlError *url.Error
+	return errors.As(err, &urlError) && !errors.Is(err, context.Canceled)
+}
+
+func (s *cachingStore) fallback() {
+	if !s.warned {
+		fmt.Fprintln(s.stderr, "todo: the server is unreachable, working on the local cache (run todo sync later)")
+		s.warned = true
+	}
+}
+
+// 同期していない変更があるToDoは、順序を守るためキャッシュ上で変更する
+func (s *cachingStore) hasChange(id int64) bool {
+	cached := s.offline.cache.find(id)
+	return id < 0 || (cached != nil && cached.Change != nil)
+}
+
+func (s *cachingStore) Create(ctx context.Context, toDo client.ToDoCreate) (*client.ToDo, error) {
+	// サーバーに届いたが応答を受け取れなかった場合も二重に作成しないよう、キャッシュに残すときも同じキーを使う
+	if toDo.IdempotencyKey == "" {
+		toDo.IdempotencyKey = client.NewIdempotencyKey()
+	}
+	result, err := s.online.Create(ctx, toDo)
+	if unreachable(err) {
+		s.fallback()
+		return s.offline.Create(ctx, toDo)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.offline.cache.put(*result)
+	return result, nil
+}
+
+func (s *cachingStore) Read(ctx context.Context, id int64) (*client.ToDo, error) {
+	if s.hasChange(id) {
+		return s.offline.Read(ctx, id)
+	}
+	result, err := s.online.Read(ctx, id)
+	if unreachable(err) {
+		s.fallback()
+		return s.offline.Read(ctx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.offline.cache.put(*result)
+	return result, nil
+}
+
+func (s *cachingStore) Update(ctx context.Context, id int64, update client.ToDoUpdate) (*client.ToDo, error) {
+	if s.hasChange(id) {
+		return s.offline.Update(ctx, id, update)
+	}
+	result, err := s.online.Update(ctx, id, update)
+	if unreachable(err) {
+		s.fallback()
+		return s.offline.Update(ctx, id, update)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.offline.cache.put(*result)
+	return result, nil
+}
+
+func (s *cachingStore) Delete(ctx context.Context, id int64) (*client.ToDo, error) {
+	if s.hasChange(id) {
+		return s.offline.Delete(ctx, id)
+	}
+	result, err := s.online.Delete(ctx, id)
+	if unreachable(err) {
+		s.fallback()
+		return s.offline.Delete(ctx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.offline.cache.remove(id)
+	return result, nil
+}
+
+func (s *cachingStore) List(ctx context.Context, option client.ListOption) ([]client.ToDo, error) {
+	result, err := s.online.List(ctx, option)
+	if unreachable(err) {
+		s.fallback()
+		return s.offline.List(ctx, option)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case option.AsOf != nil:
+	case option.Done == "" && option.Include == "archived":
+		// 全件を取得した場合はサーバーで消えたものもキャッシュから消す
+		s.offline.cache.replace(result)
+	default:
+		for _, toDo := range result {
+			s.offline.cache.put(toDo)
+		}
+	}
+	if pending := len(s.offline.cache.pending()); pending > 0 {
+		fmt.Fprintf(s.stderr, "todo: %d ToDo in the local cache are not synchronized, run todo sync\n", pending)
+	}
+	return result, nil
+}
+
+func (s *cachingStore) History(ctx context.Context, id int64) ([]client.History, error) {
+	return s.online.History(ctx, id)
+}
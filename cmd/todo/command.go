@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/client"
+)
+
+// Arguments and outputs of a subcommand
+type commandContext struct {
+	ctx context.Context
+	// the server, or the local cache with -offline
+	store toDoStore
+	// nil with -offline
+	online *client.Client
+	cache  *toDoCache
+	// arguments after the name of the subcommand
+	args   []string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	// output format given before the subcommand, which can be overridden by -o after it
+	output string
+}
+
+// Wrong arguments of a subcommand, exits with exitUsage
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+var commands = map[string]func(*commandContext) error{
+	"add":  add,
+	"ls":   ls,
+	"done": func(c *commandContext) error { return setDone(c, "done", true) },
+	"undo": func(c *commandContext) error { return setDone(c, "undo", false) },
+	"edit": edit,
+	"rm":   rm,
+	"show": show,
+	"sync": syncCommand,
+}
+
+// サブコマンドのフラグを読む(-oはサブコマンドの後にも書ける)
+func (c *commandContext) parse(flags *flag.FlagSet) (*printer, error) {
+	flags.SetOutput(c.stderr)
+	output := flags.String("o", c.output, "output format: table, json or yaml")
+	if err := flags.Parse(c.args); err != nil {
+		return nil, flag.ErrHelp
+	}
+	p, err := newPrinter(c.stdout, *output)
+	if err != nil {
+		return nil, &usageError{err.Error()}
+	}
+	return p, nil
+}
+
+func add(c *commandContext) error {
+	flags := flag.NewFlagSet("add", flag.ContinueOnError)
+	status := flags.String("status", "", "status of the ToDo (initial status of the workflow if empty)")
+	p, err := c.parse(flags)
+	if err != nil {
+		return err
+	}
+	title := strings.Join(flags.Args(), " ")
+	if title == "" {
+		return &usageError{"title is required"}
+	}
+
+	toDo, err := c.store.Create(c.ctx, client.ToDoCreate{Title: title, Status: *status})
+	if err != nil {
+		return err
+	}
+	return p.toDoList([]client.ToDo{*toDo})
+}
+
+func ls(c *commandContext) error {
+	flags := flag.NewFlagSet("ls", flag.ContinueOnError)
+	done := flags.String("done", "", "only done (true) or not done (false) ToDo")
+	archived := flags.Bool("archived", false, "include archived ToDo")
+	asOf := flags.String("as-of", "", "list ToDo as they were at the time (RFC 3339)")
+	p, err := c.parse(flags)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return &usageError{"unexpected arguments: " + strings.Join(flags.Args(), " ")}
+	}
+
+	option := client.ListOption{Done: *done}
+	if *archived {
+		option.Include = "archived"
+	}
+	if *asOf != "" {
+		t, err := time.Parse(time.RFC3339, *asOf)
+		if err != nil {
+			return &usageError{"as-of must be an RFC 3339 timestamp, e.g. 2021-06-15T09:00:00+09:00"}
+		}
+		option.AsOf = &t
+	}
+	toDoList, err := c.store.List(c.ctx, option)
+	if err != nil {
+		return err
+	}
+	return p.toDoList(toDoList)
+}
+
+// done, undo
+func setDone(c *commandContext, name string, done bool) error {
+	return forEachId(c, name, func(id int64) (*client.ToDo, error) {
+		return c.store.Update(c.ctx, id, client.ToDoUpdate{Done: &done})
+	})
+}
+
+func rm(c *commandContext) error {
+	return forEachId(c, "rm", func(id int64) (*client.ToDo, error) {
+		return c.store.Delete(c.ctx, id)
+	})
+}
+
+// 引数の各IDに操作し、失敗したものがあっても残りを続ける
+func forEachId(c *commandContext, name string, f func(int64) (*client.ToDo, error)) error {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	p, err := c.parse(flags)
+	if err != nil {
+		return err
+	}
+	ids, err := parseIds(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	var results []client.ToDo
+	failed := 0
+	for _, id := range ids {
+		toDo, err := f(id)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "todo %s: %d: %v\n", name, id, err)
+			failed++
+			continue
+		}
+		results = append(results, *toDo)
+	}
+	if len(results) > 0 {
+		err = p.toDoList(results)
+		if err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d ToDo failed", failed, len(ids))
+	}
+	return nil
+}
+
+func edit(c *commandContext) error {
+	flags := flag.NewFlagSet("edit", flag.ContinueOnError)
+	update := client.ToDoUpdate{}
+	flags.Func("title", "new title", func(value string) error {
+		update.Title = &value
+		return nil
+	})
+	flags.Func("status", "new status", func(value string) error {
+		update.Status = &value
+		return nil
+	})
+	p, err := c.parse(flags)
+	if err != nil {
+		return err
+	}
+	id, err := parseId(flags.Args())
+	if err != nil {
+		return err
+	}
+	if update.Title == nil && update.Status == nil {
+		return &usageError{"-title or -status is required"}
+	}
+
+	toDo, err := c.store.Update(c.ctx, id, update)
+	if err != nil {
+		return err
+	}
+	return p.toDoList([]client.ToDo{*toDo})
+}
+
+func show(c *commandContext) error {
+	flags := flag.NewFlagSet("show", flag.ContinueOnError)
+	withHistory := flags.Bool("history", false, "show the change history")
+	p, err := c.parse(flags)
+	if err != nil {
+		return err
+	}
+	id, err := parseId(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	toDo, err := c.store.Read(c.ctx, id)
+	if err != nil {
+		return err
+	}
+	var history []client.History
+	if *withHistory {
+		history, err = c.store.History(c.ctx, id)
+		if err != nil {
+			return err
+		}
+		if history == nil {
+			history = []client.History{}
+		}
+	}
+	return p.toDo(toDo, history)
+}
+
+// IDを1つだけ受け取るサブコマンドの引数
+func parseId(args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, &usageError{"exactly one ID is required"}
+	}
+	ids, err := parseIds(args)
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+func parseIds(args []string) ([]int64, error) {
+	if len(args) == 0 {
+		return nil, &usageError{"ID is required"}
+	}
+	ids := make([]int64, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		// オフラインで作成したToDoのIDは負の数
+		if err != nil || id == 0 {
+			return nil, &usageError{fmt.Sprintf("invalid ID %q", arg)}
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// server used if not configured
+const defaultServer = "http://localhost:8080"
+
+// Settings of the CLI read from the config file, e.g.
+//
+//	server: https://todo.example.com
+//	actor: alice
+//	token: xxxxxxxx
+//	cache: /home/alice/.cache/todo/cache.json
+type cliConfig struct {
+	// base URL of the API
+	Server string `yaml:"server"`
+	// sent as X-Actor and recorded in the history
+	Actor string `yaml:"actor"`
+	// sent as "Authorization: Bearer" for a gateway in front of the API
+	Token string `yaml:"token"`
+	// file of the local cache used offline
+	Cache string `yaml:"cache"`
+}
+
+// 設定ファイルの場所($TODO_CONFIG, なければ$XDG_CONFIG_HOME/todo/config.yamlなど)
+func defaultConfigPath(getenv func(string) string) string {
+	if path := getenv("TODO_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "todo", "config.yaml")
+}
+
+// Read the config file and override it with the environment variables
+// 設定ファイルがなくてもエラーにはしない
+func loadConfig(path string, getenv func(string) string) (*cliConfig, error) {
+	config := &cliConfig{}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		err = yaml.UnmarshalStrict(data, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if value := getenv("TODO_SERVER"); value != "" {
+		config.Server = value
+	}
+	if value := getenv("TODO_ACTOR"); value != "" {
+		config.Actor = value
+	}
+	if value := getenv("TODO_TOKEN"); value != "" {
+		config.Token = value
+	}
+	if value := getenv("TODO_CACHE"); value != "" {
+		config.Cache = value
+	}
+	if config.Cache == "" {
+		config.Cache = defaultCachePath()
+	}
+	if config.Server == "" {
+		config.Server = defaultServer
+	}
+	return config, nil
+}
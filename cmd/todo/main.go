@@ -0,0 +1,128 @@
+// Command todo is a command-line client of the ToDo API.
+//
+//	todo [-config file] [-server url] [-offline] [-o table|json|yaml] <command> [arguments]
+//
+// See docs/api.md#command-line-client for the commands and the config file.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/client"
+)
+
+const usage = `Usage: todo [-config file] [-server url] [-offline] [-o table|json|yaml] <command> [arguments]
+
+Commands:
+  add [-status status] <title>...          create a ToDo
+  ls [-done true|false] [-archived] [-as-of time]
+                                           list ToDo
+  done <id>...                             mark ToDo as done
+  undo <id>...                             mark ToDo as not done
+  edit [-title title] [-status status] <id>
+                                           change the title or the status of a ToDo
+  rm <id>...                               move ToDo to the trash
+  show [-history] <id>                     show a ToDo
+  sync [-strategy lww|interactive]         send the changes made offline and refresh the local cache
+
+Options:
+`
+
+// exit code of the usage errors
+const exitUsage = 2
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, os.Getenv))
+}
+
+// Run the command and return the exit code
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer, getenv func(string) string) int {
+	flags := flag.NewFlagSet("todo", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", defaultConfigPath(getenv), "config file (also $TODO_CONFIG)")
+	server := flags.String("server", "", "base URL of the API (also $TODO_SERVER)")
+	offline := flags.Bool("offline", false, "work on the local cache without the server (also $TODO_OFFLINE)")
+	output := flags.String("o", "table", "output format: table, json or yaml")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	config, err := loadConfig(*configPath, getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "todo: failed to read the config file: %v\n", err)
+		return 1
+	}
+	if *server != "" {
+		config.Server = *server
+	}
+	c, err := client.NewClient(config.Server, client.Option{Actor: config.Actor, Token: config.Token, Retries: 2})
+	if err != nil {
+		fmt.Fprintf(stderr, "todo: %v\n", err)
+		return 1
+	}
+
+	name := flags.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "todo: unknown command %q\n", name)
+		flags.Usage()
+		return exitUsage
+	}
+	cache, err := loadCache(config.Cache, config.Server)
+	if err != nil {
+		fmt.Fprintf(stderr, "todo: %v\n", err)
+		return 1
+	}
+	command := &commandContext{
+		ctx:    context.Background(),
+		online: c,
+		cache:  cache,
+		args:   flags.Args()[1:],
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+		output: *output,
+	}
+	offlineStore := &offlineStore{cache: cache, now: time.Now}
+	if *offline || getenv("TODO_OFFLINE") != "" {
+		command.online = nil
+		command.store = offlineStore
+	} else {
+		command.store = &cachingStore{online: c, offline: offlineStore, stderr: stderr}
+	}
+	err = cmd(command)
+	// 失敗した場合もそれまでの変更は残す
+	if saveErr := cache.save(); saveErr != nil {
+		fmt.Fprintf(stderr, "todo: failed to save the local cache: %v\n", saveErr)
+		if err == nil {
+			return 1
+		}
+	}
+	var usageError *usageError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &usageError):
+		fmt.Fprintf(stderr, "todo %s: %v\n", name, err)
+		return exitUsage
+	case errors.Is(err, flag.ErrHelp):
+		return exitUsage
+	default:
+		fmt.Fprintf(stderr, "todo %s: %v\n", name, err)
+		return 1
+	}
+}
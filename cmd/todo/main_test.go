@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/graphql"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/handler"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/router"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service/mock_service"
+)
+
+// 本物のルーターをhttptest.Serverで動かし、そのURLを返す
+func newTestServer(t *testing.T, toDoService service.ToDoService) string {
+	passThrough := func(h http.Handler) http.Handler { return h }
+	r := router.NewToDoRouter(handler.NewToDoHandler(toDoService), handler.NewWebhookHandler(nil), handler.NewCalDAVHandler(toDoService), graphql.NewHandler(toDoService), passThrough, passThrough)
+	server := httptest.NewServer(r.GetRouter())
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	createdAt := time.Date(2021, 6, 15, 0, 35, 7, 0, time.UTC)
+	toDo := service.ToDoObject{Id: 1, Title: "Buy a new pencil", Status: "todo", CreatedAt: createdAt, UpdatedAt: createdAt}
+	doneToDo := service.ToDoObject{Id: 2, Title: "Buy a new eraser", Status: "done", Done: true}
+	tests := []struct {
+		name             string
+		args             []string
+		prepare          func(m *mock_service.MockToDoService)
+		expectedCode     int
+		expectedStdout   string
+		expectedInStderr string
+	}{
+		{
+			name: "01_ToDoを作成するケース",
+			args: []string{"-o", "json", "add", "-status", "review", "Buy", "a", "new", "pencil"},
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Create(gomock.Any()).DoAndReturn(func(toDo *service.ToDoObject) (*service.ToDoObject, error) {
+					return &service.ToDoObject{Id: 1, Title: toDo.Title, Status: toDo.Status + " by " + toDo.Actor, CreatedAt: createdAt, UpdatedAt: createdAt}, nil
+				}).Times(1)
+			},
+			expectedStdout: `[
+  {
+    "id": 1,
+    "title": "Buy a new pencil",
+    "status": "review by alice",
+    "done": false,
+    "archived": false,
+    "created_at": "2021-06-15T00:35:07Z",
+    "updated_at": "2021-06-15T00:35:07Z"
+  }
+]
+`,
+		},
+		{
+			name: "02_一覧を表で表示するケース",
+			args: []string{"ls", "-done", "true", "-archived"},
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().List(&service.ListOption{Done: "true", Include: "archived"}).Return([]service.ToDoObject{doneToDo, {Id: 10, Title: "Old", Status: "done", Done: true, Archived: true}}, nil).Times(1)
+			},
+			expectedStdout: "ID  DONE  STATUS  TITLE             UPDATED\n" +
+				"2   [x]   done    Buy a new eraser  -\n" +
+				"10  [x]   done    Old (archived)    -\n",
+		},
+		{
+			name: "03_YAMLで表示するケース",
+			args: []string{"show", "-o", "yaml", "1"},
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Read(&service.ToDoObject{Id: 1}).Return(&toDo, nil).Times(1)
+			},
+			expectedStdout: `id: 1
+title: Buy a new pencil
+status: todo
+done: false
+archived: false
+created_at: "2021-06-15T00:35:07Z"
+updated_at: "2021-06-15T00:35:07Z"
+`,
+		},
+		{
+			name: "04_一部のToDoの完了に失敗するケース",
+			args: []string{"-o", "json", "done", "2", "3"},
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Update(gomock.Any()).DoAndReturn(func(toDo *service.ToDoObject) (*service.ToDoObject, error) {
+					if toDo.Id == 3 {
+						return nil, service.ErrInvalidTransition
+					}
+					if !toDo.Done || toDo.Status != "" {
+						t.Errorf("unexpected update: %+v", toDo)
+					}
+					return &doneToDo, nil
+				}).Times(2)
+			},
+			expectedCode:     1,
+			expectedStdout:   `"id": 2`,
+			expectedInStderr: "3: 409 Conflict: invalid status transition",
+		},
+		{
+			name: "05_タイトルを変更するケース",
+			args: []string{"-o", "json", "edit", "-title", "Buy two pencils", "1"},
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Update(gomock.Any()).DoAndReturn(func(toDo *service.ToDoObject) (*service.ToDoObject, error) {
+					// 指定していないstatusとdoneは送らない
+					if toDo.Id != 1 || toDo.Title != "Buy two pencils" || toDo.Status != "" || toDo.Actor != "alice" {
+						t.Errorf("unexpected update: %+v", toDo)
+					}
+					return toDo, nil
+				}).Times(1)
+			},
+			expectedStdout: `"id": 1`,
+		},
+		{
+			name:             "06_変更する内容がないケース",
+			args:             []string{"edit", "1"},
+			prepare:          func(m *mock_service.MockToDoService) {},
+			expectedCode:     exitUsage,
+			expectedInStderr: "-title or -status is required",
+		},
+		{
+			name:             "07_IDが数値でないケース",
+			args:             []string{"rm", "1", "abc"},
+			prepare:          func(m *mock_service.MockToDoService) {},
+			expectedCode:     exitUsage,
+			expectedInStderr: `invalid ID "abc"`,
+		},
+		{
+			name: "08_履歴とあわせて表示するケース",
+			args: []string{"show", "-history", "1"},
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Read(&service.ToDoObject{Id: 1}).Return(&service.ToDoObject{Id: 1, Title: "Buy a new pencil", Status: "done", Done: true}, nil).Times(1)
+				m.EXPECT().History(&service.ToDoObject{Id: 1}).Return([]service.HistoryObject{{Id: 1, ToDoId: 1, Operation: "update", Actor: "alice", Changes: []service.ChangeObject{{Field: "status", Before: "todo", After: "done"}}}}, nil).Times(1)
+			},
+			expectedStdout: "ID:       1\n" +
+				"Title:    Buy a new pencil\n" +
+				"Status:   done\n" +
+				"Done:     true\n" +
+				"Archived: false\n" +
+				"Created:  -\n" +
+				"Updated:  -\n" +
+				"\n" +
+				"OPERATION  ACTOR  CHANGES               AT\n" +
+				"update     alice  status: todo -> done  -\n",
+		},
+		{
+			name:             "09_存在しないコマンドのケース",
+			args:             []string{"hoge"},
+			prepare:          func(m *mock_service.MockToDoService) {},
+			expectedCode:     exitUsage,
+			expectedInStderr: `unknown command "hoge"`,
+		},
+		{
+			name:             "10_出力形式が違うケース",
+			args:             []string{"ls", "-o", "xml"},
+			prepare:          func(m *mock_service.MockToDoService) {},
+			expectedCode:     exitUsage,
+			expectedInStderr: "output must be one of table, json, yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			tt.prepare(mockToDoService)
+			env := map[string]string{
+				"TODO_CONFIG": filepath.Join(t.TempDir(), "config.yaml"),
+				"TODO_SERVER": newTestServer(t, mockToDoService),
+				"TODO_ACTOR":  "alice",
+				"TODO_CACHE":  filepath.Join(t.TempDir(), "cache.json"),
+			}
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			// Act
+			code := run(tt.args, strings.NewReader(""), stdout, stderr, func(key string) string { return env[key] })
+
+			// Assert
+			if code != tt.expectedCode {
+				t.Errorf("expected: %d, actual: %d (%s)", tt.expectedCode, code, stderr.String())
+			}
+			if strings.HasPrefix(tt.expectedStdout, `"`) {
+				if !strings.Contains(stdout.String(), tt.expectedStdout) {
+					t.Errorf("expected: %s, actual: %s", tt.expectedStdout, stdout.String())
+				}
+			} else if stdout.String() != tt.expectedStdout {
+				t.Errorf("expected:\n%s\nactual:\n%s", tt.expectedStdout, stdout.String())
+			}
+			if !strings.Contains(stderr.String(), tt.expectedInStderr) {
+				t.Errorf("expected: %s, actual: %s", tt.expectedInStderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	tests := []struct {
+		name        string
+		file        string
+		env         map[string]string
+		expected    *cliConfig
+		expectedErr bool
+	}{
+		{
+			name:     "01_設定ファイルがないケース",
+			expected: &cliConfig{Server: defaultServer, Cache: defaultCachePath()},
+		},
+		{
+			name:     "02_設定ファイルを読むケース",
+			file:     "server: https://todo.example.com\nactor: alice\ntoken: secret\ncache: /tmp/todo.json\n",
+			expected: &cliConfig{Server: "https://todo.example.com", Actor: "alice", Token: "secret", Cache: "/tmp/todo.json"},
+		},
+		{
+			name:     "03_環境変数で上書きするケース",
+			file:     "server: https://todo.example.com\nactor: alice\n",
+			env:      map[string]string{"TODO_SERVER": "http://localhost:18080", "TODO_TOKEN": "secret", "TODO_CACHE": "/tmp/todo.json"},
+			expected: &cliConfig{Server: "http://localhost:18080", Actor: "alice", Token: "secret", Cache: "/tmp/todo.json"},
+		},
+		{
+			name:        "04_知らないキーがあるケース",
+			file:        "url: https://todo.example.com\n",
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if tt.file != "" {
+				err := ioutil.WriteFile(path, []byte(tt.file), 0600)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+			}
+
+			// Act
+			config, err := loadConfig(path, func(key string) string { return tt.env[key] })
+
+			// Assert
+			if tt.expectedErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if !reflect.DeepEqual(config, tt.expected) {
+				t.Errorf("expected: %+v, actual: %+v", tt.expected, config)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/client"
+	"gopkg.in/yaml.v2"
+)
+
+var outputFormats = []string{"table", "json", "yaml"}
+
+// Writes the results of the commands in the output format
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	for _, f := range outputFormats {
+		if f == format {
+			return &printer{w: w, format: format}, nil
+		}
+	}
+	return nil, fmt.Errorf("output must be one of %s", strings.Join(outputFormats, ", "))
+}
+
+// Print the ToDo as rows of a table, or as a JSON/YAML array
+func (p *printer) toDoList(toDoList []client.ToDo) error {
+	if p.format != "table" {
+		if toDoList == nil {
+			toDoList = []client.ToDo{}
+		}
+		return p.encode(toDoList)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tDONE\tSTATUS\tTITLE\tUPDATED")
+	for _, toDo := range toDoList {
+		done := " "
+		if toDo.Done {
+			done = "x"
+		}
+		title := toDo.Title
+		if toDo.Archived {
+			title += " (archived)"
+		}
+		fmt.Fprintf(tw, "%d\t[%s]\t%s\t%s\t%s\n", toDo.Id, done, toDo.Status, title, formatTime(toDo.UpdatedAt))
+	}
+	return tw.Flush()
+}
+
+// Print the ToDo and its history as "key: value" lines, or as a JSON/YAML object
+func (p *printer) toDo(toDo *client.ToDo, history []client.History) error {
+	if p.format != "table" {
+		if history == nil {
+			return p.encode(toDo)
+		}
+		return p.encode(struct {
+			ToDo    *client.ToDo     `json:"todo"`
+			History []client.History `json:"history"`
+		}{toDo, history})
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 1, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%d\n", toDo.Id)
+	fmt.Fprintf(tw, "Title:\t%s\n", toDo.Title)
+	fmt.Fprintf(tw, "Status:\t%s\n", toDo.Status)
+	fmt.Fprintf(tw, "Done:\t%t\n", toDo.Done)
+	fmt.Fprintf(tw, "Archived:\t%t\n", toDo.Archived)
+	fmt.Fprintf(tw, "Created:\t%s\n", formatTime(toDo.CreatedAt))
+	fmt.Fprintf(tw, "Updated:\t%s\n", formatTime(toDo.UpdatedAt))
+	err := tw.Flush()
+	if err != nil || history == nil {
+		return err
+	}
+
+	fmt.Fprintln(p.w)
+	tw = tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "OPERATION\tACTOR\tCHANGES\tAT")
+	for _, h := range history {
+		changes := make([]string, 0, len(h.Changes))
+		for _, change := range h.Changes {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", change.Field, change.Before, change.After))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", h.Operation, h.Actor, strings.Join(changes, ", "), formatTime(h.CreatedAt))
+	}
+	return tw.Flush()
+}
+
+// JSONのフィールド名と順序のままYAMLにするため、一度JSONにしてから変換する
+func (p *printer) encode(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if p.format == "json" {
+		_, err = fmt.Fprintln(p.w, string(data))
+		return err
+	}
+	var value interface{}
+	if strings.HasPrefix(string(data), "[") {
+		var list []yaml.MapSlice
+		err = yaml.Unmarshal(data, &list)
+		value = list
+	} else {
+		var object yaml.MapSlice
+		err = yaml.Unmarshal(data, &object)
+		value = object
+	}
+	if err != nil {
+		return err
+	}
+	data, err = yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = p.w.Write(data)
+	return err
+}
+
+// 表では秒までのローカル時刻で表示する
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/client"
+)
+
+// How to resolve a ToDo changed both locally and on the server since the last sync
+const (
+	// the newer of the local change and updated_at of the server wins
+	strategyLastWriterWins = "lww"
+	// ask for each field changed on both sides
+	strategyInteractive = "interactive"
+)
+
+// Pushes the changes in the cache to the server and refreshes the cache
+type syncer struct {
+	ctx      context.Context
+	online   *client.Client
+	cache    *toDoCache
+	strategy string
+	now      func() time.Time
+	// answers of the interactive merge
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func syncCommand(c *commandContext) error {
+	flags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	strategy := flags.String("strategy", strategyLastWriterWins, "conflict resolution: lww (last writer wins) or interactive")
+	if _, err := c.parse(flags); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return &usageError{"unexpected arguments: " + strings.Join(flags.Args(), " ")}
+	}
+	if *strategy != strategyLastWriterWins && *strategy != strategyInteractive {
+		return &usageError{"strategy must be lww or interactive"}
+	}
+	if c.online == nil {
+		return &usageError{"sync cannot be used with -offline"}
+	}
+
+	s := &syncer{ctx: c.ctx, online: c.online, cache: c.cache, strategy: *strategy, now: time.Now, in: bufio.NewReader(c.stdin), out: c.stdout}
+	return s.sync()
+}
+
+func (s *syncer) sync() error {
+	serverList, err := s.online.List(s.ctx, client.ListOption{Include: "archived"})
+	if err != nil {
+		return err
+	}
+	server := map[int64]client.ToDo{}
+	for _, toDo := range serverList {
+		server[toDo.Id] = toDo
+	}
+
+	// 送ったものはキャッシュから消していくため、先にコピーしておく
+	var pending []cachedToDo
+	for _, cached := range s.cache.pending() {
+		pending = append(pending, *cached)
+	}
+	failed := 0
+	for _, cached := range pending {
+		id := cached.Id
+		err := s.push(cached, server)
+		if err != nil {
+			fmt.Fprintf(s.out, "failed %d: %v\n", id, err)
+			failed++
+			continue
+		}
+		// 途中で失敗しても送った分を二重に送らないよう、1件ごとに保存する
+		s.cache.remove(id)
+		err = s.cache.save()
+		if err != nil {
+			return err
+		}
+	}
+
+	serverList, err = s.online.List(s.ctx, client.ListOption{Include: "archived"})
+	if err != nil {
+		return err
+	}
+	s.cache.replace(serverList)
+	if failed == 0 {
+		now := s.now().UTC()
+		s.cache.SyncedAt = &now
+	}
+	fmt.Fprintf(s.out, "%d ToDo in the local cache\n", len(s.cache.ToDo))
+	if failed > 0 {
+		return fmt.Errorf("%d ToDo were not synchronized, they are kept in the local cache", failed)
+	}
+	return nil
+}
+
+// 1件分の変更をサーバーに送る
+func (s *syncer) push(cached cachedToDo, server map[int64]client.ToDo) error {
+	change := cached.Change
+	if cached.BaseUpdatedAt == nil {
+		// 前回の同期で作成されていた場合は、同じキーでサーバーに保存された応答が返る
+		toDo, err := s.online.Create(s.ctx, client.ToDoCreate{Title: cached.Title, Status: cached.Status, Done: cached.Done, IdempotencyKey: cached.IdempotencyKey})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "created %d (was %d)\n", toDo.Id, cached.Id)
+		return nil
+	}
+
+	current, ok := server[cached.Id]
+	if !ok {
+		if !change.Deleted {
+			fmt.Fprintf(s.out, "skipped %d: deleted on the server, the local changes are discarded\n", cached.Id)
+		}
+		return nil
+	}
+	// updated_atが変わっていなければサーバーでは変更されていない
+	conflict := !current.UpdatedAt.Equal(*cached.BaseUpdatedAt)
+
+	if change.Deleted {
+		if conflict {
+			keep, err := s.resolveDelete(cached, current)
+			if err != nil || keep {
+				return err
+			}
+		}
+		_, err := s.online.Delete(s.ctx, cached.Id)
+		if err == nil {
+			fmt.Fprintf(s.out, "deleted %d\n", cached.Id)
+		}
+		return err
+	}
+
+	update := client.ToDoUpdate{Title: change.Title, Status: change.Status, Done: change.Done}
+	if conflict {
+		var err error
+		update, err = s.resolveUpdate(cached, current)
+		if err != nil {
+			return err
+		}
+	}
+	if update.Title == nil && update.Status == nil && update.Done == nil {
+		fmt.Fprintf(s.out, "kept %d: the server version\n", cached.Id)
+		return nil
+	}
+	_, err := s.online.Update(s.ctx, cached.Id, update)
+	if err == nil {
+		fmt.Fprintf(s.out, "updated %d\n", cached.Id)
+	}
+	return err
+}
+
+// 両方で変更されたToDoについて、サーバーに送る変更を決める
+func (s *syncer) resolveUpdate(cached cachedToDo, current client.ToDo) (client.ToDoUpdate, error) {
+	change := cached.Change
+	if s.strategy == strategyLastWriterWins {
+		if change.ModifiedAt.After(current.UpdatedAt) {
+			fmt.Fprintf(s.out, "conflict %d: the local change is newer\n", cached.Id)
+			return client.ToDoUpdate{Title: change.Title, Status: change.Status, Done: change.Done}, nil
+		}
+		fmt.Fprintf(s.out, "conflict %d: the server version is newer\n", cached.Id)
+		return client.ToDoUpdate{}, nil
+	}
+
+	fmt.Fprintf(s.out, "ToDo %d was changed both locally and on the server\n", cached.Id)
+	update := client.ToDoUpdate{}
+	if change.Title != nil && *change.Title != current.Title {
+		local, err := s.ask(fmt.Sprintf("  title: local %q, server %q, keep [l]ocal or [s]erver? ", *change.Title, current.Title))
+		if err != nil {
+			return update, err
+		}
+		if local {
+			update.Title = change.Title
+		}
+	}
+	if change.Status != nil && *change.Status != current.Status {
+		local, err := s.ask(fmt.Sprintf("  status: local %q, server %q, keep [l]ocal or [s]erver? ", *change.Status, current.Status))
+		if err != nil {
+			return update, err
+		}
+		if local {
+			update.Status = change.Status
+		}
+	}
+	if change.Done != nil && *change.Done != current.Done && update.Status == nil {
+		local, err := s.ask(fmt.Sprintf("  done: local %t, server %t, keep [l]ocal or [s]erver? ", *change.Done, current.Done))
+		if err != nil {
+			return update, err
+		}
+		if local {
+			update.Done = change.Done
+		}
+	}
+	return update, nil
+}
+
+// ローカルで削除したToDoがサーバーで変更されていた場合、残すかどうか
+func (s *syncer) resolveDelete(cached cachedToDo, current client.ToDo) (bool, error) {
+	if s.strategy == strategyLastWriterWins {
+		if cached.Change.ModifiedAt.After(current.UpdatedAt) {
+			fmt.Fprintf(s.out, "conflict %d: the local deletion is newer\n", cached.Id)
+			return false, nil
+		}
+		fmt.Fprintf(s.out, "conflict %d: the server version is newer, not deleted\n", cached.Id)
+		return true, nil
+	}
+
+	fmt.Fprintf(s.out, "ToDo %d was deleted locally but changed on the server (%q, %s)\n", cached.Id, current.Title, current.Status)
+	deleteIt, err := s.ask("  delete it? [y/n] ")
+	return !deleteIt, err
+}
+
+// 質問を表示して、ローカル側(l, y)を選んだかを返す
+func (s *syncer) ask(prompt string) (bool, error) {
+	for {
+		fmt.Fprint(s.out, prompt)
+		line, err := s.in.ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		switch answer {
+		case "l", "local", "y", "yes":
+			return true, nil
+		case "s", "server", "n", "no":
+			return false, nil
+		}
+		if errors.Is(err, io.EOF) {
+			return false, errors.New("no answer for the conflict")
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uzimihsr/todo-rest-api-golang/client"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service/mock_service"
+)
+
+// コマンドを1つ実行し、終了コードと出力を返す
+func runCommand(env map[string]string, stdin string, args ...string) (int, string, string) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code := run(args, strings.NewReader(stdin), stdout, stderr, func(key string) string { return env[key] })
+	return code, stdout.String(), stderr.String()
+}
+
+// キャッシュのファイルを読む
+func readCache(t *testing.T, path string) *toDoCache {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cache := &toDoCache{}
+	err = json.Unmarshal(data, cache)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return cache
+}
+
+func TestOfflineSync(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	t0 := time.Date(2021, 6, 15, 0, 0, 0, 0, time.UTC)
+	pencil := service.ToDoObject{Id: 1, Title: "Buy a new pencil", Status: "todo", CreatedAt: t0, UpdatedAt: t0}
+	eraser := service.ToDoObject{Id: 2, Title: "Buy a new eraser", Status: "todo", CreatedAt: t0, UpdatedAt: t0}
+	milk := service.ToDoObject{Id: 3, Title: "Buy milk", Status: "todo", CreatedAt: t0.Add(time.Hour), UpdatedAt: t0.Add(time.Hour)}
+	donePencil := service.ToDoObject{Id: 1, Title: "Buy a new pencil", Status: "done", Done: true, CreatedAt: t0, UpdatedAt: t0.Add(time.Hour)}
+	mockToDoService := mock_service.NewMockToDoService(ctrl)
+	all := &service.ListOption{Include: "archived"}
+	gomock.InOrder(
+		mockToDoService.EXPECT().List(all).Return([]service.ToDoObject{pencil, eraser}, nil).Times(2),
+		mockToDoService.EXPECT().List(all).Return([]service.ToDoObject{donePencil, milk}, nil).Times(1),
+	)
+	mockToDoService.EXPECT().Create(gomock.Any()).DoAndReturn(func(toDo *service.ToDoObject) (*service.ToDoObject, error) {
+		if toDo.Title != "Buy milk" || toDo.Done {
+			t.Errorf("unexpected create: %+v", toDo)
+		}
+		return &milk, nil
+	}).Times(1)
+	mockToDoService.EXPECT().Update(gomock.Any()).DoAndReturn(func(toDo *service.ToDoObject) (*service.ToDoObject, error) {
+		if toDo.Id != 1 || !toDo.Done || toDo.Title != "" {
+			t.Errorf("unexpected update: %+v", toDo)
+		}
+		return &donePencil, nil
+	}).Times(1)
+	mockToDoService.EXPECT().Delete(gomock.Any()).DoAndReturn(func(toDo *service.ToDoObject) (*service.ToDoObject, error) {
+		if toDo.Id != 2 {
+			t.Errorf("unexpected delete: %+v", toDo)
+		}
+		return &eraser, nil
+	}).Times(1)
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+	env := map[string]string{
+		"TODO_CONFIG": filepath.Join(t.TempDir(), "config.yaml"),
+		"TODO_SERVER": newTestServer(t, mockToDoService),
+		"TODO_CACHE":  cachePath,
+	}
+
+	// Act
+	commands := [][]string{
+		{"ls", "-archived"},
+		{"-offline", "add", "Buy milk"},
+		{"-offline", "done", "1"},
+		{"-offline", "rm", "2"},
+		{"-offline", "-o", "json", "ls"},
+		{"sync"},
+	}
+	var outputs []string
+	for _, args := range commands {
+		code, stdout, stderr := runCommand(env, "", args...)
+		if code != 0 {
+			t.Fatalf("%v: exit %d: %s", args, code, stderr)
+		}
+		outputs = append(outputs, stdout)
+	}
+
+	// Assert
+	var offlineList []client.ToDo
+	err := json.Unmarshal([]byte(outputs[4]), &offlineList)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(offlineList) != 2 || offlineList[0].Id != 1 || !offlineList[0].Done || offlineList[1].Id != -1 || offlineList[1].Title != "Buy milk" {
+		t.Errorf("unexpected offline list: %+v", offlineList)
+	}
+	for _, expected := range []string{"created 3 (was -1)\n", "updated 1\n", "deleted 2\n", "2 ToDo in the local cache\n"} {
+		if !strings.Contains(outputs[5], expected) {
+			t.Errorf("expected: %s, actual: %s", expected, outputs[5])
+		}
+	}
+	cache := readCache(t, cachePath)
+	var ids []int64
+	for _, cached := range cache.ToDo {
+		if cached.Change != nil {
+			t.Errorf("not synchronized: %+v", cached)
+		}
+		ids = append(ids, cached.Id)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 3}) || cache.SyncedAt == nil {
+		t.Errorf("unexpected cache: %+v", cache)
+	}
+}
+
+func TestUnreachableServer(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	server := httptest.NewServer(nil)
+	server.Close()
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+	env := map[string]string{
+		"TODO_CONFIG": filepath.Join(t.TempDir(), "config.yaml"),
+		"TODO_SERVER": server.URL,
+		"TODO_CACHE":  cachePath,
+	}
+
+	// Act
+	code, _, stderr := runCommand(env, "", "add", "Buy milk")
+	_, stdout, _ := runCommand(env, "", "-o", "json", "ls")
+	syncCode, _, _ := runCommand(env, "", "sync")
+
+	// Assert
+	if code != 0 || !strings.Contains(stderr, "the server is unreachable") {
+		t.Errorf("unexpected result: %d %s", code, stderr)
+	}
+	if !strings.Contains(stdout, `"title": "Buy milk"`) {
+		t.Errorf("the ToDo is not in the cache: %s", stdout)
+	}
+	// 同期できなかった変更は残る
+	if syncCode != 1 || len(readCache(t, cachePath).pending()) != 1 {
+		t.Errorf("unexpected sync: %d", syncCode)
+	}
+}
+
+func TestSyncIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	// 1回目の同期では作成に失敗し、2回目で作成できるサーバー
+	var attempts int32
+	keys := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			w.Write([]byte("[]"))
+			return
+		}
+		keys <- r.Header.Get("Idempotency-Key")
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			http.Error(w, "failed", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 3, "title": "Buy milk", "status": "todo"}`))
+	}))
+	t.Cleanup(server.Close)
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+	env := map[string]string{
+		"TODO_CONFIG": filepath.Join(t.TempDir(), "config.yaml"),
+		"TODO_SERVER": server.URL,
+		"TODO_CACHE":  cachePath,
+	}
+
+	// Act
+	runCommand(env, "", "-offline", "add", "Buy milk")
+	key := readCache(t, cachePath).ToDo[0].IdempotencyKey
+	failedCode, _, _ := runCommand(env, "", "sync")
+	code, stdout, stderr := runCommand(env, "", "sync")
+
+	// Assert
+	if failedCode != 1 || code != 0 || !strings.Contains(stdout, "created 3 (was -1)") {
+		t.Errorf("unexpected sync: %d, %d, %s%s", failedCode, code, stdout, stderr)
+	}
+	// キャッシュしたときのキーで送り直す
+	close(keys)
+	var sent []string
+	for k := range keys {
+		sent = append(sent, k)
+	}
+	if key == "" || !reflect.DeepEqual(sent, []string{key, key}) {
+		t.Errorf("expected: %q, actual: %q", key, sent)
+	}
+}
+
+func TestSyncConflict(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	t0 := time.Date(2021, 6, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		strategy        string
+		localModifiedAt time.Time
+		serverUpdatedAt time.Time
+		stdin           string
+		expectedTitle   string
+		expectedStdout  string
+	}{
+		{
+			name:            "01_サーバーで変更されていないケース",
+			strategy:        "lww",
+			localModifiedAt: t0.Add(time.Hour),
+			serverUpdatedAt: t0,
+			expectedTitle:   "local title",
+			expectedStdout:  "updated 1\n",
+		},
+		{
+			name:            "02_ローカルの変更の方が新しいケース",
+			strategy:        "lww",
+			localModifiedAt: t0.Add(2 * time.Hour),
+			serverUpdatedAt: t0.Add(time.Hour),
+			expectedTitle:   "local title",
+			expectedStdout:  "conflict 1: the local change is newer\n",
+		},
+		{
+			name:            "03_サーバーの変更の方が新しいケース",
+			strategy:        "lww",
+			localModifiedAt: t0.Add(time.Hour),
+			serverUpdatedAt: t0.Add(2 * time.Hour),
+			expectedStdout:  "conflict 1: the server version is newer\nkept 1: the server version\n",
+		},
+		{
+			name:            "04_対話でローカルを選ぶケース",
+			strategy:        "interactive",
+			localModifiedAt: t0.Add(time.Hour),
+			serverUpdatedAt: t0.Add(2 * time.Hour),
+			stdin:           "x\nl\n",
+			expectedTitle:   "local title",
+			expectedStdout:  `  title: local "local title", server "server title", keep [l]ocal or [s]erver? `,
+		},
+		{
+			name:            "05_対話でサーバーを選ぶケース",
+			strategy:        "interactive",
+			localModifiedAt: t0.Add(2 * time.Hour),
+			serverUpdatedAt: t0.Add(time.Hour),
+			stdin:           "s\n",
+			expectedStdout:  "kept 1: the server version\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			serverToDo := service.ToDoObject{Id: 1, Title: "server title", Status: "todo", CreatedAt: t0, UpdatedAt: tt.serverUpdatedAt}
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			mockToDoService.EXPECT().List(&service.ListOption{Include: "archived"}).Return([]service.ToDoObject{serverToDo}, nil).Times(2)
+			var updatedTitle string
+			mockToDoService.EXPECT().Update(gomock.Any()).DoAndReturn(func(toDo *service.ToDoObject) (*service.ToDoObject, error) {
+				updatedTitle = toDo.Title
+				return toDo, nil
+			}).MaxTimes(1)
+			env := map[string]string{
+				"TODO_CONFIG": filepath.Join(t.TempDir(), "config.yaml"),
+				"TODO_SERVER": newTestServer(t, mockToDoService),
+				"TODO_CACHE":  filepath.Join(t.TempDir(), "cache.json"),
+			}
+			title := "local title"
+			cache := &toDoCache{
+				Server: env["TODO_SERVER"],
+				ToDo: []cachedToDo{{
+					ToDo:          client.ToDo{Id: 1, Title: title, Status: "todo", CreatedAt: t0, UpdatedAt: tt.localModifiedAt},
+					BaseUpdatedAt: &t0,
+					Change:        &localChange{Title: &title, ModifiedAt: tt.localModifiedAt},
+				}},
+				path:    env["TODO_CACHE"],
+				changed: true,
+			}
+			err := cache.save()
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			// Act
+			code, stdout, stderr := runCommand(env, tt.stdin, "sync", "-strategy", tt.strategy)
+
+			// Assert
+			if code != 0 {
+				t.Fatalf("exit %d: %s", code, stderr)
+			}
+			if updatedTitle != tt.expectedTitle {
+				t.Errorf("expected: %q, actual: %q", tt.expectedTitle, updatedTitle)
+			}
+			if !strings.Contains(stdout, tt.expectedStdout) {
+				t.Errorf("expected: %s, actual: %s", tt.expectedStdout, stdout)
+			}
+			if pending := readCache(t, env["TODO_CACHE"]).pending(); len(pending) != 0 {
+				t.Errorf("not synchronized: %+v", pending)
+			}
+		})
+	}
+}
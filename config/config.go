@@ -1,8 +1,18 @@
 package config
 
+import "time"
+
 type Config struct {
-	Database Database `yaml:"database"`
-	Server   Server   `yaml:"server"`
+	Database    Database    `yaml:"database"`
+	Server      Server      `yaml:"server"`
+	Workflow    Workflow    `yaml:"workflow"`
+	Trash       Trash       `yaml:"trash"`
+	Idempotency Idempotency `yaml:"idempotency"`
+	Events      Events      `yaml:"events"`
+	Webhooks    Webhooks    `yaml:"webhooks"`
+	Outbox      Outbox      `yaml:"outbox"`
+	OpenAPI     OpenAPI     `yaml:"openapi"`
+	GRPC        GRPC        `yaml:"grpc"`
 }
 
 type Database struct {
@@ -11,8 +21,66 @@ type Database struct {
 	User         string `yaml:"user"`
 	Password     string `yaml:"password"`
 	DatabaseName string `yaml:"dbName"`
+	// "table" (default) stores the current ToDo, "events" stores the changes as events
+	Store string `yaml:"store"`
 }
 
 type Server struct {
 	Port string `yaml:"port"`
 }
+
+type Workflow struct {
+	Initial     string              `yaml:"initial"`
+	Done        string              `yaml:"done"`
+	Transitions map[string][]string `yaml:"transitions"`
+}
+
+type Trash struct {
+	Retention     time.Duration `yaml:"retention"`
+	PurgeInterval time.Duration `yaml:"purgeInterval"`
+}
+
+type Idempotency struct {
+	TTL           time.Duration `yaml:"ttl"`
+	PurgeInterval time.Duration `yaml:"purgeInterval"`
+}
+
+type Events struct {
+	// number of the latest events kept for Last-Event-ID
+	BufferSize int `yaml:"bufferSize"`
+}
+
+type Webhooks struct {
+	// number of deliveries sent at the same time
+	Workers int `yaml:"workers"`
+	// attempts before the payload is stored as a dead letter
+	MaxAttempts int `yaml:"maxAttempts"`
+	// wait before the first retry, doubled on each retry
+	InitialBackoff time.Duration `yaml:"initialBackoff"`
+	// timeout of each request
+	Timeout time.Duration `yaml:"timeout"`
+	// interval of reading the payloads to send
+	PollInterval time.Duration `yaml:"pollInterval"`
+}
+
+type Outbox struct {
+	// interval of reading the events not published yet, and the published events for "bus"
+	PollInterval time.Duration `yaml:"pollInterval"`
+	// how long the published events are kept
+	Retention     time.Duration `yaml:"retention"`
+	PurgeInterval time.Duration `yaml:"purgeInterval"`
+	// "bus" (the subscribers on every server), "webhook" or "log"
+	Publishers []string `yaml:"publishers"`
+}
+
+type OpenAPI struct {
+	// reject the requests that do not match the OpenAPI document with 400
+	ValidateRequests bool `yaml:"validateRequests"`
+	// log the responses that do not match the OpenAPI document (for development)
+	ValidateResponses bool `yaml:"validateResponses"`
+}
+
+type GRPC struct {
+	// port of the gRPC API, next to the HTTP server
+	Port string `yaml:"port"`
+}
@@ -0,0 +1,15 @@
+package model
+
+// Write to a ToDo executed as a part of a batch
+type BatchOperation struct {
+	// "create", "update" or "delete"
+	Operation string
+	ToDo      *ToDo
+	History   *ToDoHistory
+}
+
+// Result of a BatchOperation
+type BatchResult struct {
+	Id  int64
+	Err error
+}
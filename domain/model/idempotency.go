@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Response stored for an Idempotency-Key
+type IdempotencyRecord struct {
+	Key string
+	// hash of the request first sent with the key
+	RequestHash string
+	// 0 while the first request is in progress
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Change of a ToDo written to the outbox in the same transaction as the change
+type OutboxEvent struct {
+	Id int64
+	// "created", "updated" or "deleted"
+	EventType string
+	// true if the update moved the ToDo to the done status
+	Completed bool
+	// ToDo after the change
+	ToDo        ToDo
+	CreatedAt   time.Time
+	PublishedAt *time.Time
+	// order in which the events were published, 0 if not published yet
+	Sequence int64
+}
@@ -3,9 +3,12 @@ package model
 import "time"
 
 type ToDo struct {
-	Id        int64
-	Title     string
-	Done      bool
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	Id         int64
+	Title      string
+	Status     string
+	Done       bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ArchivedAt *time.Time
+	DeletedAt  *time.Time
 }
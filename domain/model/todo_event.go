@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// Types of ToDoEvent
+const (
+	ToDoCreated       = "Created"
+	ToDoTitleChanged  = "TitleChanged"
+	ToDoStatusChanged = "StatusChanged"
+	ToDoCompleted     = "Completed"
+	ToDoReopened      = "Reopened"
+	ToDoArchived      = "Archived"
+	ToDoUnarchived    = "Unarchived"
+	ToDoDeleted       = "Deleted"
+	ToDoRestored      = "Restored"
+	ToDoPurged        = "Purged"
+)
+
+// Fact appended to the stream of a ToDo
+type ToDoEvent struct {
+	Id     int64
+	ToDoId int64
+	// position in the stream of the ToDo, from 1
+	Version int64
+	// revision written by the change, shared by the events of one change
+	Revision int64
+	Type     string
+	// set for Created and TitleChanged
+	Title string
+	// set for Created, StatusChanged, Completed and Reopened
+	Status string
+	// set for Created
+	Done bool
+	// operation, actor and request ID of the change (see ToDoHistory)
+	Operation string
+	Actor     string
+	RequestId string
+	CreatedAt time.Time
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Change history of a ToDo
+type ToDoHistory struct {
+	Id        int64
+	ToDoId    int64
+	Operation string
+	Changes   []FieldChange
+	Actor     string
+	RequestId string
+	CreatedAt time.Time
+}
+
+// Before/after values of a changed field
+type FieldChange struct {
+	Field  string
+	Before interface{}
+	After  interface{}
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// Snapshot of a ToDo taken on every change
+type ToDoRevision struct {
+	ToDoId    int64
+	Revision  int64
+	Title     string
+	Status    string
+	Done      bool
+	Archived  bool
+	CreatedAt time.Time
+}
@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// URL notified of the changes of ToDo
+type Webhook struct {
+	Id  int64
+	Url string
+	// key of the HMAC-SHA256 signature of the payloads
+	Secret string
+	// events to notify, all events if empty
+	Events    []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Payload that could not be delivered to a webhook
+type WebhookDeadLetter struct {
+	Id        int64
+	WebhookId int64
+	Event     string
+	Payload   []byte
+	Attempts  int
+	LastError string
+	CreatedAt time.Time
+}
+
+// Payload waiting to be delivered to a webhook, kept until the webhook returns a 2xx status
+type WebhookDelivery struct {
+	Id int64
+	// webhook to send the payload to, with its current URL and secret
+	Webhook Webhook
+	// ID of the event in the outbox
+	EventId   int64
+	Event     string
+	Payload   []byte
+	Attempts  int
+	LastError string
+	// not sent before this time, also extended while a worker is sending it
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+}
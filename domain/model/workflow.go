@@ -0,0 +1,92 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Workflow defines the statuses of a ToDo and the transitions between them
+type Workflow struct {
+	// status of a newly created ToDo
+	Initial string
+	// status regarded as done
+	Done string
+	// allowed transitions (from -> to)
+	Transitions map[string][]string
+}
+
+// Default workflow: todo -> in_progress -> review -> done, plus cancelled
+func DefaultWorkflow() *Workflow {
+	return &Workflow{
+		Initial: "todo",
+		Done:    "done",
+		Transitions: map[string][]string{
+			"todo":        {"in_progress", "done", "cancelled"},
+			"in_progress": {"todo", "review", "done", "cancelled"},
+			"review":      {"in_progress", "done", "cancelled"},
+			"done":        {"todo"},
+			"cancelled":   {"todo"},
+		},
+	}
+}
+
+// Check that the initial and the done statuses are given and every status is declared in the transitions,
+// so that a ToDo never reaches a status it cannot leave or that is not regarded as a status
+func (w *Workflow) Validate() error {
+	if w.Initial == "" {
+		return errors.New("initial status is required")
+	}
+	if w.Done == "" {
+		return errors.New("done status is required")
+	}
+	// 遷移元として書かれたステータスを宣言されたものとする
+	for _, status := range []string{w.Initial, w.Done} {
+		if _, ok := w.Transitions[status]; !ok {
+			return fmt.Errorf("status %q is not declared in the transitions", status)
+		}
+	}
+	for from, next := range w.Transitions {
+		for _, to := range next {
+			if _, ok := w.Transitions[to]; !ok {
+				return fmt.Errorf("status %q in the transitions from %q is not declared in the transitions", to, from)
+			}
+		}
+	}
+	return nil
+}
+
+// Check if the status is defined in the workflow
+func (w *Workflow) IsValid(status string) bool {
+	if status == w.Initial || status == w.Done {
+		return true
+	}
+	if _, ok := w.Transitions[status]; ok {
+		return true
+	}
+	for _, next := range w.Transitions {
+		for _, s := range next {
+			if s == status {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Check if the ToDo can move from one status to another
+func (w *Workflow) CanTransition(from string, to string) bool {
+	if from == to {
+		return true
+	}
+	for _, s := range w.Transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if the status is regarded as done
+func (w *Workflow) IsDone(status string) bool {
+	return status == w.Done
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestWorkflowValidate(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name          string
+		workflow      *Workflow
+		expectedError string
+	}{
+		{
+			name:     "01_デフォルトのワークフローのケース",
+			workflow: DefaultWorkflow(),
+		},
+		{
+			name:          "02_doneがないケース",
+			workflow:      &Workflow{Initial: "todo", Transitions: map[string][]string{"todo": {}}},
+			expectedError: "done status is required",
+		},
+		{
+			name:          "03_initialが宣言されていないケース",
+			workflow:      &Workflow{Initial: "open", Done: "done", Transitions: map[string][]string{"done": {}}},
+			expectedError: `status "open" is not declared in the transitions`,
+		},
+		{
+			name:          "04_遷移先が宣言されていないケース",
+			workflow:      &Workflow{Initial: "todo", Done: "done", Transitions: map[string][]string{"todo": {"doing"}, "done": {"todo"}}},
+			expectedError: `status "doing" in the transitions from "todo" is not declared in the transitions`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			// Act
+			err := tt.workflow.Validate()
+
+			// Assert
+			if tt.expectedError == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.expectedError != "" && (err == nil || err.Error() != tt.expectedError) {
+				t.Errorf("expected: %s, actual: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
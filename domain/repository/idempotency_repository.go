@@ -0,0 +1,26 @@
+//go:generate mockgen -source=$GOFILE -destination=mock_$GOPACKAGE/mock_$GOFILE -package=mock_$GOPACKAGE
+package repository
+
+import (
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+)
+
+// Storage of the responses for Idempotency-Key
+type IdempotencyRepository interface {
+	// Store the record if no record has the same key, and report whether it was stored
+	Insert(*model.IdempotencyRecord) (bool, error)
+
+	// Read the record specified by the key (nil if not found)
+	SelectByKey(string) (*model.IdempotencyRecord, error)
+
+	// Store the response of the record specified by the key
+	Update(*model.IdempotencyRecord) error
+
+	// Delete the record specified by the key
+	DeleteByKey(string) error
+
+	// Delete the records created before the specified time
+	DeleteCreatedBefore(time.Time) (int64, error)
+}
@@ -0,0 +1,25 @@
+//go:generate mockgen -source=$GOFILE -destination=mock_$GOPACKAGE/mock_$GOFILE -package=mock_$GOPACKAGE
+package repository
+
+import (
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+)
+
+// Events waiting to be published, written by ToDoRepository together with the changes
+type OutboxRepository interface {
+	// Pass the oldest events not published yet, up to the limit, to the function in order with the next sequence numbers,
+	// and record the events it published until it fails. The events are locked until then, so that one server publishes them at a time.
+	// Return the number of the published events and the error of the function
+	RelayUnpublished(int, func(*model.OutboxEvent) error) (int, error)
+
+	// Read the published events after the sequence number, up to the limit, in the order of the sequence numbers
+	ListPublished(int64, int) ([]model.OutboxEvent, error)
+
+	// Sequence number of the last published event, 0 if none
+	LastSequence() (int64, error)
+
+	// Delete the events published before the specified time, except the last one to continue the sequence numbers
+	DeletePublishedBefore(time.Time) (int64, error)
+}
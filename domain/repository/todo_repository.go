@@ -1,24 +1,110 @@
 //go:generate mockgen -source=$GOFILE -destination=mock_$GOPACKAGE/mock_$GOFILE -package=mock_$GOPACKAGE
 package repository
 
-import "github.com/uzimihsr/todo-rest-api-golang/domain/model"
+import (
+	"time"
 
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+)
+
+// Methods that change a ToDo also record the given history, a new revision and an outbox event in the same transaction
 type ToDoRepository interface {
 	// Create new ToDo and return the ID
-	Insert(*model.ToDo) (int64, error)
+	Insert(*model.ToDo, *model.ToDoHistory) (int64, error)
 
 	// Read the ToDo specified by sthe ID
 	SelectById(int64) (*model.ToDo, error)
 
+	// Read the ToDo specified by the IDs at once (IDs not found are skipped)
+	SelectByIds([]int64) ([]model.ToDo, error)
+
+	// Read the ToDo specified by the ID and lock it until the end of the transaction (see UnitOfWork)
+	SelectByIdForUpdate(int64) (*model.ToDo, error)
+
 	// Update the ToDo specified by the ID
-	Update(*model.ToDo) error
+	Update(*model.ToDo, *model.ToDoHistory) error
+
+	// Move the ToDo specified by the ID to the trash
+	DeleteById(int64, *model.ToDoHistory) error
+
+	// List all ToDo (archived ToDo are included if true)
+	ListAll(bool) ([]model.ToDo, error)
+
+	// List by done status (archived ToDo are included if the second argument is true)
+	ListFilteredByDone(bool, bool) ([]model.ToDo, error)
+
+	// List ToDo in the trash
+	ListDeleted() ([]model.ToDo, error)
+
+	// Read ToDo one by one in the order of the ID instead of loading all of them.
+	// ToDo are filtered by the done status unless it is nil (archived ToDo are included if the second argument is true)
+	Iterate(*bool, bool) (ToDoIterator, error)
+
+	// List at most the given number of ToDo with the ID greater than the given one in the order of the ID,
+	// so that a page is read without skipping the rows of the previous pages. ToDo are filtered like Iterate
+	ListPage(*bool, bool, int64, int) ([]model.ToDo, error)
+
+	// Count the ToDo filtered like Iterate
+	Count(*bool, bool) (int64, error)
+
+	// Restore the ToDo specified by the ID from the trash
+	Restore(int64, *model.ToDoHistory) error
+
+	// Permanently delete ToDo moved to the trash before the time and return the number of them.
+	// The history is recorded for each of them with the ID of the ToDo
+	PurgeDeletedBefore(time.Time, *model.ToDoHistory) (int64, error)
+
+	// Archive the ToDo specified by the ID
+	Archive(int64, *model.ToDoHistory) error
 
-	// Delete the ToDo specified by the ID
-	DeleteById(int64) error
+	// Unarchive the ToDo specified by the ID
+	Unarchive(int64, *model.ToDoHistory) error
 
-	// List all ToDo
-	ListAll() ([]model.ToDo, error)
+	// Archive done ToDo last updated before the time and return the number of them.
+	// The history is recorded for each of them with the ID of the ToDo
+	ArchiveDoneBefore(time.Time, *model.ToDoHistory) (int64, error)
+
+	// List the change history of the ToDo specified by the ID
+	ListHistory(int64) ([]model.ToDoHistory, error)
+
+	// List the revisions of the ToDo specified by the ID
+	ListRevisions(int64) ([]model.ToDoRevision, error)
+
+	// Read the revision of the ToDo specified by the ID and the revision number
+	SelectRevision(int64, int64) (*model.ToDoRevision, error)
+
+	// Read the ID of the ToDo imported with the iCalendar UID (0 if the UID is not recorded)
+	SelectIdByUid(string) (int64, error)
+
+	// Record the iCalendar UID of the ToDo, replacing the ToDo of the UID if already recorded
+	InsertUid(int64, string) error
+
+	// List the iCalendar UIDs recorded for ToDo by the ID of the ToDo
+	ListUids() (map[int64]string, error)
+
+	// Execute the create, update and delete operations in one transaction and return the result of each.
+	// If atomic, the first failure rolls back all of them and is returned as the error;
+	// otherwise only the failed operations are rolled back.
+	Batch([]model.BatchOperation, bool) ([]model.BatchResult, error)
+}
+
+// Iterator over ToDo read from the database, which must be closed after use
+type ToDoIterator interface {
+	// Advance to the next ToDo and return false at the end or on an error
+	Next() bool
+
+	// ToDo at the current position
+	ToDo() *model.ToDo
+
+	// Error that stopped the iteration
+	Err() error
+
+	Close() error
+}
 
-	// List by done status
-	ListFilteredByDone(bool) ([]model.ToDo, error)
+// Implemented by the repositories that can read ToDo at a point in time
+type TemporalToDoRepository interface {
+	// List ToDo as they were at the time (archived ToDo are included if true)
+	// The whole list is built in memory and is not paged
+	ListAsOf(time.Time, bool) ([]model.ToDo, error)
 }
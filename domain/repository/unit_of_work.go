@@ -0,0 +1,9 @@
+//go:generate mockgen -source=$GOFILE -destination=mock_$GOPACKAGE/mock_$GOFILE -package=mock_$GOPACKAGE
+package repository
+
+// Unit of work running multiple repository calls in one transaction
+type UnitOfWork interface {
+	// Run the function with a ToDoRepository bound to a new transaction.
+	// The transaction is committed if the function returns nil, and rolled back otherwise.
+	Do(func(ToDoRepository) error) error
+}
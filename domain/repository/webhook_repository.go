@@ -0,0 +1,45 @@
+//go:generate mockgen -source=$GOFILE -destination=mock_$GOPACKAGE/mock_$GOFILE -package=mock_$GOPACKAGE
+package repository
+
+import (
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+)
+
+// Storage of the webhooks and their undelivered payloads
+type WebhookRepository interface {
+	// Store the webhook and return its ID
+	Insert(*model.Webhook) (int64, error)
+
+	// Read the webhook specified by the ID
+	SelectById(int64) (*model.Webhook, error)
+
+	// Read all webhooks
+	ListAll() ([]model.Webhook, error)
+
+	// Store the URL, secret and events of the webhook
+	Update(*model.Webhook) error
+
+	// Delete the webhook, its deliveries and its dead letters
+	DeleteById(int64) error
+
+	// Store the payload of the event for every webhook receiving the event and return the number of them.
+	// The payload already stored for the same event and webhook is not stored again
+	InsertDeliveries(*model.WebhookDelivery) (int64, error)
+
+	// Read the deliveries due by now, up to the limit, and postpone them for the lease so that no other worker sends them
+	ClaimDeliveries(int, time.Duration) ([]model.WebhookDelivery, error)
+
+	// Delete the delivery sent successfully
+	DeleteDelivery(int64) error
+
+	// Store the attempts, the last error and the next attempt time of the delivery
+	RetryDelivery(*model.WebhookDelivery) error
+
+	// Move the delivery that could not be sent to the dead letters
+	DeadLetter(*model.WebhookDelivery) error
+
+	// Read the payloads that could not be delivered to the webhook
+	ListDeadLetters(int64) ([]model.WebhookDeadLetter, error)
+}
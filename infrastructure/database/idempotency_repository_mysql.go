@@ -0,0 +1,92 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
+)
+
+// implementation of repository.IdempotencyRepository
+type idempotencyRepositoryMySQL struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepositoryMySQL(db *sql.DB) repository.IdempotencyRepository {
+	return &idempotencyRepositoryMySQL{db: db}
+}
+
+func (r *idempotencyRepositoryMySQL) Insert(record *model.IdempotencyRecord) (bool, error) {
+	// 同じキーが既にある場合は何もしない
+	result, err := r.db.Exec(
+		"INSERT IGNORE INTO idempotency(idempotency_key, request_hash) VALUES ( ?, ? )",
+		record.Key,
+		record.RequestHash,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *idempotencyRepositoryMySQL) SelectByKey(key string) (*model.IdempotencyRecord, error) {
+	record := &model.IdempotencyRecord{}
+	err := r.db.QueryRow(
+		"SELECT idempotency_key, request_hash, status_code, content_type, body, created_at FROM idempotency WHERE idempotency_key = ?",
+		key,
+	).Scan(
+		&record.Key,
+		&record.RequestHash,
+		&record.StatusCode,
+		&record.ContentType,
+		&record.Body,
+		&record.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (r *idempotencyRepositoryMySQL) Update(record *model.IdempotencyRecord) error {
+	result, err := r.db.Exec(
+		"UPDATE idempotency SET status_code = ?, content_type = ?, body = ? WHERE idempotency_key = ?",
+		record.StatusCode,
+		record.ContentType,
+		record.Body,
+		record.Key,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errors.New("UPDATE FAILED")
+	}
+	return nil
+}
+
+func (r *idempotencyRepositoryMySQL) DeleteByKey(key string) error {
+	_, err := r.db.Exec("DELETE FROM idempotency WHERE idempotency_key = ?", key)
+	return err
+}
+
+func (r *idempotencyRepositoryMySQL) DeleteCreatedBefore(before time.Time) (int64, error) {
+	result, err := r.db.Exec("DELETE FROM idempotency WHERE created_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,224 @@
+package database
+
+import (
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+)
+
+func TestIdempotencyInsert(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name         string
+		execResult   driver.Result
+		execError    error
+		wantInserted bool
+		wantError    bool
+	}{
+		{
+			name:         "01_新しいキーが保存されるケース",
+			execResult:   sqlmock.NewResult(0, 1),
+			wantInserted: true,
+			wantError:    false,
+		},
+		{
+			name:         "02_キーが既に存在するケース",
+			execResult:   sqlmock.NewResult(0, 0),
+			wantInserted: false,
+			wantError:    false,
+		},
+		{
+			name:         "03_INSERTが失敗するケース",
+			execResult:   sqlmock.NewErrorResult(errors.New("ERROR RESULT")),
+			execError:    errors.New("INSERT FAILED"),
+			wantInserted: false,
+			wantError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			record := &model.IdempotencyRecord{Key: "key-1", RequestHash: "hash-1"}
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO idempotency(idempotency_key, request_hash) VALUES ( ?, ? )")).
+				WithArgs(record.Key, record.RequestHash).
+				WillReturnResult(tt.execResult).
+				WillReturnError(tt.execError)
+			idempotencyRepository := NewIdempotencyRepositoryMySQL(db)
+
+			// Act
+			inserted, err := idempotencyRepository.Insert(record)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			if inserted != tt.wantInserted {
+				t.Errorf("expected: %v, actual: %v", tt.wantInserted, inserted)
+			}
+		})
+	}
+}
+
+func TestIdempotencySelectByKey(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	columns := []string{"idempotency_key", "request_hash", "status_code", "content_type", "body", "created_at"}
+	tests := []struct {
+		name       string
+		queryRow   *sqlmock.Rows
+		queryError error
+		wantRecord bool
+		wantError  bool
+	}{
+		{
+			name:       "01_キーが存在するケース",
+			queryRow:   sqlmock.NewRows(columns).AddRow("key-1", "hash-1", 200, "application/json", []byte(`{"id":1}`), time.Now()),
+			wantRecord: true,
+			wantError:  false,
+		},
+		{
+			name:       "02_キーが存在しないケース",
+			queryRow:   sqlmock.NewRows(columns),
+			wantRecord: false,
+			wantError:  false,
+		},
+		{
+			name:       "03_SELECTが失敗するケース",
+			queryRow:   sqlmock.NewRows(columns),
+			queryError: errors.New("SELECT FAILED"),
+			wantRecord: false,
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT idempotency_key, request_hash, status_code, content_type, body, created_at FROM idempotency WHERE idempotency_key = ?")).
+				WithArgs("key-1").
+				WillReturnRows(tt.queryRow).
+				WillReturnError(tt.queryError)
+			idempotencyRepository := NewIdempotencyRepositoryMySQL(db)
+
+			// Act
+			record, err := idempotencyRepository.SelectByKey("key-1")
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			if (record != nil) != tt.wantRecord {
+				t.Errorf("expected: %v, actual: %v", tt.wantRecord, record)
+			}
+		})
+	}
+}
+
+func TestIdempotencyUpdate(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name       string
+		execResult driver.Result
+		execError  error
+		wantError  bool
+	}{
+		{
+			name:       "01_UPDATEが成功するケース",
+			execResult: sqlmock.NewResult(0, 1),
+			wantError:  false,
+		},
+		{
+			name:       "02_UPDATEが失敗するケース",
+			execResult: sqlmock.NewErrorResult(errors.New("ERROR RESULT")),
+			execError:  errors.New("UPDATE FAILED"),
+			wantError:  true,
+		},
+		{
+			name:       "03_キーが存在しないケース",
+			execResult: sqlmock.NewResult(0, 0),
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			record := &model.IdempotencyRecord{Key: "key-1", StatusCode: 200, ContentType: "application/json", Body: []byte(`{"id":1}`)}
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE idempotency SET status_code = ?, content_type = ?, body = ? WHERE idempotency_key = ?")).
+				WithArgs(record.StatusCode, record.ContentType, record.Body, record.Key).
+				WillReturnResult(tt.execResult).
+				WillReturnError(tt.execError)
+			idempotencyRepository := NewIdempotencyRepositoryMySQL(db)
+
+			// Act
+			err = idempotencyRepository.Update(record)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestIdempotencyDeleteCreatedBefore(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	before := time.Now()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM idempotency WHERE created_at < ?")).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	idempotencyRepository := NewIdempotencyRepositoryMySQL(db)
+
+	// Act
+	deleted, err := idempotencyRepository.DeleteCreatedBefore(before)
+
+	// Assert
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if deleted != 3 {
+		t.Errorf("expected: %d, actual: %d", 3, deleted)
+	}
+}
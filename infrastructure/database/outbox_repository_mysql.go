@@ -0,0 +1,163 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
+)
+
+// JSON representation of model.ToDo in the payload of the outbox and the state of the snapshots
+type toDoColumn struct {
+	Id         int64      `json:"id"`
+	Title      string     `json:"title"`
+	Status     string     `json:"status"`
+	Done       bool       `json:"done"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+}
+
+func newToDoColumn(toDo *model.ToDo) *toDoColumn {
+	return &toDoColumn{
+		Id:         toDo.Id,
+		Title:      toDo.Title,
+		Status:     toDo.Status,
+		Done:       toDo.Done,
+		CreatedAt:  toDo.CreatedAt,
+		UpdatedAt:  toDo.UpdatedAt,
+		ArchivedAt: toDo.ArchivedAt,
+		DeletedAt:  toDo.DeletedAt,
+	}
+}
+
+func (c *toDoColumn) toModel() *model.ToDo {
+	return &model.ToDo{
+		Id:         c.Id,
+		Title:      c.Title,
+		Status:     c.Status,
+		Done:       c.Done,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+		ArchivedAt: c.ArchivedAt,
+		DeletedAt:  c.DeletedAt,
+	}
+}
+
+// columns of the outbox table read into model.OutboxEvent
+const outboxColumns = "id, event_type, completed, payload, created_at, published_at, sequence"
+
+// implementation of repository.OutboxRepository
+type outboxRepositoryMySQL struct {
+	db *sql.DB
+}
+
+func NewOutboxRepositoryMySQL(db *sql.DB) repository.OutboxRepository {
+	return &outboxRepositoryMySQL{db: db}
+}
+
+func (r *outboxRepositoryMySQL) RelayUnpublished(limit int, publish func(*model.OutboxEvent) error) (int, error) {
+	// READ COMMITTEDにして、未配信の範囲のギャップロックで変更のINSERTを待たせないようにする
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return 0, err
+	}
+	// 他のサーバーが配信中のイベントはコミットされるまで待ち、配信済みになったものは読まない
+	events, err := selectOutbox(tx, "SELECT "+outboxColumns+" FROM outbox WHERE published_at IS NULL ORDER BY id LIMIT ? FOR UPDATE", limit)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	var sequence int64
+	err = tx.QueryRow("SELECT COALESCE(MAX(sequence), 0) FROM outbox").Scan(&sequence)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	published := 0
+	var publishError error
+	for i := range events {
+		sequence++
+		events[i].Sequence = sequence
+		publishError = publish(&events[i])
+		if publishError != nil {
+			break
+		}
+		_, err = tx.Exec("UPDATE outbox SET published_at = CURRENT_TIMESTAMP, sequence = ? WHERE id = ?", sequence, events[i].Id)
+		if err != nil {
+			// 配信したイベントは次回もう一度配信される
+			tx.Rollback()
+			return 0, err
+		}
+		published++
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return published, publishError
+}
+
+func (r *outboxRepositoryMySQL) ListPublished(afterSequence int64, limit int) ([]model.OutboxEvent, error) {
+	return selectOutbox(r.db, "SELECT "+outboxColumns+" FROM outbox WHERE sequence > ? ORDER BY sequence LIMIT ?", afterSequence, limit)
+}
+
+func (r *outboxRepositoryMySQL) LastSequence() (int64, error) {
+	var sequence int64
+	err := r.db.QueryRow("SELECT COALESCE(MAX(sequence), 0) FROM outbox").Scan(&sequence)
+	return sequence, err
+}
+
+func (r *outboxRepositoryMySQL) DeletePublishedBefore(before time.Time) (int64, error) {
+	// 最後に配信したイベントは次の番号を決めるために残す(同じテーブルはサブクエリで直接参照できないので派生テーブルにする)
+	result, err := r.db.Exec(
+		"DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < ? "+
+			"AND sequence < (SELECT last FROM (SELECT MAX(sequence) AS last FROM outbox) AS latest)",
+		before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func selectOutbox(q executor, query string, args ...interface{}) ([]model.OutboxEvent, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []model.OutboxEvent{}
+	for rows.Next() {
+		event := model.OutboxEvent{}
+		var payload []byte
+		var sequence sql.NullInt64
+		err := rows.Scan(
+			&event.Id,
+			&event.EventType,
+			&event.Completed,
+			&payload,
+			&event.CreatedAt,
+			&event.PublishedAt,
+			&sequence,
+		)
+		if err != nil {
+			return nil, err
+		}
+		event.Sequence = sequence.Int64
+		column := toDoColumn{}
+		err = json.Unmarshal(payload, &column)
+		if err != nil {
+			return nil, err
+		}
+		event.ToDo = *column.toModel()
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,278 @@
+package database
+
+import (
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+)
+
+// 変更と同じトランザクションでoutboxに書き込まれることを期待する
+func expectOutbox(mock sqlmock.Sqlmock) {
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE id = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).
+			AddRow(1, "test-ToDo", "todo", false, now, now, nil, nil))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox(event_type, completed, todo_id, payload) VALUES ( ?, ?, ?, ? )")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestInsertOutbox(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name          string
+		history       *model.ToDoHistory
+		wantEventType string
+		wantCompleted bool
+		wantError     bool
+	}{
+		{
+			name:          "01_作成のケース",
+			history:       &model.ToDoHistory{ToDoId: 1, Operation: "create", Changes: []model.FieldChange{{Field: "done", Before: nil, After: true}}},
+			wantEventType: "created",
+			wantCompleted: false,
+		},
+		{
+			name:          "02_完了にする更新のケース",
+			history:       &model.ToDoHistory{ToDoId: 1, Operation: "update", Changes: []model.FieldChange{{Field: "done", Before: false, After: true}}},
+			wantEventType: "updated",
+			wantCompleted: true,
+		},
+		{
+			name:          "03_未完了に戻す更新のケース",
+			history:       &model.ToDoHistory{ToDoId: 1, Operation: "revert", Changes: []model.FieldChange{{Field: "done", Before: true, After: false}}},
+			wantEventType: "updated",
+			wantCompleted: false,
+		},
+		{
+			name:          "04_復元のケース",
+			history:       &model.ToDoHistory{ToDoId: 1, Operation: "restore"},
+			wantEventType: "created",
+			wantCompleted: false,
+		},
+		{
+			name:      "05_不明な操作のケース",
+			history:   &model.ToDoHistory{ToDoId: 1, Operation: "hoge"},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			now := time.Now()
+			mock.ExpectBegin()
+			if !tt.wantError {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE id = ?")).
+					WithArgs(tt.history.ToDoId).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).
+						AddRow(1, "test-ToDo", "done", true, now, now, nil, nil))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox(event_type, completed, todo_id, payload) VALUES ( ?, ?, ?, ? )")).
+					WithArgs(tt.wantEventType, tt.wantCompleted, tt.history.ToDoId, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			}
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			// Act
+			err = insertOutbox(tx, tt.history)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestRelayUnpublished(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	now := time.Now().UTC().Truncate(time.Second)
+	payload := `{"id":100,"title":"test-ToDo","status":"done","done":true,"created_at":"` + now.Format(time.RFC3339) + `","updated_at":"` + now.Format(time.RFC3339) + `"}`
+	columns := []string{"id", "event_type", "completed", "payload", "created_at", "published_at", "sequence"}
+	tests := []struct {
+		name          string
+		queryRows     *sqlmock.Rows
+		publishErrors []error
+		updateError   error
+		wantUpdated   []driver.Value
+		wantCommit    bool
+		wantPublished int
+		wantError     bool
+	}{
+		{
+			name:          "01_続きの番号を付けて配信するケース",
+			queryRows:     sqlmock.NewRows(columns).AddRow(11, "updated", true, payload, now, nil, nil).AddRow(13, "deleted", false, payload, now, nil, nil),
+			wantUpdated:   []driver.Value{8, 9},
+			wantCommit:    true,
+			wantPublished: 2,
+			wantError:     false,
+		},
+		{
+			name:          "02_配信に失敗したところまで記録するケース",
+			queryRows:     sqlmock.NewRows(columns).AddRow(11, "updated", true, payload, now, nil, nil).AddRow(13, "deleted", false, payload, now, nil, nil),
+			publishErrors: []error{nil, errors.New("Publish ERROR")},
+			wantUpdated:   []driver.Value{8},
+			wantCommit:    true,
+			wantPublished: 1,
+			wantError:     true,
+		},
+		{
+			name:          "03_記録に失敗したらロールバックするケース",
+			queryRows:     sqlmock.NewRows(columns).AddRow(11, "updated", true, payload, now, nil, nil),
+			updateError:   errors.New("UPDATE FAILED"),
+			wantUpdated:   []driver.Value{8},
+			wantCommit:    false,
+			wantPublished: 0,
+			wantError:     true,
+		},
+		{
+			name:          "04_ペイロードが不正なケース",
+			queryRows:     sqlmock.NewRows(columns).AddRow(11, "updated", false, `invalid`, now, nil, nil),
+			wantUpdated:   []driver.Value{},
+			wantCommit:    false,
+			wantPublished: 0,
+			wantError:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT id, event_type, completed, payload, created_at, published_at, sequence FROM outbox WHERE published_at IS NULL ORDER BY id LIMIT ? FOR UPDATE")).
+				WithArgs(10).
+				WillReturnRows(tt.queryRows)
+			if len(tt.wantUpdated) > 0 {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(sequence), 0) FROM outbox")).
+					WillReturnRows(sqlmock.NewRows([]string{"sequence"}).AddRow(7))
+			}
+			for _, sequence := range tt.wantUpdated {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE outbox SET published_at = CURRENT_TIMESTAMP, sequence = ? WHERE id = ?")).
+					WithArgs(sequence, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1)).
+					WillReturnError(tt.updateError)
+			}
+			if tt.wantCommit {
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+			outboxRepository := NewOutboxRepositoryMySQL(db)
+			publishErrors := tt.publishErrors
+			published := []model.OutboxEvent{}
+
+			// Act
+			n, err := outboxRepository.RelayUnpublished(10, func(event *model.OutboxEvent) error {
+				if len(publishErrors) > 0 {
+					err := publishErrors[0]
+					publishErrors = publishErrors[1:]
+					if err != nil {
+						return err
+					}
+				}
+				published = append(published, *event)
+				return nil
+			})
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if n != tt.wantPublished {
+				t.Errorf("expected: %d, actual: %d", tt.wantPublished, n)
+			}
+			if len(published) > 0 {
+				toDo := published[0].ToDo
+				if published[0].Id != 11 || published[0].Sequence != 8 || published[0].EventType != "updated" || !published[0].Completed || toDo.Id != 100 || !toDo.UpdatedAt.Equal(now) {
+					t.Errorf("unexpected event: %+v", published[0])
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestListPublished(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	now := time.Now().UTC().Truncate(time.Second)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, event_type, completed, payload, created_at, published_at, sequence FROM outbox WHERE sequence > ? ORDER BY sequence LIMIT ?")).
+		WithArgs(7, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "completed", "payload", "created_at", "published_at", "sequence"}).
+			AddRow(13, "created", false, `{"id":100,"title":"test-ToDo","status":"todo"}`, now, now, 8))
+	outboxRepository := NewOutboxRepositoryMySQL(db)
+
+	// Act
+	events, err := outboxRepository.ListPublished(7, 10)
+
+	// Assert
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(events) != 1 || events[0].Id != 13 || events[0].Sequence != 8 || events[0].PublishedAt == nil || events[0].ToDo.Title != "test-ToDo" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
+
+func TestDeletePublishedBefore(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	before := time.Now()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+	// 最後に配信したイベントは残す
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < ? AND sequence < (SELECT last FROM (SELECT MAX(sequence) AS last FROM outbox) AS latest)")).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	outboxRepository := NewOutboxRepositoryMySQL(db)
+
+	// Act
+	purged, err := outboxRepository.DeletePublishedBefore(before)
+
+	// Assert
+	if err != nil || purged != 3 {
+		t.Errorf("expected: 3, actual: %d, %v", purged, err)
+	}
+}
@@ -6,13 +6,162 @@ DROP TABLE IF EXISTS todo;
 CREATE TABLE IF NOT EXISTS todo (
   id INT AUTO_INCREMENT PRIMARY KEY, 
   title VARCHAR(100) NOT NULL,
+  status VARCHAR(32) NOT NULL,
   done BOOLEAN DEFAULT false,
   created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  archived_at DATETIME NULL DEFAULT NULL,
+  deleted_at DATETIME NULL DEFAULT NULL
+);
+
+DROP TABLE IF EXISTS todo_history;
+CREATE TABLE IF NOT EXISTS todo_history (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  todo_id INT NOT NULL,
+  operation VARCHAR(16) NOT NULL,
+  changes JSON NOT NULL,
+  actor VARCHAR(100) NOT NULL DEFAULT '',
+  request_id VARCHAR(64) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX (todo_id)
+);
+
+DROP TABLE IF EXISTS todo_revision;
+CREATE TABLE IF NOT EXISTS todo_revision (
+  todo_id INT NOT NULL,
+  revision INT NOT NULL,
+  title VARCHAR(100) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  done BOOLEAN NOT NULL,
+  archived BOOLEAN NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (todo_id, revision)
+);
+
+DROP TABLE IF EXISTS idempotency;
+CREATE TABLE IF NOT EXISTS idempotency (
+  idempotency_key VARCHAR(255) PRIMARY KEY,
+  request_hash CHAR(64) NOT NULL,
+  status_code INT NOT NULL DEFAULT 0,
+  content_type VARCHAR(100) NOT NULL DEFAULT '',
+  body MEDIUMBLOB NULL DEFAULT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX (created_at)
+);
+
+DROP TABLE IF EXISTS webhook_delivery;
+DROP TABLE IF EXISTS webhook_dead_letter;
+DROP TABLE IF EXISTS webhook;
+CREATE TABLE IF NOT EXISTS webhook (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  url VARCHAR(2048) NOT NULL,
+  secret VARCHAR(255) NOT NULL,
+  events VARCHAR(255) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
   updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 );
 
-INSERT INTO todo(title, done) VALUES ('ToDo01', false);
-INSERT INTO todo(title, done) VALUES ('ToDo02', false);
-INSERT INTO todo(title, done) VALUES ('ToDo03', true);
-INSERT INTO todo(title, done) VALUES ('ToDo04', true);
-INSERT INTO todo(title, done) VALUES ('ToDo05', false);
\ No newline at end of file
+CREATE TABLE IF NOT EXISTS webhook_dead_letter (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  webhook_id INT NOT NULL,
+  event VARCHAR(16) NOT NULL,
+  payload MEDIUMBLOB NOT NULL,
+  attempts INT NOT NULL,
+  last_error TEXT NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (webhook_id) REFERENCES webhook(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  webhook_id INT NOT NULL,
+  event_id BIGINT NOT NULL,
+  event VARCHAR(16) NOT NULL,
+  payload MEDIUMBLOB NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL,
+  next_attempt_at DATETIME NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (webhook_id, event_id, event),
+  INDEX (next_attempt_at),
+  FOREIGN KEY (webhook_id) REFERENCES webhook(id) ON DELETE CASCADE
+);
+
+DROP TABLE IF EXISTS outbox;
+CREATE TABLE IF NOT EXISTS outbox (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  event_type VARCHAR(16) NOT NULL,
+  completed BOOLEAN NOT NULL DEFAULT false,
+  todo_id INT NOT NULL,
+  payload JSON NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  published_at DATETIME NULL DEFAULT NULL,
+  sequence BIGINT NULL DEFAULT NULL,
+  INDEX (published_at),
+  UNIQUE (sequence)
+);
+
+DROP TABLE IF EXISTS todo_stream;
+CREATE TABLE IF NOT EXISTS todo_stream (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  version INT NOT NULL DEFAULT 0,
+  revision INT NOT NULL DEFAULT 0,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+DROP TABLE IF EXISTS todo_event;
+CREATE TABLE IF NOT EXISTS todo_event (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  todo_id INT NOT NULL,
+  version INT NOT NULL,
+  revision INT NOT NULL,
+  event_type VARCHAR(16) NOT NULL,
+  title VARCHAR(100) NOT NULL DEFAULT '',
+  status VARCHAR(32) NOT NULL DEFAULT '',
+  done BOOLEAN NOT NULL DEFAULT false,
+  operation VARCHAR(16) NOT NULL,
+  actor VARCHAR(100) NOT NULL DEFAULT '',
+  request_id VARCHAR(64) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL,
+  UNIQUE (todo_id, version),
+  INDEX (created_at)
+);
+
+DROP TABLE IF EXISTS todo_snapshot;
+CREATE TABLE IF NOT EXISTS todo_snapshot (
+  todo_id INT NOT NULL,
+  version INT NOT NULL,
+  revision INT NOT NULL,
+  state JSON NOT NULL,
+  created_at DATETIME NOT NULL,
+  PRIMARY KEY (todo_id, version),
+  INDEX (created_at)
+);
+
+DROP TABLE IF EXISTS todo_projection;
+CREATE TABLE IF NOT EXISTS todo_projection (
+  id INT PRIMARY KEY,
+  title VARCHAR(100) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  done BOOLEAN NOT NULL,
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL,
+  archived_at DATETIME NULL DEFAULT NULL,
+  deleted_at DATETIME NULL DEFAULT NULL,
+  INDEX (done),
+  INDEX (deleted_at)
+);
+
+DROP TABLE IF EXISTS todo_uid;
+CREATE TABLE IF NOT EXISTS todo_uid (
+  uid VARCHAR(255) PRIMARY KEY,
+  todo_id INT NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX (todo_id)
+);
+
+INSERT INTO todo(title, status, done) VALUES ('ToDo01', 'todo', false);
+INSERT INTO todo(title, status, done) VALUES ('ToDo02', 'todo', false);
+INSERT INTO todo(title, status, done) VALUES ('ToDo03', 'done', true);
+INSERT INTO todo(title, status, done) VALUES ('ToDo04', 'done', true);
+INSERT INTO todo(title, status, done) VALUES ('ToDo05', 'todo', false);
\ No newline at end of file
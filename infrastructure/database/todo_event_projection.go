@@ -0,0 +1,162 @@
+package database
+
+import "github.com/uzimihsr/todo-rest-api-golang/domain/model"
+
+// イベントを1件適用した状態を返す(Created以前とPurged以降はnil)
+func applyToDoEvent(toDo *model.ToDo, event *model.ToDoEvent) *model.ToDo {
+	at := event.CreatedAt
+	if event.Type == model.ToDoCreated {
+		return &model.ToDo{
+			Id:        event.ToDoId,
+			Title:     event.Title,
+			Status:    event.Status,
+			Done:      event.Done,
+			CreatedAt: at,
+			UpdatedAt: at,
+		}
+	}
+	if toDo == nil {
+		return nil
+	}
+
+	next := *toDo
+	next.UpdatedAt = at
+	switch event.Type {
+	case model.ToDoTitleChanged:
+		next.Title = event.Title
+	case model.ToDoStatusChanged:
+		next.Status = event.Status
+	case model.ToDoCompleted:
+		next.Status = event.Status
+		next.Done = true
+	case model.ToDoReopened:
+		next.Status = event.Status
+		next.Done = false
+	case model.ToDoArchived:
+		next.ArchivedAt = &at
+	case model.ToDoUnarchived:
+		next.ArchivedAt = nil
+	case model.ToDoDeleted:
+		next.DeletedAt = &at
+	case model.ToDoRestored:
+		next.DeletedAt = nil
+	case model.ToDoPurged:
+		return nil
+	}
+	return &next
+}
+
+// スナップショット(なければnil)以降のイベントを順に適用する
+func projectToDo(snapshot *model.ToDo, events []model.ToDoEvent) *model.ToDo {
+	toDo := snapshot
+	for i := range events {
+		toDo = applyToDoEvent(toDo, &events[i])
+	}
+	return toDo
+}
+
+// beforeをafterにするイベントを列挙する(作成時はbeforeがnil)
+func diffToDoEvents(before *model.ToDo, after *model.ToDo) []model.ToDoEvent {
+	if before == nil {
+		return []model.ToDoEvent{{Type: model.ToDoCreated, Title: after.Title, Status: after.Status, Done: after.Done}}
+	}
+
+	events := []model.ToDoEvent{}
+	if before.Title != after.Title {
+		events = append(events, model.ToDoEvent{Type: model.ToDoTitleChanged, Title: after.Title})
+	}
+	switch {
+	case !before.Done && after.Done:
+		events = append(events, model.ToDoEvent{Type: model.ToDoCompleted, Status: after.Status})
+	case before.Done && !after.Done:
+		events = append(events, model.ToDoEvent{Type: model.ToDoReopened, Status: after.Status})
+	case before.Status != after.Status:
+		events = append(events, model.ToDoEvent{Type: model.ToDoStatusChanged, Status: after.Status})
+	}
+	switch {
+	case before.ArchivedAt == nil && after.ArchivedAt != nil:
+		events = append(events, model.ToDoEvent{Type: model.ToDoArchived})
+	case before.ArchivedAt != nil && after.ArchivedAt == nil:
+		events = append(events, model.ToDoEvent{Type: model.ToDoUnarchived})
+	}
+	return events
+}
+
+// ストリームの全イベントから変更ごとの履歴を組み立てる
+func historyOf(events []model.ToDoEvent) []model.ToDoHistory {
+	var historyList []model.ToDoHistory
+	var before *model.ToDo
+	for i := 0; i < len(events); {
+		// 同じリビジョンのイベントを1つの変更としてまとめる
+		first := events[i]
+		after := before
+		for ; i < len(events) && events[i].Revision == first.Revision; i++ {
+			after = applyToDoEvent(after, &events[i])
+		}
+		historyList = append(historyList, model.ToDoHistory{
+			Id:        first.Id,
+			ToDoId:    first.ToDoId,
+			Operation: first.Operation,
+			Changes:   fieldChanges(before, after),
+			Actor:     first.Actor,
+			RequestId: first.RequestId,
+			CreatedAt: first.CreatedAt,
+		})
+		before = after
+	}
+	return historyList
+}
+
+// ストリームの全イベントから変更ごとのリビジョンを組み立てる
+func revisionsOf(events []model.ToDoEvent) []model.ToDoRevision {
+	var revisionList []model.ToDoRevision
+	var toDo *model.ToDo
+	for i := range events {
+		toDo = applyToDoEvent(toDo, &events[i])
+		if toDo == nil || (i+1 < len(events) && events[i+1].Revision == events[i].Revision) {
+			continue
+		}
+		revisionList = append(revisionList, model.ToDoRevision{
+			ToDoId:    toDo.Id,
+			Revision:  events[i].Revision,
+			Title:     toDo.Title,
+			Status:    toDo.Status,
+			Done:      toDo.Done,
+			Archived:  toDo.ArchivedAt != nil,
+			CreatedAt: events[i].CreatedAt,
+		})
+	}
+	return revisionList
+}
+
+// 変更前後で値が変わった項目を列挙する(作成時はbeforeがnil)
+func fieldChanges(before *model.ToDo, after *model.ToDo) []model.FieldChange {
+	if after == nil {
+		return []model.FieldChange{{Field: "purged", Before: false, After: true}}
+	}
+	if before == nil {
+		return []model.FieldChange{
+			{Field: "title", Before: nil, After: after.Title},
+			{Field: "status", Before: nil, After: after.Status},
+			{Field: "done", Before: nil, After: after.Done},
+		}
+	}
+
+	changes := []model.FieldChange{}
+	if before.Title != after.Title {
+		changes = append(changes, model.FieldChange{Field: "title", Before: before.Title, After: after.Title})
+	}
+	if before.Status != after.Status {
+		changes = append(changes, model.FieldChange{Field: "status", Before: before.Status, After: after.Status})
+	}
+	if before.Done != after.Done {
+		changes = append(changes, model.FieldChange{Field: "done", Before: before.Done, After: after.Done})
+	}
+	if (before.ArchivedAt != nil) != (after.ArchivedAt != nil) {
+		changes = append(changes, model.FieldChange{Field: "archived", Before: before.ArchivedAt != nil, After: after.ArchivedAt != nil})
+	}
+	if (before.DeletedAt != nil) != (after.DeletedAt != nil) {
+		changes = append(changes, model.FieldChange{Field: "deleted", Before: before.DeletedAt != nil, After: after.DeletedAt != nil})
+	}
+	return changes
+}
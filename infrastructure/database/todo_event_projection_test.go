@@ -0,0 +1,180 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+)
+
+func TestApplyToDoEvent(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	current := &model.ToDo{Id: 1, Title: "test-ToDo", Status: "todo", Done: false, CreatedAt: createdAt, UpdatedAt: createdAt}
+	tests := []struct {
+		name  string
+		toDo  *model.ToDo
+		event *model.ToDoEvent
+		want  *model.ToDo
+	}{
+		{
+			name:  "01_Createdのケース",
+			toDo:  nil,
+			event: &model.ToDoEvent{ToDoId: 1, Type: model.ToDoCreated, Title: "test-ToDo", Status: "todo", CreatedAt: createdAt},
+			want:  current,
+		},
+		{
+			name:  "02_TitleChangedのケース",
+			toDo:  current,
+			event: &model.ToDoEvent{ToDoId: 1, Type: model.ToDoTitleChanged, Title: "updated-ToDo", CreatedAt: at},
+			want:  &model.ToDo{Id: 1, Title: "updated-ToDo", Status: "todo", Done: false, CreatedAt: createdAt, UpdatedAt: at},
+		},
+		{
+			name:  "03_Completedのケース",
+			toDo:  current,
+			event: &model.ToDoEvent{ToDoId: 1, Type: model.ToDoCompleted, Status: "done", CreatedAt: at},
+			want:  &model.ToDo{Id: 1, Title: "test-ToDo", Status: "done", Done: true, CreatedAt: createdAt, UpdatedAt: at},
+		},
+		{
+			name:  "04_Deletedのケース",
+			toDo:  current,
+			event: &model.ToDoEvent{ToDoId: 1, Type: model.ToDoDeleted, CreatedAt: at},
+			want:  &model.ToDo{Id: 1, Title: "test-ToDo", Status: "todo", Done: false, CreatedAt: createdAt, UpdatedAt: at, DeletedAt: &at},
+		},
+		{
+			name:  "05_Purgedのケース",
+			toDo:  current,
+			event: &model.ToDoEvent{ToDoId: 1, Type: model.ToDoPurged, CreatedAt: at},
+			want:  nil,
+		},
+		{
+			name:  "06_作成前のイベントのケース",
+			toDo:  nil,
+			event: &model.ToDoEvent{ToDoId: 1, Type: model.ToDoTitleChanged, Title: "updated-ToDo", CreatedAt: at},
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			before := current.Title
+
+			// Act
+			got := applyToDoEvent(tt.toDo, tt.event)
+
+			// Assert
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected: %+v, actual: %+v", tt.want, got)
+			}
+			if current.Title != before {
+				t.Error("the given ToDo must not be changed")
+			}
+		})
+	}
+}
+
+func TestDiffToDoEvents(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	now := time.Now()
+	tests := []struct {
+		name      string
+		before    *model.ToDo
+		after     *model.ToDo
+		wantTypes []string
+	}{
+		{
+			name:      "01_作成のケース",
+			before:    nil,
+			after:     &model.ToDo{Title: "test-ToDo", Status: "todo"},
+			wantTypes: []string{model.ToDoCreated},
+		},
+		{
+			name:      "02_タイトルと完了状態が変わるケース",
+			before:    &model.ToDo{Title: "test-ToDo", Status: "todo"},
+			after:     &model.ToDo{Title: "updated-ToDo", Status: "done", Done: true},
+			wantTypes: []string{model.ToDoTitleChanged, model.ToDoCompleted},
+		},
+		{
+			name:      "03_ステータスだけが変わるケース",
+			before:    &model.ToDo{Title: "test-ToDo", Status: "todo"},
+			after:     &model.ToDo{Title: "test-ToDo", Status: "in_progress"},
+			wantTypes: []string{model.ToDoStatusChanged},
+		},
+		{
+			name:      "04_未完了に戻してアーカイブを解除するケース",
+			before:    &model.ToDo{Title: "test-ToDo", Status: "done", Done: true, ArchivedAt: &now},
+			after:     &model.ToDo{Title: "test-ToDo", Status: "todo"},
+			wantTypes: []string{model.ToDoReopened, model.ToDoUnarchived},
+		},
+		{
+			name:      "05_変更がないケース",
+			before:    &model.ToDo{Title: "test-ToDo", Status: "todo"},
+			after:     &model.ToDo{Title: "test-ToDo", Status: "todo"},
+			wantTypes: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Act
+			events := diffToDoEvents(tt.before, tt.after)
+
+			// Assert
+			types := []string{}
+			for _, e := range events {
+				types = append(types, e.Type)
+			}
+			if !reflect.DeepEqual(types, tt.wantTypes) {
+				t.Errorf("expected: %v, actual: %v", tt.wantTypes, types)
+			}
+		})
+	}
+}
+
+func TestHistoryOf(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	now := time.Now()
+	events := []model.ToDoEvent{
+		{Id: 1, ToDoId: 1, Version: 1, Revision: 1, Type: model.ToDoCreated, Title: "test-ToDo", Status: "todo", Operation: "create", CreatedAt: now},
+		{Id: 2, ToDoId: 1, Version: 2, Revision: 2, Type: model.ToDoTitleChanged, Title: "updated-ToDo", Operation: "update", CreatedAt: now},
+		{Id: 3, ToDoId: 1, Version: 3, Revision: 2, Type: model.ToDoCompleted, Status: "done", Operation: "update", CreatedAt: now},
+	}
+
+	// Act
+	historyList := historyOf(events)
+	revisionList := revisionsOf(events)
+
+	// Assert
+	if len(historyList) != 2 {
+		t.Fatalf("expected: 2 histories, actual: %d", len(historyList))
+	}
+	wantChanges := []model.FieldChange{
+		{Field: "title", Before: "test-ToDo", After: "updated-ToDo"},
+		{Field: "status", Before: "todo", After: "done"},
+		{Field: "done", Before: false, After: true},
+	}
+	if historyList[1].Id != 2 || !reflect.DeepEqual(historyList[1].Changes, wantChanges) {
+		t.Errorf("expected: %+v, actual: %+v", wantChanges, historyList[1])
+	}
+	if len(revisionList) != 2 {
+		t.Fatalf("expected: 2 revisions, actual: %d", len(revisionList))
+	}
+	if revisionList[1].Revision != 2 || revisionList[1].Title != "updated-ToDo" || !revisionList[1].Done {
+		t.Errorf("unexpected revision: %+v", revisionList[1])
+	}
+}
@@ -0,0 +1,699 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
+)
+
+// columns of the todo_event table read into model.ToDoEvent
+const eventColumns = "e.id, e.todo_id, e.version, e.revision, e.event_type, e.title, e.status, e.done, e.operation, e.actor, e.request_id, e.created_at"
+
+// number of events between the snapshots of a stream
+const snapshotInterval = 50
+
+// number of ToDo read at once by the iterator
+const iteratePageSize = 100
+
+// implementation of repository.ToDoRepository and repository.TemporalToDoRepository
+// that stores the changes of ToDo as events and derives the current state from them
+type toDoRepositoryEventStore struct {
+	connection
+}
+
+// Position of the stream of a ToDo
+type toDoStream struct {
+	id       int64
+	version  int64
+	revision int64
+}
+
+func NewToDoRepositoryEventStore(db *sql.DB) repository.ToDoRepository {
+	return &toDoRepositoryEventStore{connection{db: db}}
+}
+
+func (r *toDoRepositoryEventStore) Insert(toDo *model.ToDo, history *model.ToDoHistory) (int64, error) {
+	var id int64
+	err := r.transaction(func(tx *sql.Tx) error {
+		var err error
+		id, err = insertToDoEvents(tx, toDo, history)
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+func (r *toDoRepositoryEventStore) SelectById(id int64) (*model.ToDo, error) {
+	_, toDo, err := loadStream(r.conn(), id, false)
+	if err != nil {
+		return nil, err
+	}
+	if toDo == nil || toDo.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+	return toDo, nil
+}
+
+func (r *toDoRepositoryEventStore) SelectByIds(ids []int64) ([]model.ToDo, error) {
+	// ストリームごとにスナップショットとイベントを読む必要があるため1件ずつ組み立てる
+	var toDoList []model.ToDo
+	for _, id := range ids {
+		toDo, err := r.SelectById(id)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		toDoList = append(toDoList, *toDo)
+	}
+	return toDoList, nil
+}
+
+func (r *toDoRepositoryEventStore) SelectByIdForUpdate(id int64) (*model.ToDo, error) {
+	// ストリームをロックしてトランザクションが終わるまで他の更新を待たせる
+	_, toDo, err := loadStream(r.conn(), id, true)
+	if err != nil {
+		return nil, err
+	}
+	if toDo == nil || toDo.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+	return toDo, nil
+}
+
+func (r *toDoRepositoryEventStore) Update(toDo *model.ToDo, history *model.ToDoHistory) error {
+	return r.transaction(func(tx *sql.Tx) error {
+		return updateToDoEvents(tx, toDo, history)
+	})
+}
+
+func (r *toDoRepositoryEventStore) DeleteById(id int64, history *model.ToDoHistory) error {
+	return r.transaction(func(tx *sql.Tx) error {
+		return deleteToDoEvents(tx, id, history)
+	})
+}
+
+func (r *toDoRepositoryEventStore) ListAll(includeArchived bool) ([]model.ToDo, error) {
+	return listProjection(r.conn(), "deleted_at IS NULL", includeArchived)
+}
+
+func (r *toDoRepositoryEventStore) ListFilteredByDone(done bool, includeArchived bool) ([]model.ToDo, error) {
+	return listProjection(r.conn(), "done = ? AND deleted_at IS NULL", includeArchived, done)
+}
+
+func (r *toDoRepositoryEventStore) ListDeleted() ([]model.ToDo, error) {
+	return listProjection(r.conn(), "deleted_at IS NOT NULL", true)
+}
+
+func (r *toDoRepositoryEventStore) Iterate(done *bool, includeArchived bool) (repository.ToDoIterator, error) {
+	return &toDoProjectionIterator{q: r.conn(), done: done, includeArchived: includeArchived}, nil
+}
+
+func (r *toDoRepositoryEventStore) ListPage(done *bool, includeArchived bool, afterId int64, limit int) ([]model.ToDo, error) {
+	return listPage(r.conn(), "todo_projection", done, includeArchived, afterId, limit)
+}
+
+func (r *toDoRepositoryEventStore) Count(done *bool, includeArchived bool) (int64, error) {
+	return countToDo(r.conn(), "todo_projection", done, includeArchived)
+}
+
+// All the streams are folded in memory, the cost grows with the number of ToDo
+// and up to snapshotInterval events of each of them
+func (r *toDoRepositoryEventStore) ListAsOf(asOf time.Time, includeArchived bool) ([]model.ToDo, error) {
+	// ストリームごとにasOf時点で最新のスナップショットから始め、それ以降のイベントだけを適用する
+	latest := "SELECT todo_id, MAX(version) AS version FROM todo_snapshot WHERE created_at <= ? GROUP BY todo_id"
+	rows, err := r.conn().Query(
+		"SELECT s.state FROM todo_snapshot s JOIN ("+latest+") l ON l.todo_id = s.todo_id AND l.version = s.version",
+		asOf,
+	)
+	if err != nil {
+		return nil, err
+	}
+	toDoMap, err := scanSnapshots(rows)
+	if err != nil {
+		return nil, err
+	}
+	rows, err = r.conn().Query(
+		"SELECT "+eventColumns+" FROM todo_event e LEFT JOIN ("+latest+") l ON l.todo_id = e.todo_id "+
+			"WHERE e.created_at <= ? AND e.version > COALESCE(l.version, 0) ORDER BY e.todo_id, e.version",
+		asOf,
+		asOf,
+	)
+	if err != nil {
+		return nil, err
+	}
+	toDoList, err := foldEvents(rows, toDoMap)
+	if err != nil {
+		return nil, err
+	}
+	return filterToDo(toDoList, includeArchived), nil
+}
+
+func (r *toDoRepositoryEventStore) Restore(id int64, history *model.ToDoHistory) error {
+	return r.transaction(func(tx *sql.Tx) error {
+		return appendChange(tx, id, history, "RESTORE FAILED",
+			func(t *model.ToDo) bool { return t.DeletedAt != nil },
+			[]model.ToDoEvent{{Type: model.ToDoRestored}},
+		)
+	})
+}
+
+func (r *toDoRepositoryEventStore) PurgeDeletedBefore(before time.Time, history *model.ToDoHistory) (int64, error) {
+	var purged int64
+	err := r.transaction(func(tx *sql.Tx) error {
+		ids, err := appendAll(tx, model.ToDoPurged, history,
+			"deleted_at IS NOT NULL AND deleted_at < ?", []interface{}{before},
+			func(t *model.ToDo) bool { return t.DeletedAt != nil && t.DeletedAt.Before(before) },
+		)
+		if err != nil {
+			return err
+		}
+		purged = int64(len(ids))
+		return deleteUids(tx, ids)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+func (r *toDoRepositoryEventStore) Archive(id int64, history *model.ToDoHistory) error {
+	return r.transaction(func(tx *sql.Tx) error {
+		return appendChange(tx, id, history, "ARCHIVE FAILED",
+			func(t *model.ToDo) bool { return t.DeletedAt == nil && t.ArchivedAt == nil },
+			[]model.ToDoEvent{{Type: model.ToDoArchived}},
+		)
+	})
+}
+
+func (r *toDoRepositoryEventStore) Unarchive(id int64, history *model.ToDoHistory) error {
+	return r.transaction(func(tx *sql.Tx) error {
+		return appendChange(tx, id, history, "UNARCHIVE FAILED",
+			func(t *model.ToDo) bool { return t.DeletedAt == nil && t.ArchivedAt != nil },
+			[]model.ToDoEvent{{Type: model.ToDoUnarchived}},
+		)
+	})
+}
+
+func (r *toDoRepositoryEventStore) ArchiveDoneBefore(before time.Time, history *model.ToDoHistory) (int64, error) {
+	var archived int64
+	err := r.transaction(func(tx *sql.Tx) error {
+		ids, err := appendAll(tx, model.ToDoArchived, history,
+			"done = true AND updated_at < ? AND archived_at IS NULL AND deleted_at IS NULL", []interface{}{before},
+			func(t *model.ToDo) bool {
+				return t.Done && t.UpdatedAt.Before(before) && t.ArchivedAt == nil && t.DeletedAt == nil
+			},
+		)
+		archived = int64(len(ids))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return archived, nil
+}
+
+func (r *toDoRepositoryEventStore) ListHistory(id int64) ([]model.ToDoHistory, error) {
+	events, err := listEvents(r.conn(), id)
+	if err != nil {
+		return nil, err
+	}
+	return historyOf(events), nil
+}
+
+func (r *toDoRepositoryEventStore) ListRevisions(id int64) ([]model.ToDoRevision, error) {
+	events, err := listEvents(r.conn(), id)
+	if err != nil {
+		return nil, err
+	}
+	return revisionsOf(events), nil
+}
+
+func (r *toDoRepositoryEventStore) SelectRevision(id int64, revision int64) (*model.ToDoRevision, error) {
+	revisionList, err := r.ListRevisions(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, rev := range revisionList {
+		if rev.Revision == revision {
+			return &rev, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *toDoRepositoryEventStore) Batch(operations []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+	results := make([]model.BatchResult, len(operations))
+	itemFailed := false
+	err := r.transaction(func(tx *sql.Tx) error {
+		for i, operation := range operations {
+			// best-effortの場合は失敗した操作だけを取り消せるようにする
+			if !atomic {
+				_, err := tx.Exec("SAVEPOINT batch_item")
+				if err != nil {
+					return err
+				}
+			}
+			id, err := execEventOperation(tx, operation)
+			results[i] = model.BatchResult{Id: id, Err: err}
+			if err == nil {
+				continue
+			}
+			if atomic {
+				itemFailed = true
+				return err
+			}
+			_, err = tx.Exec("ROLLBACK TO SAVEPOINT batch_item")
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && !itemFailed {
+		return nil, err
+	}
+	return results, err
+}
+
+// バッチの1件分の操作を実行し、対象のIDを返す
+func execEventOperation(tx *sql.Tx, operation model.BatchOperation) (int64, error) {
+	switch operation.Operation {
+	case "create":
+		return insertToDoEvents(tx, operation.ToDo, operation.History)
+	case "update":
+		return operation.ToDo.Id, updateToDoEvents(tx, operation.ToDo, operation.History)
+	case "delete":
+		return operation.ToDo.Id, deleteToDoEvents(tx, operation.ToDo.Id, operation.History)
+	default:
+		return 0, fmt.Errorf("unknown operation: %s", operation.Operation)
+	}
+}
+
+func insertToDoEvents(tx *sql.Tx, toDo *model.ToDo, history *model.ToDoHistory) (int64, error) {
+	// ストリームを作成してIDを払い出す
+	result, err := tx.Exec("INSERT INTO todo_stream(version, revision) VALUES ( 0, 0 )")
+	if err != nil {
+		return -1, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+	history.ToDoId = id
+	stream := &toDoStream{id: id}
+	_, err = appendEvents(tx, stream, nil, history, diffToDoEvents(nil, toDo))
+	if err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+func updateToDoEvents(tx *sql.Tx, toDo *model.ToDo, history *model.ToDoHistory) error {
+	stream, current, err := loadStream(tx, toDo.Id, true)
+	if err == sql.ErrNoRows || (err == nil && (current == nil || current.DeletedAt != nil)) {
+		return noRowsError("UPDATE FAILED")
+	}
+	if err != nil {
+		return err
+	}
+	// 値が変わらない更新は何も書き込まない(通知もしない)
+	events := diffToDoEvents(current, toDo)
+	if len(events) == 0 {
+		return nil
+	}
+	_, err = appendEvents(tx, stream, current, history, events)
+	return err
+}
+
+func deleteToDoEvents(tx *sql.Tx, id int64, history *model.ToDoHistory) error {
+	// 論理削除(ゴミ箱へ移動)
+	return appendChange(tx, id, history, "DELETE FAILED",
+		func(t *model.ToDo) bool { return t.DeletedAt == nil },
+		[]model.ToDoEvent{{Type: model.ToDoDeleted}},
+	)
+}
+
+// 条件を満たすToDoのストリームにイベントを追加する(満たさない場合はエラー)
+func appendChange(tx *sql.Tx, id int64, history *model.ToDoHistory, failedMessage string, applicable func(*model.ToDo) bool, events []model.ToDoEvent) error {
+	stream, current, err := loadStream(tx, id, true)
+	if err == sql.ErrNoRows || (err == nil && (current == nil || !applicable(current))) {
+		return noRowsError(failedMessage)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = appendEvents(tx, stream, current, history, events)
+	return err
+}
+
+// 条件を満たす全てのToDoのストリームにイベントを追加し、そのIDを返す
+// 対象は一覧から探し、ストリームをロックしてから条件を確かめ直す
+func appendAll(tx *sql.Tx, eventType string, history *model.ToDoHistory, where string, args []interface{}, applicable func(*model.ToDo) bool) ([]int64, error) {
+	ids, err := selectIds(tx, "SELECT id FROM todo_projection WHERE "+where+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	appended := []int64{}
+	for _, id := range ids {
+		stream, current, err := loadStream(tx, id, true)
+		if err == sql.ErrNoRows || (err == nil && (current == nil || !applicable(current))) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		_, err = appendEvents(tx, stream, current, historyFor(history, id), []model.ToDoEvent{{Type: eventType}})
+		if err != nil {
+			return nil, err
+		}
+		appended = append(appended, id)
+	}
+	return appended, nil
+}
+
+// ストリームの末尾にイベントを書き込み、適用後のToDoを返す
+// 1回の変更のイベントは同じリビジョンになり、変更後のToDoを一覧とoutboxに書き込む
+func appendEvents(tx *sql.Tx, stream *toDoStream, current *model.ToDo, history *model.ToDoHistory, events []model.ToDoEvent) (*model.ToDo, error) {
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	revision := stream.revision + 1
+	version := stream.version
+	toDo := current
+	for i := range events {
+		version++
+		events[i].ToDoId = stream.id
+		events[i].Version = version
+		events[i].Revision = revision
+		events[i].Operation = history.Operation
+		events[i].Actor = history.Actor
+		events[i].RequestId = history.RequestId
+		events[i].CreatedAt = createdAt
+		_, err := tx.Exec(
+			"INSERT INTO todo_event(todo_id, version, revision, event_type, title, status, done, operation, actor, request_id, created_at) VALUES ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? )",
+			events[i].ToDoId,
+			events[i].Version,
+			events[i].Revision,
+			events[i].Type,
+			events[i].Title,
+			events[i].Status,
+			events[i].Done,
+			events[i].Operation,
+			events[i].Actor,
+			events[i].RequestId,
+			events[i].CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		toDo = applyToDoEvent(toDo, &events[i])
+	}
+
+	// 値が変わらない変更はイベントにならない(リビジョンも進めない)
+	if len(events) > 0 {
+		_, err := tx.Exec(
+			"UPDATE todo_stream SET version = ?, revision = ? WHERE id = ?",
+			version,
+			revision,
+			stream.id,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if toDo != nil && version/snapshotInterval != stream.version/snapshotInterval {
+			err = saveSnapshot(tx, stream.id, version, revision, toDo, createdAt)
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = saveProjection(tx, stream.id, toDo)
+		if err != nil {
+			return nil, err
+		}
+		stream.version = version
+		stream.revision = revision
+	}
+
+	// 完全に削除した場合は削除前のToDoを通知する
+	notified := toDo
+	if notified == nil {
+		notified = current
+	}
+	if notified != nil {
+		err := writeOutbox(tx, history, notified)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return toDo, nil
+}
+
+// 読み込み時にイベントを全て適用しなくて済むよう状態を保存する
+// 過去の時点の読み込みにも使うため、古いスナップショットも残す
+func saveSnapshot(tx *sql.Tx, id int64, version int64, revision int64, toDo *model.ToDo, createdAt time.Time) error {
+	state, err := json.Marshal(newToDoColumn(toDo))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO todo_snapshot(todo_id, version, revision, state, created_at) VALUES ( ?, ?, ?, ?, ? )",
+		id,
+		version,
+		revision,
+		string(state),
+		createdAt,
+	)
+	return err
+}
+
+// 一覧の検索に使う現在のToDoを保存する(完全に削除された場合は消す)
+func saveProjection(tx *sql.Tx, id int64, toDo *model.ToDo) error {
+	if toDo == nil {
+		_, err := tx.Exec("DELETE FROM todo_projection WHERE id = ?", id)
+		return err
+	}
+	_, err := tx.Exec(
+		"REPLACE INTO todo_projection("+toDoColumns+") VALUES ( ?, ?, ?, ?, ?, ?, ?, ? )",
+		id,
+		toDo.Title,
+		toDo.Status,
+		toDo.Done,
+		toDo.CreatedAt,
+		toDo.UpdatedAt,
+		toDo.ArchivedAt,
+		toDo.DeletedAt,
+	)
+	return err
+}
+
+// ストリームの位置と現在のToDo(完全に削除された場合はnil)を読み込む
+func loadStream(q executor, id int64, forUpdate bool) (*toDoStream, *model.ToDo, error) {
+	query := "SELECT id, version, revision FROM todo_stream WHERE id = ?"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	stream := &toDoStream{}
+	err := q.QueryRow(query, id).Scan(&stream.id, &stream.version, &stream.revision)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// スナップショットがあればそれ以降のイベントだけを適用する
+	var snapshot *model.ToDo
+	var snapshotVersion int64
+	var state []byte
+	err = q.QueryRow("SELECT version, state FROM todo_snapshot WHERE todo_id = ? ORDER BY version DESC LIMIT 1", id).Scan(&snapshotVersion, &state)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return nil, nil, err
+	default:
+		column := &toDoColumn{}
+		err = json.Unmarshal(state, column)
+		if err != nil {
+			return nil, nil, err
+		}
+		snapshot = column.toModel()
+	}
+
+	rows, err := q.Query(
+		"SELECT "+eventColumns+" FROM todo_event e WHERE e.todo_id = ? AND e.version > ? ORDER BY e.version",
+		id,
+		snapshotVersion,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	events, err := scanEventList(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stream, projectToDo(snapshot, events), nil
+}
+
+// 現在のToDoの一覧から条件に合うものをID順に読み込む
+func listProjection(q executor, where string, includeArchived bool, args ...interface{}) ([]model.ToDo, error) {
+	query := "SELECT " + toDoColumns + " FROM todo_projection WHERE " + where
+	if !includeArchived {
+		query += " AND archived_at IS NULL"
+	}
+	rows, err := q.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanToDoList(rows)
+}
+
+// スナップショットの状態をIDごとのToDoに詰める
+func scanSnapshots(rows *sql.Rows) (map[int64]*model.ToDo, error) {
+	defer rows.Close()
+
+	toDoMap := map[int64]*model.ToDo{}
+	for rows.Next() {
+		var state []byte
+		err := rows.Scan(&state)
+		if err != nil {
+			return nil, err
+		}
+		column := &toDoColumn{}
+		err = json.Unmarshal(state, column)
+		if err != nil {
+			return nil, err
+		}
+		toDoMap[column.Id] = column.toModel()
+	}
+	return toDoMap, rows.Err()
+}
+
+// イベントを順にToDoへ適用し、ID順のリストにする
+func foldEvents(rows *sql.Rows, toDoMap map[int64]*model.ToDo) ([]model.ToDo, error) {
+	events, err := scanEventList(rows)
+	if err != nil {
+		return nil, err
+	}
+	for i := range events {
+		toDoMap[events[i].ToDoId] = applyToDoEvent(toDoMap[events[i].ToDoId], &events[i])
+	}
+
+	var toDoList []model.ToDo
+	for _, t := range toDoMap {
+		if t != nil {
+			toDoList = append(toDoList, *t)
+		}
+	}
+	sort.Slice(toDoList, func(i, j int) bool { return toDoList[i].Id < toDoList[j].Id })
+	return toDoList, nil
+}
+
+// ゴミ箱にないToDoを返す
+func filterToDo(toDoList []model.ToDo, includeArchived bool) []model.ToDo {
+	var filtered []model.ToDo
+	for _, t := range toDoList {
+		if t.DeletedAt != nil || (!includeArchived && t.ArchivedAt != nil) {
+			continue
+		}
+		filtered = append(filtered, t)
+	}
+	return filtered
+}
+
+// implementation of repository.ToDoIterator reading the current ToDo page by page
+type toDoProjectionIterator struct {
+	q               executor
+	done            *bool
+	includeArchived bool
+	// ToDo not returned yet in the current page
+	page   []model.ToDo
+	lastId int64
+	// no more pages
+	exhausted bool
+	toDo      *model.ToDo
+	err       error
+}
+
+func (i *toDoProjectionIterator) Next() bool {
+	if i.err != nil || (len(i.page) == 0 && !i.nextPage()) {
+		return false
+	}
+	i.toDo = &i.page[0]
+	i.page = i.page[1:]
+	return true
+}
+
+// 次のページを読み込む(残りがなければfalse)
+func (i *toDoProjectionIterator) nextPage() bool {
+	if i.exhausted {
+		return false
+	}
+	i.page, i.err = listPage(i.q, "todo_projection", i.done, i.includeArchived, i.lastId, iteratePageSize)
+	if i.err != nil {
+		return false
+	}
+	if len(i.page) < iteratePageSize {
+		i.exhausted = true
+	}
+	if len(i.page) == 0 {
+		return false
+	}
+	i.lastId = i.page[len(i.page)-1].Id
+	return true
+}
+
+func (i *toDoProjectionIterator) ToDo() *model.ToDo {
+	return i.toDo
+}
+
+func (i *toDoProjectionIterator) Err() error {
+	return i.err
+}
+
+func (i *toDoProjectionIterator) Close() error {
+	i.page = nil
+	i.exhausted = true
+	return nil
+}
+
+// ストリームの全イベントを読み込む
+func listEvents(q executor, id int64) ([]model.ToDoEvent, error) {
+	rows, err := q.Query("SELECT "+eventColumns+" FROM todo_event e WHERE e.todo_id = ? ORDER BY e.version", id)
+	if err != nil {
+		return nil, err
+	}
+	return scanEventList(rows)
+}
+
+// 全行分のレコードをイベントのリストに詰める
+func scanEventList(rows *sql.Rows) ([]model.ToDoEvent, error) {
+	defer rows.Close()
+
+	var events []model.ToDoEvent
+	for rows.Next() {
+		event := model.ToDoEvent{}
+		err := rows.Scan(
+			&event.Id,
+			&event.ToDoId,
+			&event.Version,
+			&event.Revision,
+			&event.Type,
+			&event.Title,
+			&event.Status,
+			&event.Done,
+			&event.Operation,
+			&event.Actor,
+			&event.RequestId,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,466 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
+)
+
+var eventColumnNames = []string{"id", "todo_id", "version", "revision", "event_type", "title", "status", "done", "operation", "actor", "request_id", "created_at"}
+
+func TestEventStoreInsert(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name        string
+		streamError error
+		eventError  error
+		wantId      int64
+		wantError   bool
+	}{
+		{
+			name:   "01_INSERTが成功するケース",
+			wantId: 1,
+		},
+		{
+			name:        "02_ストリームの作成が失敗するケース",
+			streamError: errors.New("INSERT FAILED"),
+			wantId:      -1,
+			wantError:   true,
+		},
+		{
+			name:       "03_イベントの書き込みが失敗するケース",
+			eventError: errors.New("INSERT FAILED"),
+			wantId:     -1,
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			toDo := &model.ToDo{Title: "test-ToDo", Status: "todo", Done: false}
+			history := &model.ToDoHistory{Operation: "create", Actor: "alice", RequestId: "req-1"}
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectBegin()
+			streamInsert := mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_stream(version, revision) VALUES ( 0, 0 )"))
+			if tt.streamError != nil {
+				streamInsert.WillReturnError(tt.streamError)
+				mock.ExpectRollback()
+			} else {
+				streamInsert.WillReturnResult(sqlmock.NewResult(1, 1))
+				eventInsert := mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_event(todo_id, version, revision, event_type, title, status, done, operation, actor, request_id, created_at) VALUES ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? )")).
+					WithArgs(1, 1, 1, model.ToDoCreated, "test-ToDo", "todo", false, "create", "alice", "req-1", sqlmock.AnyArg())
+				if tt.eventError != nil {
+					eventInsert.WillReturnError(tt.eventError)
+					mock.ExpectRollback()
+				} else {
+					eventInsert.WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec(regexp.QuoteMeta("UPDATE todo_stream SET version = ?, revision = ? WHERE id = ?")).
+						WithArgs(1, 1, 1).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectExec(regexp.QuoteMeta("REPLACE INTO todo_projection("+toDoColumns+") VALUES ( ?, ?, ?, ?, ?, ?, ?, ? )")).
+						WithArgs(1, "test-ToDo", "todo", false, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, nil).
+						WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox(event_type, completed, todo_id, payload) VALUES ( ?, ?, ?, ? )")).
+						WithArgs("created", false, 1, sqlmock.AnyArg()).
+						WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectCommit()
+				}
+			}
+			toDoRepository := NewToDoRepositoryEventStore(db)
+
+			// Act
+			id, err := toDoRepository.Insert(toDo, history)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if id != tt.wantId {
+				t.Errorf("expected: %d, actual: %d", tt.wantId, id)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestEventStoreSelectById(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	updatedAt := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		snapshot  *sqlmock.Rows
+		events    *sqlmock.Rows
+		want      *model.ToDo
+		wantError error
+	}{
+		{
+			name:     "01_イベントから組み立てるケース",
+			snapshot: sqlmock.NewRows([]string{"version", "state"}),
+			events: sqlmock.NewRows(eventColumnNames).
+				AddRow(1, 1, 1, 1, model.ToDoCreated, "test-ToDo", "todo", false, "create", "", "", createdAt).
+				AddRow(2, 1, 2, 2, model.ToDoCompleted, "", "done", false, "update", "", "", updatedAt),
+			want: &model.ToDo{Id: 1, Title: "test-ToDo", Status: "done", Done: true, CreatedAt: createdAt, UpdatedAt: updatedAt},
+		},
+		{
+			name: "02_スナップショット以降のイベントを適用するケース",
+			snapshot: sqlmock.NewRows([]string{"version", "state"}).
+				AddRow(50, `{"id":1,"title":"test-ToDo","status":"todo","done":false,"created_at":"2021-01-01T00:00:00Z","updated_at":"2021-01-01T00:00:00Z"}`),
+			events: sqlmock.NewRows(eventColumnNames).
+				AddRow(51, 1, 51, 30, model.ToDoTitleChanged, "updated-ToDo", "", false, "update", "", "", updatedAt),
+			want: &model.ToDo{Id: 1, Title: "updated-ToDo", Status: "todo", Done: false, CreatedAt: createdAt, UpdatedAt: updatedAt},
+		},
+		{
+			name:     "03_ゴミ箱にあるケース",
+			snapshot: sqlmock.NewRows([]string{"version", "state"}),
+			events: sqlmock.NewRows(eventColumnNames).
+				AddRow(1, 1, 1, 1, model.ToDoCreated, "test-ToDo", "todo", false, "create", "", "", createdAt).
+				AddRow(2, 1, 2, 2, model.ToDoDeleted, "", "", false, "delete", "", "", updatedAt),
+			wantError: sql.ErrNoRows,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			id := int64(1)
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT id, version, revision FROM todo_stream WHERE id = ?")).
+				WithArgs(id).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "version", "revision"}).AddRow(1, 2, 2))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT version, state FROM todo_snapshot WHERE todo_id = ?")).
+				WithArgs(id).
+				WillReturnRows(tt.snapshot)
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT " + eventColumns + " FROM todo_event e WHERE e.todo_id = ? AND e.version > ? ORDER BY e.version")).
+				WillReturnRows(tt.events)
+			toDoRepository := NewToDoRepositoryEventStore(db)
+
+			// Act
+			got, err := toDoRepository.SelectById(id)
+
+			// Assert
+			if err != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if tt.want != nil && (got == nil || *got != *tt.want) {
+				t.Errorf("expected: %+v, actual: %+v", tt.want, got)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestEventStoreSelectByIds(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, version, revision FROM todo_stream WHERE id = ?")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version", "revision"}).AddRow(1, 1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, state FROM todo_snapshot WHERE todo_id = ?")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "state"}))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + eventColumns + " FROM todo_event e WHERE e.todo_id = ? AND e.version > ? ORDER BY e.version")).
+		WillReturnRows(sqlmock.NewRows(eventColumnNames).
+			AddRow(1, 1, 1, 1, model.ToDoCreated, "test-ToDo", "todo", false, "create", "", "", createdAt))
+	// 存在しないIDは読み飛ばす
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, version, revision FROM todo_stream WHERE id = ?")).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version", "revision"}))
+	toDoRepository := NewToDoRepositoryEventStore(db)
+
+	// Act
+	got, err := toDoRepository.SelectByIds([]int64{1, 2})
+
+	// Assert
+	if err != nil {
+		t.Error(err.Error())
+	}
+	want := []model.ToDo{{Id: 1, Title: "test-ToDo", Status: "todo", CreatedAt: createdAt, UpdatedAt: createdAt}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected: %+v, actual: %+v", want, got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestEventStoreListAll(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		includeArchived bool
+		wantQuery       string
+	}{
+		{
+			name:            "01_アーカイブを含まないケース",
+			includeArchived: false,
+			wantQuery:       "SELECT " + toDoColumns + " FROM todo_projection WHERE deleted_at IS NULL AND archived_at IS NULL ORDER BY id",
+		},
+		{
+			name:            "02_アーカイブを含むケース",
+			includeArchived: true,
+			wantQuery:       "SELECT " + toDoColumns + " FROM todo_projection WHERE deleted_at IS NULL ORDER BY id",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			// イベントを読まずに一覧から検索する
+			mock.ExpectQuery("^" + regexp.QuoteMeta(tt.wantQuery) + "$").
+				WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).
+					AddRow(1, "ToDo01", "todo", false, createdAt, createdAt, nil, nil))
+			toDoRepository := NewToDoRepositoryEventStore(db)
+
+			// Act
+			toDoList, err := toDoRepository.ListAll(tt.includeArchived)
+
+			// Assert
+			if err != nil {
+				t.Error(err.Error())
+			}
+			want := []model.ToDo{{Id: 1, Title: "ToDo01", Status: "todo", CreatedAt: createdAt, UpdatedAt: createdAt}}
+			if !reflect.DeepEqual(toDoList, want) {
+				t.Errorf("expected: %+v, actual: %+v", want, toDoList)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestEventStoreArchiveDoneBefore(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	updatedAt := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM todo_projection WHERE done = true AND updated_at < ? AND archived_at IS NULL AND deleted_at IS NULL ORDER BY id")).
+		WithArgs(before).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	streams := map[int64]*sqlmock.Rows{
+		1: sqlmock.NewRows(eventColumnNames).
+			AddRow(1, 1, 1, 1, model.ToDoCreated, "ToDo01", "todo", false, "create", "", "", createdAt).
+			AddRow(2, 1, 2, 2, model.ToDoCompleted, "", "done", false, "update", "", "", updatedAt),
+		// 一覧を読んだ後に未完了に戻されている
+		2: sqlmock.NewRows(eventColumnNames).
+			AddRow(3, 2, 1, 1, model.ToDoCreated, "ToDo02", "todo", false, "create", "", "", createdAt).
+			AddRow(4, 2, 2, 2, model.ToDoCompleted, "", "done", false, "update", "", "", updatedAt).
+			AddRow(5, 2, 3, 3, model.ToDoReopened, "", "todo", false, "update", "", "", before.Add(time.Hour)),
+	}
+	for id := int64(1); id <= 2; id++ {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id, version, revision FROM todo_stream WHERE id = ? FOR UPDATE")).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "version", "revision"}).AddRow(id, 1+id, 1+id))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT version, state FROM todo_snapshot WHERE todo_id = ? ORDER BY version DESC LIMIT 1")).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"version", "state"}))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT "+eventColumns+" FROM todo_event e WHERE e.todo_id = ? AND e.version > ? ORDER BY e.version")).
+			WithArgs(id, 0).
+			WillReturnRows(streams[id])
+		if id == 2 {
+			break
+		}
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_event(todo_id, version, revision, event_type, title, status, done, operation, actor, request_id, created_at) VALUES ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? )")).
+			WithArgs(1, 3, 3, model.ToDoArchived, "", "", false, "archive", "", "", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(6, 1))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE todo_stream SET version = ?, revision = ? WHERE id = ?")).
+			WithArgs(3, 3, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("REPLACE INTO todo_projection("+toDoColumns+") VALUES ( ?, ?, ?, ?, ?, ?, ?, ? )")).
+			WithArgs(1, "ToDo01", "done", true, createdAt, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox(event_type, completed, todo_id, payload) VALUES ( ?, ?, ?, ? )")).
+			WithArgs("updated", false, 1, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+	toDoRepository := NewToDoRepositoryEventStore(db)
+	history := &model.ToDoHistory{Operation: "archive", Changes: []model.FieldChange{{Field: "archived", Before: false, After: true}}}
+
+	// Act
+	archived, err := toDoRepository.ArchiveDoneBefore(before, history)
+
+	// Assert
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if archived != 1 {
+		t.Errorf("expected: 1, actual: %d", archived)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestEventStoreListAsOf(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	asOf := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		includeArchived bool
+		wantIds         []int64
+	}{
+		{
+			name:            "01_アーカイブを含まないケース",
+			includeArchived: false,
+			wantIds:         []int64{1},
+		},
+		{
+			name:            "02_アーカイブを含むケース",
+			includeArchived: true,
+			wantIds:         []int64{1, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			// ToDo03はasOf時点で最新のスナップショットから組み立てる
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT s.state FROM todo_snapshot s JOIN (SELECT todo_id, MAX(version) AS version FROM todo_snapshot WHERE created_at <= ? GROUP BY todo_id) l ON l.todo_id = s.todo_id AND l.version = s.version")).
+				WithArgs(asOf).
+				WillReturnRows(sqlmock.NewRows([]string{"state"}).
+					AddRow(`{"id":3,"title":"ToDo03","status":"todo","done":false,"created_at":"2021-01-01T00:00:00Z","updated_at":"2021-01-01T00:00:00Z"}`))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT "+eventColumns+" FROM todo_event e LEFT JOIN (SELECT todo_id, MAX(version) AS version FROM todo_snapshot WHERE created_at <= ? GROUP BY todo_id) l ON l.todo_id = e.todo_id WHERE e.created_at <= ? AND e.version > COALESCE(l.version, 0) ORDER BY e.todo_id, e.version")).
+				WithArgs(asOf, asOf).
+				WillReturnRows(sqlmock.NewRows(eventColumnNames).
+					AddRow(1, 1, 1, 1, model.ToDoCreated, "ToDo01", "todo", false, "create", "", "", createdAt).
+					AddRow(2, 2, 1, 1, model.ToDoCreated, "ToDo02", "todo", false, "create", "", "", createdAt).
+					AddRow(3, 2, 2, 2, model.ToDoDeleted, "", "", false, "delete", "", "", createdAt).
+					AddRow(55, 3, 51, 40, model.ToDoArchived, "", "", false, "archive", "", "", createdAt))
+			toDoRepository := NewToDoRepositoryEventStore(db).(repository.TemporalToDoRepository)
+
+			// Act
+			toDoList, err := toDoRepository.ListAsOf(asOf, tt.includeArchived)
+
+			// Assert
+			if err != nil {
+				t.Error(err.Error())
+			}
+			ids := []int64{}
+			for _, toDo := range toDoList {
+				ids = append(ids, toDo.Id)
+			}
+			if len(ids) != len(tt.wantIds) {
+				t.Fatalf("expected: %v, actual: %v", tt.wantIds, ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIds[i] {
+					t.Errorf("expected: %v, actual: %v", tt.wantIds, ids)
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestEventStoreIterate(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	done := false
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+toDoColumns+" FROM todo_projection WHERE id > ? AND deleted_at IS NULL AND done = ? AND archived_at IS NULL ORDER BY id LIMIT ?")).
+		WithArgs(0, false, iteratePageSize).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).
+			AddRow(1, "ToDo01", "todo", false, createdAt, createdAt, nil, nil).
+			AddRow(3, "ToDo03", "todo", false, createdAt, createdAt, nil, nil))
+	toDoRepository := NewToDoRepositoryEventStore(db)
+
+	// Act
+	ids := []int64{}
+	iterator, err := toDoRepository.Iterate(&done, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for iterator.Next() {
+		ids = append(ids, iterator.ToDo().Id)
+	}
+	iterator.Close()
+
+	// Assert
+	if err := iterator.Err(); err != nil {
+		t.Error(err.Error())
+	}
+	// 1ページに満たないので次のページは読まない
+	if !reflect.DeepEqual(ids, []int64{1, 3}) {
+		t.Errorf("expected: [1 3], actual: %v", ids)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err.Error())
+	}
+}
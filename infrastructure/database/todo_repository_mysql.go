@@ -2,31 +2,51 @@ package database
 
 import (
 	"database/sql"
-	"errors"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
 	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
 )
 
+// columns of the todo table read into model.ToDo
+const toDoColumns = "id, title, status, done, created_at, updated_at, archived_at, deleted_at"
+
+// columns of the todo_revision table read into model.ToDoRevision
+const revisionColumns = "todo_id, revision, title, status, done, archived, created_at"
+
 // implementation of repository
 type toDoRepositoryMySQL struct {
+	connection
+}
+
+// Database shared by the repositories
+type connection struct {
 	db *sql.DB
+	// set when the repository is used in a unit of work
+	tx *sql.Tx
+}
+
+// *sql.DB or *sql.Tx
+type executor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 func NewToDoRepositoryMySQL(db *sql.DB) repository.ToDoRepository {
-	return &toDoRepositoryMySQL{db: db}
+	return &toDoRepositoryMySQL{connection{db: db}}
 }
 
-func (r *toDoRepositoryMySQL) Insert(model *model.ToDo) (int64, error) {
-	result, err := r.db.Exec(
-		"INSERT INTO todo(title, done) VALUES ( ?, ? )",
-		model.Title,
-		model.Done,
-	)
-	if err != nil {
-		return -1, err
-	}
-	id, err := result.LastInsertId()
+func (r *toDoRepositoryMySQL) Insert(model *model.ToDo, history *model.ToDoHistory) (int64, error) {
+	var id int64
+	err := r.transaction(func(tx *sql.Tx) error {
+		var err error
+		id, err = insertToDo(tx, model, history)
+		return err
+	})
 	if err != nil {
 		return -1, err
 	}
@@ -34,30 +54,486 @@ func (r *toDoRepositoryMySQL) Insert(model *model.ToDo) (int64, error) {
 }
 
 func (todoDB *toDoRepositoryMySQL) SelectById(id int64) (*model.ToDo, error) {
-	todo := &model.ToDo{}
-	err := todoDB.db.QueryRow(
-		"SELECT id, title, done, created_at, updated_at FROM todo WHERE id = ?",
+	return scanToDo(todoDB.conn().QueryRow(
+		"SELECT "+toDoColumns+" FROM todo WHERE id = ? AND deleted_at IS NULL",
 		id,
-	).Scan(
-		&todo.Id,
-		&todo.Title,
-		&todo.Done,
-		&todo.CreatedAt,
-		&todo.UpdatedAt,
+	))
+}
+
+func (todoDB *toDoRepositoryMySQL) SelectByIds(ids []int64) ([]model.ToDo, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	placeholders, args := inClause(ids)
+	rows, err := todoDB.conn().Query(
+		"SELECT "+toDoColumns+" FROM todo WHERE id IN ("+placeholders+") AND deleted_at IS NULL ORDER BY id",
+		args...,
 	)
 	if err != nil {
 		return nil, err
 	}
-	return todo, nil
+	return scanToDoList(rows)
+}
+
+func (todoDB *toDoRepositoryMySQL) SelectByIdForUpdate(id int64) (*model.ToDo, error) {
+	// トランザクションが終わるまで他の更新を待たせる
+	return scanToDo(todoDB.conn().QueryRow(
+		"SELECT "+toDoColumns+" FROM todo WHERE id = ? AND deleted_at IS NULL FOR UPDATE",
+		id,
+	))
+}
+
+func (todoDB *toDoRepositoryMySQL) Update(model *model.ToDo, history *model.ToDoHistory) error {
+	return todoDB.transaction(func(tx *sql.Tx) error {
+		return updateToDo(tx, model, history)
+	})
+}
+
+func (todoDB *toDoRepositoryMySQL) DeleteById(id int64, history *model.ToDoHistory) error {
+	return todoDB.transaction(func(tx *sql.Tx) error {
+		return deleteToDo(tx, id, history)
+	})
+}
+
+func (todoDB *toDoRepositoryMySQL) ListAll(includeArchived bool) ([]model.ToDo, error) {
+	query := "SELECT " + toDoColumns + " FROM todo WHERE deleted_at IS NULL"
+	if !includeArchived {
+		query += " AND archived_at IS NULL"
+	}
+	rows, err := todoDB.conn().Query(query)
+	if err != nil {
+		return nil, err
+	}
+	return scanToDoList(rows)
+}
+
+func (todoDB *toDoRepositoryMySQL) ListFilteredByDone(done bool, includeArchived bool) ([]model.ToDo, error) {
+	query := "SELECT " + toDoColumns + " FROM todo WHERE done = ? AND deleted_at IS NULL"
+	if !includeArchived {
+		query += " AND archived_at IS NULL"
+	}
+	rows, err := todoDB.conn().Query(query, done)
+	if err != nil {
+		return nil, err
+	}
+	return scanToDoList(rows)
+}
+
+func (todoDB *toDoRepositoryMySQL) ListDeleted() ([]model.ToDo, error) {
+	rows, err := todoDB.conn().Query("SELECT " + toDoColumns + " FROM todo WHERE deleted_at IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	return scanToDoList(rows)
+}
+
+func (todoDB *toDoRepositoryMySQL) Iterate(done *bool, includeArchived bool) (repository.ToDoIterator, error) {
+	where, args := toDoCondition(done, includeArchived)
+	rows, err := todoDB.conn().Query("SELECT "+toDoColumns+" FROM todo WHERE "+where+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	return &toDoRowIterator{rows: rows}, nil
+}
+
+func (todoDB *toDoRepositoryMySQL) ListPage(done *bool, includeArchived bool, afterId int64, limit int) ([]model.ToDo, error) {
+	return listPage(todoDB.conn(), "todo", done, includeArchived, afterId, limit)
+}
+
+func (todoDB *toDoRepositoryMySQL) Count(done *bool, includeArchived bool) (int64, error) {
+	return countToDo(todoDB.conn(), "todo", done, includeArchived)
+}
+
+func (todoDB *toDoRepositoryMySQL) Restore(id int64, history *model.ToDoHistory) error {
+	return todoDB.execWithHistory(
+		history,
+		"RESTORE FAILED",
+		"UPDATE todo SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL",
+		id,
+	)
+}
+
+func (todoDB *toDoRepositoryMySQL) PurgeDeletedBefore(before time.Time, history *model.ToDoHistory) (int64, error) {
+	var purged int64
+	err := todoDB.transaction(func(tx *sql.Tx) error {
+		// 削除前のToDoを通知するため、IDだけでなく行ごと読む
+		rows, err := tx.Query("SELECT "+toDoColumns+" FROM todo WHERE deleted_at IS NOT NULL AND deleted_at < ? ORDER BY id FOR UPDATE", before)
+		if err != nil {
+			return err
+		}
+		toDoList, err := scanToDoList(rows)
+		if err != nil || len(toDoList) == 0 {
+			return err
+		}
+		ids := make([]int64, len(toDoList))
+		for i := range toDoList {
+			ids[i] = toDoList[i].Id
+		}
+		placeholders, args := inClause(ids)
+		_, err = tx.Exec("DELETE FROM todo WHERE id IN ("+placeholders+")", args...)
+		if err != nil {
+			return err
+		}
+		err = deleteUids(tx, ids)
+		if err != nil {
+			return err
+		}
+		// 削除したToDoの履歴は残す
+		for i := range toDoList {
+			h := historyFor(history, toDoList[i].Id)
+			err = insertHistory(tx, h)
+			if err != nil {
+				return err
+			}
+			err = writeOutbox(tx, h, &toDoList[i])
+			if err != nil {
+				return err
+			}
+		}
+		purged = int64(len(ids))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+func (todoDB *toDoRepositoryMySQL) Archive(id int64, history *model.ToDoHistory) error {
+	return todoDB.execWithHistory(
+		history,
+		"ARCHIVE FAILED",
+		"UPDATE todo SET archived_at = CURRENT_TIMESTAMP WHERE id = ? AND archived_at IS NULL AND deleted_at IS NULL",
+		id,
+	)
+}
+
+func (todoDB *toDoRepositoryMySQL) Unarchive(id int64, history *model.ToDoHistory) error {
+	return todoDB.execWithHistory(
+		history,
+		"UNARCHIVE FAILED",
+		"UPDATE todo SET archived_at = NULL WHERE id = ? AND archived_at IS NOT NULL AND deleted_at IS NULL",
+		id,
+	)
+}
+
+func (todoDB *toDoRepositoryMySQL) ArchiveDoneBefore(before time.Time, history *model.ToDoHistory) (int64, error) {
+	var archived int64
+	err := todoDB.transaction(func(tx *sql.Tx) error {
+		ids, err := selectIds(tx, "SELECT id FROM todo WHERE done = true AND updated_at < ? AND archived_at IS NULL AND deleted_at IS NULL ORDER BY id FOR UPDATE", before)
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		placeholders, args := inClause(ids)
+		_, err = tx.Exec("UPDATE todo SET archived_at = CURRENT_TIMESTAMP WHERE id IN ("+placeholders+")", args...)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			err = record(tx, historyFor(history, id))
+			if err != nil {
+				return err
+			}
+		}
+		archived = int64(len(ids))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return archived, nil
+}
+
+func (todoDB *toDoRepositoryMySQL) ListHistory(id int64) ([]model.ToDoHistory, error) {
+	rows, err := todoDB.conn().Query(
+		"SELECT id, todo_id, operation, changes, actor, request_id, created_at FROM todo_history WHERE todo_id = ? ORDER BY id",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var historyList []model.ToDoHistory
+	for rows.Next() {
+		history := model.ToDoHistory{}
+		var changes []byte
+		err := rows.Scan(
+			&history.Id,
+			&history.ToDoId,
+			&history.Operation,
+			&changes,
+			&history.Actor,
+			&history.RequestId,
+			&history.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		var columns []changeColumn
+		err = json.Unmarshal(changes, &columns)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range columns {
+			history.Changes = append(history.Changes, model.FieldChange{Field: c.Field, Before: c.Before, After: c.After})
+		}
+		historyList = append(historyList, history)
+	}
+	return historyList, rows.Err()
+}
+
+func (todoDB *toDoRepositoryMySQL) ListRevisions(id int64) ([]model.ToDoRevision, error) {
+	rows, err := todoDB.conn().Query(
+		"SELECT "+revisionColumns+" FROM todo_revision WHERE todo_id = ? ORDER BY revision",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisionList []model.ToDoRevision
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisionList = append(revisionList, *revision)
+	}
+	return revisionList, rows.Err()
+}
+
+func (todoDB *toDoRepositoryMySQL) SelectRevision(id int64, revision int64) (*model.ToDoRevision, error) {
+	return scanRevision(todoDB.conn().QueryRow(
+		"SELECT "+revisionColumns+" FROM todo_revision WHERE todo_id = ? AND revision = ?",
+		id,
+		revision,
+	))
+}
+
+func (todoDB *toDoRepositoryMySQL) Batch(operations []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+	results := make([]model.BatchResult, len(operations))
+	itemFailed := false
+	err := todoDB.transaction(func(tx *sql.Tx) error {
+		for i, operation := range operations {
+			// best-effortの場合は失敗した操作だけを取り消せるようにする
+			if !atomic {
+				_, err := tx.Exec("SAVEPOINT batch_item")
+				if err != nil {
+					return err
+				}
+			}
+			id, err := execOperation(tx, operation)
+			results[i] = model.BatchResult{Id: id, Err: err}
+			if err == nil {
+				continue
+			}
+			if atomic {
+				itemFailed = true
+				return err
+			}
+			_, err = tx.Exec("ROLLBACK TO SAVEPOINT batch_item")
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && !itemFailed {
+		return nil, err
+	}
+	return results, err
+}
+
+// クエリを実行する接続(UnitOfWorkの中ではそのトランザクション)
+func (r *connection) conn() executor {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// トランザクション内でfnを実行し、エラーがあればロールバックする
+// UnitOfWorkの中ではそのトランザクションで実行し、コミットとロールバックはUnitOfWorkに任せる
+func (r *connection) transaction(fn func(tx *sql.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// 一覧を絞り込むWHERE句と引数(ゴミ箱のToDoは含めない)
+func toDoCondition(done *bool, includeArchived bool) (string, []interface{}) {
+	where := "deleted_at IS NULL"
+	args := []interface{}{}
+	if done != nil {
+		where += " AND done = ?"
+		args = append(args, *done)
+	}
+	if !includeArchived {
+		where += " AND archived_at IS NULL"
+	}
+	return where, args
+}
+
+// IDがafterIdより大きいToDoをIDの順にlimit件まで読む(OFFSETのように前のページの行を読み飛ばさない)
+func listPage(q executor, table string, done *bool, includeArchived bool, afterId int64, limit int) ([]model.ToDo, error) {
+	where, args := toDoCondition(done, includeArchived)
+	args = append([]interface{}{afterId}, args...)
+	rows, err := q.Query("SELECT "+toDoColumns+" FROM "+table+" WHERE id > ? AND "+where+" ORDER BY id LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	return scanToDoList(rows)
+}
+
+func countToDo(q executor, table string, done *bool, includeArchived bool) (int64, error) {
+	where, args := toDoCondition(done, includeArchived)
+	var count int64
+	err := q.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE "+where, args...).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// IN句のプレースホルダと引数
+func inClause(ids []int64) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.Repeat(", ?", len(ids))[2:], args
+}
+
+// 条件に合うToDoのIDを読む(FOR UPDATEを付けるとトランザクションが終わるまで他の更新を待たせる)
+func selectIds(tx *sql.Tx, query string, args ...interface{}) ([]int64, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// まとめて変更したToDoそれぞれの履歴
+func historyFor(history *model.ToDoHistory, id int64) *model.ToDoHistory {
+	h := *history
+	h.ToDoId = id
+	return &h
 }
 
-func (todoDB *toDoRepositoryMySQL) Update(model *model.ToDo) error {
-	result, err := todoDB.db.Exec(
-		"UPDATE todo SET title = ?, done = ? WHERE id = ?",
+// 1件のレコードを更新し、同じトランザクションで履歴を書き込む
+func (r *toDoRepositoryMySQL) execWithHistory(history *model.ToDoHistory, failedMessage string, query string, args ...interface{}) error {
+	return r.transaction(func(tx *sql.Tx) error {
+		return execOne(tx, history, failedMessage, query, args...)
+	})
+}
+
+// バッチの1件分の操作を実行し、対象のIDを返す
+func execOperation(tx *sql.Tx, operation model.BatchOperation) (int64, error) {
+	switch operation.Operation {
+	case "create":
+		return insertToDo(tx, operation.ToDo, operation.History)
+	case "update":
+		return operation.ToDo.Id, updateToDo(tx, operation.ToDo, operation.History)
+	case "delete":
+		return operation.ToDo.Id, deleteToDo(tx, operation.ToDo.Id, operation.History)
+	default:
+		return 0, fmt.Errorf("unknown operation: %s", operation.Operation)
+	}
+}
+
+func insertToDo(tx *sql.Tx, model *model.ToDo, history *model.ToDoHistory) (int64, error) {
+	result, err := tx.Exec(
+		"INSERT INTO todo(title, status, done) VALUES ( ?, ?, ? )",
+		model.Title,
+		model.Status,
+		model.Done,
+	)
+	if err != nil {
+		return -1, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+	history.ToDoId = id
+	return id, record(tx, history)
+}
+
+func updateToDo(tx *sql.Tx, model *model.ToDo, history *model.ToDoHistory) error {
+	current, err := scanToDo(tx.QueryRow(
+		"SELECT "+toDoColumns+" FROM todo WHERE id = ? AND deleted_at IS NULL FOR UPDATE",
+		model.Id,
+	))
+	if err == sql.ErrNoRows {
+		return noRowsError("UPDATE FAILED")
+	}
+	if err != nil {
+		return err
+	}
+	// イベントストアと同じく、値が変わらない更新は履歴もリビジョンも通知も書き込まない
+	if len(diffToDoEvents(current, model)) == 0 {
+		return nil
+	}
+	return execOne(
+		tx,
+		history,
+		"UPDATE FAILED",
+		"UPDATE todo SET title = ?, status = ?, done = ?, archived_at = ? WHERE id = ? AND deleted_at IS NULL",
 		model.Title,
+		model.Status,
 		model.Done,
+		model.ArchivedAt,
 		model.Id,
 	)
+}
+
+func deleteToDo(tx *sql.Tx, id int64, history *model.ToDoHistory) error {
+	// 論理削除(ゴミ箱へ移動)
+	return execOne(
+		tx,
+		history,
+		"DELETE FAILED",
+		"UPDATE todo SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL",
+		id,
+	)
+}
+
+// Error when no ToDo matches the condition of the change, errors.Is reports it as sql.ErrNoRows
+type noRowsError string
+
+func (e noRowsError) Error() string {
+	return string(e)
+}
+
+func (e noRowsError) Unwrap() error {
+	return sql.ErrNoRows
+}
+
+// 1件のレコードを更新して履歴を書き込む(1件以外が更新された場合はエラー)
+func execOne(tx *sql.Tx, history *model.ToDoHistory, failedMessage string, query string, args ...interface{}) error {
+	result, err := tx.Exec(query, args...)
 	if err != nil {
 		return err
 	}
@@ -66,78 +542,206 @@ func (todoDB *toDoRepositoryMySQL) Update(model *model.ToDo) error {
 		return err
 	}
 	if affected != 1 {
-		return errors.New("UPDATE FAILED")
+		return noRowsError(failedMessage)
 	}
-	return nil
+	return record(tx, history)
 }
 
-func (todoDB *toDoRepositoryMySQL) DeleteById(id int64) error {
-	result, err := todoDB.db.Exec("DELETE FROM todo WHERE id = ?", id)
+// 変更の履歴と変更後のリビジョン、通知するイベントを書き込む
+func record(tx *sql.Tx, history *model.ToDoHistory) error {
+	err := insertHistory(tx, history)
 	if err != nil {
 		return err
 	}
-	affected, err := result.RowsAffected()
+	err = insertRevision(tx, history.ToDoId)
 	if err != nil {
 		return err
 	}
-	if affected != 1 {
-		return errors.New("UPDATE FAILED")
+	return insertOutbox(tx, history)
+}
+
+// JSON representation of model.FieldChange in the changes column
+type changeColumn struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+func insertHistory(tx *sql.Tx, history *model.ToDoHistory) error {
+	columns := []changeColumn{}
+	for _, c := range history.Changes {
+		columns = append(columns, changeColumn{Field: c.Field, Before: c.Before, After: c.After})
+	}
+	changes, err := json.Marshal(columns)
+	if err != nil {
+		return err
 	}
-	return nil
+	_, err = tx.Exec(
+		"INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )",
+		history.ToDoId,
+		history.Operation,
+		string(changes),
+		history.Actor,
+		history.RequestId,
+	)
+	return err
 }
 
-func (todoDB *toDoRepositoryMySQL) ListAll() ([]model.ToDo, error) {
-	var rows *sql.Rows
-	var err error
-	var toDoList []model.ToDo
+// 現在のToDoを次の番号のリビジョンとして保存する
+func insertRevision(tx *sql.Tx, id int64) error {
+	_, err := tx.Exec(
+		"INSERT INTO todo_revision(todo_id, revision, title, status, done, archived) "+
+			"SELECT id, (SELECT COALESCE(MAX(revision), 0) + 1 FROM todo_revision WHERE todo_id = ?), title, status, done, archived_at IS NOT NULL FROM todo WHERE id = ?",
+		id,
+		id,
+	)
+	return err
+}
+
+// event type of each operation in the history
+var outboxEventTypes = map[string]string{
+	"create":    "created",
+	"restore":   "created",
+	"update":    "updated",
+	"archive":   "updated",
+	"unarchive": "updated",
+	"revert":    "updated",
+	"delete":    "deleted",
+	"purge":     "deleted",
+}
 
-	rows, err = todoDB.db.Query("SELECT id, title, done, created_at, updated_at FROM todo")
+// 変更後のToDoをイベントとしてoutboxに書き込む(リレーがコミット後に配信する)
+func insertOutbox(tx *sql.Tx, history *model.ToDoHistory) error {
+	if _, ok := outboxEventTypes[history.Operation]; !ok {
+		return fmt.Errorf("unknown operation: %s", history.Operation)
+	}
+	toDo, err := scanToDo(tx.QueryRow("SELECT "+toDoColumns+" FROM todo WHERE id = ?", history.ToDoId))
 	if err != nil {
-		return nil, err
+		return err
 	}
+	return writeOutbox(tx, history, toDo)
+}
 
-	for rows.Next() {
-		todo := model.ToDo{}
-		err := rows.Scan(
-			&todo.Id,
-			&todo.Title,
-			&todo.Done,
-			&todo.CreatedAt,
-			&todo.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		toDoList = append(toDoList, todo)
+// 変更後のToDoをoutboxに書き込む
+func writeOutbox(tx *sql.Tx, history *model.ToDoHistory, toDo *model.ToDo) error {
+	eventType, ok := outboxEventTypes[history.Operation]
+	if !ok {
+		return fmt.Errorf("unknown operation: %s", history.Operation)
+	}
+	payload, err := json.Marshal(newToDoColumn(toDo))
+	if err != nil {
+		return err
 	}
+	_, err = tx.Exec(
+		"INSERT INTO outbox(event_type, completed, todo_id, payload) VALUES ( ?, ?, ?, ? )",
+		eventType,
+		eventType == "updated" && completes(history),
+		history.ToDoId,
+		string(payload),
+	)
+	return err
+}
 
-	return toDoList, nil
+// 未完了から完了に変わる変更かどうか
+func completes(history *model.ToDoHistory) bool {
+	for _, change := range history.Changes {
+		if change.Field == "done" && change.After == true {
+			return true
+		}
+	}
+	return false
 }
 
-func (todoDB *toDoRepositoryMySQL) ListFilteredByDone(done bool) ([]model.ToDo, error) {
-	var rows *sql.Rows
-	var err error
-	var toDoList []model.ToDo
+// *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
 
-	rows, err = todoDB.db.Query("SELECT id, title, done, created_at, updated_at FROM todo WHERE done = ?", done)
+// 1行分のレコードをToDoに詰める
+func scanToDo(row scanner) (*model.ToDo, error) {
+	todo := &model.ToDo{}
+	var archivedAt, deletedAt sql.NullTime
+	err := row.Scan(
+		&todo.Id,
+		&todo.Title,
+		&todo.Status,
+		&todo.Done,
+		&todo.CreatedAt,
+		&todo.UpdatedAt,
+		&archivedAt,
+		&deletedAt,
+	)
 	if err != nil {
 		return nil, err
 	}
+	if archivedAt.Valid {
+		todo.ArchivedAt = &archivedAt.Time
+	}
+	if deletedAt.Valid {
+		todo.DeletedAt = &deletedAt.Time
+	}
+	return todo, nil
+}
 
+// 全行分のレコードをToDoのリストに詰める
+func scanToDoList(rows *sql.Rows) ([]model.ToDo, error) {
+	defer rows.Close()
+
+	var toDoList []model.ToDo
 	for rows.Next() {
-		todo := model.ToDo{}
-		err := rows.Scan(
-			&todo.Id,
-			&todo.Title,
-			&todo.Done,
-			&todo.CreatedAt,
-			&todo.UpdatedAt,
-		)
+		todo, err := scanToDo(rows)
 		if err != nil {
 			return nil, err
 		}
-		toDoList = append(toDoList, todo)
+		toDoList = append(toDoList, *todo)
 	}
+	return toDoList, rows.Err()
+}
+
+// implementation of repository.ToDoIterator reading the rows one by one
+type toDoRowIterator struct {
+	rows *sql.Rows
+	toDo *model.ToDo
+	err  error
+}
 
-	return toDoList, nil
+func (i *toDoRowIterator) Next() bool {
+	if i.err != nil || !i.rows.Next() {
+		return false
+	}
+	i.toDo, i.err = scanToDo(i.rows)
+	return i.err == nil
+}
+
+func (i *toDoRowIterator) ToDo() *model.ToDo {
+	return i.toDo
+}
+
+func (i *toDoRowIterator) Err() error {
+	if i.err != nil {
+		return i.err
+	}
+	return i.rows.Err()
+}
+
+func (i *toDoRowIterator) Close() error {
+	return i.rows.Close()
+}
+
+// 1行分のレコードをリビジョンに詰める
+func scanRevision(row scanner) (*model.ToDoRevision, error) {
+	revision := &model.ToDoRevision{}
+	err := row.Scan(
+		&revision.ToDoId,
+		&revision.Revision,
+		&revision.Title,
+		&revision.Status,
+		&revision.Done,
+		&revision.Archived,
+		&revision.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
 }
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -56,48 +58,993 @@ func TestInsert(t *testing.T) {
 
 			// Arrange
 			toDoModel := &model.ToDo{
-				Title: "test-ToDo",
-				Done:  false,
+				Title:  "test-ToDo",
+				Status: "todo",
+				Done:   false,
 			}
 			db, mock, err := sqlmock.New()
 			if err != nil {
 				t.Error(err.Error())
 			}
 			defer db.Close()
-			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo(title, done) VALUES ( ?, ? )")).
-				WithArgs(toDoModel.Title, toDoModel.Done).
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo(title, status, done) VALUES ( ?, ?, ? )")).
+				WithArgs(toDoModel.Title, toDoModel.Status, toDoModel.Done).
 				WillReturnResult(tt.execResult).
 				WillReturnError(tt.execError)
+			if tt.wantError {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_revision(todo_id, revision, title, status, done, archived)")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				expectOutbox(mock)
+				mock.ExpectCommit()
+			}
+			toDoRepository := NewToDoRepositoryMySQL(db)
+
+			// Act
+			_, err = toDoRepository.Insert(toDoModel, &model.ToDoHistory{Operation: "create"})
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestSelectById(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name      string
+		queryRow  *sqlmock.Rows
+		wantError bool
+	}{
+		{
+			name:      "01_SELECTが成功するケース",
+			queryRow:  sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(1, "test-ToDo", "todo", false, time.Now(), time.Now(), nil, nil),
+			wantError: false,
+		},
+		{
+			name:      "02_Scanが失敗するケース",
+			queryRow:  sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}),
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			id := int64(100)
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE id = ? AND deleted_at IS NULL")).
+				WithArgs(id).
+				WillReturnRows(tt.queryRow)
+			toDoRepository := NewToDoRepositoryMySQL(db)
+
+			// Act
+			_, err = toDoRepository.SelectById(id)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestSelectByIds(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	columns := []string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}
+	tests := []struct {
+		name      string
+		ids       []int64
+		query     bool
+		rows      *sqlmock.Rows
+		want      int
+		wantError bool
+	}{
+		{
+			name:  "01_まとめてSELECTするケース",
+			ids:   []int64{1, 2, 3},
+			query: true,
+			rows:  sqlmock.NewRows(columns).AddRow(1, "test-ToDo", "todo", false, time.Now(), time.Now(), nil, nil).AddRow(3, "test-ToDo", "done", true, time.Now(), time.Now(), nil, nil),
+			want:  2,
+		},
+		{
+			name: "02_IDがないケース",
+			ids:  nil,
+			want: 0,
+		},
+		{
+			name:      "03_Scanが失敗するケース",
+			ids:       []int64{1},
+			query:     true,
+			rows:      sqlmock.NewRows(columns).AddRow(1, "test-ToDo", "todo", "not a bool", time.Now(), time.Now(), nil, nil),
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			if tt.query {
+				placeholders := strings.Repeat(", ?", len(tt.ids))[2:]
+				args := []driver.Value{}
+				for _, id := range tt.ids {
+					args = append(args, id)
+				}
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE id IN (" + placeholders + ") AND deleted_at IS NULL ORDER BY id")).
+					WithArgs(args...).
+					WillReturnRows(tt.rows)
+			}
+			toDoRepository := NewToDoRepositoryMySQL(db)
+
+			// Act
+			got, err := toDoRepository.SelectByIds(tt.ids)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if !tt.wantError && len(got) != tt.want {
+				t.Errorf("expected: %d, actual: %+v", tt.want, got)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestSelectByIdForUpdate(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name      string
+		queryRow  *sqlmock.Rows
+		wantError bool
+	}{
+		{
+			name:      "01_SELECTが成功するケース",
+			queryRow:  sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(1, "test-ToDo", "todo", false, time.Now(), time.Now(), nil, nil),
+			wantError: false,
+		},
+		{
+			name:      "02_Scanが失敗するケース",
+			queryRow:  sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}),
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			id := int64(100)
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE id = ? AND deleted_at IS NULL FOR UPDATE")).
+				WithArgs(id).
+				WillReturnRows(tt.queryRow)
+			toDoRepository := NewToDoRepositoryMySQL(db)
+
+			// Act
+			_, err = toDoRepository.SelectByIdForUpdate(id)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name          string
+		execError     error
+		execResult    driver.Result
+		historyError  error
+		revisionError error
+		wantError     bool
+	}{
+		{
+			name:       "01_UPDATEが成功するケース",
+			execError:  nil,
+			execResult: sqlmock.NewResult(1, 1),
+			wantError:  false,
+		},
+		{
+			name:       "02_UPDATEが失敗するケース",
+			execError:  errors.New("UPDATE FAILED"),
+			execResult: sqlmock.NewErrorResult(errors.New("ERROR RESULT")),
+			wantError:  true,
+		},
+		{
+			name:       "03_RowsAffected()が失敗するケース",
+			execError:  nil,
+			execResult: sqlmock.NewErrorResult(errors.New("ERROR RESULT")),
+			wantError:  true,
+		},
+		{
+			name:       "04_RowsAffected()で1以外が返るケース",
+			execError:  nil,
+			execResult: sqlmock.NewResult(0, 0),
+			wantError:  true,
+		},
+		{
+			name:         "05_履歴の書き込みが失敗するケース",
+			execError:    nil,
+			execResult:   sqlmock.NewResult(1, 1),
+			historyError: errors.New("INSERT FAILED"),
+			wantError:    true,
+		},
+		{
+			name:          "06_リビジョンの書き込みが失敗するケース",
+			execError:     nil,
+			execResult:    sqlmock.NewResult(1, 1),
+			revisionError: errors.New("INSERT FAILED"),
+			wantError:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			toDoModel := &model.ToDo{
+				Id:     100,
+				Title:  "test-ToDo",
+				Status: "done",
+				Done:   true,
+			}
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE id = ? AND deleted_at IS NULL FOR UPDATE")).
+				WithArgs(toDoModel.Id).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(toDoModel.Id, "test-ToDo", "todo", false, time.Now(), time.Now(), nil, nil))
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET title = ?, status = ?, done = ?, archived_at = ? WHERE id = ? AND deleted_at IS NULL")).
+				WithArgs(toDoModel.Title, toDoModel.Status, toDoModel.Done, nil, toDoModel.Id).
+				WillReturnResult(tt.execResult).
+				WillReturnError(tt.execError)
+			switch {
+			case tt.historyError != nil:
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )")).
+					WithArgs(toDoModel.Id, "update", `[{"field":"done","before":false,"after":true}]`, "", "").
+					WillReturnError(tt.historyError)
+				mock.ExpectRollback()
+			case tt.revisionError != nil:
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )")).
+					WithArgs(toDoModel.Id, "update", `[{"field":"done","before":false,"after":true}]`, "", "").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_revision(todo_id, revision, title, status, done, archived)")).
+					WithArgs(toDoModel.Id, toDoModel.Id).
+					WillReturnError(tt.revisionError)
+				mock.ExpectRollback()
+			case tt.wantError:
+				mock.ExpectRollback()
+			default:
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )")).
+					WithArgs(toDoModel.Id, "update", `[{"field":"done","before":false,"after":true}]`, "", "").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_revision(todo_id, revision, title, status, done, archived)")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				expectOutbox(mock)
+				mock.ExpectCommit()
+			}
+			toDoRepository := NewToDoRepositoryMySQL(db)
+
+			// Act
+			err = toDoRepository.Update(toDoModel, &model.ToDoHistory{
+				ToDoId:    toDoModel.Id,
+				Operation: "update",
+				Changes:   []model.FieldChange{{Field: "done", Before: false, After: true}},
+			})
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestDeleteById(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name       string
+		execError  error
+		execResult driver.Result
+		wantError  bool
+	}{
+		{
+			name:       "01_DELETEが成功するケース",
+			execError:  nil,
+			execResult: sqlmock.NewResult(0, 1),
+			wantError:  false,
+		},
+		{
+			name:       "02_DELETEが失敗するケース",
+			execError:  errors.New("DELETE FAILED"),
+			execResult: nil,
+			wantError:  true,
+		},
+		{
+			name:       "03_RowsAffected()が失敗するケース",
+			execError:  nil,
+			execResult: sqlmock.NewErrorResult(errors.New("ERROR RESULT")),
+			wantError:  true,
+		},
+		{
+			name:       "04_RowsAffected()が1以外を返すケース",
+			execError:  nil,
+			execResult: sqlmock.NewResult(0, 100),
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			id := int64(100)
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL")).
+				WithArgs(id).
+				WillReturnResult(tt.execResult).
+				WillReturnError(tt.execError)
+			if tt.wantError {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_revision(todo_id, revision, title, status, done, archived)")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				expectOutbox(mock)
+				mock.ExpectCommit()
+			}
+			toDoRepository := NewToDoRepositoryMySQL(db)
+
+			// Act
+			err = toDoRepository.DeleteById(id, &model.ToDoHistory{Operation: "delete"})
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestListAll(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name            string
+		includeArchived bool
+		queryRow        *sqlmock.Rows
+		queryError      error
+		wantError       bool
+	}{
+		{
+			name:       "01_SELECTが成功するケース",
+			queryRow:   sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(1, "test-ToDo", "done", true, time.Now(), time.Now(), nil, nil),
+			queryError: nil,
+			wantError:  false,
+		},
+		{
+			name:            "04_SELECTが成功するケース_アーカイブ済みを含む",
+			includeArchived: true,
+			queryRow:        sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(1, "test-ToDo", "done", true, time.Now(), time.Now(), time.Now(), nil),
+			queryError:      nil,
+			wantError:       false,
+		},
+		{
+			name:       "02_SELECTが失敗するケース",
+			queryRow:   sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}),
+			queryError: errors.New("SELECT FAILED"),
+			wantError:  true,
+		},
+		{
+			name:       "03_Scanが失敗するケース",
+			queryRow:   sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(nil, nil, nil, nil, nil, nil, nil, nil),
+			queryError: nil,
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			query := "SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE deleted_at IS NULL"
+			if !tt.includeArchived {
+				query += " AND archived_at IS NULL"
+			}
+			mock.ExpectQuery(regexp.QuoteMeta(query)).
+				WillReturnRows(tt.queryRow).
+				WillReturnError(tt.queryError)
+			toDoRepository := NewToDoRepositoryMySQL(db)
+
+			// Act
+			_, err = toDoRepository.ListAll(tt.includeArchived)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestListFilteredByDone(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name            string
+		done            bool
+		includeArchived bool
+		queryRow        *sqlmock.Rows
+		queryError      error
+		wantError       bool
+	}{
+		{
+			name:       "01_SELECTが成功するケース_done=true",
+			done:       true,
+			queryRow:   sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(1, "test-ToDo", "done", true, time.Now(), time.Now(), nil, nil),
+			queryError: nil,
+			wantError:  false,
+		},
+		{
+			name:       "02_SELECTが成功するケース_done=false",
+			done:       false,
+			queryRow:   sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(1, "test-ToDo", "todo", false, time.Now(), time.Now(), nil, nil),
+			queryError: nil,
+			wantError:  false,
+		},
+		{
+			name:       "03_SELECTが失敗するケース",
+			done:       true,
+			queryRow:   sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}),
+			queryError: errors.New("SELECT FAILED"),
+			wantError:  true,
+		},
+		{
+			name:       "04_Scanが失敗するケース",
+			done:       true,
+			queryRow:   sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(nil, nil, nil, nil, nil, nil, nil, nil),
+			queryError: nil,
+			wantError:  true,
+		},
+		{
+			name:            "05_SELECTが成功するケース_アーカイブ済みを含む",
+			done:            true,
+			includeArchived: true,
+			queryRow:        sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(1, "test-ToDo", "done", true, time.Now(), time.Now(), time.Now(), nil),
+			queryError:      nil,
+			wantError:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			query := "SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE done = ? AND deleted_at IS NULL"
+			if !tt.includeArchived {
+				query += " AND archived_at IS NULL"
+			}
+			mock.ExpectQuery(regexp.QuoteMeta(query)).
+				WithArgs(tt.done).
+				WillReturnRows(tt.queryRow).
+				WillReturnError(tt.queryError)
+			toDoRepository := NewToDoRepositoryMySQL(db)
+
+			// Act
+			_, err = toDoRepository.ListFilteredByDone(tt.done, tt.includeArchived)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestListDeleted(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	columns := []string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}
+	tests := []struct {
+		name       string
+		queryRow   *sqlmock.Rows
+		queryError error
+		wantError  bool
+	}{
+		{
+			name:       "01_SELECTが成功するケース",
+			queryRow:   sqlmock.NewRows(columns).AddRow(1, "test-ToDo", "todo", false, time.Now(), time.Now(), nil, time.Now()),
+			queryError: nil,
+			wantError:  false,
+		},
+		{
+			name:       "02_SELECTが失敗するケース",
+			queryRow:   sqlmock.NewRows(columns),
+			queryError: errors.New("SELECT FAILED"),
+			wantError:  true,
+		},
+		{
+			name:       "03_Scanが失敗するケース",
+			queryRow:   sqlmock.NewRows(columns).AddRow(nil, nil, nil, nil, nil, nil, nil, nil),
+			queryError: nil,
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE deleted_at IS NOT NULL")).
+				WillReturnRows(tt.queryRow).
+				WillReturnError(tt.queryError)
+			toDoRepository := NewToDoRepositoryMySQL(db)
+
+			// Act
+			result, err := toDoRepository.ListDeleted()
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			for _, todo := range result {
+				if todo.DeletedAt == nil {
+					t.Error("DeletedAt IS NOT SET")
+				}
+			}
+		})
+	}
+}
+
+func TestIterate(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	done := true
+	tests := []struct {
+		name            string
+		done            *bool
+		includeArchived bool
+		query           string
+		queryRow        *sqlmock.Rows
+		queryError      error
+		wantIds         []int64
+		wantError       bool
+	}{
+		{
+			name:     "01_全件を読むケース",
+			done:     nil,
+			query:    "SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE deleted_at IS NULL AND archived_at IS NULL ORDER BY id",
+			queryRow: sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(1, "test-ToDo01", "todo", false, time.Now(), time.Now(), nil, nil).AddRow(2, "test-ToDo02", "done", true, time.Now(), time.Now(), nil, nil),
+			wantIds:  []int64{1, 2},
+		},
+		{
+			name:            "02_doneで絞り込みアーカイブ済みを含むケース",
+			done:            &done,
+			includeArchived: true,
+			query:           "SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE deleted_at IS NULL AND done = ? ORDER BY id",
+			queryRow:        sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(2, "test-ToDo02", "done", true, time.Now(), time.Now(), time.Now(), nil),
+			wantIds:         []int64{2},
+		},
+		{
+			name:       "03_SELECTが失敗するケース",
+			done:       nil,
+			query:      "SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE deleted_at IS NULL AND archived_at IS NULL ORDER BY id",
+			queryRow:   sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}),
+			queryError: errors.New("SELECT FAILED"),
+			wantIds:    []int64{},
+			wantError:  true,
+		},
+		{
+			name:      "04_途中でScanが失敗するケース",
+			done:      nil,
+			query:     "SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE deleted_at IS NULL AND archived_at IS NULL ORDER BY id",
+			queryRow:  sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(1, "test-ToDo01", "todo", false, time.Now(), time.Now(), nil, nil).AddRow(nil, nil, nil, nil, nil, nil, nil, nil),
+			wantIds:   []int64{1},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			expected := mock.ExpectQuery(regexp.QuoteMeta(tt.query)).
+				WillReturnRows(tt.queryRow).
+				WillReturnError(tt.queryError)
+			if tt.done != nil {
+				expected.WithArgs(*tt.done)
+			}
+			toDoRepository := NewToDoRepositoryMySQL(db)
+
+			// Act
+			ids := []int64{}
+			iterator, err := toDoRepository.Iterate(tt.done, tt.includeArchived)
+			if err == nil {
+				for iterator.Next() {
+					ids = append(ids, iterator.ToDo().Id)
+				}
+				err = iterator.Err()
+				iterator.Close()
+			}
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIds) {
+				t.Errorf("expected: %v, actual: %v", tt.wantIds, ids)
+			}
+		})
+	}
+}
+
+func TestListPage(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	done := false
+	tests := []struct {
+		name            string
+		done            *bool
+		includeArchived bool
+		afterId         int64
+		query           string
+		args            []driver.Value
+		queryRow        *sqlmock.Rows
+		queryError      error
+		wantIds         []int64
+		wantError       bool
+	}{
+		{
+			name:     "01_最初のページを読むケース",
+			done:     nil,
+			afterId:  0,
+			query:    "SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE id > ? AND deleted_at IS NULL AND archived_at IS NULL ORDER BY id LIMIT ?",
+			args:     []driver.Value{0, 2},
+			queryRow: sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(1, "test-ToDo01", "todo", false, time.Now(), time.Now(), nil, nil).AddRow(2, "test-ToDo02", "done", true, time.Now(), time.Now(), nil, nil),
+			wantIds:  []int64{1, 2},
+		},
+		{
+			name:            "02_doneで絞り込み指定したIDより後を読むケース",
+			done:            &done,
+			includeArchived: true,
+			afterId:         2,
+			query:           "SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE id > ? AND deleted_at IS NULL AND done = ? ORDER BY id LIMIT ?",
+			args:            []driver.Value{2, false, 2},
+			queryRow:        sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(5, "test-ToDo05", "todo", false, time.Now(), time.Now(), time.Now(), nil),
+			wantIds:         []int64{5},
+		},
+		{
+			name:       "03_SELECTが失敗するケース",
+			done:       nil,
+			query:      "SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE id > ? AND deleted_at IS NULL AND archived_at IS NULL ORDER BY id LIMIT ?",
+			args:       []driver.Value{0, 2},
+			queryRow:   sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}),
+			queryError: errors.New("SELECT FAILED"),
+			wantIds:    []int64{},
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectQuery(regexp.QuoteMeta(tt.query)).
+				WithArgs(tt.args...).
+				WillReturnRows(tt.queryRow).
+				WillReturnError(tt.queryError)
+			toDoRepository := NewToDoRepositoryMySQL(db)
+
+			// Act
+			result, err := toDoRepository.ListPage(tt.done, tt.includeArchived, tt.afterId, 2)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			ids := []int64{}
+			for _, toDo := range result {
+				ids = append(ids, toDo.Id)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIds) {
+				t.Errorf("expected: %v, actual: %v", tt.wantIds, ids)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestCount(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	done := true
+	tests := []struct {
+		name            string
+		done            *bool
+		includeArchived bool
+		query           string
+		args            []driver.Value
+		queryError      error
+		wantCount       int64
+		wantError       bool
+	}{
+		{
+			name:      "01_全件を数えるケース",
+			done:      nil,
+			query:     "SELECT COUNT(*) FROM todo WHERE deleted_at IS NULL AND archived_at IS NULL",
+			args:      []driver.Value{},
+			wantCount: 3,
+		},
+		{
+			name:            "02_doneで絞り込みアーカイブ済みを含むケース",
+			done:            &done,
+			includeArchived: true,
+			query:           "SELECT COUNT(*) FROM todo WHERE deleted_at IS NULL AND done = ?",
+			args:            []driver.Value{true},
+			wantCount:       3,
+		},
+		{
+			name:       "03_SELECTが失敗するケース",
+			done:       nil,
+			query:      "SELECT COUNT(*) FROM todo WHERE deleted_at IS NULL AND archived_at IS NULL",
+			args:       []driver.Value{},
+			queryError: errors.New("SELECT FAILED"),
+			wantCount:  0,
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectQuery(regexp.QuoteMeta(tt.query)).
+				WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(3)).
+				WillReturnError(tt.queryError)
+			toDoRepository := NewToDoRepositoryMySQL(db)
+
+			// Act
+			count, err := toDoRepository.Count(tt.done, tt.includeArchived)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if count != tt.wantCount {
+				t.Errorf("expected: %v, actual: %v", tt.wantCount, count)
+			}
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name       string
+		execError  error
+		execResult driver.Result
+		wantError  bool
+		wantNoRows bool
+	}{
+		{
+			name:       "01_UPDATEが成功するケース",
+			execError:  nil,
+			execResult: sqlmock.NewResult(0, 1),
+			wantError:  false,
+		},
+		{
+			name:       "02_UPDATEが失敗するケース",
+			execError:  errors.New("UPDATE FAILED"),
+			execResult: nil,
+			wantError:  true,
+		},
+		{
+			name:       "03_RowsAffected()が1以外を返すケース(ゴミ箱にない)",
+			execError:  nil,
+			execResult: sqlmock.NewResult(0, 0),
+			wantError:  true,
+			wantNoRows: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			id := int64(100)
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL")).
+				WithArgs(id).
+				WillReturnResult(tt.execResult).
+				WillReturnError(tt.execError)
+			if tt.wantError {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_revision(todo_id, revision, title, status, done, archived)")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				expectOutbox(mock)
+				mock.ExpectCommit()
+			}
 			toDoRepository := NewToDoRepositoryMySQL(db)
 
 			// Act
-			_, err = toDoRepository.Insert(toDoModel)
+			err = toDoRepository.Restore(id, &model.ToDoHistory{Operation: "restore"})
 
 			// Assert
 			if (err != nil) != tt.wantError {
 				t.Error(err.Error())
 			}
+			if errors.Is(err, sql.ErrNoRows) != tt.wantNoRows {
+				t.Errorf("expected sql.ErrNoRows: %t, actual: %v", tt.wantNoRows, err)
+			}
 		})
 	}
 }
 
-func TestSelectById(t *testing.T) {
+func TestPurgeDeletedBefore(t *testing.T) {
 	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
 
 	tests := []struct {
-		name      string
-		queryRow  *sqlmock.Rows
-		wantError bool
+		name         string
+		ids          []int64
+		selectError  error
+		execError    error
+		historyError error
+		wantAffected int64
+		wantError    bool
 	}{
 		{
-			name:      "01_SELECTが成功するケース",
-			queryRow:  sqlmock.NewRows([]string{"id", "title", "done", "created_at", "updated_at"}).AddRow(1, "test-ToDo", false, time.Now(), time.Now()),
-			wantError: false,
+			name:         "01_DELETEが成功するケース",
+			ids:          []int64{100, 101},
+			wantAffected: 2,
+			wantError:    false,
 		},
 		{
-			name:      "02_Scanが失敗するケース",
-			queryRow:  sqlmock.NewRows([]string{"id", "title", "done", "created_at", "updated_at"}),
-			wantError: true,
+			name:         "02_ゴミ箱に対象のToDoがないケース",
+			ids:          []int64{},
+			wantAffected: 0,
+			wantError:    false,
+		},
+		{
+			name:         "03_SELECTが失敗するケース",
+			selectError:  errors.New("SELECT FAILED"),
+			wantAffected: 0,
+			wantError:    true,
+		},
+		{
+			name:         "04_DELETEが失敗するケース",
+			ids:          []int64{100, 101},
+			execError:    errors.New("DELETE FAILED"),
+			wantAffected: 0,
+			wantError:    true,
+		},
+		{
+			name:         "05_履歴の書き込みが失敗するケース",
+			ids:          []int64{100, 101},
+			historyError: errors.New("INSERT FAILED"),
+			wantAffected: 0,
+			wantError:    true,
 		},
 	}
 
@@ -108,29 +1055,75 @@ func TestSelectById(t *testing.T) {
 			t.Log(tt.name)
 
 			// Arrange
-			id := int64(100)
+			before := time.Now()
 			db, mock, err := sqlmock.New()
 			if err != nil {
 				t.Error(err.Error())
 			}
 			defer db.Close()
-			mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, done, created_at, updated_at FROM todo WHERE id = ?")).
-				WithArgs(id).
-				WillReturnRows(tt.queryRow)
+			mock.ExpectBegin()
+			rows := sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"})
+			for _, id := range tt.ids {
+				rows.AddRow(id, "test-ToDo", "todo", false, before, before, nil, before)
+			}
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE deleted_at IS NOT NULL AND deleted_at < ? ORDER BY id FOR UPDATE")).
+				WithArgs(before).
+				WillReturnRows(rows).
+				WillReturnError(tt.selectError)
+			if tt.selectError == nil && len(tt.ids) > 0 {
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM todo WHERE id IN (?, ?)")).
+					WithArgs(tt.ids[0], tt.ids[1]).
+					WillReturnResult(sqlmock.NewResult(0, 2)).
+					WillReturnError(tt.execError)
+				if tt.execError == nil {
+					// 同じUIDを再びインポートしたら新しく作成する
+					mock.ExpectExec(regexp.QuoteMeta("DELETE FROM todo_uid WHERE todo_id IN (?, ?)")).
+						WithArgs(tt.ids[0], tt.ids[1]).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )")).
+						WithArgs(tt.ids[0], "purge", `[{"field":"purged","before":false,"after":true}]`, "", "").
+						WillReturnResult(sqlmock.NewResult(1, 1)).
+						WillReturnError(tt.historyError)
+					if tt.historyError == nil {
+						// 削除前のToDoをdeletedとして通知する
+						mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox(event_type, completed, todo_id, payload) VALUES ( ?, ?, ?, ? )")).
+							WithArgs("deleted", false, tt.ids[0], sqlmock.AnyArg()).
+							WillReturnResult(sqlmock.NewResult(1, 1))
+						mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )")).
+							WithArgs(tt.ids[1], "purge", `[{"field":"purged","before":false,"after":true}]`, "", "").
+							WillReturnResult(sqlmock.NewResult(2, 1))
+						mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox(event_type, completed, todo_id, payload) VALUES ( ?, ?, ?, ? )")).
+							WithArgs("deleted", false, tt.ids[1], sqlmock.AnyArg()).
+							WillReturnResult(sqlmock.NewResult(2, 1))
+					}
+				}
+			}
+			if tt.wantError {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectCommit()
+			}
 			toDoRepository := NewToDoRepositoryMySQL(db)
+			history := &model.ToDoHistory{Operation: "purge", Changes: []model.FieldChange{{Field: "purged", Before: false, After: true}}}
 
 			// Act
-			_, err = toDoRepository.SelectById(id)
+			affected, err := toDoRepository.PurgeDeletedBefore(before, history)
 
 			// Assert
 			if (err != nil) != tt.wantError {
 				t.Error(err.Error())
 			}
+			if affected != tt.wantAffected {
+				t.Errorf("expected: %v, actual: %v", tt.wantAffected, affected)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err.Error())
+			}
 		})
 	}
 }
 
-func TestUpdate(t *testing.T) {
+func TestArchive(t *testing.T) {
 	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
 
 	tests := []struct {
@@ -142,23 +1135,17 @@ func TestUpdate(t *testing.T) {
 		{
 			name:       "01_UPDATEが成功するケース",
 			execError:  nil,
-			execResult: sqlmock.NewResult(1, 1),
+			execResult: sqlmock.NewResult(0, 1),
 			wantError:  false,
 		},
 		{
 			name:       "02_UPDATEが失敗するケース",
 			execError:  errors.New("UPDATE FAILED"),
-			execResult: sqlmock.NewErrorResult(errors.New("ERROR RESULT")),
-			wantError:  true,
-		},
-		{
-			name:       "03_RowsAffected()が失敗するケース",
-			execError:  nil,
-			execResult: sqlmock.NewErrorResult(errors.New("ERROR RESULT")),
+			execResult: nil,
 			wantError:  true,
 		},
 		{
-			name:       "04_RowsAffected()で1以外が返るケース",
+			name:       "03_RowsAffected()が1以外を返すケース(アーカイブ済み)",
 			execError:  nil,
 			execResult: sqlmock.NewResult(0, 0),
 			wantError:  true,
@@ -172,24 +1159,31 @@ func TestUpdate(t *testing.T) {
 			t.Log(tt.name)
 
 			// Arrange
-			toDoModel := &model.ToDo{
-				Id:    100,
-				Title: "test-ToDo",
-				Done:  true,
-			}
+			id := int64(100)
 			db, mock, err := sqlmock.New()
 			if err != nil {
 				t.Error(err.Error())
 			}
 			defer db.Close()
-			mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET title = ?, done = ? WHERE id = ?")).
-				WithArgs(toDoModel.Title, toDoModel.Done, toDoModel.Id).
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET archived_at = CURRENT_TIMESTAMP WHERE id = ? AND archived_at IS NULL AND deleted_at IS NULL")).
+				WithArgs(id).
 				WillReturnResult(tt.execResult).
 				WillReturnError(tt.execError)
+			if tt.wantError {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_revision(todo_id, revision, title, status, done, archived)")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				expectOutbox(mock)
+				mock.ExpectCommit()
+			}
 			toDoRepository := NewToDoRepositoryMySQL(db)
 
 			// Act
-			err = toDoRepository.Update(toDoModel)
+			err = toDoRepository.Archive(id, &model.ToDoHistory{Operation: "archive"})
 
 			// Assert
 			if (err != nil) != tt.wantError {
@@ -199,7 +1193,7 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
-func TestDeleteById(t *testing.T) {
+func TestUnarchive(t *testing.T) {
 	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
 
 	tests := []struct {
@@ -209,27 +1203,21 @@ func TestDeleteById(t *testing.T) {
 		wantError  bool
 	}{
 		{
-			name:       "01_DELETEが成功するケース",
+			name:       "01_UPDATEが成功するケース",
 			execError:  nil,
 			execResult: sqlmock.NewResult(0, 1),
 			wantError:  false,
 		},
 		{
-			name:       "02_DELETEが失敗するケース",
-			execError:  errors.New("DELETE FAILED"),
+			name:       "02_UPDATEが失敗するケース",
+			execError:  errors.New("UPDATE FAILED"),
 			execResult: nil,
 			wantError:  true,
 		},
 		{
-			name:       "03_RowsAffected()が失敗するケース",
-			execError:  nil,
-			execResult: sqlmock.NewErrorResult(errors.New("ERROR RESULT")),
-			wantError:  true,
-		},
-		{
-			name:       "04_RowsAffected()が1以外を返すケース",
+			name:       "03_RowsAffected()が1以外を返すケース(アーカイブされていない)",
 			execError:  nil,
-			execResult: sqlmock.NewResult(0, 100),
+			execResult: sqlmock.NewResult(0, 0),
 			wantError:  true,
 		},
 	}
@@ -247,14 +1235,25 @@ func TestDeleteById(t *testing.T) {
 				t.Error(err.Error())
 			}
 			defer db.Close()
-			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM todo WHERE id = ?")).
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET archived_at = NULL WHERE id = ? AND archived_at IS NOT NULL AND deleted_at IS NULL")).
 				WithArgs(id).
 				WillReturnResult(tt.execResult).
 				WillReturnError(tt.execError)
+			if tt.wantError {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_revision(todo_id, revision, title, status, done, archived)")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				expectOutbox(mock)
+				mock.ExpectCommit()
+			}
 			toDoRepository := NewToDoRepositoryMySQL(db)
 
 			// Act
-			err = toDoRepository.DeleteById(id)
+			err = toDoRepository.Unarchive(id, &model.ToDoHistory{Operation: "unarchive"})
 
 			// Assert
 			if (err != nil) != tt.wantError {
@@ -264,30 +1263,151 @@ func TestDeleteById(t *testing.T) {
 	}
 }
 
-func TestListAll(t *testing.T) {
+func TestArchiveDoneBefore(t *testing.T) {
 	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
 
 	tests := []struct {
-		name       string
-		queryRow   *sqlmock.Rows
-		queryError error
-		wantError  bool
+		name         string
+		ids          []int64
+		selectError  error
+		execError    error
+		historyError error
+		wantAffected int64
+		wantError    bool
 	}{
 		{
-			name:       "01_SELECTが成功するケース",
-			queryRow:   sqlmock.NewRows([]string{"id", "title", "done", "created_at", "updated_at"}).AddRow(1, "test-ToDo", true, time.Now(), time.Now()),
-			queryError: nil,
-			wantError:  false,
+			name:         "01_UPDATEが成功するケース",
+			ids:          []int64{100, 101},
+			wantAffected: 2,
+			wantError:    false,
+		},
+		{
+			name:         "02_対象のToDoがないケース",
+			ids:          []int64{},
+			wantAffected: 0,
+			wantError:    false,
+		},
+		{
+			name:         "03_SELECTが失敗するケース",
+			selectError:  errors.New("SELECT FAILED"),
+			wantAffected: 0,
+			wantError:    true,
+		},
+		{
+			name:         "04_UPDATEが失敗するケース",
+			ids:          []int64{100, 101},
+			execError:    errors.New("UPDATE FAILED"),
+			wantAffected: 0,
+			wantError:    true,
+		},
+		{
+			name:         "05_履歴の書き込みが失敗するケース",
+			ids:          []int64{100, 101},
+			historyError: errors.New("INSERT FAILED"),
+			wantAffected: 0,
+			wantError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			before := time.Now()
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectBegin()
+			rows := sqlmock.NewRows([]string{"id"})
+			for _, id := range tt.ids {
+				rows.AddRow(id)
+			}
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM todo WHERE done = true AND updated_at < ? AND archived_at IS NULL AND deleted_at IS NULL ORDER BY id FOR UPDATE")).
+				WithArgs(before).
+				WillReturnRows(rows).
+				WillReturnError(tt.selectError)
+			if tt.selectError == nil && len(tt.ids) > 0 {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET archived_at = CURRENT_TIMESTAMP WHERE id IN (?, ?)")).
+					WithArgs(tt.ids[0], tt.ids[1]).
+					WillReturnResult(sqlmock.NewResult(0, 2)).
+					WillReturnError(tt.execError)
+				if tt.execError == nil {
+					mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )")).
+						WithArgs(tt.ids[0], "archive", `[{"field":"archived","before":false,"after":true}]`, "", "").
+						WillReturnResult(sqlmock.NewResult(1, 1)).
+						WillReturnError(tt.historyError)
+					if tt.historyError == nil {
+						for _, id := range tt.ids {
+							if id != tt.ids[0] {
+								mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )")).
+									WithArgs(id, "archive", `[{"field":"archived","before":false,"after":true}]`, "", "").
+									WillReturnResult(sqlmock.NewResult(1, 1))
+							}
+							mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_revision(todo_id, revision, title, status, done, archived)")).
+								WithArgs(id, id).
+								WillReturnResult(sqlmock.NewResult(1, 1))
+							expectOutbox(mock)
+						}
+					}
+				}
+			}
+			if tt.wantError {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectCommit()
+			}
+			toDoRepository := NewToDoRepositoryMySQL(db)
+			history := &model.ToDoHistory{Operation: "archive", Changes: []model.FieldChange{{Field: "archived", Before: false, After: true}}}
+
+			// Act
+			affected, err := toDoRepository.ArchiveDoneBefore(before, history)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			if affected != tt.wantAffected {
+				t.Errorf("expected: %v, actual: %v", tt.wantAffected, affected)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestListHistory(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	columns := []string{"id", "todo_id", "operation", "changes", "actor", "request_id", "created_at"}
+	tests := []struct {
+		name        string
+		queryRow    *sqlmock.Rows
+		queryError  error
+		wantChanges int
+		wantError   bool
+	}{
+		{
+			name:        "01_SELECTが成功するケース",
+			queryRow:    sqlmock.NewRows(columns).AddRow(1, 100, "update", `[{"field":"title","before":"a","after":"b"},{"field":"done","before":false,"after":true}]`, "tester", "req-1", time.Now()),
+			queryError:  nil,
+			wantChanges: 2,
+			wantError:   false,
 		},
 		{
 			name:       "02_SELECTが失敗するケース",
-			queryRow:   sqlmock.NewRows([]string{"id", "title", "done", "created_at", "updated_at"}),
+			queryRow:   sqlmock.NewRows(columns),
 			queryError: errors.New("SELECT FAILED"),
 			wantError:  true,
 		},
 		{
-			name:       "03_Scanが失敗するケース",
-			queryRow:   sqlmock.NewRows([]string{"id", "title", "done", "created_at", "updated_at"}).AddRow(nil, nil, nil, nil, nil),
+			name:       "03_changesがJSONでないケース",
+			queryRow:   sqlmock.NewRows(columns).AddRow(1, 100, "update", "invalid", "tester", "req-1", time.Now()),
 			queryError: nil,
 			wantError:  true,
 		},
@@ -300,63 +1420,114 @@ func TestListAll(t *testing.T) {
 			t.Log(tt.name)
 
 			// Arrange
+			id := int64(100)
 			db, mock, err := sqlmock.New()
 			if err != nil {
 				t.Error(err.Error())
 			}
 			defer db.Close()
-			mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, done, created_at, updated_at FROM todo")).
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT id, todo_id, operation, changes, actor, request_id, created_at FROM todo_history WHERE todo_id = ? ORDER BY id")).
+				WithArgs(id).
 				WillReturnRows(tt.queryRow).
 				WillReturnError(tt.queryError)
 			toDoRepository := NewToDoRepositoryMySQL(db)
 
 			// Act
-			_, err = toDoRepository.ListAll()
+			result, err := toDoRepository.ListHistory(id)
 
 			// Assert
 			if (err != nil) != tt.wantError {
 				t.Error(err.Error())
 			}
+			if (result != nil) && len(result[0].Changes) != tt.wantChanges {
+				t.Errorf("expected: %v, actual: %v", tt.wantChanges, len(result[0].Changes))
+			}
 		})
 	}
 }
 
-func TestListFilteredByDone(t *testing.T) {
+func TestListRevisions(t *testing.T) {
 	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
 
+	columns := []string{"todo_id", "revision", "title", "status", "done", "archived", "created_at"}
 	tests := []struct {
 		name       string
-		done       bool
 		queryRow   *sqlmock.Rows
 		queryError error
+		wantLength int
 		wantError  bool
 	}{
 		{
-			name:       "01_SELECTが成功するケース_done=true",
-			done:       true,
-			queryRow:   sqlmock.NewRows([]string{"id", "title", "done", "created_at", "updated_at"}).AddRow(1, "test-ToDo", true, time.Now(), time.Now()),
-			queryError: nil,
-			wantError:  false,
-		},
-		{
-			name:       "02_SELECTが成功するケース_done=false",
-			done:       false,
-			queryRow:   sqlmock.NewRows([]string{"id", "title", "done", "created_at", "updated_at"}).AddRow(1, "test-ToDo", false, time.Now(), time.Now()),
+			name: "01_SELECTが成功するケース",
+			queryRow: sqlmock.NewRows(columns).
+				AddRow(100, 1, "test-ToDo", "todo", false, false, time.Now()).
+				AddRow(100, 2, "test-ToDo", "done", true, false, time.Now()),
 			queryError: nil,
+			wantLength: 2,
 			wantError:  false,
 		},
 		{
-			name:       "03_SELECTが失敗するケース",
-			done:       true,
-			queryRow:   sqlmock.NewRows([]string{"id", "title", "done", "created_at", "updated_at"}),
+			name:       "02_SELECTが失敗するケース",
+			queryRow:   sqlmock.NewRows(columns),
 			queryError: errors.New("SELECT FAILED"),
+			wantLength: 0,
 			wantError:  true,
 		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			id := int64(100)
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT todo_id, revision, title, status, done, archived, created_at FROM todo_revision WHERE todo_id = ? ORDER BY revision")).
+				WithArgs(id).
+				WillReturnRows(tt.queryRow).
+				WillReturnError(tt.queryError)
+			toDoRepository := NewToDoRepositoryMySQL(db)
+
+			// Act
+			result, err := toDoRepository.ListRevisions(id)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			if len(result) != tt.wantLength {
+				t.Errorf("expected: %v, actual: %v", tt.wantLength, len(result))
+			}
+		})
+	}
+}
+
+func TestSelectRevision(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	columns := []string{"todo_id", "revision", "title", "status", "done", "archived", "created_at"}
+	tests := []struct {
+		name       string
+		queryRow   *sqlmock.Rows
+		queryError error
+		wantError  bool
+	}{
 		{
-			name:       "04_Scanが失敗するケース",
-			done:       true,
-			queryRow:   sqlmock.NewRows([]string{"id", "title", "done", "created_at", "updated_at"}).AddRow(nil, nil, nil, nil, nil),
+			name:       "01_SELECTが成功するケース",
+			queryRow:   sqlmock.NewRows(columns).AddRow(100, 2, "test-ToDo", "done", true, true, time.Now()),
 			queryError: nil,
+			wantError:  false,
+		},
+		{
+			name:       "02_SELECTが失敗するケース",
+			queryRow:   sqlmock.NewRows(columns),
+			queryError: errors.New("SELECT FAILED"),
 			wantError:  true,
 		},
 	}
@@ -368,22 +1539,145 @@ func TestListFilteredByDone(t *testing.T) {
 			t.Log(tt.name)
 
 			// Arrange
+			id := int64(100)
+			revision := int64(2)
 			db, mock, err := sqlmock.New()
 			if err != nil {
 				t.Error(err.Error())
 			}
 			defer db.Close()
-			mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, done, created_at, updated_at FROM todo WHERE done = ?")).
-				WithArgs(tt.done).
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT todo_id, revision, title, status, done, archived, created_at FROM todo_revision WHERE todo_id = ? AND revision = ?")).
+				WithArgs(id, revision).
 				WillReturnRows(tt.queryRow).
 				WillReturnError(tt.queryError)
 			toDoRepository := NewToDoRepositoryMySQL(db)
 
 			// Act
-			_, err = toDoRepository.ListFilteredByDone(tt.done)
+			result, err := toDoRepository.SelectRevision(id, revision)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			if result != nil && (result.Revision != revision || !result.Archived) {
+				t.Errorf("unexpected revision: %v", result)
+			}
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name        string
+		atomic      bool
+		deleteError error
+		wantIds     []int64
+		wantErrors  []bool
+		wantError   bool
+	}{
+		{
+			name:       "01_atomicで全件成功するケース",
+			atomic:     true,
+			wantIds:    []int64{10, 100},
+			wantErrors: []bool{false, false},
+			wantError:  false,
+		},
+		{
+			name:        "02_atomicで途中の操作が失敗するケース",
+			atomic:      true,
+			deleteError: errors.New("DELETE FAILED"),
+			wantIds:     []int64{10, 100},
+			wantErrors:  []bool{false, true},
+			wantError:   true,
+		},
+		{
+			name:       "03_best-effortで全件成功するケース",
+			atomic:     false,
+			wantIds:    []int64{10, 100},
+			wantErrors: []bool{false, false},
+			wantError:  false,
+		},
+		{
+			name:        "04_best-effortで失敗した操作だけ取り消すケース",
+			atomic:      false,
+			deleteError: errors.New("DELETE FAILED"),
+			wantIds:     []int64{10, 100},
+			wantErrors:  []bool{false, true},
+			wantError:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			operations := []model.BatchOperation{
+				{Operation: "create", ToDo: &model.ToDo{Title: "test-ToDo", Status: "todo"}, History: &model.ToDoHistory{Operation: "create"}},
+				{Operation: "delete", ToDo: &model.ToDo{Id: 100}, History: &model.ToDoHistory{ToDoId: 100, Operation: "delete"}},
+			}
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectBegin()
+			if !tt.atomic {
+				mock.ExpectExec("SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo(title, status, done) VALUES ( ?, ?, ? )")).
+				WillReturnResult(sqlmock.NewResult(10, 1))
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )")).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_revision(todo_id, revision, title, status, done, archived)")).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectOutbox(mock)
+			if !tt.atomic {
+				mock.ExpectExec("SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+			if tt.deleteError != nil {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL")).
+					WithArgs(100).
+					WillReturnError(tt.deleteError)
+				if tt.atomic {
+					mock.ExpectRollback()
+				} else {
+					mock.ExpectExec("ROLLBACK TO SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectCommit()
+				}
+			} else {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL")).
+					WithArgs(100).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )")).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_revision(todo_id, revision, title, status, done, archived)")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				expectOutbox(mock)
+				mock.ExpectCommit()
+			}
+			toDoRepository := NewToDoRepositoryMySQL(db)
+
+			// Act
+			results, err := toDoRepository.Batch(operations, tt.atomic)
 
 			// Assert
 			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if len(results) != len(operations) {
+				t.Fatalf("expected: %d results, actual: %v", len(operations), results)
+			}
+			for i := range results {
+				if results[i].Id != tt.wantIds[i] || (results[i].Err != nil) != tt.wantErrors[i] {
+					t.Errorf("unexpected result %d: %+v", i, results[i])
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
 				t.Error(err.Error())
 			}
 		})
@@ -398,12 +1692,13 @@ func TestInsertWithDB(t *testing.T) {
 	defer closeMySQLContainer(resource, pool)
 	db := connectMySQLContainer(resource, pool)
 	expected := &model.ToDo{
-		Title: "testToDo",
+		Title:  "testToDo",
+		Status: "todo",
 	}
 	toDoRepository := NewToDoRepositoryMySQL(db)
 
 	// Act
-	id, err := toDoRepository.Insert(expected)
+	id, err := toDoRepository.Insert(expected, &model.ToDoHistory{Operation: "create"})
 	if err != nil {
 		t.Error(err.Error())
 	}
@@ -458,14 +1753,15 @@ func TestUpdateWithDB(t *testing.T) {
 	defer closeMySQLContainer(resource, pool)
 	db := connectMySQLContainer(resource, pool)
 	expected := &model.ToDo{
-		Id:    1, // see test/test_read.sql
-		Title: "ToDo01",
-		Done:  true,
+		Id:     1, // see test/test_read.sql
+		Title:  "ToDo01",
+		Status: "done",
+		Done:   true,
 	}
 	toDoRepository := NewToDoRepositoryMySQL(db)
 
 	// Act
-	err := toDoRepository.Update(expected)
+	err := toDoRepository.Update(expected, &model.ToDoHistory{Operation: "update", Actor: "tester"})
 	if err != nil {
 		t.Error(err.Error())
 	}
@@ -482,6 +1778,20 @@ func TestUpdateWithDB(t *testing.T) {
 	if actual.Done != expected.Done {
 		t.Errorf("expected: %v, actual: %v", expected.Done, actual.Done)
 	}
+	history, err := toDoRepository.ListHistory(expected.Id)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(history) != 1 || history[0].Operation != "update" || history[0].Actor != "tester" {
+		t.Errorf("history is not recorded: %v", history)
+	}
+	revisions, err := toDoRepository.ListRevisions(expected.Id)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(revisions) != 1 || revisions[0].Revision != 1 || !revisions[0].Done {
+		t.Errorf("revision is not recorded: %v", revisions)
+	}
 
 }
 
@@ -494,16 +1804,23 @@ func TestDeleteByIdWithDB(t *testing.T) {
 	toDoRepository := NewToDoRepositoryMySQL(db)
 
 	// Act
-	err := toDoRepository.DeleteById(1)
+	err := toDoRepository.DeleteById(1, &model.ToDoHistory{Operation: "delete"})
 	if err != nil {
 		t.Error(err.Error())
 	}
 
 	// Assert
-	actual := &model.ToDo{}
-	err = db.QueryRow("SELECT id, title, done FROM todo WHERE id = ?", 1).Scan(&actual.Id, &actual.Title, &actual.Done)
+	_, err = toDoRepository.SelectById(1)
 	if err == nil {
-		t.Error("THE RECORD STILL EXISTS")
+		t.Error("THE RECORD IS NOT IN THE TRASH")
+	}
+	var deletedAt sql.NullTime
+	err = db.QueryRow("SELECT deleted_at FROM todo WHERE id = ?", 1).Scan(&deletedAt)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if !deletedAt.Valid {
+		t.Error("deleted_at IS NOT SET")
 	}
 
 }
@@ -545,7 +1862,7 @@ func TestListAllWithDB(t *testing.T) {
 	}
 
 	// Act
-	actual, err := toDoRepository.ListAll()
+	actual, err := toDoRepository.ListAll(false)
 	if err != nil {
 		t.Error(err.Error())
 	}
@@ -593,7 +1910,7 @@ func TestListFilteredByDoneWithDB(t *testing.T) {
 				}
 
 				// Act
-				actual, err := toDoRepository.ListFilteredByDone(true)
+				actual, err := toDoRepository.ListFilteredByDone(true, false)
 				if err != nil {
 					t.Error(err.Error())
 				}
@@ -638,7 +1955,7 @@ func TestListFilteredByDoneWithDB(t *testing.T) {
 				}
 
 				// Act
-				actual, err := toDoRepository.ListFilteredByDone(false)
+				actual, err := toDoRepository.ListFilteredByDone(false, false)
 				if err != nil {
 					t.Error(err.Error())
 				}
@@ -683,7 +2000,7 @@ func TestListFilteredByDoneWithDB(t *testing.T) {
 	// 		}
 
 	// 		// Act
-	// 		actual, err := toDoRepository.ListFilteredByDone(true)
+	// 		actual, err := toDoRepository.ListFilteredByDone(true, false)
 	// 		if err != nil {
 	// 			t.Error(err.Error())
 	// 		}
@@ -728,7 +2045,7 @@ func TestListFilteredByDoneWithDB(t *testing.T) {
 	// 		}
 
 	// 		// Act
-	// 		actual, err := toDoRepository.ListFilteredByDone(false)
+	// 		actual, err := toDoRepository.ListFilteredByDone(false, false)
 	// 		if err != nil {
 	// 			t.Error(err.Error())
 	// 		}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
+)
+
+// MySQLとイベントストアで同じ結果になることを確かめる
+func TestUpdateUnchanged(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 保存されているToDoを読み込ませる(foundがfalseなら見つからない)
+	expectMySQL := func(mock sqlmock.Sqlmock, id int64, found bool) {
+		rows := sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"})
+		if found {
+			rows.AddRow(id, "test-ToDo", "todo", false, createdAt, createdAt, nil, nil)
+		}
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE id = ? AND deleted_at IS NULL FOR UPDATE")).
+			WithArgs(id).
+			WillReturnRows(rows)
+	}
+	expectEventStore := func(mock sqlmock.Sqlmock, id int64, found bool) {
+		stream := sqlmock.NewRows([]string{"id", "version", "revision"})
+		if found {
+			stream.AddRow(id, 1, 1)
+		}
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id, version, revision FROM todo_stream WHERE id = ? FOR UPDATE")).
+			WithArgs(id).
+			WillReturnRows(stream)
+		if !found {
+			return
+		}
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT version, state FROM todo_snapshot WHERE todo_id = ?")).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"version", "state"}))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + eventColumns + " FROM todo_event e WHERE e.todo_id = ? AND e.version > ? ORDER BY e.version")).
+			WillReturnRows(sqlmock.NewRows(eventColumnNames).
+				AddRow(1, id, 1, 1, model.ToDoCreated, "test-ToDo", "todo", false, "create", "", "", createdAt))
+	}
+
+	tests := []struct {
+		name          string
+		newRepository func(*sql.DB) repository.ToDoRepository
+		expectLoad    func(sqlmock.Sqlmock, int64, bool)
+		found         bool
+		wantNotFound  bool
+	}{
+		{
+			name:          "01_MySQLで値が変わらない更新は何も書き込まないケース",
+			newRepository: NewToDoRepositoryMySQL,
+			expectLoad:    expectMySQL,
+			found:         true,
+		},
+		{
+			name:          "02_イベントストアで値が変わらない更新は何も書き込まないケース",
+			newRepository: NewToDoRepositoryEventStore,
+			expectLoad:    expectEventStore,
+			found:         true,
+		},
+		{
+			name:          "03_MySQLで対象が見つからないケース",
+			newRepository: NewToDoRepositoryMySQL,
+			expectLoad:    expectMySQL,
+			found:         false,
+			wantNotFound:  true,
+		},
+		{
+			name:          "04_イベントストアで対象が見つからないケース",
+			newRepository: NewToDoRepositoryEventStore,
+			expectLoad:    expectEventStore,
+			found:         false,
+			wantNotFound:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			id := int64(1)
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectBegin()
+			tt.expectLoad(mock, id, tt.found)
+			// 履歴、リビジョン、outboxのどれかを書き込むと期待していないExecでエラーになる
+			if tt.wantNotFound {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectCommit()
+			}
+			toDoRepository := tt.newRepository(db)
+
+			// Act
+			err = toDoRepository.Update(
+				&model.ToDo{Id: id, Title: "test-ToDo", Status: "todo", Done: false},
+				&model.ToDoHistory{ToDoId: id, Operation: "update"},
+			)
+
+			// Assert
+			if tt.wantNotFound != errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("expected not found: %v, actual: %v", tt.wantNotFound, err)
+			}
+			if !tt.wantNotFound && err != nil {
+				t.Error(err.Error())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package database
+
+import "database/sql"
+
+// iCalendarのUIDはToDoの保存方式によらず同じテーブルに記録する
+
+func (c *connection) SelectIdByUid(uid string) (int64, error) {
+	var id int64
+	err := c.conn().QueryRow("SELECT todo_id FROM todo_uid WHERE uid = ?", uid).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+func (c *connection) InsertUid(id int64, uid string) error {
+	_, err := c.conn().Exec("REPLACE INTO todo_uid(uid, todo_id) VALUES ( ?, ? )", uid, id)
+	return err
+}
+
+// 完全に削除したToDoのUIDを消す(同じUIDを再びインポートすると新しいToDoを作成する)
+func deleteUids(tx *sql.Tx, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	placeholders, args := inClause(ids)
+	_, err := tx.Exec("DELETE FROM todo_uid WHERE todo_id IN ("+placeholders+")", args...)
+	return err
+}
+
+func (c *connection) ListUids() (map[int64]string, error) {
+	rows, err := c.conn().Query("SELECT uid, todo_id FROM todo_uid")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	uids := map[int64]string{}
+	for rows.Next() {
+		var uid string
+		var id int64
+		err := rows.Scan(&uid, &id)
+		if err != nil {
+			return nil, err
+		}
+		uids[id] = uid
+	}
+	return uids, rows.Err()
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSelectIdByUid(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name       string
+		queryRow   *sqlmock.Rows
+		queryError error
+		wantId     int64
+		wantError  bool
+	}{
+		{
+			name:     "01_UIDが記録されているケース",
+			queryRow: sqlmock.NewRows([]string{"todo_id"}).AddRow(100),
+			wantId:   100,
+		},
+		{
+			name:     "02_UIDが記録されていないケース",
+			queryRow: sqlmock.NewRows([]string{"todo_id"}),
+			wantId:   0,
+		},
+		{
+			name:       "03_SELECTが失敗するケース",
+			queryRow:   sqlmock.NewRows([]string{"todo_id"}),
+			queryError: errors.New("SELECT FAILED"),
+			wantId:     -1,
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT todo_id FROM todo_uid WHERE uid = ?")).
+				WithArgs("abc@example.com").
+				WillReturnRows(tt.queryRow).
+				WillReturnError(tt.queryError)
+			toDoRepository := NewToDoRepositoryEventStore(db)
+
+			// Act
+			id, err := toDoRepository.SelectIdByUid("abc@example.com")
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if id != tt.wantId {
+				t.Errorf("expected: %d, actual: %d", tt.wantId, id)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
+)
+
+// implementation of repository.UnitOfWork
+type unitOfWorkMySQL struct {
+	db *sql.DB
+	// repository using the transaction
+	newRepository func(connection) repository.ToDoRepository
+}
+
+func NewUnitOfWorkMySQL(db *sql.DB) repository.UnitOfWork {
+	return &unitOfWorkMySQL{
+		db: db,
+		newRepository: func(c connection) repository.ToDoRepository {
+			return &toDoRepositoryMySQL{c}
+		},
+	}
+}
+
+// Unit of work of the repository made by NewToDoRepositoryEventStore
+func NewUnitOfWorkEventStore(db *sql.DB) repository.UnitOfWork {
+	return &unitOfWorkMySQL{
+		db: db,
+		newRepository: func(c connection) repository.ToDoRepository {
+			return &toDoRepositoryEventStore{c}
+		},
+	}
+}
+
+func (u *unitOfWorkMySQL) Do(fn func(repository.ToDoRepository) error) error {
+	tx, err := u.db.Begin()
+	if err != nil {
+		return err
+	}
+	// 同じトランザクションで実行するリポジトリを渡す
+	err = fn(u.newRepository(connection{db: u.db, tx: tx}))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
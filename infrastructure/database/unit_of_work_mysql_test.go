@@ -0,0 +1,97 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
+)
+
+func TestUnitOfWorkDo(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name        string
+		beginError  error
+		updateError error
+		wantError   bool
+	}{
+		{
+			name:      "01_読み込みと更新が1つのトランザクションでコミットされるケース",
+			wantError: false,
+		},
+		{
+			name:       "02_Beginが失敗するケース",
+			beginError: errors.New("BEGIN FAILED"),
+			wantError:  true,
+		},
+		{
+			name:        "03_更新が失敗してロールバックされるケース",
+			updateError: errors.New("UPDATE FAILED"),
+			wantError:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			id := int64(100)
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectBegin().WillReturnError(tt.beginError)
+			if tt.beginError == nil {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE id = ? AND deleted_at IS NULL FOR UPDATE")).
+					WithArgs(id).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(id, "test-ToDo", "todo", false, time.Now(), time.Now(), nil, nil))
+				// 更新はUnitOfWorkのトランザクションで実行され、新たにBeginされない
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, status, done, created_at, updated_at, archived_at, deleted_at FROM todo WHERE id = ? AND deleted_at IS NULL FOR UPDATE")).
+					WithArgs(id).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status", "done", "created_at", "updated_at", "archived_at", "deleted_at"}).AddRow(id, "test-ToDo", "todo", false, time.Now(), time.Now(), nil, nil))
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET title = ?, status = ?, done = ?, archived_at = ? WHERE id = ? AND deleted_at IS NULL")).
+					WillReturnResult(sqlmock.NewResult(0, 1)).
+					WillReturnError(tt.updateError)
+				if tt.updateError != nil {
+					mock.ExpectRollback()
+				} else {
+					mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_history(todo_id, operation, changes, actor, request_id) VALUES ( ?, ?, ?, ?, ? )")).
+						WillReturnResult(sqlmock.NewResult(1, 1))
+					mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_revision(todo_id, revision, title, status, done, archived)")).
+						WillReturnResult(sqlmock.NewResult(1, 1))
+					expectOutbox(mock)
+					mock.ExpectCommit()
+				}
+			}
+			unitOfWork := NewUnitOfWorkMySQL(db)
+
+			// Act
+			err = unitOfWork.Do(func(toDoRepository repository.ToDoRepository) error {
+				toDo, err := toDoRepository.SelectByIdForUpdate(id)
+				if err != nil {
+					return err
+				}
+				toDo.Status = "done"
+				toDo.Done = true
+				return toDoRepository.Update(toDo, &model.ToDoHistory{ToDoId: id, Operation: "update"})
+			})
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
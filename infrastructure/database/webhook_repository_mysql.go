@@ -0,0 +1,265 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
+)
+
+const webhookColumns = "id, url, secret, events, created_at, updated_at"
+
+// columns of the webhook_delivery table joined with the webhook table, read into model.WebhookDelivery
+const deliveryColumns = "d.id, w.id, w.url, w.secret, w.events, w.created_at, w.updated_at, d.event_id, d.event, d.payload, d.attempts, d.last_error, d.next_attempt_at, d.created_at"
+
+// implementation of repository.WebhookRepository
+type webhookRepositoryMySQL struct {
+	connection
+}
+
+func NewWebhookRepositoryMySQL(db *sql.DB) repository.WebhookRepository {
+	return &webhookRepositoryMySQL{connection{db: db}}
+}
+
+func (r *webhookRepositoryMySQL) Insert(webhook *model.Webhook) (int64, error) {
+	result, err := r.db.Exec(
+		"INSERT INTO webhook(url, secret, events) VALUES ( ?, ?, ? )",
+		webhook.Url,
+		webhook.Secret,
+		strings.Join(webhook.Events, ","),
+	)
+	if err != nil {
+		return -1, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *webhookRepositoryMySQL) SelectById(id int64) (*model.Webhook, error) {
+	return scanWebhook(r.db.QueryRow("SELECT "+webhookColumns+" FROM webhook WHERE id = ?", id))
+}
+
+func (r *webhookRepositoryMySQL) ListAll() ([]model.Webhook, error) {
+	rows, err := r.db.Query("SELECT " + webhookColumns + " FROM webhook ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []model.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (r *webhookRepositoryMySQL) Update(webhook *model.Webhook) error {
+	// 値が変わらない場合は更新件数が0になるため、存在確認は呼び出し側で行う
+	_, err := r.db.Exec(
+		"UPDATE webhook SET url = ?, secret = ?, events = ? WHERE id = ?",
+		webhook.Url,
+		webhook.Secret,
+		strings.Join(webhook.Events, ","),
+		webhook.Id,
+	)
+	return err
+}
+
+func (r *webhookRepositoryMySQL) DeleteById(id int64) error {
+	// 配信待ちと配信できなかったペイロードは外部キーで一緒に削除される
+	result, err := r.db.Exec("DELETE FROM webhook WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errors.New("DELETE FAILED")
+	}
+	return nil
+}
+
+func (r *webhookRepositoryMySQL) InsertDeliveries(delivery *model.WebhookDelivery) (int64, error) {
+	// リレーが同じイベントを再送しても、同じWebhookへの配信は1件だけにする
+	result, err := r.db.Exec(
+		"INSERT INTO webhook_delivery(webhook_id, event_id, event, payload, last_error, next_attempt_at) "+
+			"SELECT id, ?, ?, ?, '', ? FROM webhook WHERE events = '' OR FIND_IN_SET(?, events) > 0 "+
+			"ON DUPLICATE KEY UPDATE webhook_delivery.id = webhook_delivery.id",
+		delivery.EventId,
+		delivery.Event,
+		delivery.Payload,
+		delivery.NextAttemptAt,
+		delivery.Event,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *webhookRepositoryMySQL) ClaimDeliveries(limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	deliveries := []model.WebhookDelivery{}
+	err := r.transaction(func(tx *sql.Tx) error {
+		now := time.Now()
+		rows, err := tx.Query(
+			"SELECT "+deliveryColumns+" FROM webhook_delivery d JOIN webhook w ON w.id = d.webhook_id "+
+				"WHERE d.next_attempt_at <= ? ORDER BY d.id LIMIT ? FOR UPDATE",
+			now,
+			limit,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			delivery, err := scanDelivery(rows)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, *delivery)
+		}
+		err = rows.Err()
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		// 送信中に止まった場合は借りた期間が過ぎてから再送される
+		ids := make([]int64, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].Id
+			deliveries[i].NextAttemptAt = now.Add(lease)
+		}
+		placeholders, args := inClause(ids)
+		_, err = tx.Exec(
+			"UPDATE webhook_delivery SET next_attempt_at = ? WHERE id IN ("+placeholders+")",
+			append([]interface{}{now.Add(lease)}, args...)...,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepositoryMySQL) DeleteDelivery(id int64) error {
+	_, err := r.db.Exec("DELETE FROM webhook_delivery WHERE id = ?", id)
+	return err
+}
+
+func (r *webhookRepositoryMySQL) RetryDelivery(delivery *model.WebhookDelivery) error {
+	_, err := r.db.Exec(
+		"UPDATE webhook_delivery SET attempts = ?, last_error = ?, next_attempt_at = ? WHERE id = ?",
+		delivery.Attempts,
+		delivery.LastError,
+		delivery.NextAttemptAt,
+		delivery.Id,
+	)
+	return err
+}
+
+func (r *webhookRepositoryMySQL) DeadLetter(delivery *model.WebhookDelivery) error {
+	return r.transaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			"INSERT INTO webhook_dead_letter(webhook_id, event, payload, attempts, last_error) VALUES ( ?, ?, ?, ?, ? )",
+			delivery.Webhook.Id,
+			delivery.Event,
+			delivery.Payload,
+			delivery.Attempts,
+			delivery.LastError,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM webhook_delivery WHERE id = ?", delivery.Id)
+		return err
+	})
+}
+
+func (r *webhookRepositoryMySQL) ListDeadLetters(webhookId int64) ([]model.WebhookDeadLetter, error) {
+	rows, err := r.db.Query(
+		"SELECT id, webhook_id, event, payload, attempts, last_error, created_at FROM webhook_dead_letter WHERE webhook_id = ? ORDER BY id",
+		webhookId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deadLetters := []model.WebhookDeadLetter{}
+	for rows.Next() {
+		deadLetter := model.WebhookDeadLetter{}
+		err := rows.Scan(
+			&deadLetter.Id,
+			&deadLetter.WebhookId,
+			&deadLetter.Event,
+			&deadLetter.Payload,
+			&deadLetter.Attempts,
+			&deadLetter.LastError,
+			&deadLetter.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+	return deadLetters, rows.Err()
+}
+
+// 1行分のレコードを配信に詰める
+func scanDelivery(row scanner) (*model.WebhookDelivery, error) {
+	delivery := &model.WebhookDelivery{}
+	var events string
+	err := row.Scan(
+		&delivery.Id,
+		&delivery.Webhook.Id,
+		&delivery.Webhook.Url,
+		&delivery.Webhook.Secret,
+		&events,
+		&delivery.Webhook.CreatedAt,
+		&delivery.Webhook.UpdatedAt,
+		&delivery.EventId,
+		&delivery.Event,
+		&delivery.Payload,
+		&delivery.Attempts,
+		&delivery.LastError,
+		&delivery.NextAttemptAt,
+		&delivery.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if events != "" {
+		delivery.Webhook.Events = strings.Split(events, ",")
+	}
+	return delivery, nil
+}
+
+// 1行分のレコードをWebhookに詰める
+func scanWebhook(row scanner) (*model.Webhook, error) {
+	webhook := &model.Webhook{}
+	var events string
+	err := row.Scan(
+		&webhook.Id,
+		&webhook.Url,
+		&webhook.Secret,
+		&events,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if events != "" {
+		webhook.Events = strings.Split(events, ",")
+	}
+	return webhook, nil
+}
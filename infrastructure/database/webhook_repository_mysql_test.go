@@ -0,0 +1,443 @@
+package database
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+)
+
+func TestWebhookInsert(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name       string
+		webhook    *model.Webhook
+		wantEvents string
+		execResult driver.Result
+		execError  error
+		wantId     int64
+		wantError  bool
+	}{
+		{
+			name:       "01_イベントを指定して保存するケース",
+			webhook:    &model.Webhook{Url: "http://example.com/hook", Secret: "secret", Events: []string{"created", "completed"}},
+			wantEvents: "created,completed",
+			execResult: sqlmock.NewResult(1, 1),
+			wantId:     1,
+			wantError:  false,
+		},
+		{
+			name:       "02_イベントを指定せずに保存するケース",
+			webhook:    &model.Webhook{Url: "http://example.com/hook", Secret: "secret"},
+			wantEvents: "",
+			execResult: sqlmock.NewResult(2, 1),
+			wantId:     2,
+			wantError:  false,
+		},
+		{
+			name:       "03_INSERTが失敗するケース",
+			webhook:    &model.Webhook{Url: "http://example.com/hook", Secret: "secret"},
+			wantEvents: "",
+			execResult: sqlmock.NewErrorResult(errors.New("ERROR RESULT")),
+			execError:  errors.New("INSERT FAILED"),
+			wantId:     -1,
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO webhook(url, secret, events) VALUES ( ?, ?, ? )")).
+				WithArgs(tt.webhook.Url, tt.webhook.Secret, tt.wantEvents).
+				WillReturnResult(tt.execResult).
+				WillReturnError(tt.execError)
+			webhookRepository := NewWebhookRepositoryMySQL(db)
+
+			// Act
+			id, err := webhookRepository.Insert(tt.webhook)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			if id != tt.wantId {
+				t.Errorf("expected: %d, actual: %d", tt.wantId, id)
+			}
+		})
+	}
+}
+
+func TestWebhookListAll(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	now := time.Now()
+	columns := []string{"id", "url", "secret", "events", "created_at", "updated_at"}
+	tests := []struct {
+		name         string
+		queryRows    *sqlmock.Rows
+		queryError   error
+		wantWebhooks []model.Webhook
+		wantError    bool
+	}{
+		{
+			name: "01_Webhookが取得できるケース",
+			queryRows: sqlmock.NewRows(columns).
+				AddRow(1, "http://example.com/1", "secret-1", "completed", now, now).
+				AddRow(2, "http://example.com/2", "secret-2", "", now, now),
+			wantWebhooks: []model.Webhook{
+				{Id: 1, Url: "http://example.com/1", Secret: "secret-1", Events: []string{"completed"}, CreatedAt: now, UpdatedAt: now},
+				{Id: 2, Url: "http://example.com/2", Secret: "secret-2", CreatedAt: now, UpdatedAt: now},
+			},
+			wantError: false,
+		},
+		{
+			name:         "02_SELECTが失敗するケース",
+			queryRows:    sqlmock.NewRows(columns),
+			queryError:   errors.New("SELECT FAILED"),
+			wantWebhooks: nil,
+			wantError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT id, url, secret, events, created_at, updated_at FROM webhook ORDER BY id")).
+				WillReturnRows(tt.queryRows).
+				WillReturnError(tt.queryError)
+			webhookRepository := NewWebhookRepositoryMySQL(db)
+
+			// Act
+			webhooks, err := webhookRepository.ListAll()
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			if !reflect.DeepEqual(webhooks, tt.wantWebhooks) {
+				t.Errorf("expected: %+v, actual: %+v", tt.wantWebhooks, webhooks)
+			}
+		})
+	}
+}
+
+func TestWebhookDeleteById(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name       string
+		execResult driver.Result
+		execError  error
+		wantError  bool
+	}{
+		{
+			name:       "01_Webhookが削除されるケース",
+			execResult: sqlmock.NewResult(0, 1),
+			wantError:  false,
+		},
+		{
+			name:       "02_Webhookが存在しないケース",
+			execResult: sqlmock.NewResult(0, 0),
+			wantError:  true,
+		},
+		{
+			name:       "03_DELETEが失敗するケース",
+			execResult: sqlmock.NewErrorResult(errors.New("ERROR RESULT")),
+			execError:  errors.New("DELETE FAILED"),
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM webhook WHERE id = ?")).
+				WithArgs(1).
+				WillReturnResult(tt.execResult).
+				WillReturnError(tt.execError)
+			webhookRepository := NewWebhookRepositoryMySQL(db)
+
+			// Act
+			err = webhookRepository.DeleteById(1)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+		})
+	}
+}
+
+func TestWebhookInsertDeliveries(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	now := time.Now()
+	delivery := &model.WebhookDelivery{EventId: 2, Event: "completed", Payload: []byte(`{"id":2}`), NextAttemptAt: now}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO webhook_delivery(webhook_id, event_id, event, payload, last_error, next_attempt_at) SELECT id, ?, ?, ?, '', ? FROM webhook WHERE events = '' OR FIND_IN_SET(?, events) > 0")).
+		WithArgs(delivery.EventId, delivery.Event, delivery.Payload, now, delivery.Event).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	webhookRepository := NewWebhookRepositoryMySQL(db)
+
+	// Act
+	inserted, err := webhookRepository.InsertDeliveries(delivery)
+
+	// Assert
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if inserted != 2 {
+		t.Errorf("expected: %v, actual: %v", 2, inserted)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestWebhookClaimDeliveries(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	columns := []string{"d.id", "w.id", "w.url", "w.secret", "w.events", "w.created_at", "w.updated_at", "d.event_id", "d.event", "d.payload", "d.attempts", "d.last_error", "d.next_attempt_at", "d.created_at"}
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		rows       *sqlmock.Rows
+		queryError error
+		wantIds    []int64
+		wantError  bool
+	}{
+		{
+			name: "01_期限の来た配信を借りるケース",
+			rows: sqlmock.NewRows(columns).
+				AddRow(10, 1, "http://example.com/hook", "secret", "", createdAt, createdAt, 2, "created", []byte(`{"id":2}`), 0, "", createdAt, createdAt).
+				AddRow(11, 2, "http://example.com/other", "secret", "created,completed", createdAt, createdAt, 2, "completed", []byte(`{"id":2}`), 1, "503 Service Unavailable", createdAt, createdAt),
+			wantIds:   []int64{10, 11},
+			wantError: false,
+		},
+		{
+			name:      "02_配信待ちがないケース",
+			rows:      sqlmock.NewRows(columns),
+			wantIds:   []int64{},
+			wantError: false,
+		},
+		{
+			name:       "03_読み込みに失敗するケース",
+			queryError: errors.New("Query ERROR"),
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			lease := 10 * time.Second
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectBegin()
+			query := mock.ExpectQuery(regexp.QuoteMeta("SELECT "+deliveryColumns+" FROM webhook_delivery d JOIN webhook w ON w.id = d.webhook_id WHERE d.next_attempt_at <= ? ORDER BY d.id LIMIT ? FOR UPDATE")).
+				WithArgs(sqlmock.AnyArg(), 5)
+			if tt.queryError != nil {
+				query.WillReturnError(tt.queryError)
+				mock.ExpectRollback()
+			} else {
+				query.WillReturnRows(tt.rows)
+				if len(tt.wantIds) > 0 {
+					mock.ExpectExec(regexp.QuoteMeta("UPDATE webhook_delivery SET next_attempt_at = ? WHERE id IN (?, ?)")).
+						WithArgs(sqlmock.AnyArg(), tt.wantIds[0], tt.wantIds[1]).
+						WillReturnResult(sqlmock.NewResult(0, 2))
+				}
+				mock.ExpectCommit()
+			}
+			webhookRepository := NewWebhookRepositoryMySQL(db)
+
+			// Act
+			start := time.Now()
+			deliveries, err := webhookRepository.ClaimDeliveries(5, lease)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if !tt.wantError {
+				ids := []int64{}
+				for _, d := range deliveries {
+					ids = append(ids, d.Id)
+					// 借りている間は他のワーカーから見えない
+					if d.NextAttemptAt.Before(start.Add(lease)) {
+						t.Errorf("delivery %d is not leased: %v", d.Id, d.NextAttemptAt)
+					}
+				}
+				if !reflect.DeepEqual(ids, tt.wantIds) {
+					t.Errorf("expected: %v, actual: %v", tt.wantIds, ids)
+				}
+			}
+			if len(tt.wantIds) > 1 && !reflect.DeepEqual(deliveries[1].Webhook.Events, []string{"created", "completed"}) {
+				t.Errorf("unexpected events: %v", deliveries[1].Webhook.Events)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
+
+func TestWebhookRetryDelivery(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	delivery := &model.WebhookDelivery{Id: 10, Attempts: 2, LastError: "503 Service Unavailable", NextAttemptAt: time.Now()}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE webhook_delivery SET attempts = ?, last_error = ?, next_attempt_at = ? WHERE id = ?")).
+		WithArgs(delivery.Attempts, delivery.LastError, delivery.NextAttemptAt, delivery.Id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	webhookRepository := NewWebhookRepositoryMySQL(db)
+
+	// Act
+	err = webhookRepository.RetryDelivery(delivery)
+
+	// Assert
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestWebhookDeleteDelivery(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM webhook_delivery WHERE id = ?")).
+		WithArgs(10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	webhookRepository := NewWebhookRepositoryMySQL(db)
+
+	// Act
+	err = webhookRepository.DeleteDelivery(10)
+
+	// Assert
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name        string
+		insertError error
+		wantError   bool
+	}{
+		{
+			name:      "01_配信をデッドレターに移すケース",
+			wantError: false,
+		},
+		{
+			name:        "02_デッドレターの保存に失敗するケース",
+			insertError: errors.New("Insert ERROR"),
+			wantError:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			delivery := &model.WebhookDelivery{Id: 10, Webhook: model.Webhook{Id: 1}, Event: "completed", Payload: []byte(`{"id":1}`), Attempts: 5, LastError: "503 Service Unavailable"}
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			defer db.Close()
+			mock.ExpectBegin()
+			insert := mock.ExpectExec(regexp.QuoteMeta("INSERT INTO webhook_dead_letter(webhook_id, event, payload, attempts, last_error) VALUES ( ?, ?, ?, ?, ? )")).
+				WithArgs(delivery.Webhook.Id, delivery.Event, delivery.Payload, delivery.Attempts, delivery.LastError)
+			if tt.insertError != nil {
+				// 配信は残り、次のワーカーがもう一度移す
+				insert.WillReturnError(tt.insertError)
+				mock.ExpectRollback()
+			} else {
+				insert.WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM webhook_delivery WHERE id = ?")).
+					WithArgs(delivery.Id).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+			webhookRepository := NewWebhookRepositoryMySQL(db)
+
+			// Act
+			err = webhookRepository.DeadLetter(delivery)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err.Error())
+			}
+		})
+	}
+}
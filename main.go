@@ -6,13 +6,20 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 
 	"github.com/uzimihsr/todo-rest-api-golang/config"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
 	"github.com/uzimihsr/todo-rest-api-golang/infrastructure/database"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/graphql"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/grpc"
 	"github.com/uzimihsr/todo-rest-api-golang/presentation/handler"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/openapi"
 	"github.com/uzimihsr/todo-rest-api-golang/presentation/router"
 	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
 	"gopkg.in/yaml.v2"
@@ -46,10 +53,154 @@ func main() {
 
 	fmt.Println(config.Database.User + ":" + config.Database.Password + "@tcp(" + config.Database.Host + ":" + config.Database.Port + ")/" + config.Database.DatabaseName + "?charset=utf8mb4&parseTime=true")
 
-	repository := database.NewToDoRepositoryMySQL(db)
-	service := service.NewToDoService(repository)
-	handler := handler.NewToDoHandler(service)
-	router := router.NewToDoRouter(handler)
+	// ワークフローが設定されていない場合はデフォルトを使う
+	workflow := model.DefaultWorkflow()
+	if config.Workflow.Initial != "" || config.Workflow.Done != "" || len(config.Workflow.Transitions) > 0 {
+		workflow = &model.Workflow{
+			Initial:     config.Workflow.Initial,
+			Done:        config.Workflow.Done,
+			Transitions: config.Workflow.Transitions,
+		}
+	}
+	err = workflow.Validate()
+	if err != nil {
+		log.Fatalf("invalid workflow: %v", err)
+	}
+
+	// ToDoの保存方式を選ぶ(イベントで保存する場合は過去の時点の一覧を読める)
+	var unitOfWork repository.UnitOfWork
+	var repository repository.ToDoRepository
+	switch config.Database.Store {
+	case "", "table":
+		repository = database.NewToDoRepositoryMySQL(db)
+		unitOfWork = database.NewUnitOfWorkMySQL(db)
+	case "events":
+		repository = database.NewToDoRepositoryEventStore(db)
+		unitOfWork = database.NewUnitOfWorkEventStore(db)
+	default:
+		log.Fatalf("unknown database store: %s", config.Database.Store)
+	}
+	// 再接続時に再送できるよう直近のイベントを保持する
+	eventBufferSize := config.Events.BufferSize
+	if eventBufferSize == 0 {
+		eventBufferSize = 1000
+	}
+	events := service.NewEventBroker(eventBufferSize)
+	toDoService := service.NewToDoService(repository, unitOfWork, workflow, events)
+
+	// 設定されていない項目はデフォルトを使う
+	webhookOption := service.WebhookDeliveryOption{
+		Workers:        config.Webhooks.Workers,
+		MaxAttempts:    config.Webhooks.MaxAttempts,
+		InitialBackoff: config.Webhooks.InitialBackoff,
+		Timeout:        config.Webhooks.Timeout,
+		PollInterval:   config.Webhooks.PollInterval,
+	}
+	if webhookOption.Workers == 0 {
+		webhookOption.Workers = 4
+	}
+	if webhookOption.MaxAttempts == 0 {
+		webhookOption.MaxAttempts = 5
+	}
+	if webhookOption.InitialBackoff == 0 {
+		webhookOption.InitialBackoff = time.Second
+	}
+	if webhookOption.Timeout == 0 {
+		webhookOption.Timeout = 10 * time.Second
+	}
+	if webhookOption.PollInterval == 0 {
+		webhookOption.PollInterval = time.Second
+	}
+	webhookRepository := database.NewWebhookRepositoryMySQL(db)
+	dispatcher := service.NewWebhookDispatcher(webhookRepository, webhookOption)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	// 変更と同じトランザクションで書き込まれたイベントを配信先に送る
+	outboxRepository := database.NewOutboxRepositoryMySQL(db)
+	outboxPollInterval := config.Outbox.PollInterval
+	if outboxPollInterval == 0 {
+		outboxPollInterval = 200 * time.Millisecond
+	}
+	publishers := service.EventPublishers{}
+	outboxPublishers := config.Outbox.Publishers
+	if len(outboxPublishers) == 0 {
+		outboxPublishers = []string{"bus", "webhook"}
+	}
+	for _, name := range outboxPublishers {
+		switch name {
+		case "bus":
+			// どのサーバーのリレーが配信したイベントも、このサーバーの購読者に送る
+			feed := service.NewOutboxFeed(outboxRepository, events, outboxPollInterval)
+			feed.Start()
+			defer feed.Stop()
+		case "webhook":
+			publishers = append(publishers, dispatcher)
+		case "log":
+			publishers = append(publishers, service.NewLogEventPublisher(log.Default()))
+		default:
+			log.Fatalf("unknown outbox publisher: %s", name)
+		}
+	}
+	outboxRetention := config.Outbox.Retention
+	if outboxRetention == 0 {
+		outboxRetention = 24 * time.Hour
+	}
+	outboxPurgeInterval := config.Outbox.PurgeInterval
+	if outboxPurgeInterval == 0 {
+		outboxPurgeInterval = time.Hour
+	}
+	relay := service.NewOutboxRelay(outboxRepository, publishers, outboxPollInterval, outboxRetention, outboxPurgeInterval)
+	relay.Start()
+	defer relay.Stop()
+
+	// ゴミ箱の保持期間を過ぎたToDoを定期的に削除する
+	if config.Trash.Retention > 0 && config.Trash.PurgeInterval > 0 {
+		purger := service.NewTrashPurger(toDoService, config.Trash.Retention, config.Trash.PurgeInterval)
+		purger.Start()
+		defer purger.Stop()
+	}
+
+	// Idempotency-Keyの保持期間が設定されていない場合は24時間
+	idempotencyTTL := config.Idempotency.TTL
+	if idempotencyTTL == 0 {
+		idempotencyTTL = 24 * time.Hour
+	}
+	idempotencyService := service.NewIdempotencyService(database.NewIdempotencyRepositoryMySQL(db), idempotencyTTL)
+	if config.Idempotency.PurgeInterval > 0 {
+		purger := service.NewIdempotencyPurger(idempotencyService, config.Idempotency.PurgeInterval)
+		purger.Start()
+		defer purger.Stop()
+	}
+
+	idempotency := handler.Idempotency(idempotencyService)
+	// OpenAPIの定義と合わないリクエストをハンドラに渡す前に弾く
+	validator, err := openapi.NewValidator(openapi.Spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	validation := handler.ValidateOpenAPI(validator, config.OpenAPI.ValidateRequests, config.OpenAPI.ValidateResponses)
+	webhookHandler := handler.NewWebhookHandler(service.NewWebhookService(webhookRepository))
+	calDAVHandler := handler.NewCalDAVHandler(toDoService)
+	handler := handler.NewToDoHandler(toDoService)
+	router := router.NewToDoRouter(handler, webhookHandler, calDAVHandler, graphql.NewHandler(toDoService), idempotency, validation)
+
+	// gRPCのAPIはHTTPのサーバーとは別のポートで待ち受ける
+	grpcPort := config.GRPC.Port
+	if grpcPort == "" {
+		grpcPort = "9090"
+	}
+	grpcListener, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		err := grpc.NewToDoServer(toDoService).Serve(grpcListener)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+
 	server := &http.Server{
 		Addr:    ":" + string(config.Server.Port),
 		Handler: router.GetRouter(),
@@ -0,0 +1,130 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	qerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+)
+
+const (
+	// header of the user who made the request
+	actorHeader = "X-Actor"
+	// header of the ID of the request
+	requestIdHeader = "X-Request-Id"
+	// max size of a request body
+	maxRequestSize = 1 << 20
+)
+
+// Serves the GraphQL API at /graphql
+type Handler interface {
+	// queries and mutations over GET and POST
+	Query() http.HandlerFunc
+	// subscriptions (and queries and mutations) over WebSocket with the graphql-transport-ws protocol
+	Subscribe() http.HandlerFunc
+	// the schema in SDL
+	Schema() http.HandlerFunc
+}
+
+// Body of a GraphQL request (POST JSON or the query parameters of GET)
+type requestParams struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type handler struct {
+	service service.ToDoService
+	schema  *graphql.Schema
+}
+
+// The schema is embedded and checked by the tests, so an invalid schema is a bug and panics
+func NewHandler(service service.ToDoService) Handler {
+	s, err := newToDoSchema(service)
+	if err != nil {
+		panic(fmt.Sprintf("invalid GraphQL schema: %s", err))
+	}
+	return &handler{service: service, schema: s}
+}
+
+// リゾルバーに渡すリクエストの情報
+func (h *handler) newRequest(r *http.Request, allowMutation bool) *request {
+	return &request{
+		actor:         r.Header.Get(actorHeader),
+		requestId:     r.Header.Get(requestIdHeader),
+		loader:        newToDoLoader(h.service),
+		allowMutation: allowMutation,
+	}
+}
+
+func (h *handler) Query() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := readParams(r)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, &graphql.Response{Errors: []*qerrors.QueryError{{Message: err.Error()}}})
+			return
+		}
+		// GETは安全なメソッドのため、ミューテーションは実行しない
+		ctx := withRequest(r.Context(), h.newRequest(r, r.Method == http.MethodPost))
+		response := h.schema.Exec(ctx, params.Query, params.OperationName, params.Variables)
+		for _, err := range response.Errors {
+			if errors.Is(err, errMutationNotAllowed) {
+				w.Header().Set("Allow", http.MethodPost)
+				writeResponse(w, http.StatusMethodNotAllowed, &graphql.Response{Errors: []*qerrors.QueryError{{Message: errMutationNotAllowed.Error()}}})
+				return
+			}
+		}
+		writeResponse(w, http.StatusOK, response)
+	}
+}
+
+func (h *handler) Schema() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/graphql; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(schemaSDL))
+	}
+}
+
+// GETはクエリパラメーター、POSTはJSONのボディから読む
+func readParams(r *http.Request) (*requestParams, error) {
+	params := &requestParams{}
+	if r.Method != http.MethodPost {
+		query := r.URL.Query()
+		params.Query = query.Get("query")
+		params.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &params.Variables); err != nil {
+				return nil, fmt.Errorf("invalid variables: %s", err)
+			}
+		}
+	} else {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "application/json" {
+			return nil, fmt.Errorf("Content-Type must be application/json")
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestSize))
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(body, params); err != nil {
+			return nil, fmt.Errorf("invalid request body: %s", err)
+		}
+	}
+	if params.Query == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	return params, nil
+}
+
+func writeResponse(w http.ResponseWriter, statusCode int, r *graphql.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(r)
+}
@@ -0,0 +1,324 @@
+package graphql
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service/mock_service"
+)
+
+var testTime = time.Date(2021, 6, 15, 9, 35, 7, 0, time.UTC)
+
+func testToDo(id int64, title string, done bool) *service.ToDoObject {
+	status := "todo"
+	if done {
+		status = "done"
+	}
+	return &service.ToDoObject{Id: id, Title: title, Status: status, Done: done, CreatedAt: testTime, UpdatedAt: testTime}
+}
+
+// 本物のハンドラーにリクエストを送り、ステータスコードとボディを返す
+func serveGraphQL(t *testing.T, toDoService service.ToDoService, method string, target string, body string) (int, string) {
+	var r *http.Request
+	if method == http.MethodPost {
+		r = httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	r.Header.Set("X-Actor", "tester")
+	r.Header.Set("X-Request-Id", "request-1")
+	w := httptest.NewRecorder()
+	NewHandler(toDoService).Query().ServeHTTP(w, r)
+	data, err := ioutil.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return w.Code, strings.TrimSpace(string(data))
+}
+
+func TestQuery(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name       string
+		body       string
+		arrange    func(m *mock_service.MockToDoService)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "01_IDを指定したToDoをまとめて読み込むケース",
+			body: `{"query":"query ($second: ID!) { first: todo(id: 1) { ...fields } second: todo(id: $second) { ...fields } missing: todo(id: 3) { id } } fragment fields on ToDo { __typename id title done archivedAt }","variables":{"second":2}}`,
+			arrange: func(m *mock_service.MockToDoService) {
+				m.EXPECT().ReadByIds([]int64{1, 2, 3}).Return(map[int64]*service.ToDoObject{
+					1: testToDo(1, "first", false),
+					2: testToDo(2, "second", true),
+				}, nil).Times(1)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"data":{"first":{"__typename":"ToDo","id":"1","title":"first","done":false,"archivedAt":null},"second":{"__typename":"ToDo","id":"2","title":"second","done":true,"archivedAt":null},"missing":null}}`,
+		},
+		{
+			name: "02_一覧をページに分けて返すケース",
+			body: `{"query":"{ todos(done: false, first: 2, after: \"dG9kbzox\") { totalCount pageInfo { hasNextPage endCursor } edges { cursor node { id } } } }"}`,
+			arrange: func(m *mock_service.MockToDoService) {
+				// カーソルのIDより後の1ページ分だけ読む
+				m.EXPECT().ListPage(&service.ListOption{Done: "false"}, int64(1), 2).Return(&service.ToDoPageObject{
+					ToDo: []service.ToDoObject{*testToDo(2, "b", false), *testToDo(3, "c", false)}, HasNext: true,
+				}, nil).Times(1)
+				m.EXPECT().Count(&service.ListOption{Done: "false"}).Return(int64(4), nil).Times(1)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"data":{"todos":{"totalCount":4,"pageInfo":{"hasNextPage":true,"endCursor":"dG9kbzoz"},"edges":[{"cursor":"dG9kbzoy","node":{"id":"2"}},{"cursor":"dG9kbzoz","node":{"id":"3"}}]}}}`,
+		},
+		{
+			name:       "03_1ページの件数が範囲外のケース",
+			body:       `{"query":"{ todos(first: 101) { totalCount } }"}`,
+			arrange:    func(m *mock_service.MockToDoService) {},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"errors":[{"message":"first must be between 1 and 100","path":["todos"]}],"data":null}`,
+		},
+		{
+			name: "04_変数で渡した入力でToDoを作成するケース",
+			body: `{"query":"mutation Create($input: CreateToDoInput!) { createToDo(input: $input) { id title status } }","variables":{"input":{"title":"new"}}}`,
+			arrange: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Create(&service.ToDoObject{Title: "new", Actor: "tester", RequestId: "request-1"}).Return(testToDo(5, "new", false), nil).Times(1)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"data":{"createToDo":{"id":"5","title":"new","status":"todo"}}}`,
+		},
+		{
+			name: "05_タイトルのみ更新して完了を保つケース",
+			body: `{"query":"mutation { updateToDo(id: 2, input: {title: \"renamed\"}) { title done } }"}`,
+			arrange: func(m *mock_service.MockToDoService) {
+				title := "renamed"
+				// 省略したフィールドはサービスが更新時にロックした値のままにする
+				m.EXPECT().Patch(&service.ToDoPatchObject{Id: 2, Title: &title, Actor: "tester", RequestId: "request-1"}).Return(testToDo(2, "renamed", true), nil).Times(1)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"data":{"updateToDo":{"title":"renamed","done":true}}}`,
+		},
+		{
+			name: "06_完了を切り替えるケース",
+			body: `{"query":"mutation { toggleToDo(id: \"1\") { done status } }"}`,
+			arrange: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Toggle(&service.ToDoObject{Id: 1, Actor: "tester", RequestId: "request-1"}).Return(testToDo(1, "first", true), nil).Times(1)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"data":{"toggleToDo":{"done":true,"status":"done"}}}`,
+		},
+		{
+			name: "07_存在しないToDoを削除するケース",
+			body: `{"query":"mutation { deleteToDo(id: 9) { id } }"}`,
+			arrange: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Delete(&service.ToDoObject{Id: 9, Actor: "tester", RequestId: "request-1"}).Return(nil, errors.New("sql: no rows in result set")).Times(1)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"errors":[{"message":"sql: no rows in result set","path":["deleteToDo"]}],"data":null}`,
+		},
+		{
+			name: "08_ミューテーションを順に実行し失敗したフィールドのみnullにするケース",
+			body: `{"query":"mutation { a: createToDo(input: {title: \"a\"}) { id } b: createToDo(input: {title: \"b\", status: \"unknown\"}) { id } }"}`,
+			arrange: func(m *mock_service.MockToDoService) {
+				gomock.InOrder(
+					m.EXPECT().Create(&service.ToDoObject{Title: "a", Actor: "tester", RequestId: "request-1"}).Return(testToDo(6, "a", false), nil),
+					m.EXPECT().Create(&service.ToDoObject{Title: "b", Status: "unknown", Actor: "tester", RequestId: "request-1"}).Return(nil, service.ErrInvalidStatus),
+				)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"errors":[{"message":"invalid status","path":["b"]}],"data":null}`,
+		},
+		{
+			name: "09_nullを許すフィールドでエラーを止めるケース",
+			body: `{"query":"{ todo(id: 1) { id } }"}`,
+			arrange: func(m *mock_service.MockToDoService) {
+				m.EXPECT().ReadByIds([]int64{1}).Return(nil, errors.New("connection refused")).Times(1)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"errors":[{"message":"connection refused","path":["todo"]}],"data":{"todo":null}}`,
+		},
+		{
+			name:       "10_スキーマにないフィールドを選択したケース",
+			body:       `{"query":"{ todo(id: 1) { id owner } }"}`,
+			arrange:    func(m *mock_service.MockToDoService) {},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"errors":[{"message":"Cannot query field \"owner\" on type \"ToDo\".","locations":[{"line":1,"column":20}]}]}`,
+		},
+		{
+			name:       "11_構文が誤っているケース",
+			body:       `{"query":"{ todo(id: 1) { id }"}`,
+			arrange:    func(m *mock_service.MockToDoService) {},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"errors":[{"message":"syntax error: unexpected \"\", expecting Ident","locations":[{"line":1,"column":21}]}]}`,
+		},
+		{
+			name:       "12_必須の変数がないケース",
+			body:       `{"query":"query ($id: ID!) { todo(id: $id) { id } }"}`,
+			arrange:    func(m *mock_service.MockToDoService) {},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"errors":[{"message":"Variable \"id\" has invalid value null.\nExpected type \"ID!\", found null.","locations":[{"line":1,"column":8}]}]}`,
+		},
+		{
+			name: "13_ディレクティブでフィールドを除くケース",
+			body: `{"query":"query ($full: Boolean!) { todo(id: 1) { id title @include(if: $full) status @skip(if: true) } }","variables":{"full":false}}`,
+			arrange: func(m *mock_service.MockToDoService) {
+				m.EXPECT().ReadByIds([]int64{1}).Return(map[int64]*service.ToDoObject{1: testToDo(1, "first", false)}, nil).Times(1)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"data":{"todo":{"id":"1"}}}`,
+		},
+		{
+			name:       "14_サブスクリプションをHTTPで送ったケース",
+			body:       `{"query":"subscription { todoChanged { id } }"}`,
+			arrange:    func(m *mock_service.MockToDoService) {},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"errors":[{"message":"graphql-ws protocol header is missing"}]}`,
+		},
+		{
+			name:       "15_クエリがないケース",
+			body:       `{"variables":{}}`,
+			arrange:    func(m *mock_service.MockToDoService) {},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"errors":[{"message":"query is required"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			tt.arrange(mockToDoService)
+
+			// Act
+			status, body := serveGraphQL(t, mockToDoService, http.MethodPost, "/graphql", tt.body)
+
+			// Assert
+			if status != tt.wantStatus {
+				t.Errorf("expected status: %d, actual: %d", tt.wantStatus, status)
+			}
+			if body != tt.wantBody {
+				t.Errorf("expected: %s\nactual:   %s", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestQueryGet(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name       string
+		query      url.Values
+		readTimes  int
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "01_クエリパラメーターのクエリを実行するケース",
+			query:      url.Values{"query": {"query Read($id: ID!) { todo(id: $id) { title } }"}, "variables": {`{"id":"1"}`}},
+			readTimes:  1,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"data":{"todo":{"title":"first"}}}`,
+		},
+		{
+			name:       "02_ミューテーションをGETで送ったケース",
+			query:      url.Values{"query": {`mutation { deleteToDo(id: 1) { id } }`}},
+			readTimes:  0,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   `{"errors":[{"message":"mutations must be sent with POST"}]}`,
+		},
+		{
+			name:       "03_変数がJSONでないケース",
+			query:      url.Values{"query": {"{ todo(id: 1) { id } }"}, "variables": {"id=1"}},
+			readTimes:  0,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"errors":[{"message":"invalid variables: invalid character 'i' looking for beginning of value"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			mockToDoService.EXPECT().ReadByIds([]int64{1}).Return(map[int64]*service.ToDoObject{1: testToDo(1, "first", false)}, nil).Times(tt.readTimes)
+
+			// Act
+			status, body := serveGraphQL(t, mockToDoService, http.MethodGet, "/graphql?"+tt.query.Encode(), "")
+
+			// Assert
+			if status != tt.wantStatus {
+				t.Errorf("expected status: %d, actual: %d", tt.wantStatus, status)
+			}
+			if body != tt.wantBody {
+				t.Errorf("expected: %s\nactual:   %s", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+// 同時に要求されたIDはまとめて読み込み、ミューテーションまでは読み直さない
+func TestToDoLoader(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	mockToDoService := mock_service.NewMockToDoService(ctrl)
+	gomock.InOrder(
+		mockToDoService.EXPECT().ReadByIds([]int64{1, 2, 3}).Return(map[int64]*service.ToDoObject{
+			1: testToDo(1, "first", false),
+			2: testToDo(2, "second", false),
+		}, nil).Times(1),
+		mockToDoService.EXPECT().ReadByIds([]int64{1}).Return(map[int64]*service.ToDoObject{
+			1: testToDo(1, "renamed", false),
+		}, nil).Times(1),
+	)
+	loader := newToDoLoader(mockToDoService)
+
+	// Act
+	titles := make([]string, 3)
+	var wg sync.WaitGroup
+	for i, id := range []int64{3, 1, 2} {
+		wg.Add(1)
+		go func(i int, id int64) {
+			defer wg.Done()
+			toDo, err := loader.load(id)
+			if err != nil {
+				t.Error(err.Error())
+			}
+			if toDo != nil {
+				titles[i] = toDo.Title
+			}
+		}(i, id)
+	}
+	wg.Wait()
+	cached, _ := loader.load(2)
+	loader.clear()
+	reloaded, _ := loader.load(1)
+
+	// Assert
+	if !reflect.DeepEqual(titles, []string{"", "first", "second"}) {
+		t.Errorf("expected: [ first second], actual: %v", titles)
+	}
+	if cached.Title != "second" || reloaded.Title != "renamed" {
+		t.Errorf("expected: second renamed, actual: %s %s", cached.Title, reloaded.Title)
+	}
+}
@@ -0,0 +1,78 @@
+package graphql
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+)
+
+// time to wait for the other IDs before reading a batch, the resolvers of the same level run concurrently
+const loaderWait = 5 * time.Millisecond
+
+// Loads ToDo by the ID like DataLoader: the IDs requested within loaderWait of each other
+// are read with one ReadByIds, and the ToDo read are cached during the request
+type toDoLoader struct {
+	service service.ToDoService
+	mu      sync.Mutex
+	// batch collecting the IDs, nil if none is waiting
+	pending *toDoBatch
+	// batch that read or is reading each ID
+	batches map[int64]*toDoBatch
+}
+
+// IDs read with one ReadByIds
+type toDoBatch struct {
+	ids []int64
+	// closed when toDo and err are set
+	done chan struct{}
+	// IDs not found are not included
+	toDo map[int64]*service.ToDoObject
+	err  error
+}
+
+func newToDoLoader(toDoService service.ToDoService) *toDoLoader {
+	return &toDoLoader{service: toDoService, batches: map[int64]*toDoBatch{}}
+}
+
+// Read the ToDo together with the other IDs requested meanwhile, nil if not found
+func (l *toDoLoader) load(id int64) (*service.ToDoObject, error) {
+	l.mu.Lock()
+	batch, ok := l.batches[id]
+	if !ok {
+		if l.pending == nil {
+			l.pending = &toDoBatch{done: make(chan struct{})}
+			time.AfterFunc(loaderWait, l.dispatch)
+		}
+		batch = l.pending
+		batch.ids = append(batch.ids, id)
+		l.batches[id] = batch
+	}
+	l.mu.Unlock()
+
+	<-batch.done
+	if batch.err != nil {
+		return nil, batch.err
+	}
+	return batch.toDo[id], nil
+}
+
+func (l *toDoLoader) dispatch() {
+	l.mu.Lock()
+	batch := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+
+	// 要求された順によらず同じ順で読む
+	sort.Slice(batch.ids, func(i, j int) bool { return batch.ids[i] < batch.ids[j] })
+	batch.toDo, batch.err = l.service.ReadByIds(batch.ids)
+	close(batch.done)
+}
+
+// Forget the ToDo read, called before a mutation changes them
+func (l *toDoLoader) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.batches = map[int64]*toDoBatch{}
+}
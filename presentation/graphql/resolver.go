@@ -0,0 +1,506 @@
+package graphql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	_ "embed"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+)
+
+// The schema served at /graphql
+//
+//go:embed schema.graphql
+var schemaSDL string
+
+const (
+	// max number of ToDo in a page of todos (the default is in the schema)
+	maxPageSize = 100
+	// prefix of the cursors before encoding to base64
+	cursorPrefix = "todo:"
+)
+
+// returned by the mutations of a GET request, which must not change anything
+var errMutationNotAllowed = errors.New("mutations must be sent with POST")
+
+// Build the schema of the ToDo API with the resolvers delegating to ToDoService
+func newToDoSchema(toDoService service.ToDoService) (*graphql.Schema, error) {
+	return graphql.ParseSchema(schemaSDL, &resolver{service: toDoService}, graphql.UseStringDescriptions())
+}
+
+// Information of the HTTP request or the WebSocket operation passed to the resolvers in the context
+type request struct {
+	// who made the request and its ID, recorded in the history by the mutations
+	actor     string
+	requestId string
+	loader    *toDoLoader
+	// false for GET requests
+	allowMutation bool
+	// set to 1 when todoChanged ends because the client could not keep up
+	overflowed int32
+}
+
+type requestKey struct{}
+
+func withRequest(ctx context.Context, r *request) context.Context {
+	return context.WithValue(ctx, requestKey{}, r)
+}
+
+func requestFrom(ctx context.Context) *request {
+	return ctx.Value(requestKey{}).(*request)
+}
+
+// 履歴に記録するリクエストの情報を設定したToDo
+func (r *request) newToDo(id objectId) *service.ToDoObject {
+	return &service.ToDoObject{Id: int64(id), Actor: r.actor, RequestId: r.requestId}
+}
+
+// ミューテーションを実行してよいかを確認する
+func (r *request) mutation() error {
+	if !r.allowMutation {
+		return errMutationNotAllowed
+	}
+	// 変更したToDoを読み直させる
+	r.loader.clear()
+	return nil
+}
+
+// ID scalar of the schema, accepted as a string or an integer and returned as a string
+type objectId int64
+
+func (objectId) ImplementsGraphQLType(name string) bool {
+	return name == "ID"
+}
+
+func (id *objectId) UnmarshalGraphQL(input interface{}) error {
+	switch input := input.(type) {
+	case string:
+		parsed, err := strconv.ParseInt(input, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid ID %q", input)
+		}
+		*id = objectId(parsed)
+	case int32:
+		*id = objectId(input)
+	case float64:
+		// JSONの変数の数値
+		if input != math.Trunc(input) {
+			return fmt.Errorf("invalid ID %v", input)
+		}
+		*id = objectId(input)
+	default:
+		return fmt.Errorf("invalid ID of type %T", input)
+	}
+	return nil
+}
+
+func (id objectId) MarshalJSON() ([]byte, error) {
+	return strconv.AppendQuote(nil, strconv.FormatInt(int64(id), 10)), nil
+}
+
+// DateTime scalar of the schema in RFC 3339
+type dateTime struct {
+	time.Time
+}
+
+func (dateTime) ImplementsGraphQLType(name string) bool {
+	return name == "DateTime"
+}
+
+func (t *dateTime) UnmarshalGraphQL(input interface{}) error {
+	s, ok := input.(string)
+	if !ok {
+		return fmt.Errorf("DateTime must be a string, not %T", input)
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return fmt.Errorf("invalid DateTime %q", s)
+	}
+	t.Time = parsed
+	return nil
+}
+
+func (t dateTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Time)
+}
+
+// nilの時刻はnullにする
+func optionalDateTime(t *time.Time) *dateTime {
+	if t == nil {
+		return nil
+	}
+	return &dateTime{*t}
+}
+
+// サービスのエラーをレスポンスのメッセージにする
+func serviceError(id int64, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("ToDo %d not found", id)
+	}
+	return err
+}
+
+// Root of the queries, the mutations and the subscriptions
+type resolver struct {
+	service service.ToDoService
+}
+
+func (r *resolver) Todo(ctx context.Context, args struct{ Id objectId }) (*toDoResolver, error) {
+	toDo, err := requestFrom(ctx).loader.load(int64(args.Id))
+	if err != nil || toDo == nil {
+		return nil, err
+	}
+	return &toDoResolver{toDo}, nil
+}
+
+func (r *resolver) Todos(ctx context.Context, args struct {
+	Done *bool
+	// the arguments with the default value are not nil
+	IncludeArchived bool
+	AsOf            *dateTime
+	First           int32
+	After           *string
+}) (*toDoConnectionResolver, error) {
+	option := &service.ListOption{}
+	if args.Done != nil {
+		option.Done = strconv.FormatBool(*args.Done)
+	}
+	if args.IncludeArchived {
+		option.Include = "archived"
+	}
+	if args.AsOf != nil {
+		option.AsOf = &args.AsOf.Time
+	}
+	first := int(args.First)
+	if first < 1 || first > maxPageSize {
+		return nil, fmt.Errorf("first must be between 1 and %d", maxPageSize)
+	}
+	var after int64
+	if args.After != nil {
+		var err error
+		if after, err = decodeCursor(*args.After); err != nil {
+			return nil, err
+		}
+	}
+
+	// カーソルのIDより後から1ページ分だけ読む
+	page, err := r.service.ListPage(option, after, first)
+	if err != nil {
+		return nil, err
+	}
+	return &toDoConnectionResolver{service: r.service, option: option, page: page}, nil
+}
+
+// Fields of CreateToDoInput
+type createToDoInput struct {
+	Title  string
+	Status *string
+	Done   *bool
+}
+
+// Fields of UpdateToDoInput
+type updateToDoInput struct {
+	Title  *string
+	Status *string
+	Done   *bool
+}
+
+func (r *resolver) CreateToDo(ctx context.Context, args struct{ Input createToDoInput }) (*toDoResolver, error) {
+	request := requestFrom(ctx)
+	if err := request.mutation(); err != nil {
+		return nil, err
+	}
+	toDo := request.newToDo(0)
+	toDo.Title = args.Input.Title
+	if args.Input.Status != nil {
+		toDo.Status = *args.Input.Status
+	}
+	if args.Input.Done != nil {
+		toDo.Done = *args.Input.Done
+	}
+	result, err := r.service.Create(toDo)
+	if err != nil {
+		return nil, err
+	}
+	return &toDoResolver{result}, nil
+}
+
+func (r *resolver) UpdateToDo(ctx context.Context, args struct {
+	Id    objectId
+	Input updateToDoInput
+}) (*toDoResolver, error) {
+	request := requestFrom(ctx)
+	if err := request.mutation(); err != nil {
+		return nil, err
+	}
+	// 入力にないフィールドはサービスがロックした時点の値のままにする
+	result, err := r.service.Patch(&service.ToDoPatchObject{
+		Id:        int64(args.Id),
+		Title:     args.Input.Title,
+		Status:    args.Input.Status,
+		Done:      args.Input.Done,
+		Actor:     request.actor,
+		RequestId: request.requestId,
+	})
+	if err != nil {
+		return nil, serviceError(int64(args.Id), err)
+	}
+	return &toDoResolver{result}, nil
+}
+
+func (r *resolver) DeleteToDo(ctx context.Context, args struct{ Id objectId }) (*toDoResolver, error) {
+	request := requestFrom(ctx)
+	if err := request.mutation(); err != nil {
+		return nil, err
+	}
+	result, err := r.service.Delete(request.newToDo(args.Id))
+	if err != nil {
+		return nil, serviceError(int64(args.Id), err)
+	}
+	return &toDoResolver{result}, nil
+}
+
+func (r *resolver) ToggleToDo(ctx context.Context, args struct{ Id objectId }) (*toDoResolver, error) {
+	request := requestFrom(ctx)
+	if err := request.mutation(); err != nil {
+		return nil, err
+	}
+	result, err := r.service.Toggle(request.newToDo(args.Id))
+	if err != nil {
+		return nil, serviceError(int64(args.Id), err)
+	}
+	return &toDoResolver{result}, nil
+}
+
+// Send the events until the context is canceled or the client cannot keep up
+func (r *resolver) TodoChanged(ctx context.Context, args struct {
+	Ids         *[]objectId
+	Done        *bool
+	LastEventId *objectId
+}) (<-chan *toDoEventResolver, error) {
+	ids := map[int64]bool{}
+	if args.Ids != nil {
+		for _, id := range *args.Ids {
+			ids[int64(id)] = true
+		}
+	}
+	var lastEventId int64
+	if args.LastEventId != nil {
+		lastEventId = int64(*args.LastEventId)
+	}
+	matches := func(event *service.EventObject) bool {
+		return (len(ids) == 0 || ids[event.ToDo.Id]) && (args.Done == nil || event.ToDo.Done == *args.Done)
+	}
+
+	request := requestFrom(ctx)
+	subscription := r.service.Subscribe(lastEventId)
+	events := make(chan *toDoEventResolver)
+	go func() {
+		defer close(events)
+		defer subscription.Close()
+		send := func(event *service.EventObject) bool {
+			select {
+			case events <- &toDoEventResolver{event}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if subscription.Gap {
+			// 取りこぼしたイベントがあるため、クライアントにToDoを読み直させる
+			if !send(&service.EventObject{Type: "reset"}) {
+				return
+			}
+		}
+		for i := range subscription.Missed {
+			if matches(&subscription.Missed[i]) && !send(&subscription.Missed[i]) {
+				return
+			}
+		}
+		for {
+			select {
+			case event, ok := <-subscription.Events:
+				if !ok {
+					atomic.StoreInt32(&request.overflowed, 1)
+					return
+				}
+				if matches(&event) && !send(&event) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+type toDoResolver struct {
+	toDo *service.ToDoObject
+}
+
+func (r *toDoResolver) Id() objectId {
+	return objectId(r.toDo.Id)
+}
+
+func (r *toDoResolver) Title() string {
+	return r.toDo.Title
+}
+
+func (r *toDoResolver) Status() string {
+	return r.toDo.Status
+}
+
+func (r *toDoResolver) Done() bool {
+	return r.toDo.Done
+}
+
+func (r *toDoResolver) Archived() bool {
+	return r.toDo.Archived
+}
+
+func (r *toDoResolver) CreatedAt() dateTime {
+	return dateTime{r.toDo.CreatedAt}
+}
+
+func (r *toDoResolver) UpdatedAt() dateTime {
+	return dateTime{r.toDo.UpdatedAt}
+}
+
+func (r *toDoResolver) ArchivedAt() *dateTime {
+	return optionalDateTime(r.toDo.ArchivedAt)
+}
+
+func (r *toDoResolver) DeletedAt() *dateTime {
+	return optionalDateTime(r.toDo.DeletedAt)
+}
+
+type toDoConnectionResolver struct {
+	service service.ToDoService
+	option  *service.ListOption
+	page    *service.ToDoPageObject
+}
+
+func (r *toDoConnectionResolver) Edges() []*toDoEdgeResolver {
+	edges := make([]*toDoEdgeResolver, len(r.page.ToDo))
+	for i := range r.page.ToDo {
+		edges[i] = &toDoEdgeResolver{&r.page.ToDo[i]}
+	}
+	return edges
+}
+
+func (r *toDoConnectionResolver) Nodes() []*toDoResolver {
+	nodes := make([]*toDoResolver, len(r.page.ToDo))
+	for i := range r.page.ToDo {
+		nodes[i] = &toDoResolver{&r.page.ToDo[i]}
+	}
+	return nodes
+}
+
+func (r *toDoConnectionResolver) PageInfo() *pageInfoResolver {
+	info := &pageInfoResolver{hasNextPage: r.page.HasNext}
+	if n := len(r.page.ToDo); n > 0 {
+		cursor := encodeCursor(r.page.ToDo[n-1].Id)
+		info.endCursor = &cursor
+	}
+	return info
+}
+
+// 全ページの件数は選択された場合のみ数える
+func (r *toDoConnectionResolver) TotalCount() (int32, error) {
+	count, err := r.service.Count(r.option)
+	if err != nil {
+		return 0, err
+	}
+	return int32(count), nil
+}
+
+type toDoEdgeResolver struct {
+	toDo *service.ToDoObject
+}
+
+func (r *toDoEdgeResolver) Cursor() string {
+	return encodeCursor(r.toDo.Id)
+}
+
+func (r *toDoEdgeResolver) Node() *toDoResolver {
+	return &toDoResolver{r.toDo}
+}
+
+type pageInfoResolver struct {
+	hasNextPage bool
+	endCursor   *string
+}
+
+func (r *pageInfoResolver) HasNextPage() bool {
+	return r.hasNextPage
+}
+
+func (r *pageInfoResolver) EndCursor() *string {
+	return r.endCursor
+}
+
+// ToDoEvent type of the schema, the ID, the ToDo and the time are null for reset
+type toDoEventResolver struct {
+	event *service.EventObject
+}
+
+func (r *toDoEventResolver) reset() bool {
+	return r.event.Type == "reset"
+}
+
+func (r *toDoEventResolver) Id() *objectId {
+	if r.reset() {
+		return nil
+	}
+	id := objectId(r.event.Id)
+	return &id
+}
+
+func (r *toDoEventResolver) Type() string {
+	return r.event.Type
+}
+
+func (r *toDoEventResolver) Todo() *toDoResolver {
+	if r.reset() {
+		return nil
+	}
+	return &toDoResolver{&r.event.ToDo}
+}
+
+func (r *toDoEventResolver) Completed() bool {
+	return r.event.Completed
+}
+
+func (r *toDoEventResolver) CreatedAt() *dateTime {
+	if r.reset() {
+		return nil
+	}
+	return &dateTime{r.event.CreatedAt}
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil && strings.HasPrefix(string(decoded), cursorPrefix) {
+		id, err := strconv.ParseInt(strings.TrimPrefix(string(decoded), cursorPrefix), 10, 64)
+		if err == nil {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid cursor %q", cursor)
+}
@@ -0,0 +1,272 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	graphql "github.com/graph-gophers/graphql-go"
+	qerrors "github.com/graph-gophers/graphql-go/errors"
+)
+
+const (
+	// subprotocol of https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+	webSocketSubprotocol = "graphql-transport-ws"
+	// time allowed for the client to send connection_init
+	connectionInitWait = 10 * time.Second
+	// time allowed to write a message to the client
+	webSocketWriteWait = 10 * time.Second
+	// time allowed to read the next message or pong from the client
+	webSocketPongWait = 60 * time.Second
+	// interval of pings, must be shorter than webSocketPongWait
+	webSocketPingInterval = webSocketPongWait * 9 / 10
+)
+
+// Close codes of graphql-transport-ws
+const (
+	closeInvalidMessage           = 4400
+	closeUnauthorized             = 4401
+	closeSubprotocolNotAcceptable = 4406
+	closeInitTimeout              = 4408
+	closeSubscriberExists         = 4409
+	closeTooManyInitRequests      = 4429
+)
+
+var upgrader = websocket.Upgrader{Subprotocols: []string{webSocketSubprotocol}}
+
+// Message of graphql-transport-ws
+type webSocketMessage struct {
+	// "connection_init", "connection_ack", "ping", "pong", "subscribe", "next", "error" or "complete"
+	Type    string          `json:"type"`
+	Id      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Connection of a WebSocket client
+type webSocketConnection struct {
+	handler *handler
+	conn    *websocket.Conn
+	r       *http.Request
+	// writes are serialized because the subscriptions write from their goroutines
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	// stop funcs of the running operations by the ID
+	operations map[string]func()
+}
+
+func (h *handler) Subscribe() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgradeがエラーレスポンスを返している
+			return
+		}
+		defer conn.Close()
+		c := &webSocketConnection{handler: h, conn: conn, r: r, operations: map[string]func(){}}
+		defer c.stopAll()
+		if conn.Subprotocol() != webSocketSubprotocol {
+			c.close(closeSubprotocolNotAcceptable, "Subprotocol not acceptable")
+			return
+		}
+
+		done := make(chan struct{})
+		defer close(done)
+		go c.ping(done)
+
+		conn.SetReadDeadline(time.Now().Add(connectionInitWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+		})
+		acknowledged := false
+		for {
+			message := &webSocketMessage{}
+			err := conn.ReadJSON(message)
+			if err != nil {
+				switch e := err.(type) {
+				case *websocket.CloseError:
+				case *json.SyntaxError, *json.UnmarshalTypeError:
+					c.close(closeInvalidMessage, "Invalid message received")
+				default:
+					if !acknowledged {
+						c.close(closeInitTimeout, "Connection initialisation timeout")
+					} else {
+						log.Println("failed to read WebSocket message:", e)
+					}
+				}
+				return
+			}
+
+			switch message.Type {
+			case "connection_init":
+				if acknowledged {
+					c.close(closeTooManyInitRequests, "Too many initialisation requests")
+					return
+				}
+				acknowledged = true
+				conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+				c.write(&webSocketMessage{Type: "connection_ack"})
+			case "ping":
+				c.write(&webSocketMessage{Type: "pong"})
+			case "pong":
+			case "subscribe":
+				if !acknowledged {
+					c.close(closeUnauthorized, "Unauthorized")
+					return
+				}
+				if message.Id == "" {
+					c.close(closeInvalidMessage, "Invalid message received")
+					return
+				}
+				if !c.start(message) {
+					return
+				}
+			case "complete":
+				c.stop(message.Id)
+			default:
+				c.close(closeInvalidMessage, fmt.Sprintf("Invalid message type %q", message.Type))
+				return
+			}
+		}
+	}
+}
+
+// Start the operation of the subscribe message, false if the connection is closed
+func (c *webSocketConnection) start(message *webSocketMessage) bool {
+	c.mu.Lock()
+	_, exists := c.operations[message.Id]
+	c.mu.Unlock()
+	if exists {
+		c.close(closeSubscriberExists, fmt.Sprintf("Subscriber for %s already exists", message.Id))
+		return false
+	}
+	params := &requestParams{}
+	if err := json.Unmarshal(message.Payload, params); err != nil {
+		c.close(closeInvalidMessage, "Invalid message received")
+		return false
+	}
+
+	// クエリとミューテーションも結果を1つ送って閉じるチャネルで返る
+	ctx, cancel := context.WithCancel(context.Background())
+	request := c.handler.newRequest(c.r, true)
+	stream, err := c.handler.schema.Subscribe(withRequest(ctx, request), params.Query, params.OperationName, params.Variables)
+	if err != nil {
+		cancel()
+		c.writeErrors(message.Id, []*qerrors.QueryError{{Message: err.Error()}})
+		return true
+	}
+	stopped := make(chan struct{})
+	c.mu.Lock()
+	c.operations[message.Id] = func() {
+		close(stopped)
+		cancel()
+	}
+	c.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		var errors []*qerrors.QueryError
+		for r := range stream {
+			response := r.(*graphql.Response)
+			if response.Data == nil {
+				// 構文や検証のエラーで実行されなかった
+				errors = response.Errors
+				continue
+			}
+			c.writeNext(message.Id, response)
+		}
+		select {
+		case <-stopped:
+			// クライアントが止めた
+			return
+		default:
+		}
+		c.mu.Lock()
+		delete(c.operations, message.Id)
+		c.mu.Unlock()
+		switch {
+		case len(errors) > 0:
+			c.writeErrors(message.Id, errors)
+		case atomic.LoadInt32(&request.overflowed) == 1:
+			// 追いつけずに購読が切れたため、クライアントにlastEventIdで購読し直させる
+			c.writeErrors(message.Id, []*qerrors.QueryError{{Message: "too many events to send, subscribe again with lastEventId"}})
+		default:
+			c.write(&webSocketMessage{Type: "complete", Id: message.Id})
+		}
+	}()
+	return true
+}
+
+// Stop the operation by the ID, the ID can be reused after that
+func (c *webSocketConnection) stop(id string) {
+	c.mu.Lock()
+	stop, ok := c.operations[id]
+	delete(c.operations, id)
+	c.mu.Unlock()
+	if ok {
+		stop()
+	}
+}
+
+func (c *webSocketConnection) stopAll() {
+	c.mu.Lock()
+	operations := c.operations
+	c.operations = map[string]func(){}
+	c.mu.Unlock()
+	for _, stop := range operations {
+		stop()
+	}
+}
+
+func (c *webSocketConnection) writeNext(id string, r *graphql.Response) {
+	payload, err := json.Marshal(r)
+	if err != nil {
+		log.Println("failed to encode GraphQL response:", err)
+		return
+	}
+	c.write(&webSocketMessage{Type: "next", Id: id, Payload: payload})
+}
+
+func (c *webSocketConnection) writeErrors(id string, errors []*qerrors.QueryError) {
+	payload, _ := json.Marshal(errors)
+	c.write(&webSocketMessage{Type: "error", Id: id, Payload: payload})
+}
+
+func (c *webSocketConnection) write(message *webSocketMessage) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteWait))
+	if err := c.conn.WriteJSON(message); err != nil {
+		c.conn.Close()
+	}
+}
+
+func (c *webSocketConnection) close(code int, reason string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(webSocketWriteWait))
+}
+
+func (c *webSocketConnection) ping(done <-chan struct{}) {
+	ticker := time.NewTicker(webSocketPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.writeMu.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(webSocketWriteWait))
+			c.writeMu.Unlock()
+			if err != nil {
+				c.conn.Close()
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
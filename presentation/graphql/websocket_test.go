@@ -0,0 +1,217 @@
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service/mock_service"
+)
+
+// graphql-transport-wsでつなぎ、connection_ackまで受け取ったクライアントを返す
+func dialGraphQL(t *testing.T, toDoService service.ToDoService) *websocket.Conn {
+	r := mux.NewRouter()
+	r.HandleFunc("/graphql", NewHandler(toDoService).Subscribe()).Methods(http.MethodGet)
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	dialer := websocket.Dialer{Subprotocols: []string{webSocketSubprotocol}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/graphql", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	conn.WriteJSON(webSocketMessage{Type: "connection_init"})
+	ack := readMessage(t, conn)
+	if ack.Type != "connection_ack" {
+		t.Fatalf("expected connection_ack, actual: %+v", ack)
+	}
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) *webSocketMessage {
+	message := &webSocketMessage{}
+	err := conn.ReadJSON(message)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return message
+}
+
+func subscribeMessage(id string, query string) *webSocketMessage {
+	payload, _ := json.Marshal(requestParams{Query: query})
+	return &webSocketMessage{Type: "subscribe", Id: id, Payload: payload}
+}
+
+func TestSubscription(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	events := make(chan service.EventObject, 10)
+	mockToDoService := mock_service.NewMockToDoService(ctrl)
+	mockToDoService.EXPECT().Subscribe(int64(5)).Return(&service.Subscription{
+		Events: events,
+		Missed: []service.EventObject{{Id: 5, Type: "created", ToDo: *testToDo(100, "missed", false), CreatedAt: testTime}},
+		Gap:    true,
+	}).Times(1)
+	conn := dialGraphQL(t, mockToDoService)
+
+	// Act
+	conn.WriteJSON(subscribeMessage("1", `subscription { todoChanged(ids: [100], lastEventId: 5) { id type todo { title } } }`))
+	events <- service.EventObject{Id: 6, Type: "updated", ToDo: *testToDo(200, "other", false)}
+	events <- service.EventObject{Id: 7, Type: "updated", ToDo: *testToDo(100, "renamed", true), Completed: true}
+	var payloads []string
+	for i := 0; i < 3; i++ {
+		message := readMessage(t, conn)
+		if message.Type != "next" || message.Id != "1" {
+			t.Fatalf("expected next for 1, actual: %+v", message)
+		}
+		payloads = append(payloads, string(message.Payload))
+	}
+	conn.WriteJSON(webSocketMessage{Type: "complete", Id: "1"})
+	conn.WriteJSON(webSocketMessage{Type: "ping"})
+	pong := readMessage(t, conn)
+
+	// Assert
+	expected := []string{
+		`{"data":{"todoChanged":{"id":null,"type":"reset","todo":null}}}`,
+		`{"data":{"todoChanged":{"id":"5","type":"created","todo":{"title":"missed"}}}}`,
+		`{"data":{"todoChanged":{"id":"7","type":"updated","todo":{"title":"renamed"}}}}`,
+	}
+	for i := range expected {
+		if payloads[i] != expected[i] {
+			t.Errorf("expected: %s\nactual:   %s", expected[i], payloads[i])
+		}
+	}
+	if pong.Type != "pong" {
+		t.Errorf("expected pong, actual: %+v", pong)
+	}
+}
+
+func TestSubscriptionClosed(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	events := make(chan service.EventObject)
+	mockToDoService := mock_service.NewMockToDoService(ctrl)
+	mockToDoService.EXPECT().Subscribe(int64(0)).Return(&service.Subscription{Events: events}).Times(1)
+	conn := dialGraphQL(t, mockToDoService)
+
+	// Act
+	conn.WriteJSON(subscribeMessage("1", `subscription { todoChanged { id } }`))
+	// 追いつけなかった購読はイベントのチャネルが閉じられる
+	close(events)
+	message := readMessage(t, conn)
+
+	// Assert
+	if message.Type != "error" || message.Id != "1" {
+		t.Fatalf("expected error for 1, actual: %+v", message)
+	}
+	if string(message.Payload) != `[{"message":"too many events to send, subscribe again with lastEventId"}]` {
+		t.Errorf("unexpected payload: %s", message.Payload)
+	}
+}
+
+func TestWebSocketOperation(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name        string
+		messages    []*webSocketMessage
+		wantType    string
+		wantPayload string
+		wantClose   int
+	}{
+		{
+			name:        "01_クエリを実行して結果を返すケース",
+			messages:    []*webSocketMessage{subscribeMessage("1", `{ todo(id: 1) { title } }`)},
+			wantType:    "next",
+			wantPayload: `{"data":{"todo":{"title":"first"}}}`,
+		},
+		{
+			name:        "02_検証に失敗したケース",
+			messages:    []*webSocketMessage{subscribeMessage("1", `subscription { todoChanged { owner } }`)},
+			wantType:    "error",
+			wantPayload: `[{"message":"Cannot query field \"owner\" on type \"ToDoEvent\".","locations":[{"line":1,"column":30}]}]`,
+		},
+		{
+			name:      "03_知らない種類のメッセージのケース",
+			messages:  []*webSocketMessage{{Type: "start", Id: "1"}},
+			wantClose: closeInvalidMessage,
+		},
+		{
+			name:      "04_connection_initを2回送ったケース",
+			messages:  []*webSocketMessage{{Type: "connection_init"}},
+			wantClose: closeTooManyInitRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			mockToDoService.EXPECT().ReadByIds([]int64{1}).Return(map[int64]*service.ToDoObject{1: testToDo(1, "first", false)}, nil).AnyTimes()
+			conn := dialGraphQL(t, mockToDoService)
+
+			// Act
+			for _, message := range tt.messages {
+				conn.WriteJSON(message)
+			}
+			message := &webSocketMessage{}
+			err := conn.ReadJSON(message)
+
+			// Assert
+			if tt.wantClose != 0 {
+				if !websocket.IsCloseError(err, tt.wantClose) {
+					t.Errorf("expected close %d, actual: %v", tt.wantClose, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if message.Type != tt.wantType || string(message.Payload) != tt.wantPayload {
+				t.Errorf("expected %s %s, actual: %s %s", tt.wantType, tt.wantPayload, message.Type, message.Payload)
+			}
+		})
+	}
+}
+
+func TestWebSocketSubprotocol(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	r := mux.NewRouter()
+	r.HandleFunc("/graphql", NewHandler(nil).Subscribe()).Methods(http.MethodGet)
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	// Act
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/graphql", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+
+	// Assert
+	if !websocket.IsCloseError(err, closeSubprotocolNotAcceptable) {
+		t.Errorf("expected close %d, actual: %v", closeSubprotocolNotAcceptable, err)
+	}
+}
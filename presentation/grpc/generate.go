@@ -0,0 +1,12 @@
+//go:build protoc
+// +build protoc
+
+package grpc
+
+// todo.protoを変更したらtodopbを生成し直してコミットする(go generate ./...ではprotocを必要としないよう実行しない)
+//
+//	go install google.golang.org/protobuf/cmd/protoc-gen-go@v1.27.1
+//	go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@v1.1.0
+//	go generate -tags protoc ./presentation/grpc
+
+//go:generate protoc -I . --go_out=. --go_opt=module=github.com/uzimihsr/todo-rest-api-golang/presentation/grpc --go-grpc_out=. --go-grpc_opt=module=github.com/uzimihsr/todo-rest-api-golang/presentation/grpc todo.proto
@@ -0,0 +1,106 @@
+package grpc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/grpc/todopb"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Condition of the events sent by Watch
+type watchFilter struct {
+	ids map[int64]bool
+	// nil if not filtered by done
+	done *bool
+}
+
+func newWatchFilter(request *todopb.WatchRequest) (*watchFilter, error) {
+	done, err := doneFilter(request.GetDone())
+	if err != nil {
+		return nil, err
+	}
+	filter := &watchFilter{ids: map[int64]bool{}, done: done}
+	for _, id := range request.GetIds() {
+		filter.ids[id] = true
+	}
+	return filter, nil
+}
+
+func (f *watchFilter) matches(event *service.EventObject) bool {
+	if len(f.ids) > 0 && !f.ids[event.ToDo.Id] {
+		return false
+	}
+	return f.done == nil || event.ToDo.Done == *f.done
+}
+
+func toDoMessage(toDo *service.ToDoObject) *todopb.ToDo {
+	return &todopb.ToDo{
+		Id:         toDo.Id,
+		Title:      toDo.Title,
+		Status:     toDo.Status,
+		Done:       toDo.Done,
+		Archived:   toDo.Archived,
+		CreatedAt:  timestamppb.New(toDo.CreatedAt),
+		UpdatedAt:  timestamppb.New(toDo.UpdatedAt),
+		ArchivedAt: timestampMessage(toDo.ArchivedAt),
+		DeletedAt:  timestampMessage(toDo.DeletedAt),
+	}
+}
+
+func eventMessage(event *service.EventObject) *todopb.Event {
+	return &todopb.Event{
+		Id:        event.Id,
+		Type:      event.Type,
+		Todo:      toDoMessage(&event.ToDo),
+		Completed: event.Completed,
+		CreatedAt: timestamppb.New(event.CreatedAt),
+	}
+}
+
+// nilの時刻はフィールドを設定しない
+func timestampMessage(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func listOption(request *todopb.ListToDoRequest) (*service.ListOption, error) {
+	option := &service.ListOption{}
+	done, err := doneFilter(request.GetDone())
+	if err != nil {
+		return nil, err
+	}
+	if done != nil {
+		option.Done = fmt.Sprint(*done)
+	}
+	if request.GetIncludeArchived() {
+		option.Include = "archived"
+	}
+	if request.AsOf != nil {
+		// protobufのエラーの文言は安定しないため、固定の文言を返す
+		if request.AsOf.CheckValid() != nil {
+			return nil, status.Error(codes.InvalidArgument, "as_of is out of the range of the timestamp")
+		}
+		asOf := request.AsOf.AsTime()
+		option.AsOf = &asOf
+	}
+	return option, nil
+}
+
+// DoneFilterをdoneの値にする(絞り込まない場合はnil)
+func doneFilter(filter todopb.DoneFilter) (*bool, error) {
+	switch filter {
+	case todopb.DoneFilter_DONE_FILTER_UNSPECIFIED:
+		return nil, nil
+	case todopb.DoneFilter_DONE_FILTER_DONE, todopb.DoneFilter_DONE_FILTER_NOT_DONE:
+		done := filter == todopb.DoneFilter_DONE_FILTER_DONE
+		return &done, nil
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown done filter %d", filter)
+	}
+}
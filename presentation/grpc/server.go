@@ -0,0 +1,173 @@
+// Package grpc serves the ToDo service over gRPC as defined in todo.proto.
+//
+// The messages and the service interface are generated into todopb by protoc-gen-go and protoc-gen-go-grpc
+// and committed, so that the build does not need protoc (see generate.go).
+package grpc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/grpc/todopb"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// metadata of the user who made the request
+	actorMetadata = "x-actor"
+	// metadata of the ID of the request
+	requestIdMetadata = "x-request-id"
+)
+
+type toDoServer struct {
+	todopb.UnimplementedToDoServiceServer
+	service service.ToDoService
+}
+
+// gRPC server with the ToDo service registered
+func NewToDoServer(service service.ToDoService) *grpc.Server {
+	server := grpc.NewServer()
+	todopb.RegisterToDoServiceServer(server, &toDoServer{service: service})
+	return server
+}
+
+func (s *toDoServer) Create(ctx context.Context, request *todopb.CreateToDoRequest) (*todopb.ToDo, error) {
+	toDo := &service.ToDoObject{
+		Title:  request.GetTitle(),
+		Status: request.GetStatus(),
+		Done:   request.GetDone(),
+	}
+	setRequestInfo(ctx, toDo)
+	result, err := s.service.Create(toDo)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return toDoMessage(result), nil
+}
+
+func (s *toDoServer) Read(ctx context.Context, request *todopb.ReadToDoRequest) (*todopb.ToDo, error) {
+	result, err := s.service.Read(&service.ToDoObject{Id: request.GetId()})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return toDoMessage(result), nil
+}
+
+func (s *toDoServer) Update(ctx context.Context, request *todopb.UpdateToDoRequest) (*todopb.ToDo, error) {
+	toDo := &service.ToDoObject{
+		Id:     request.GetId(),
+		Title:  request.GetTitle(),
+		Status: request.GetStatus(),
+		Done:   request.GetDone(),
+	}
+	setRequestInfo(ctx, toDo)
+	result, err := s.service.Update(toDo)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return toDoMessage(result), nil
+}
+
+func (s *toDoServer) Delete(ctx context.Context, request *todopb.DeleteToDoRequest) (*todopb.ToDo, error) {
+	toDo := &service.ToDoObject{Id: request.GetId()}
+	setRequestInfo(ctx, toDo)
+	result, err := s.service.Delete(toDo)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return toDoMessage(result), nil
+}
+
+func (s *toDoServer) List(ctx context.Context, request *todopb.ListToDoRequest) (*todopb.ListToDoResponse, error) {
+	option, err := listOption(request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.service.List(option)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	response := &todopb.ListToDoResponse{Todos: make([]*todopb.ToDo, 0, len(result))}
+	for i := range result {
+		response.Todos = append(response.Todos, toDoMessage(&result[i]))
+	}
+	return response, nil
+}
+
+// Send the events until the client cancels the call
+func (s *toDoServer) Watch(request *todopb.WatchRequest, stream todopb.ToDoService_WatchServer) error {
+	filter, err := newWatchFilter(request)
+	if err != nil {
+		return err
+	}
+	subscription := s.service.Subscribe(request.GetLastEventId())
+	defer subscription.Close()
+
+	if subscription.Gap {
+		// 取りこぼしたイベントがあるため、クライアントに一覧を取り直させる
+		err = stream.Send(&todopb.Event{Type: "reset"})
+		if err != nil {
+			return err
+		}
+	}
+	for i := range subscription.Missed {
+		err = sendEvent(stream, filter, &subscription.Missed[i])
+		if err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return status.Error(codes.Unavailable, "too many events to send, watch again with last_event_id")
+			}
+			err = sendEvent(stream, filter, &event)
+			if err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// 購読の条件に合うイベントのみ送る
+func sendEvent(stream todopb.ToDoService_WatchServer, filter *watchFilter, event *service.EventObject) error {
+	if !filter.matches(event) {
+		return nil
+	}
+	return stream.Send(eventMessage(event))
+}
+
+// 履歴に記録する操作者とリクエストIDをメタデータから取得する
+func setRequestInfo(ctx context.Context, toDo *service.ToDoObject) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(actorMetadata); len(values) > 0 {
+		toDo.Actor = values[0]
+	}
+	if values := md.Get(requestIdMetadata); len(values) > 0 {
+		toDo.RequestId = values[0]
+	}
+}
+
+// サービスのエラーを対応するgRPCのステータスにする
+func toStatusError(err error) error {
+	code := codes.Unknown
+	switch {
+	case errors.Is(err, service.ErrInvalidStatus):
+		code = codes.InvalidArgument
+	case errors.Is(err, service.ErrInvalidTransition):
+		code = codes.FailedPrecondition
+	case errors.Is(err, sql.ErrNoRows):
+		code = codes.NotFound
+	case errors.Is(err, service.ErrTemporalQueryUnsupported):
+		code = codes.Unimplemented
+	}
+	return status.Error(code, err.Error())
+}
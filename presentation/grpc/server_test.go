@@ -0,0 +1,321 @@
+package grpc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/grpc/todopb"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service/mock_service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// サーバーをメモリ上の接続で動かし、生成されたクライアントで接続する
+func newTestClient(t *testing.T, mockToDoService service.ToDoService) (todopb.ToDoServiceClient, *grpc.ClientConn) {
+	listener := bufconn.Listen(1 << 20)
+	server := NewToDoServer(mockToDoService)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() { conn.Close() })
+	return todopb.NewToDoServiceClient(conn), conn
+}
+
+// レスポンスのToDoをサービスのオブジェクトに戻す
+func toDoObject(toDo *todopb.ToDo) service.ToDoObject {
+	object := service.ToDoObject{
+		Id:        toDo.Id,
+		Title:     toDo.Title,
+		Status:    toDo.Status,
+		Done:      toDo.Done,
+		Archived:  toDo.Archived,
+		CreatedAt: toDo.CreatedAt.AsTime(),
+		UpdatedAt: toDo.UpdatedAt.AsTime(),
+	}
+	if toDo.ArchivedAt != nil {
+		archivedAt := toDo.ArchivedAt.AsTime()
+		object.ArchivedAt = &archivedAt
+	}
+	if toDo.DeletedAt != nil {
+		deletedAt := toDo.DeletedAt.AsTime()
+		object.DeletedAt = &deletedAt
+	}
+	return object
+}
+
+func TestToDoServer(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	t0 := time.Date(2021, 6, 15, 9, 35, 7, 123456789, time.UTC)
+	archivedAt := t0.Add(time.Hour)
+	pencil := service.ToDoObject{Id: 1, Title: "Buy a new pencil", Status: "todo", CreatedAt: t0, UpdatedAt: t0}
+	eraser := service.ToDoObject{Id: 2, Title: "Buy a new eraser", Status: "done", Done: true, Archived: true, CreatedAt: t0, UpdatedAt: archivedAt, ArchivedAt: &archivedAt}
+	// 1件を返すメソッドの結果を一覧にする
+	one := func(toDo *todopb.ToDo, err error) ([]*todopb.ToDo, error) {
+		if err != nil {
+			return nil, err
+		}
+		return []*todopb.ToDo{toDo}, nil
+	}
+	tests := []struct {
+		name            string
+		call            func(ctx context.Context, c todopb.ToDoServiceClient, conn *grpc.ClientConn) ([]*todopb.ToDo, error)
+		metadata        metadata.MD
+		prepare         func(m *mock_service.MockToDoService)
+		expectedToDo    []service.ToDoObject
+		expectedCode    codes.Code
+		expectedMessage string
+	}{
+		{
+			name: "01_ToDoを作成するケース",
+			call: func(ctx context.Context, c todopb.ToDoServiceClient, conn *grpc.ClientConn) ([]*todopb.ToDo, error) {
+				return one(c.Create(ctx, &todopb.CreateToDoRequest{Title: "Buy a new pencil"}))
+			},
+			metadata: metadata.Pairs("x-actor", "alice", "x-request-id", "req-1"),
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Create(&service.ToDoObject{Title: "Buy a new pencil", Actor: "alice", RequestId: "req-1"}).Return(&pencil, nil).Times(1)
+			},
+			expectedToDo: []service.ToDoObject{pencil},
+		},
+		{
+			name: "02_ToDoを読むケース",
+			call: func(ctx context.Context, c todopb.ToDoServiceClient, conn *grpc.ClientConn) ([]*todopb.ToDo, error) {
+				return one(c.Read(ctx, &todopb.ReadToDoRequest{Id: 2}))
+			},
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Read(&service.ToDoObject{Id: 2}).Return(&eraser, nil).Times(1)
+			},
+			expectedToDo: []service.ToDoObject{eraser},
+		},
+		{
+			name: "03_ToDoを更新するケース",
+			call: func(ctx context.Context, c todopb.ToDoServiceClient, conn *grpc.ClientConn) ([]*todopb.ToDo, error) {
+				return one(c.Update(ctx, &todopb.UpdateToDoRequest{Id: 1, Done: true}))
+			},
+			metadata: metadata.Pairs("x-actor", "alice"),
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Update(&service.ToDoObject{Id: 1, Done: true, Actor: "alice"}).Return(&pencil, nil).Times(1)
+			},
+			expectedToDo: []service.ToDoObject{pencil},
+		},
+		{
+			name: "04_ToDoを削除するケース",
+			call: func(ctx context.Context, c todopb.ToDoServiceClient, conn *grpc.ClientConn) ([]*todopb.ToDo, error) {
+				return one(c.Delete(ctx, &todopb.DeleteToDoRequest{Id: 1}))
+			},
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Delete(&service.ToDoObject{Id: 1}).Return(&pencil, nil).Times(1)
+			},
+			expectedToDo: []service.ToDoObject{pencil},
+		},
+		{
+			name: "05_完了したToDoをアーカイブも含めて一覧するケース",
+			call: func(ctx context.Context, c todopb.ToDoServiceClient, conn *grpc.ClientConn) ([]*todopb.ToDo, error) {
+				response, err := c.List(ctx, &todopb.ListToDoRequest{Done: todopb.DoneFilter_DONE_FILTER_DONE, IncludeArchived: true})
+				return response.GetTodos(), err
+			},
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().List(&service.ListOption{Done: "true", Include: "archived"}).Return([]service.ToDoObject{pencil, eraser}, nil).Times(1)
+			},
+			expectedToDo: []service.ToDoObject{pencil, eraser},
+		},
+		{
+			name: "06_過去の時点の一覧が読めないケース",
+			call: func(ctx context.Context, c todopb.ToDoServiceClient, conn *grpc.ClientConn) ([]*todopb.ToDo, error) {
+				response, err := c.List(ctx, &todopb.ListToDoRequest{AsOf: timestamppb.New(t0)})
+				return response.GetTodos(), err
+			},
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().List(&service.ListOption{AsOf: &t0}).Return(nil, service.ErrTemporalQueryUnsupported).Times(1)
+			},
+			expectedCode:    codes.Unimplemented,
+			expectedMessage: "temporal query is not supported",
+		},
+		{
+			name: "07_ToDoが存在しないケース",
+			call: func(ctx context.Context, c todopb.ToDoServiceClient, conn *grpc.ClientConn) ([]*todopb.ToDo, error) {
+				return one(c.Read(ctx, &todopb.ReadToDoRequest{Id: 3}))
+			},
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Read(&service.ToDoObject{Id: 3}).Return(nil, sql.ErrNoRows).Times(1)
+			},
+			expectedCode:    codes.NotFound,
+			expectedMessage: "sql: no rows in result set",
+		},
+		{
+			name: "08_許可されていない遷移のケース",
+			call: func(ctx context.Context, c todopb.ToDoServiceClient, conn *grpc.ClientConn) ([]*todopb.ToDo, error) {
+				return one(c.Update(ctx, &todopb.UpdateToDoRequest{Id: 2, Status: "review"}))
+			},
+			prepare: func(m *mock_service.MockToDoService) {
+				m.EXPECT().Update(&service.ToDoObject{Id: 2, Status: "review"}).Return(nil, service.ErrInvalidTransition).Times(1)
+			},
+			expectedCode:    codes.FailedPrecondition,
+			expectedMessage: "invalid status transition",
+		},
+		{
+			name: "09_不正な時刻のケース",
+			call: func(ctx context.Context, c todopb.ToDoServiceClient, conn *grpc.ClientConn) ([]*todopb.ToDo, error) {
+				response, err := c.List(ctx, &todopb.ListToDoRequest{AsOf: &timestamppb.Timestamp{Nanos: -1}})
+				return response.GetTodos(), err
+			},
+			prepare:         func(m *mock_service.MockToDoService) {},
+			expectedCode:    codes.InvalidArgument,
+			expectedMessage: "as_of is out of the range of the timestamp",
+		},
+		{
+			name: "10_知らないDoneFilterのケース",
+			call: func(ctx context.Context, c todopb.ToDoServiceClient, conn *grpc.ClientConn) ([]*todopb.ToDo, error) {
+				response, err := c.List(ctx, &todopb.ListToDoRequest{Done: 3})
+				return response.GetTodos(), err
+			},
+			prepare:         func(m *mock_service.MockToDoService) {},
+			expectedCode:    codes.InvalidArgument,
+			expectedMessage: "unknown done filter 3",
+		},
+		{
+			name: "11_存在しないメソッドのケース",
+			call: func(ctx context.Context, c todopb.ToDoServiceClient, conn *grpc.ClientConn) ([]*todopb.ToDo, error) {
+				return nil, conn.Invoke(ctx, "/todo.v1.ToDoService/Restore", &todopb.ReadToDoRequest{Id: 1}, &todopb.ToDo{})
+			},
+			prepare:         func(m *mock_service.MockToDoService) {},
+			expectedCode:    codes.Unimplemented,
+			expectedMessage: "unknown method Restore for service todo.v1.ToDoService",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			tt.prepare(mockToDoService)
+			client, conn := newTestClient(t, mockToDoService)
+			ctx := metadata.NewOutgoingContext(context.Background(), tt.metadata)
+
+			// Act
+			result, err := tt.call(ctx, client, conn)
+
+			// Assert
+			actual := status.Convert(err)
+			if actual.Code() != tt.expectedCode || actual.Message() != tt.expectedMessage {
+				t.Errorf("expected: %s %q, actual: %s %q", tt.expectedCode, tt.expectedMessage, actual.Code(), actual.Message())
+			}
+			var actualToDo []service.ToDoObject
+			for _, toDo := range result {
+				actualToDo = append(actualToDo, toDoObject(toDo))
+			}
+			if !reflect.DeepEqual(actualToDo, tt.expectedToDo) {
+				t.Errorf("expected: %+v, actual: %+v", tt.expectedToDo, actualToDo)
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	t0 := time.Date(2021, 6, 15, 9, 35, 7, 0, time.UTC)
+	created := service.EventObject{Id: 6, Type: "created", ToDo: service.ToDoObject{Id: 1, Title: "Buy a new pencil", Status: "todo"}, CreatedAt: t0}
+	completed := service.EventObject{Id: 7, Type: "updated", ToDo: service.ToDoObject{Id: 1, Title: "Buy a new pencil", Status: "done", Done: true}, Completed: true, CreatedAt: t0}
+	other := service.EventObject{Id: 8, Type: "created", ToDo: service.ToDoObject{Id: 2, Title: "Buy a new eraser", Status: "todo"}, CreatedAt: t0}
+	tests := []struct {
+		name           string
+		request        *todopb.WatchRequest
+		gap            bool
+		missed         []service.EventObject
+		events         []service.EventObject
+		expectedEvents []string
+	}{
+		{
+			name:           "01_取りこぼしたイベントから再開するケース",
+			request:        &todopb.WatchRequest{LastEventId: 5},
+			missed:         []service.EventObject{created},
+			events:         []service.EventObject{completed, other},
+			expectedEvents: []string{"6 created 1", "7 updated 1", "8 created 2"},
+		},
+		{
+			name:           "02_保持されていないイベントがあるケース",
+			request:        &todopb.WatchRequest{LastEventId: 1},
+			gap:            true,
+			missed:         []service.EventObject{completed},
+			expectedEvents: []string{"0 reset 0", "7 updated 1"},
+		},
+		{
+			name:           "03_IDと完了で絞り込むケース",
+			request:        &todopb.WatchRequest{Ids: []int64{1, 3}, Done: todopb.DoneFilter_DONE_FILTER_DONE},
+			events:         []service.EventObject{created, completed, other},
+			expectedEvents: []string{"7 updated 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			events := make(chan service.EventObject, len(tt.events))
+			for _, event := range tt.events {
+				events <- event
+			}
+			// 送り終えたら購読が切れたものとして扱わせる
+			close(events)
+			mockToDoService.EXPECT().Subscribe(tt.request.LastEventId).Return(&service.Subscription{Events: events, Missed: tt.missed, Gap: tt.gap}).Times(1)
+			client, _ := newTestClient(t, mockToDoService)
+
+			// Act
+			stream, err := client.Watch(context.Background(), tt.request)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			var actualEvents []string
+			for {
+				event, err := stream.Recv()
+				if err != nil {
+					if err != io.EOF && status.Code(err) != codes.Unavailable {
+						t.Errorf("unexpected error: %v", err)
+					}
+					// 購読が切れたことはUnavailableで伝わる
+					if status.Code(err) != codes.Unavailable {
+						t.Errorf("expected: %s, actual: %v", codes.Unavailable, err)
+					}
+					break
+				}
+				actualEvents = append(actualEvents, fmt.Sprintf("%d %s %d", event.Id, event.Type, event.GetTodo().GetId()))
+			}
+
+			// Assert
+			if !reflect.DeepEqual(actualEvents, tt.expectedEvents) {
+				t.Errorf("expected: %v, actual: %v", tt.expectedEvents, actualEvents)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package todopb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ToDoServiceClient is the client API for ToDoService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ToDoServiceClient interface {
+	Create(ctx context.Context, in *CreateToDoRequest, opts ...grpc.CallOption) (*ToDo, error)
+	Read(ctx context.Context, in *ReadToDoRequest, opts ...grpc.CallOption) (*ToDo, error)
+	Update(ctx context.Context, in *UpdateToDoRequest, opts ...grpc.CallOption) (*ToDo, error)
+	// Moves the ToDo to the trash.
+	Delete(ctx context.Context, in *DeleteToDoRequest, opts ...grpc.CallOption) (*ToDo, error)
+	List(ctx context.Context, in *ListToDoRequest, opts ...grpc.CallOption) (*ListToDoResponse, error)
+	// Streams the changes of ToDo until the call is canceled.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (ToDoService_WatchClient, error)
+}
+
+type toDoServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewToDoServiceClient(cc grpc.ClientConnInterface) ToDoServiceClient {
+	return &toDoServiceClient{cc}
+}
+
+func (c *toDoServiceClient) Create(ctx context.Context, in *CreateToDoRequest, opts ...grpc.CallOption) (*ToDo, error) {
+	out := new(ToDo)
+	err := c.cc.Invoke(ctx, "/todo.v1.ToDoService/Create", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *toDoServiceClient) Read(ctx context.Context, in *ReadToDoRequest, opts ...grpc.CallOption) (*ToDo, error) {
+	out := new(ToDo)
+	err := c.cc.Invoke(ctx, "/todo.v1.ToDoService/Read", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *toDoServiceClient) Update(ctx context.Context, in *UpdateToDoRequest, opts ...grpc.CallOption) (*ToDo, error) {
+	out := new(ToDo)
+	err := c.cc.Invoke(ctx, "/todo.v1.ToDoService/Update", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *toDoServiceClient) Delete(ctx context.Context, in *DeleteToDoRequest, opts ...grpc.CallOption) (*ToDo, error) {
+	out := new(ToDo)
+	err := c.cc.Invoke(ctx, "/todo.v1.ToDoService/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *toDoServiceClient) List(ctx context.Context, in *ListToDoRequest, opts ...grpc.CallOption) (*ListToDoResponse, error) {
+	out := new(ListToDoResponse)
+	err := c.cc.Invoke(ctx, "/todo.v1.ToDoService/List", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *toDoServiceClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (ToDoService_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ToDoService_ServiceDesc.Streams[0], "/todo.v1.ToDoService/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &toDoServiceWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ToDoService_WatchClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type toDoServiceWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *toDoServiceWatchClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ToDoServiceServer is the server API for ToDoService service.
+// All implementations must embed UnimplementedToDoServiceServer
+// for forward compatibility
+type ToDoServiceServer interface {
+	Create(context.Context, *CreateToDoRequest) (*ToDo, error)
+	Read(context.Context, *ReadToDoRequest) (*ToDo, error)
+	Update(context.Context, *UpdateToDoRequest) (*ToDo, error)
+	// Moves the ToDo to the trash.
+	Delete(context.Context, *DeleteToDoRequest) (*ToDo, error)
+	List(context.Context, *ListToDoRequest) (*ListToDoResponse, error)
+	// Streams the changes of ToDo until the call is canceled.
+	Watch(*WatchRequest, ToDoService_WatchServer) error
+	mustEmbedUnimplementedToDoServiceServer()
+}
+
+// UnimplementedToDoServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedToDoServiceServer struct {
+}
+
+func (UnimplementedToDoServiceServer) Create(context.Context, *CreateToDoRequest) (*ToDo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedToDoServiceServer) Read(context.Context, *ReadToDoRequest) (*ToDo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Read not implemented")
+}
+func (UnimplementedToDoServiceServer) Update(context.Context, *UpdateToDoRequest) (*ToDo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedToDoServiceServer) Delete(context.Context, *DeleteToDoRequest) (*ToDo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedToDoServiceServer) List(context.Context, *ListToDoRequest) (*ListToDoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedToDoServiceServer) Watch(*WatchRequest, ToDoService_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedToDoServiceServer) mustEmbedUnimplementedToDoServiceServer() {}
+
+// UnsafeToDoServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ToDoServiceServer will
+// result in compilation errors.
+type UnsafeToDoServiceServer interface {
+	mustEmbedUnimplementedToDoServiceServer()
+}
+
+func RegisterToDoServiceServer(s grpc.ServiceRegistrar, srv ToDoServiceServer) {
+	s.RegisterService(&ToDoService_ServiceDesc, srv)
+}
+
+func _ToDoService_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateToDoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ToDoServiceServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/todo.v1.ToDoService/Create",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ToDoServiceServer).Create(ctx, req.(*CreateToDoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ToDoService_Read_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadToDoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ToDoServiceServer).Read(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/todo.v1.ToDoService/Read",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ToDoServiceServer).Read(ctx, req.(*ReadToDoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ToDoService_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateToDoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ToDoServiceServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/todo.v1.ToDoService/Update",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ToDoServiceServer).Update(ctx, req.(*UpdateToDoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ToDoService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteToDoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ToDoServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/todo.v1.ToDoService/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ToDoServiceServer).Delete(ctx, req.(*DeleteToDoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ToDoService_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListToDoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ToDoServiceServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/todo.v1.ToDoService/List",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ToDoServiceServer).List(ctx, req.(*ListToDoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ToDoService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ToDoServiceServer).Watch(m, &toDoServiceWatchServer{stream})
+}
+
+type ToDoService_WatchServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type toDoServiceWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *toDoServiceWatchServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// ToDoService_ServiceDesc is the grpc.ServiceDesc for ToDoService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ToDoService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "todo.v1.ToDoService",
+	HandlerType: (*ToDoServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Create",
+			Handler:    _ToDoService_Create_Handler,
+		},
+		{
+			MethodName: "Read",
+			Handler:    _ToDoService_Read_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _ToDoService_Update_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _ToDoService_Delete_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _ToDoService_List_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _ToDoService_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "todo.proto",
+}
@@ -0,0 +1,628 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+)
+
+// Minimal CalDAV server (RFC 4791) for the task apps.
+// /caldav/ is the principal and the calendar home, and ToDo are the VTODO resources /caldav/todo/{uid}.ics of the calendar collection /caldav/todo/
+type CalDAVHandler interface {
+	Options() http.HandlerFunc
+	PropFind() http.HandlerFunc
+	Report() http.HandlerFunc
+	Get() http.HandlerFunc
+	Put() http.HandlerFunc
+	Delete() http.HandlerFunc
+}
+
+const (
+	calDAVHome       = "/caldav/"
+	calDAVCollection = "/caldav/todo/"
+)
+
+// XML namespaces of the properties
+const (
+	davNamespace            = "DAV:"
+	calDAVNamespace         = "urn:ietf:params:xml:ns:caldav"
+	calendarServerNamespace = "http://calendarserver.org/ns/"
+)
+
+const calDAVContentType = "text/calendar; charset=utf-8; component=VTODO"
+
+type calDAVHandler struct {
+	service service.ToDoService
+}
+
+func NewCalDAVHandler(service service.ToDoService) CalDAVHandler {
+	return &calDAVHandler{
+		service: service,
+	}
+}
+
+func (h *calDAVHandler) Options() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("DAV", "1, calendar-access")
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (h *calDAVHandler) PropFind() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request := &davPropFind{}
+		err := parseDAVRequest(r, request)
+		if err != nil {
+			http.Error(w, err.Error(), requestBodyStatusCode(err, http.StatusBadRequest))
+			return
+		}
+		// 無限の深さには対応しないため、Depthが0でなければ1として扱う
+		depth := r.Header.Get("Depth")
+
+		responses := []davResponse{}
+		if strings.TrimSuffix(r.URL.Path, "/") == strings.TrimSuffix(calDAVHome, "/") {
+			responses = append(responses, request.response(calDAVHome, calDAVHomeProperties()))
+			if depth == "0" {
+				writeMultistatus(w, responses)
+				return
+			}
+		}
+
+		if name, ok := mux.Vars(r)["name"]; ok {
+			resource, err := h.resource(calDAVUid(name))
+			if err != nil {
+				http.Error(w, err.Error(), errorStatusCode(err))
+				return
+			}
+			if resource == nil {
+				http.NotFound(w, r)
+				return
+			}
+			writeMultistatus(w, append(responses, request.response(resource.href(), resource.properties())))
+			return
+		}
+
+		resources, err := h.resources()
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+		responses = append(responses, request.response(calDAVCollection, calDAVCollectionProperties(resources)))
+		if depth != "0" && len(responses) == 1 {
+			for i := range resources {
+				responses = append(responses, request.response(resources[i].href(), resources[i].properties()))
+			}
+		}
+		writeMultistatus(w, responses)
+	}
+}
+
+// calendar-queryとcalendar-multigetに対応する
+func (h *calDAVHandler) Report() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request := &davReport{}
+		err := parseDAVRequest(r, request)
+		if err != nil {
+			http.Error(w, err.Error(), requestBodyStatusCode(err, http.StatusBadRequest))
+			return
+		}
+		if request.XMLName.Space != calDAVNamespace || (request.XMLName.Local != "calendar-query" && request.XMLName.Local != "calendar-multiget") {
+			http.Error(w, fmt.Sprintf("unsupported report %q", request.XMLName.Local), http.StatusForbidden)
+			return
+		}
+
+		propFind := &request.davPropFind
+		responses := []davResponse{}
+		if request.XMLName.Local == "calendar-multiget" {
+			for _, href := range request.Hrefs {
+				resource, err := h.resource(calDAVHrefUid(href))
+				if err != nil {
+					http.Error(w, err.Error(), errorStatusCode(err))
+					return
+				}
+				if resource == nil {
+					responses = append(responses, davResponse{Href: href, Status: davStatus(http.StatusNotFound)})
+					continue
+				}
+				responses = append(responses, propFind.response(resource.href(), resource.reportProperties()))
+			}
+			writeMultistatus(w, responses)
+			return
+		}
+		resources, err := h.resources()
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+		for i := range resources {
+			if request.Filter.CompFilter.matches(&resources[i]) {
+				responses = append(responses, propFind.response(resources[i].href(), resources[i].reportProperties()))
+			}
+		}
+		writeMultistatus(w, responses)
+	}
+}
+
+func (h *calDAVHandler) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resource, err := h.resource(calDAVUid(mux.Vars(r)["name"]))
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+		if resource == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", calDAVContentType)
+		w.Header().Set("ETag", resource.etag)
+		w.WriteHeader(http.StatusOK)
+		w.Write(resource.data)
+	}
+}
+
+func (h *calDAVHandler) Put() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid := calDAVUid(mux.Vars(r)["name"])
+		data, err := readRequestBody(r, maxRequestSize)
+		if err != nil {
+			http.Error(w, err.Error(), requestBodyStatusCode(err, http.StatusBadRequest))
+			return
+		}
+
+		object := &service.ICalendarObject{
+			Uid:       uid,
+			Data:      data,
+			Actor:     r.Header.Get(actorHeader),
+			RequestId: r.Header.Get(requestIdHeader),
+		}
+		object.Precondition = calDAVPrecondition(r, uid)
+		_, created, err := h.service.PutICalendar(object)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+		// 保存したVTODOは送られたものと同じではないため、ETagを返さずにクライアントに読み直させる(RFC 4791 5.3.4)
+		if created {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *calDAVHandler) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid := calDAVUid(mux.Vars(r)["name"])
+		if uid == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		object := &service.ICalendarObject{
+			Uid:          uid,
+			Precondition: calDAVPrecondition(r, uid),
+			Actor:        r.Header.Get(actorHeader),
+			RequestId:    r.Header.Get(requestIdHeader),
+		}
+		_, err := h.service.DeleteICalendar(object)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ToDo as a VTODO resource of the calendar collection
+type calDAVResource struct {
+	toDo service.ToDoObject
+	uid  string
+	// iCalendar object with the VTODO
+	data []byte
+	etag string
+}
+
+// コレクションの全リソースを読む(アーカイブされたToDoは含めない)
+func (h *calDAVHandler) resources() ([]calDAVResource, error) {
+	resources := []calDAVResource{}
+	err := h.service.ExportICalendar(&service.ListOption{}, func(toDo *service.ToDoObject, uid string) error {
+		resource, err := newCalDAVResource(toDo, uid)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, *resource)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// UIDのリソースだけを読む(ない場合はnil)
+func (h *calDAVHandler) resource(uid string) (*calDAVResource, error) {
+	if uid == "" {
+		return nil, nil
+	}
+	toDo, err := h.service.ReadICalendar(uid)
+	if err != nil || toDo == nil {
+		return nil, err
+	}
+	return newCalDAVResource(toDo, uid)
+}
+
+func newCalDAVResource(toDo *service.ToDoObject, uid string) (*calDAVResource, error) {
+	var b bytes.Buffer
+	err := writeICalendarLines(&b, iCalendarHeader...)
+	if err == nil {
+		err = writeVTodo(&b, toDo, uid)
+	}
+	if err == nil {
+		err = writeICalendarLines(&b, "END:VCALENDAR")
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 出力する内容(LAST-MODIFIEDを含む)のハッシュをETagにする
+	return &calDAVResource{
+		toDo: *toDo,
+		uid:  uid,
+		data: b.Bytes(),
+		etag: fmt.Sprintf(`"%x"`, sha1.Sum(b.Bytes())),
+	}, nil
+}
+
+func (c *calDAVResource) href() string {
+	return calDAVCollection + url.PathEscape(c.uid) + ".ics"
+}
+
+func (c *calDAVResource) properties() []davProperty {
+	return []davProperty{
+		{davName("resourcetype"), ""},
+		{davName("getetag"), davText(c.etag)},
+		{davName("getcontenttype"), davText(calDAVContentType)},
+		{davName("getlastmodified"), davText(c.toDo.UpdatedAt.UTC().Format(http.TimeFormat))},
+	}
+}
+
+// REPORTではVTODOの内容も返す
+func (c *calDAVResource) reportProperties() []davProperty {
+	return append(c.properties(), davProperty{xml.Name{Space: calDAVNamespace, Local: "calendar-data"}, davText(string(c.data))})
+}
+
+// prop-filterで比べるVTODOのプロパティの値(see writeVTodo)
+func (c *calDAVResource) values() map[string]string {
+	values := map[string]string{
+		"UID":           c.uid,
+		"DTSTAMP":       c.toDo.UpdatedAt.UTC().Format(iCalendarTimeFormat),
+		"SUMMARY":       c.toDo.Title,
+		"CREATED":       c.toDo.CreatedAt.UTC().Format(iCalendarTimeFormat),
+		"LAST-MODIFIED": c.toDo.UpdatedAt.UTC().Format(iCalendarTimeFormat),
+		"STATUS":        "NEEDS-ACTION",
+	}
+	if c.toDo.Done {
+		values["COMPLETED"] = c.toDo.UpdatedAt.UTC().Format(iCalendarTimeFormat)
+		values["STATUS"] = "COMPLETED"
+	}
+	return values
+}
+
+func calDAVHomeProperties() []davProperty {
+	home := "<href>" + davText(calDAVHome) + "</href>"
+	return []davProperty{
+		{davName("resourcetype"), "<collection/>"},
+		{davName("displayname"), "ToDo"},
+		{davName("current-user-principal"), home},
+		{davName("principal-URL"), home},
+		{xml.Name{Space: calDAVNamespace, Local: "calendar-home-set"}, `<href xmlns="DAV:">` + davText(calDAVHome) + "</href>"},
+	}
+}
+
+func calDAVCollectionProperties(resources []calDAVResource) []davProperty {
+	// リソースのUIDかETagが変わるとctagも変わる
+	ctag := sha1.New()
+	for i := range resources {
+		io.WriteString(ctag, resources[i].uid+resources[i].etag)
+	}
+	reports := ""
+	for _, report := range []string{"calendar-query", "calendar-multiget"} {
+		reports += `<supported-report><report><` + report + ` xmlns="` + calDAVNamespace + `"/></report></supported-report>`
+	}
+	return []davProperty{
+		{davName("resourcetype"), `<collection/><calendar xmlns="` + calDAVNamespace + `"/>`},
+		{davName("displayname"), "ToDo"},
+		{davName("current-user-principal"), "<href>" + davText(calDAVHome) + "</href>"},
+		{davName("supported-report-set"), reports},
+		{xml.Name{Space: calDAVNamespace, Local: "supported-calendar-component-set"}, `<comp name="VTODO"/>`},
+		{xml.Name{Space: calendarServerNamespace, Local: "getctag"}, fmt.Sprintf("%x", ctag.Sum(nil))},
+	}
+}
+
+// パスパラメータ{name}からUIDを取り出す
+func calDAVUid(name string) string {
+	return strings.TrimSuffix(name, ".ics")
+}
+
+// calendar-multigetのhref(絶対URLの場合もある)からUIDを取り出す(コレクションの外なら空文字)
+func calDAVHrefUid(href string) string {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil || !strings.HasPrefix(u.Path, calDAVCollection) {
+		return ""
+	}
+	return calDAVUid(strings.TrimPrefix(u.Path, calDAVCollection))
+}
+
+// 別のリクエストに変更されないよう、条件はToDoをロックしたトランザクションの中で確認させる(条件がない場合はnil)
+func calDAVPrecondition(r *http.Request, uid string) func(*service.ToDoObject) error {
+	if r.Header.Get("If-Match") == "" && r.Header.Get("If-None-Match") == "" {
+		return nil
+	}
+	return func(current *service.ToDoObject) error {
+		var resource *calDAVResource
+		if current != nil {
+			var err error
+			resource, err = newCalDAVResource(current, uid)
+			if err != nil {
+				return err
+			}
+		}
+		if calDAVPreconditionFailed(r, resource) {
+			return fmt.Errorf("%w: the resource has been changed", service.ErrPreconditionFailed)
+		}
+		return nil
+	}
+}
+
+// If-MatchとIf-None-Matchの条件を現在のリソース(ない場合はnil)と比べる
+func calDAVPreconditionFailed(r *http.Request, resource *calDAVResource) bool {
+	if value := r.Header.Get("If-Match"); value != "" {
+		if resource == nil || (value != "*" && !containsETag(value, resource.etag)) {
+			return true
+		}
+	}
+	if value := r.Header.Get("If-None-Match"); value != "" {
+		if resource != nil && (value == "*" || containsETag(value, resource.etag)) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsETag(value string, etag string) bool {
+	for _, v := range strings.Split(value, ",") {
+		if strings.TrimSpace(v) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Property of a WebDAV resource, of which the value is an XML fragment
+type davProperty struct {
+	name  xml.Name
+	value string
+}
+
+func davName(local string) xml.Name {
+	return xml.Name{Space: davNamespace, Local: local}
+}
+
+func davText(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+func davStatus(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+// Body of PROPFIND, also the properties requested in REPORT
+type davPropFind struct {
+	AllProp  *struct{}    `xml:"allprop"`
+	PropName *struct{}    `xml:"propname"`
+	Prop     davPropNames `xml:"prop"`
+}
+
+// Body of REPORT
+type davReport struct {
+	XMLName xml.Name
+	davPropFind
+	// calendar-multiget
+	Hrefs []string `xml:"href"`
+	// calendar-query
+	Filter struct {
+		CompFilter davCompFilter `xml:"comp-filter"`
+	} `xml:"filter"`
+}
+
+// Names of the properties in a prop element
+type davPropNames []xml.Name
+
+func (n *davPropNames) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			*n = append(*n, t.Name)
+			// calendar-dataの子要素などの条件は使わない
+			err := d.Skip()
+			if err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// 空のボディはallpropとして扱う
+func parseDAVRequest(r *http.Request, v interface{}) error {
+	body, err := readRequestBody(r, maxRequestSize)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if propFind, ok := v.(*davPropFind); ok {
+			propFind.AllProp = &struct{}{}
+		}
+		return nil
+	}
+	return xml.Unmarshal(body, v)
+}
+
+// 要求されたプロパティを見つかったもの(200)と見つからなかったもの(404)に分けて返す
+func (p *davPropFind) response(href string, properties []davProperty) davResponse {
+	found := []davProperty{}
+	missing := []davProperty{}
+	switch {
+	case p.PropName != nil:
+		for _, property := range properties {
+			found = append(found, davProperty{name: property.name})
+		}
+	case p.AllProp != nil || len(p.Prop) == 0:
+		found = properties
+	default:
+		for _, name := range p.Prop {
+			property, ok := findDAVProperty(properties, name)
+			if ok {
+				found = append(found, property)
+			} else {
+				missing = append(missing, davProperty{name: name})
+			}
+		}
+	}
+
+	response := davResponse{Href: href}
+	if len(found) > 0 {
+		response.PropStats = append(response.PropStats, newDAVPropStat(found, http.StatusOK))
+	}
+	if len(missing) > 0 {
+		response.PropStats = append(response.PropStats, newDAVPropStat(missing, http.StatusNotFound))
+	}
+	return response
+}
+
+func findDAVProperty(properties []davProperty, name xml.Name) (davProperty, bool) {
+	for _, property := range properties {
+		if property.name == name {
+			return property, true
+		}
+	}
+	return davProperty{}, false
+}
+
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"DAV: multistatus"`
+	Responses []davResponse `xml:"response"`
+}
+
+type davResponse struct {
+	Href      string        `xml:"href"`
+	Status    string        `xml:"status,omitempty"`
+	PropStats []davPropStat `xml:"propstat"`
+}
+
+type davPropStat struct {
+	Prop struct {
+		// プロパティごとに名前空間を宣言したXML
+		InnerXML string `xml:",innerxml"`
+	} `xml:"prop"`
+	Status string `xml:"status"`
+}
+
+func newDAVPropStat(properties []davProperty, code int) davPropStat {
+	propStat := davPropStat{Status: davStatus(code)}
+	var b strings.Builder
+	for _, p := range properties {
+		if p.value == "" {
+			fmt.Fprintf(&b, `<%s xmlns="%s"/>`, p.name.Local, p.name.Space)
+			continue
+		}
+		fmt.Fprintf(&b, `<%s xmlns="%s">%s</%s>`, p.name.Local, p.name.Space, p.value, p.name.Local)
+	}
+	propStat.Prop.InnerXML = b.String()
+	return propStat
+}
+
+func writeMultistatus(w http.ResponseWriter, responses []davResponse) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(davMultistatus{Responses: responses})
+}
+
+// Filter of calendar-query. Only the prop-filter of VTODO is supported and the other conditions like time-range are ignored
+type davCompFilter struct {
+	Name         string          `xml:"name,attr"`
+	IsNotDefined *struct{}       `xml:"is-not-defined"`
+	CompFilters  []davCompFilter `xml:"comp-filter"`
+	PropFilters  []davPropFilter `xml:"prop-filter"`
+}
+
+type davPropFilter struct {
+	Name         string    `xml:"name,attr"`
+	IsNotDefined *struct{} `xml:"is-not-defined"`
+	TextMatch    *struct {
+		Text   string `xml:",chardata"`
+		Negate string `xml:"negate-condition,attr"`
+	} `xml:"text-match"`
+}
+
+// VCALENDARのフィルタにリソースが合うか調べる(フィルタがなければ全件が合う)
+func (f *davCompFilter) matches(resource *calDAVResource) bool {
+	if f.Name == "" {
+		return true
+	}
+	if f.Name != "VCALENDAR" || f.IsNotDefined != nil {
+		return false
+	}
+	for _, c := range f.CompFilters {
+		// リソースに含まれるコンポーネントはVTODOだけ
+		if c.IsNotDefined != nil {
+			if c.Name == "VTODO" {
+				return false
+			}
+			continue
+		}
+		if c.Name != "VTODO" {
+			return false
+		}
+		values := resource.values()
+		for _, p := range c.PropFilters {
+			if !p.matches(values) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// text-matchは大文字小文字を区別しない部分一致(i;ascii-casemap)
+func (p *davPropFilter) matches(values map[string]string) bool {
+	value, ok := values[strings.ToUpper(p.Name)]
+	if p.IsNotDefined != nil {
+		return !ok
+	}
+	if !ok {
+		return false
+	}
+	if p.TextMatch == nil {
+		return true
+	}
+	matched := strings.Contains(strings.ToLower(value), strings.ToLower(strings.TrimSpace(p.TextMatch.Text)))
+	return matched != (p.TextMatch.Negate == "yes")
+}
@@ -0,0 +1,581 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service/mock_service"
+)
+
+// ToDo in the collection of the tests, the second one is imported with the UID "abc@example.com"
+var calDAVToDoList = []service.ToDoObject{
+	{Id: 123, Title: "Buy a new pencil", Done: true, CreatedAt: time.Date(2021, 6, 1, 0, 35, 7, 0, time.UTC), UpdatedAt: time.Date(2021, 6, 15, 0, 40, 10, 0, time.UTC)},
+	{Id: 456, Title: "Go to the cinema", CreatedAt: time.Date(2021, 6, 1, 0, 35, 7, 0, time.UTC), UpdatedAt: time.Date(2021, 6, 1, 0, 35, 7, 0, time.UTC)},
+}
+
+var calDAVUids = map[int64]string{456: "abc@example.com"}
+
+// UIDのToDoをcalDAVToDoListから探す(ない場合はnil)
+func findCalDAVToDo(uid string) *service.ToDoObject {
+	for i := range calDAVToDoList {
+		if service.ICalendarUid(calDAVToDoList[i].Id, calDAVUids) == uid {
+			toDo := calDAVToDoList[i]
+			return &toDo
+		}
+	}
+	return nil
+}
+
+// serviceErrorはExportICalendarとReadICalendarが返すエラー
+func newCalDAVTestRouter(ctrl *gomock.Controller, serviceError error) (*mux.Router, *mock_service.MockToDoService) {
+	mockToDoService := mock_service.NewMockToDoService(ctrl)
+	mockToDoService.EXPECT().ExportICalendar(gomock.Any(), gomock.Any()).DoAndReturn(func(option *service.ListOption, fn func(*service.ToDoObject, string) error) error {
+		if serviceError != nil {
+			return serviceError
+		}
+		for i := range calDAVToDoList {
+			toDo := calDAVToDoList[i]
+			err := fn(&toDo, service.ICalendarUid(toDo.Id, calDAVUids))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}).AnyTimes()
+	mockToDoService.EXPECT().ReadICalendar(gomock.Any()).DoAndReturn(func(uid string) (*service.ToDoObject, error) {
+		if serviceError != nil {
+			return nil, serviceError
+		}
+		return findCalDAVToDo(uid), nil
+	}).AnyTimes()
+	h := NewCalDAVHandler(mockToDoService)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/caldav/", h.PropFind()).Methods("PROPFIND")
+	r.HandleFunc("/caldav/todo/", h.PropFind()).Methods("PROPFIND")
+	r.HandleFunc("/caldav/todo/", h.Report()).Methods("REPORT")
+	r.HandleFunc("/caldav/todo/{name}", h.PropFind()).Methods("PROPFIND")
+	r.HandleFunc("/caldav/todo/{name}", h.Get()).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc("/caldav/todo/{name}", h.Put()).Methods(http.MethodPut)
+	r.HandleFunc("/caldav/todo/{name}", h.Delete()).Methods(http.MethodDelete)
+	return r, mockToDoService
+}
+
+// CalDAVクライアントとしてリクエストを送る
+func doCalDAV(r *mux.Router, method string, path string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "http://hogehoge"+path, strings.NewReader(body))
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// multistatusをhrefごとのプロパティの値に変換する(見つからなかったプロパティの値は"404")
+func parseMultistatus(t *testing.T, body string) map[string]map[string]string {
+	var multistatus struct {
+		Responses []struct {
+			Href      string `xml:"DAV: href"`
+			Status    string `xml:"DAV: status"`
+			PropStats []struct {
+				Prop struct {
+					Properties []struct {
+						XMLName xml.Name
+						Text    string `xml:",chardata"`
+						Inner   string `xml:",innerxml"`
+					} `xml:",any"`
+				} `xml:"DAV: prop"`
+				Status string `xml:"DAV: status"`
+			} `xml:"DAV: propstat"`
+		} `xml:"DAV: response"`
+	}
+	err := xml.Unmarshal([]byte(body), &multistatus)
+	if err != nil {
+		t.Fatalf("invalid multistatus: %v\n%s", err, body)
+	}
+	result := map[string]map[string]string{}
+	for _, response := range multistatus.Responses {
+		properties := map[string]string{}
+		if response.Status != "" {
+			properties["status"] = response.Status
+		}
+		for _, propStat := range response.PropStats {
+			for _, p := range propStat.Prop.Properties {
+				value := p.Text
+				if strings.TrimSpace(value) == "" {
+					value = p.Inner
+				}
+				if !strings.Contains(propStat.Status, "200") {
+					value = "404"
+				}
+				properties[p.XMLName.Local] = value
+			}
+		}
+		result[response.Href] = properties
+	}
+	return result
+}
+
+func TestCalDAVPropFind(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	propFind := `<?xml version="1.0"?><d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/" xmlns:c="urn:ietf:params:xml:ns:caldav">` +
+		`<d:prop><d:resourcetype/><d:getetag/><cs:getctag/><c:calendar-home-set/><d:current-user-principal/></d:prop></d:propfind>`
+	tests := []struct {
+		name               string
+		path               string
+		depth              string
+		body               string
+		serviceError       error
+		expectedStatusCode int
+		// hrefごとに確認するプロパティ(値が"*"なら空でないことだけを確認する)
+		expectedProperties map[string]map[string]string
+	}{
+		{
+			name:               "01_ホームを探すケース",
+			path:               "/caldav/",
+			depth:              "0",
+			body:               propFind,
+			expectedStatusCode: http.StatusMultiStatus,
+			expectedProperties: map[string]map[string]string{
+				"/caldav/": {"current-user-principal": "<href>/caldav/</href>", "calendar-home-set": `<href xmlns="DAV:">/caldav/</href>`, "getetag": "404"},
+			},
+		},
+		{
+			name:               "02_ホームの下のコレクションを探すケース",
+			path:               "/caldav/",
+			depth:              "1",
+			body:               propFind,
+			expectedStatusCode: http.StatusMultiStatus,
+			expectedProperties: map[string]map[string]string{
+				"/caldav/":      {"resourcetype": "<collection/>"},
+				"/caldav/todo/": {"resourcetype": `<collection/><calendar xmlns="urn:ietf:params:xml:ns:caldav"/>`, "getctag": "*"},
+			},
+		},
+		{
+			name:               "03_コレクションのリソースを一覧するケース",
+			path:               "/caldav/todo/",
+			depth:              "1",
+			body:               propFind,
+			expectedStatusCode: http.StatusMultiStatus,
+			expectedProperties: map[string]map[string]string{
+				"/caldav/todo/": {"getctag": "*", "getetag": "404"},
+				"/caldav/todo/123@todo-rest-api-golang.ics": {"getetag": "*", "getctag": "404"},
+				"/caldav/todo/abc@example.com.ics":          {"getetag": "*"},
+			},
+		},
+		{
+			name:               "04_ボディがなく全プロパティを返すケース",
+			path:               "/caldav/todo/abc@example.com.ics",
+			depth:              "0",
+			expectedStatusCode: http.StatusMultiStatus,
+			expectedProperties: map[string]map[string]string{
+				"/caldav/todo/abc@example.com.ics": {"getcontenttype": "text/calendar; charset=utf-8; component=VTODO", "getlastmodified": "Tue, 01 Jun 2021 00:35:07 GMT"},
+			},
+		},
+		{
+			name:               "05_リソースがないケース",
+			path:               "/caldav/todo/unknown.ics",
+			depth:              "0",
+			body:               propFind,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "06_ボディが不正なケース",
+			path:               "/caldav/todo/",
+			depth:              "0",
+			body:               "<propfind",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "07_ExportICalendarが失敗するケース",
+			path:               "/caldav/todo/",
+			depth:              "1",
+			body:               propFind,
+			serviceError:       errors.New("Export ERROR"),
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "08_ReadICalendarが失敗するケース",
+			path:               "/caldav/todo/abc@example.com.ics",
+			depth:              "0",
+			body:               propFind,
+			serviceError:       errors.New("Read ERROR"),
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			r, _ := newCalDAVTestRouter(ctrl, tt.serviceError)
+
+			// Act
+			w := doCalDAV(r, "PROPFIND", tt.path, tt.body, map[string]string{"Depth": tt.depth})
+
+			// Assert
+			if w.Code != tt.expectedStatusCode {
+				t.Fatalf("expected: %d, actual: %d, body: %s", tt.expectedStatusCode, w.Code, w.Body.String())
+			}
+			if tt.expectedStatusCode != http.StatusMultiStatus {
+				return
+			}
+			actual := parseMultistatus(t, w.Body.String())
+			if len(actual) != len(tt.expectedProperties) {
+				t.Errorf("expected: %d responses, actual: %v", len(tt.expectedProperties), actual)
+			}
+			for href, properties := range tt.expectedProperties {
+				for name, value := range properties {
+					got, ok := actual[href][name]
+					if !ok || (value == "*" && got == "") || (value != "*" && got != value) {
+						t.Errorf("%s %s expected: %q, actual: %q", href, name, value, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCalDAVReport(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	calendarQuery := func(filter string) string {
+		return `<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop><d:getetag/><c:calendar-data/></d:prop>` +
+			`<c:filter><c:comp-filter name="VCALENDAR">` + filter + `</c:comp-filter></c:filter></c:calendar-query>`
+	}
+	tests := []struct {
+		name               string
+		body               string
+		expectedStatusCode int
+		expectedHrefs      []string
+	}{
+		{
+			name:               "01_VTODOを全件検索するケース",
+			body:               calendarQuery(`<c:comp-filter name="VTODO"/>`),
+			expectedStatusCode: http.StatusMultiStatus,
+			expectedHrefs:      []string{"/caldav/todo/123@todo-rest-api-golang.ics", "/caldav/todo/abc@example.com.ics"},
+		},
+		{
+			name:               "02_完了していないVTODOを検索するケース",
+			body:               calendarQuery(`<c:comp-filter name="VTODO"><c:prop-filter name="COMPLETED"><c:is-not-defined/></c:prop-filter></c:comp-filter>`),
+			expectedStatusCode: http.StatusMultiStatus,
+			expectedHrefs:      []string{"/caldav/todo/abc@example.com.ics"},
+		},
+		{
+			name:               "03_STATUSで検索するケース",
+			body:               calendarQuery(`<c:comp-filter name="VTODO"><c:prop-filter name="STATUS"><c:text-match negate-condition="yes">needs-action</c:text-match></c:prop-filter></c:comp-filter>`),
+			expectedStatusCode: http.StatusMultiStatus,
+			expectedHrefs:      []string{"/caldav/todo/123@todo-rest-api-golang.ics"},
+		},
+		{
+			name:               "04_VEVENTを検索するケース",
+			body:               calendarQuery(`<c:comp-filter name="VEVENT"/>`),
+			expectedStatusCode: http.StatusMultiStatus,
+			expectedHrefs:      []string{},
+		},
+		{
+			name: "05_hrefを指定して取得するケース",
+			body: `<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop><d:getetag/><c:calendar-data/></d:prop>` +
+				`<d:href>http://hogehoge/caldav/todo/abc%40example.com.ics</d:href><d:href>/caldav/todo/unknown.ics</d:href></c:calendar-multiget>`,
+			expectedStatusCode: http.StatusMultiStatus,
+			expectedHrefs:      []string{"/caldav/todo/abc@example.com.ics", "/caldav/todo/unknown.ics"},
+		},
+		{
+			name:               "06_対応していないREPORTのケース",
+			body:               `<d:sync-collection xmlns:d="DAV:"><d:sync-token/></d:sync-collection>`,
+			expectedStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			r, _ := newCalDAVTestRouter(ctrl, nil)
+
+			// Act
+			w := doCalDAV(r, "REPORT", "/caldav/todo/", tt.body, map[string]string{"Depth": "1"})
+
+			// Assert
+			if w.Code != tt.expectedStatusCode {
+				t.Fatalf("expected: %d, actual: %d, body: %s", tt.expectedStatusCode, w.Code, w.Body.String())
+			}
+			if tt.expectedStatusCode != http.StatusMultiStatus {
+				return
+			}
+			actual := parseMultistatus(t, w.Body.String())
+			if len(actual) != len(tt.expectedHrefs) {
+				t.Errorf("expected: %v, actual: %v", tt.expectedHrefs, actual)
+			}
+			for _, href := range tt.expectedHrefs {
+				properties, ok := actual[href]
+				switch {
+				case !ok:
+					t.Errorf("%s is not found in %v", href, actual)
+				case properties["status"] != "":
+					if !strings.Contains(properties["status"], "404") {
+						t.Errorf("%s unexpected status: %s", href, properties["status"])
+					}
+				case !strings.HasPrefix(properties["calendar-data"], "BEGIN:VCALENDAR\r\n") || properties["getetag"] == "":
+					t.Errorf("%s unexpected properties: %v", href, properties)
+				}
+			}
+		})
+	}
+}
+
+func TestCalDAVGet(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	r, _ := newCalDAVTestRouter(ctrl, nil)
+
+	// Act
+	w := doCalDAV(r, http.MethodGet, "/caldav/todo/abc@example.com.ics", "", nil)
+	list := doCalDAV(r, "PROPFIND", "/caldav/todo/", "", map[string]string{"Depth": "1"})
+	missing := doCalDAV(r, http.MethodGet, "/caldav/todo/unknown.ics", "", nil)
+
+	// Assert
+	if w.Code != http.StatusOK || missing.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d, %d", w.Code, missing.Code)
+	}
+	expected := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//todo-rest-api-golang//ToDo//EN\r\n" +
+		"BEGIN:VTODO\r\nUID:abc@example.com\r\nDTSTAMP:20210601T003507Z\r\nSUMMARY:Go to the cinema\r\n" +
+		"CREATED:20210601T003507Z\r\nLAST-MODIFIED:20210601T003507Z\r\nSTATUS:NEEDS-ACTION\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+	if body := w.Body.String(); body != expected {
+		t.Errorf("expected: %q, actual: %q", expected, body)
+	}
+	// 一覧のETagとGETのETagが一致する
+	etag := parseMultistatus(t, list.Body.String())["/caldav/todo/abc@example.com.ics"]["getetag"]
+	if etag == "" || w.Header().Get("ETag") != etag {
+		t.Errorf("expected: %q, actual: %q", etag, w.Header().Get("ETag"))
+	}
+}
+
+func TestCalDAVPut(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	etag := func(r *mux.Router) string {
+		return doCalDAV(r, http.MethodGet, "/caldav/todo/abc@example.com.ics", "", nil).Header().Get("ETag")
+	}
+	tests := []struct {
+		name        string
+		path        string
+		headers     func(*mux.Router) map[string]string
+		putCreated  bool
+		putError    error
+		expectedUid string
+		// If-MatchかIf-None-Matchがあれば、条件はPutICalendarのトランザクションの中で確認する
+		expectedPrecondition bool
+		expectedStatusCode   int
+	}{
+		{
+			name:                 "01_新しいリソースを作成するケース",
+			path:                 "/caldav/todo/new@example.com.ics",
+			headers:              func(*mux.Router) map[string]string { return map[string]string{"If-None-Match": "*"} },
+			putCreated:           true,
+			expectedUid:          "new@example.com",
+			expectedPrecondition: true,
+			expectedStatusCode:   http.StatusCreated,
+		},
+		{
+			name:                 "02_ETagが一致するリソースを更新するケース",
+			path:                 "/caldav/todo/abc@example.com.ics",
+			headers:              func(r *mux.Router) map[string]string { return map[string]string{"If-Match": etag(r)} },
+			expectedUid:          "abc@example.com",
+			expectedPrecondition: true,
+			expectedStatusCode:   http.StatusNoContent,
+		},
+		{
+			name:                 "03_ETagが一致しないケース",
+			path:                 "/caldav/todo/abc@example.com.ics",
+			headers:              func(*mux.Router) map[string]string { return map[string]string{"If-Match": `"stale"`} },
+			expectedUid:          "abc@example.com",
+			expectedPrecondition: true,
+			expectedStatusCode:   http.StatusPreconditionFailed,
+		},
+		{
+			name:                 "04_作成しようとしたリソースが既にあるケース",
+			path:                 "/caldav/todo/123@todo-rest-api-golang.ics",
+			headers:              func(*mux.Router) map[string]string { return map[string]string{"If-None-Match": "*"} },
+			expectedUid:          "123@todo-rest-api-golang",
+			expectedPrecondition: true,
+			expectedStatusCode:   http.StatusPreconditionFailed,
+		},
+		{
+			name:               "05_VTODOが不正なケース",
+			path:               "/caldav/todo/new@example.com.ics",
+			headers:            func(*mux.Router) map[string]string { return nil },
+			putError:           fmt.Errorf("%w: UID is required", service.ErrInvalidImport),
+			expectedUid:        "new@example.com",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			r, mockToDoService := newCalDAVTestRouter(ctrl, nil)
+			body := "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:" + tt.expectedUid + "\r\nSUMMARY:Call Mom\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+			mockToDoService.EXPECT().PutICalendar(gomock.Any()).DoAndReturn(func(object *service.ICalendarObject) (*service.ToDoObject, bool, error) {
+				if object.Uid != tt.expectedUid || string(object.Data) != body || object.Actor != "alice" {
+					t.Errorf("unexpected object: %+v", object)
+				}
+				if (object.Precondition != nil) != tt.expectedPrecondition {
+					t.Errorf("expected precondition: %t", tt.expectedPrecondition)
+				}
+				// PutICalendarはロックした現在のToDoで条件を確認する
+				if object.Precondition != nil {
+					err := object.Precondition(findCalDAVToDo(object.Uid))
+					if err != nil {
+						return nil, false, err
+					}
+				}
+				return &service.ToDoObject{Id: 789}, tt.putCreated, tt.putError
+			})
+			headers := tt.headers(r)
+			if headers == nil {
+				headers = map[string]string{}
+			}
+			headers["Content-Type"] = "text/calendar"
+			headers[actorHeader] = "alice"
+
+			// Act
+			w := doCalDAV(r, http.MethodPut, tt.path, body, headers)
+
+			// Assert
+			if w.Code != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d, body: %s", tt.expectedStatusCode, w.Code, w.Body.String())
+			}
+			if w.Header().Get("ETag") != "" {
+				t.Errorf("ETag must not be returned: %s", w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestCalDAVDelete(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	etag := func(r *mux.Router) string {
+		return doCalDAV(r, http.MethodGet, "/caldav/todo/abc@example.com.ics", "", nil).Header().Get("ETag")
+	}
+	tests := []struct {
+		name        string
+		path        string
+		ifMatch     func(*mux.Router) string
+		deleteError error
+		expectedUid string
+		// If-Matchがあれば、条件はDeleteICalendarのトランザクションの中で確認する
+		expectedPrecondition bool
+		expectedStatusCode   int
+	}{
+		{
+			name:               "01_リソースを削除するケース",
+			path:               "/caldav/todo/abc@example.com.ics",
+			expectedUid:        "abc@example.com",
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "02_リソースがないケース",
+			path:               "/caldav/todo/unknown.ics",
+			deleteError:        fmt.Errorf("%w: no ToDo has UID %q", sql.ErrNoRows, "unknown"),
+			expectedUid:        "unknown",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:                 "03_ETagが一致するケース",
+			path:                 "/caldav/todo/abc@example.com.ics",
+			ifMatch:              etag,
+			expectedUid:          "abc@example.com",
+			expectedPrecondition: true,
+			expectedStatusCode:   http.StatusNoContent,
+		},
+		{
+			name:                 "04_ETagが一致しないケース",
+			path:                 "/caldav/todo/abc@example.com.ics",
+			ifMatch:              func(*mux.Router) string { return `"stale"` },
+			expectedUid:          "abc@example.com",
+			expectedPrecondition: true,
+			expectedStatusCode:   http.StatusPreconditionFailed,
+		},
+		{
+			name:               "05_DeleteICalendarが失敗するケース",
+			path:               "/caldav/todo/abc@example.com.ics",
+			deleteError:        errors.New("Delete ERROR"),
+			expectedUid:        "abc@example.com",
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			r, mockToDoService := newCalDAVTestRouter(ctrl, nil)
+			mockToDoService.EXPECT().DeleteICalendar(gomock.Any()).DoAndReturn(func(object *service.ICalendarObject) (*service.ToDoObject, error) {
+				if object.Uid != tt.expectedUid || object.Actor != "alice" {
+					t.Errorf("unexpected object: %+v", object)
+				}
+				if (object.Precondition != nil) != tt.expectedPrecondition {
+					t.Errorf("expected precondition: %t", tt.expectedPrecondition)
+				}
+				// DeleteICalendarはロックした現在のToDoで条件を確認する
+				current := findCalDAVToDo(object.Uid)
+				if object.Precondition != nil {
+					err := object.Precondition(current)
+					if err != nil {
+						return nil, err
+					}
+				}
+				return current, tt.deleteError
+			})
+			headers := map[string]string{actorHeader: "alice"}
+			if tt.ifMatch != nil {
+				headers["If-Match"] = tt.ifMatch(r)
+			}
+
+			// Act
+			w := doCalDAV(r, http.MethodDelete, tt.path, "", headers)
+
+			// Assert
+			if w.Code != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d, body: %s", tt.expectedStatusCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Encodes and decodes the request and response bodies in a media type
+type codec struct {
+	// Content-Type of the responses
+	mediaType string
+	// other media types accepted for the same format
+	aliases []string
+	encode  func(w io.Writer, v interface{}) error
+	decode  func(data []byte, v interface{}) error
+	// the values have no types and are typed by the struct they are decoded into (XML)
+	untyped bool
+}
+
+// Codecs selected by Accept and Content-Type, the first one is used if they are not given.
+// The formats other than JSON are converted from and to JSON, so the field names and values are the same in all formats
+var codecs = []*codec{
+	{mediaType: "application/json", encode: encodeJSON, decode: json.Unmarshal},
+	{mediaType: "application/xml", aliases: []string{"text/xml"}, encode: encodeXML, decode: decodeXML, untyped: true},
+	{mediaType: "application/yaml", aliases: []string{"application/x-yaml", "text/yaml"}, encode: encodeYAML, decode: decodeYAML},
+	{mediaType: "application/msgpack", aliases: []string{"application/x-msgpack", "application/vnd.msgpack"}, encode: encodeMessagePack, decode: decodeMessagePack},
+}
+
+var errUnsupportedMediaType = errors.New("unsupported media type")
+
+// 対応しているメディアタイプ(エラーメッセージ用)
+func supportedMediaTypes() string {
+	mediaTypes := []string{}
+	for _, c := range codecs {
+		mediaTypes = append(mediaTypes, c.mediaType)
+	}
+	return strings.Join(mediaTypes, ", ")
+}
+
+func findCodec(mediaType string) *codec {
+	for _, c := range codecs {
+		if c.mediaType == mediaType {
+			return c
+		}
+		for _, alias := range c.aliases {
+			if alias == mediaType {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// Codec of the response selected by Accept (the one with the highest q), nil if Accept allows none of them
+func responseCodec(r *http.Request) *codec {
+	accept := strings.Join(r.Header.Values("Accept"), ",")
+	if strings.TrimSpace(accept) == "" {
+		return codecs[0]
+	}
+	var best *codec
+	bestQuality := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if value, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+		}
+		c := findCodec(mediaType)
+		if mediaType == "*/*" || mediaType == "application/*" {
+			c = codecs[0]
+		}
+		// 同じqなら先に書かれたものを選ぶ
+		if c != nil && quality > bestQuality {
+			best, bestQuality = c, quality
+		}
+	}
+	return best
+}
+
+// Codec of the request body selected by Content-Type (the first one if Content-Type is not given)
+func requestCodec(r *http.Request) (*codec, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return codecs[0], nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errUnsupportedMediaType, contentType)
+	}
+	c := findCodec(mediaType)
+	if c == nil {
+		return nil, fmt.Errorf("%w: %s (supported: %s)", errUnsupportedMediaType, mediaType, supportedMediaTypes())
+	}
+	return c, nil
+}
+
+// Reject the request with 406 before running the handler if Accept allows none of the codecs
+func negotiate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if responseCodec(r) == nil {
+			http.Error(w, "Accept must allow one of "+supportedMediaTypes(), http.StatusNotAcceptable)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// the request body is larger than the limit of the operation
+var errRequestTooLarge = errors.New("too large data")
+
+// Read the request body, which must be at most limit bytes
+func readRequestBody(r *http.Request, limit int64) ([]byte, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("%w (max %d bytes)", errRequestTooLarge, limit)
+	}
+	return body, nil
+}
+
+// Decode the request body in the format of Content-Type
+func decodeRequest(r *http.Request, v interface{}) error {
+	c, err := requestCodec(r)
+	if err != nil {
+		return err
+	}
+	body, err := readRequestBody(r, maxRequestBodySize(r))
+	if err != nil {
+		return err
+	}
+	return c.decode(body, v)
+}
+
+// Write the response in the format selected by Accept
+func writeResponse(w http.ResponseWriter, r *http.Request, statusCode int, v interface{}) {
+	c := responseCodec(r)
+	if c == nil {
+		// negotiateで弾いていない場合はJSONで返す
+		c = codecs[0]
+	}
+	w.Header().Set("Content-Type", c.mediaType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(statusCode)
+	c.encode(w, v)
+}
+
+// リクエストボディを読めなかったときのステータスコード(形式に対応していなければ415、大きすぎれば413)
+func requestBodyStatusCode(err error, statusCode int) int {
+	switch {
+	case errors.Is(err, errUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, errRequestTooLarge):
+		return http.StatusRequestEntityTooLarge
+	}
+	return statusCode
+}
+
+func encodeJSON(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// JSON object keeping the order of the keys
+type orderedMap []keyValue
+
+type keyValue struct {
+	key   string
+	value interface{}
+}
+
+// Convert the value to JSON and read it as nil, bool, json.Number, string, []interface{} or orderedMap
+func toJSONValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return readJSONValue(decoder)
+}
+
+func readJSONValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+	if delim == '[' {
+		list := []interface{}{}
+		for decoder.More() {
+			item, err := readJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		_, err = decoder.Token()
+		return list, err
+	}
+	object := orderedMap{}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		value, err := readJSONValue(decoder)
+		if err != nil {
+			return nil, err
+		}
+		object = append(object, keyValue{key: key.(string), value: value})
+	}
+	_, err = decoder.Token()
+	return object, err
+}
+
+// 他の形式で読んだ値をJSONにしてから構造体に入れる
+func fromJSONValue(value interface{}, v interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func encodeYAML(w io.Writer, v interface{}) error {
+	value, err := toJSONValue(v)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(yamlValue(value))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// キーの順序を保つためMapSliceにする
+func yamlValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case orderedMap:
+		slice := yaml.MapSlice{}
+		for _, kv := range value {
+			slice = append(slice, yaml.MapItem{Key: kv.key, Value: yamlValue(kv.value)})
+		}
+		return slice
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			list[i] = yamlValue(item)
+		}
+		return list
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return n
+		}
+		f, _ := value.Float64()
+		return f
+	}
+	return value
+}
+
+func decodeYAML(data []byte, v interface{}) error {
+	var value interface{}
+	err := yaml.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	value, err = stringKeys(value)
+	if err != nil {
+		return err
+	}
+	return fromJSONValue(value, v)
+}
+
+// YAMLのmap[interface{}]interface{}をJSONにできる形にする
+func stringKeys(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		object := map[string]interface{}{}
+		for key, item := range value {
+			s, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("yaml: key %v is not a string", key)
+			}
+			converted, err := stringKeys(item)
+			if err != nil {
+				return nil, err
+			}
+			object[s] = converted
+		}
+		return object, nil
+	case []interface{}:
+		for i, item := range value {
+			converted, err := stringKeys(item)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = converted
+		}
+	}
+	return value, nil
+}
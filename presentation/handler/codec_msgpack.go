@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// max nesting of the objects and the lists in the request bodies
+const maxDecodeDepth = 64
+
+// Encode the value as MessagePack (https://github.com/msgpack/msgpack/blob/master/spec.md)
+func encodeMessagePack(w io.Writer, v interface{}) error {
+	value, err := toJSONValue(v)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	err = encodeMessagePackValue(msgpack.NewEncoder(buf), value)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// キーの順序を保つためorderedMapは自分で書く
+func encodeMessagePackValue(e *msgpack.Encoder, value interface{}) error {
+	switch value := value.(type) {
+	case json.Number:
+		// 整数は一番短い形式で書く
+		if n, err := value.Int64(); err == nil {
+			return e.EncodeInt(n)
+		}
+		if n, err := strconv.ParseUint(string(value), 10, 64); err == nil {
+			return e.EncodeUint(n)
+		}
+		f, _ := value.Float64()
+		return e.EncodeFloat64(f)
+	case []interface{}:
+		err := e.EncodeArrayLen(len(value))
+		if err != nil {
+			return err
+		}
+		for _, item := range value {
+			err = encodeMessagePackValue(e, item)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case orderedMap:
+		err := e.EncodeMapLen(len(value))
+		if err != nil {
+			return err
+		}
+		for _, kv := range value {
+			err = e.EncodeString(kv.key)
+			if err != nil {
+				return err
+			}
+			err = encodeMessagePackValue(e, kv.value)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return e.Encode(value)
+}
+
+// Decode the MessagePack into v through JSON, the binaries become base64 strings and the timestamps become RFC 3339 strings
+func decodeMessagePack(data []byte, v interface{}) error {
+	r := bytes.NewReader(data)
+	value, err := decodeMessagePackValue(msgpack.NewDecoder(r), 0)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return errors.New("msgpack: unexpected end of data")
+	}
+	if err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return errors.New("msgpack: unexpected data after the top-level value")
+	}
+	return fromJSONValue(value, v)
+}
+
+// ライブラリは入れ子の深さを制限しないので、リストとマップは自分で読む
+func decodeMessagePackValue(d *msgpack.Decoder, depth int) (interface{}, error) {
+	if depth > maxDecodeDepth {
+		return nil, errors.New("msgpack: too deeply nested")
+	}
+	code, err := d.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32:
+		n, err := d.DecodeArrayLen()
+		if err != nil {
+			return nil, err
+		}
+		// 長さは信用できないので先に確保しない
+		list := []interface{}{}
+		for i := 0; i < n; i++ {
+			item, err := decodeMessagePackValue(d, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	case msgpcode.IsFixedMap(code) || code == msgpcode.Map16 || code == msgpcode.Map32:
+		n, err := d.DecodeMapLen()
+		if err != nil {
+			return nil, err
+		}
+		object := map[string]interface{}{}
+		for i := 0; i < n; i++ {
+			code, err := d.PeekCode()
+			if err != nil {
+				return nil, err
+			}
+			if !msgpcode.IsString(code) {
+				return nil, fmt.Errorf("msgpack: key with code 0x%02x is not a string", code)
+			}
+			key, err := d.DecodeString()
+			if err != nil {
+				return nil, err
+			}
+			object[key], err = decodeMessagePackValue(d, depth+1)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return object, nil
+	}
+	// 拡張型はライブラリに登録されたタイムスタンプ(-1)だけ読める
+	value, err := d.DecodeInterface()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := value.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano), nil
+	}
+	// []byteはJSONでbase64の文字列になる
+	return value, nil
+}
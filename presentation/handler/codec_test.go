@@ -0,0 +1,351 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service/mock_service"
+)
+
+func newRequestWithHeader(method string, target string, body string, key string, value string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set(key, value)
+	return r
+}
+
+var codecTestTime = time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestResponseCodec(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name              string
+		accept            string
+		expectedMediaType string
+	}{
+		{
+			name:              "01_Acceptがないケース",
+			accept:            "",
+			expectedMediaType: "application/json",
+		},
+		{
+			name:              "02_別名で指定したケース",
+			accept:            "application/x-msgpack",
+			expectedMediaType: "application/msgpack",
+		},
+		{
+			name:              "03_対応していないものと対応しているものがあるケース",
+			accept:            "text/html, application/yaml;q=0.5",
+			expectedMediaType: "application/yaml",
+		},
+		{
+			name:              "04_qが一番大きいものを選ぶケース",
+			accept:            "application/json;q=0.1, application/xml",
+			expectedMediaType: "application/xml",
+		},
+		{
+			name:              "05_qが同じなら先に書かれたものを選ぶケース",
+			accept:            "application/yaml, application/xml",
+			expectedMediaType: "application/yaml",
+		},
+		{
+			name:              "06_ワイルドカードのケース",
+			accept:            "*/*",
+			expectedMediaType: "application/json",
+		},
+		{
+			name:              "07_対応していないもののみのケース",
+			accept:            "text/html",
+			expectedMediaType: "",
+		},
+		{
+			name:              "08_qが0のケース",
+			accept:            "application/xml;q=0",
+			expectedMediaType: "",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			r := newRequestWithHeader(http.MethodGet, "http://hogehoge/todo", "", "Accept", tt.accept)
+
+			// Act
+			c := responseCodec(r)
+
+			// Assert
+			mediaType := ""
+			if c != nil {
+				mediaType = c.mediaType
+			}
+			if mediaType != tt.expectedMediaType {
+				t.Errorf("expected: %q, actual: %q", tt.expectedMediaType, mediaType)
+			}
+		})
+	}
+}
+
+func TestEncodeResponse(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	toDo := &service.ToDoObject{Id: 1, Title: "a & b", Status: "todo", CreatedAt: codecTestTime, UpdatedAt: codecTestTime}
+	tests := []struct {
+		name         string
+		accept       string
+		value        interface{}
+		expectedBody string
+	}{
+		{
+			name:         "01_JSONのケース",
+			accept:       "application/json",
+			value:        toDo,
+			expectedBody: `{"id":1,"title":"a \u0026 b","status":"todo","done":false,"archived":false,"created_at":"2021-01-02T03:04:05Z","updated_at":"2021-01-02T03:04:05Z"}` + "\n",
+		},
+		{
+			name:         "02_XMLのケース",
+			accept:       "application/xml",
+			value:        toDo,
+			expectedBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<response><id>1</id><title>a &amp; b</title><status>todo</status><done>false</done><archived>false</archived><created_at>2021-01-02T03:04:05Z</created_at><updated_at>2021-01-02T03:04:05Z</updated_at></response>`,
+		},
+		{
+			name:         "03_XMLのリストのケース",
+			accept:       "application/xml",
+			value:        map[string][]string{"events": {"created", "deleted"}},
+			expectedBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<response><events><item>created</item><item>deleted</item></events></response>`,
+		},
+		{
+			name:         "04_YAMLのケース",
+			accept:       "application/yaml",
+			value:        toDo,
+			expectedBody: "id: 1\ntitle: a & b\nstatus: todo\ndone: false\narchived: false\ncreated_at: \"2021-01-02T03:04:05Z\"\nupdated_at: \"2021-01-02T03:04:05Z\"\n",
+		},
+		{
+			name:         "05_MessagePackのケース",
+			accept:       "application/msgpack",
+			value:        map[string]interface{}{"id": 1, "ok": true, "ids": []int64{-1, 200, 70000}},
+			expectedBody: "\x83\xa2id\x01\xa3ids\x93\xff\xcc\xc8\xce\x00\x01\x11\x70\xa2ok\xc3",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			r := newRequestWithHeader(http.MethodGet, "http://hogehoge/todo/1", "", "Accept", tt.accept)
+			w := httptest.NewRecorder()
+
+			// Act
+			writeResponse(w, r, http.StatusOK, tt.value)
+
+			// Assert
+			if contentType := w.Result().Header.Get("Content-Type"); contentType != tt.accept {
+				t.Errorf("expected: %s, actual: %s", tt.accept, contentType)
+			}
+			if w.Body.String() != tt.expectedBody {
+				t.Errorf("expected: %q, actual: %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDecodeRequest(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name          string
+		contentType   string
+		body          string
+		expected      *service.ToDoObject
+		expectedError string
+	}{
+		{
+			name:        "01_Content-TypeがないとJSONとして読むケース",
+			contentType: "",
+			body:        `{"title": "a", "done": true}`,
+			expected:    &service.ToDoObject{Title: "a", Done: true},
+		},
+		{
+			name:        "02_XMLのケース",
+			contentType: "text/xml; charset=utf-8",
+			body:        "<request>\n  <title> a </title>\n  <done> true </done>\n  <owner>ignored</owner>\n  <created_at>2021-01-02T03:04:05Z</created_at>\n</request>",
+			expected:    &service.ToDoObject{Title: " a ", Done: true, CreatedAt: codecTestTime},
+		},
+		{
+			name:        "03_YAMLのケース",
+			contentType: "application/yaml",
+			body:        "title: a\ndone: true\nid: 10\n",
+			expected:    &service.ToDoObject{Id: 10, Title: "a", Done: true},
+		},
+		{
+			name:        "04_MessagePackのケース",
+			contentType: "application/msgpack",
+			body:        "\x84\xa5title\xa1a\xa4done\xc3\xa2id\xcc\xc8\xaacreated_at\xd6\xff\x5f\xef\xe2\xa5",
+			expected:    &service.ToDoObject{Id: 200, Title: "a", Done: true, CreatedAt: codecTestTime},
+		},
+		{
+			name:          "05_対応していないContent-Typeのケース",
+			contentType:   "text/csv",
+			body:          "title\na\n",
+			expectedError: "unsupported media type: text/csv (supported: application/json, application/xml, application/yaml, application/msgpack)",
+		},
+		{
+			name:          "06_XMLの型が違うケース",
+			contentType:   "application/xml",
+			body:          "<request><done>yes</done></request>",
+			expectedError: "xml: <done> must be true or false",
+		},
+		{
+			name:          "07_YAMLのキーが文字列でないケース",
+			contentType:   "application/yaml",
+			body:          "1: a\n",
+			expectedError: "yaml: key 1 is not a string",
+		},
+		{
+			name:          "08_MessagePackが途中で終わるケース",
+			contentType:   "application/msgpack",
+			body:          "\x81\xa5title\xa5a",
+			expectedError: "msgpack: unexpected end of data",
+		},
+		{
+			name:          "09_MessagePackの長さが残りより長いケース",
+			contentType:   "application/msgpack",
+			body:          "\xdd\xff\xff\xff\xff\xc0",
+			expectedError: "msgpack: unexpected end of data",
+		},
+		{
+			name:          "10_MessagePackの後ろに余計なデータがあるケース",
+			contentType:   "application/msgpack",
+			body:          "\x80\xc0",
+			expectedError: "msgpack: unexpected data after the top-level value",
+		},
+		{
+			name:          "11_MessagePackの入れ子が深すぎるケース",
+			contentType:   "application/msgpack",
+			body:          strings.Repeat("\x91", maxDecodeDepth+1) + "\xc0",
+			expectedError: "msgpack: too deeply nested",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			r := newRequestWithHeader(http.MethodPost, "http://hogehoge/todo", tt.body, "Content-Type", tt.contentType)
+			actual := &service.ToDoObject{}
+
+			// Act
+			err := decodeRequest(r, actual)
+
+			// Assert
+			if tt.expectedError != "" {
+				if err == nil || err.Error() != tt.expectedError {
+					t.Errorf("expected error: %s, actual: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected: %+v, actual: %+v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+// どの形式でも書いたものを同じ値として読めること
+func TestCodecRoundTrip(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	archivedAt := codecTestTime.Add(time.Hour)
+	batch := &service.BatchObject{
+		Mode: "best_effort",
+		Operations: []service.BatchOperationObject{
+			{Op: "create", ToDoObject: service.ToDoObject{Title: "<new>", Done: true}},
+			{Op: "delete", ToDoObject: service.ToDoObject{Id: 1 << 40}},
+		},
+	}
+	for _, c := range codecs {
+		c := c
+		t.Run(c.mediaType, func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			toDo := &service.ToDoObject{Id: -3, Title: "日本語\n", Status: "done", Done: true, Archived: true, CreatedAt: codecTestTime, UpdatedAt: codecTestTime, ArchivedAt: &archivedAt}
+			toDoBuffer := &bytes.Buffer{}
+			batchBuffer := &bytes.Buffer{}
+			actualToDo := &service.ToDoObject{}
+			actualBatch := &service.BatchObject{}
+
+			// Act
+			err := c.encode(toDoBuffer, toDo)
+			if err == nil {
+				err = c.decode(toDoBuffer.Bytes(), actualToDo)
+			}
+			if err == nil {
+				err = c.encode(batchBuffer, batch)
+			}
+			if err == nil {
+				err = c.decode(batchBuffer.Bytes(), actualBatch)
+			}
+
+			// Assert
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if !reflect.DeepEqual(actualToDo, toDo) {
+				t.Errorf("expected: %+v, actual: %+v", toDo, actualToDo)
+			}
+			if !reflect.DeepEqual(actualBatch, batch) {
+				t.Errorf("expected: %+v, actual: %+v", batch, actualBatch)
+			}
+		})
+	}
+}
+
+func TestCreateNegotiated(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	mockToDoService := mock_service.NewMockToDoService(ctrl)
+	mockToDoService.EXPECT().Create(&service.ToDoObject{Title: "test-ToDo"}).Return(&service.ToDoObject{Id: 1, Title: "test-ToDo", Status: "todo"}, nil).Times(1)
+	r := mux.NewRouter()
+	r.HandleFunc("/todo", NewToDoHandler(mockToDoService).Create()).Methods(http.MethodPost)
+	request := newRequestWithHeader(http.MethodPost, "http://hogehoge/todo", "<todo><title>test-ToDo</title></todo>", "Content-Type", "application/xml")
+	request.Header.Set("Accept", "application/yaml")
+	w := httptest.NewRecorder()
+
+	// Act
+	r.ServeHTTP(w, request)
+
+	// Assert
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("expected: %d, actual: %d", http.StatusOK, w.Result().StatusCode)
+	}
+	if w.Result().Header.Get("Content-Type") != "application/yaml" || w.Result().Header.Get("Vary") != "Accept" {
+		t.Errorf("unexpected header: %v", w.Result().Header)
+	}
+	expected := "id: 1\ntitle: test-ToDo\nstatus: todo\ndone: false\narchived: false\ncreated_at: \"0001-01-01T00:00:00Z\"\nupdated_at: \"0001-01-01T00:00:00Z\"\n"
+	if w.Body.String() != expected {
+		t.Errorf("expected: %q, actual: %q", expected, w.Body.String())
+	}
+}
@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Root element of the XML responses
+const xmlRootElement = "response"
+
+// Encode the value as XML, the keys of the objects become the elements and the items of the lists become <item>
+func encodeXML(w io.Writer, v interface{}) error {
+	value, err := toJSONValue(v)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	err = writeXMLElement(encoder, xml.StartElement{Name: xml.Name{Local: xmlRootElement}}, value)
+	if err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func writeXMLElement(encoder *xml.Encoder, start xml.StartElement, value interface{}) error {
+	err := encoder.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case orderedMap:
+		for _, kv := range value {
+			// nullのフィールドは要素を書かない
+			if kv.value == nil {
+				continue
+			}
+			err = writeXMLElement(encoder, xmlStartElement(kv.key), kv.value)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			err = writeXMLElement(encoder, xml.StartElement{Name: xml.Name{Local: "item"}}, item)
+			if err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		err = encoder.EncodeToken(xml.CharData(fmt.Sprint(value)))
+		if err != nil {
+			return err
+		}
+	}
+	return encoder.EncodeToken(start.End())
+}
+
+// 要素名にできないキーは<entry key="...">にする
+func xmlStartElement(key string) xml.StartElement {
+	if isXMLName(key) {
+		return xml.StartElement{Name: xml.Name{Local: key}}
+	}
+	return xml.StartElement{
+		Name: xml.Name{Local: "entry"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}},
+	}
+}
+
+func isXMLName(s string) bool {
+	if s == "" || strings.HasPrefix(strings.ToLower(s), "xml") {
+		return false
+	}
+	for i, c := range s {
+		if c == '_' || unicode.IsLetter(c) {
+			continue
+		}
+		if i > 0 && (c == '-' || c == '.' || unicode.IsDigit(c)) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Element of the XML request
+type xmlNode struct {
+	name     string
+	key      string
+	text     string
+	children []*xmlNode
+}
+
+// Decode the XML with the elements named after the json tags of v, the name of the root element is not checked
+func decodeXML(data []byte, v interface{}) error {
+	root, err := parseXML(data)
+	if err != nil {
+		return err
+	}
+	value, err := xmlValue(root, reflect.TypeOf(v))
+	if err != nil {
+		return err
+	}
+	return fromJSONValue(value, v)
+}
+
+func parseXML(data []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	var root *xmlNode
+	stack := []*xmlNode{}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			if root != nil && len(stack) == 0 {
+				return nil, errors.New("xml: multiple root elements")
+			}
+			if len(stack) >= maxDecodeDepth {
+				return nil, errors.New("xml: too deeply nested")
+			}
+			node := &xmlNode{name: token.Name.Local}
+			for _, attr := range token.Attr {
+				if attr.Name.Local == "key" {
+					node.key = attr.Value
+				}
+			}
+			if root == nil {
+				root = node
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(token)
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("xml: no root element")
+	}
+	return root, nil
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// XMLには型がないので、入れ先の型に合わせてJSONの値にする
+func xmlValue(node *xmlNode, t reflect.Type) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	// time.Timeなどは文字列のままJSONに任せる
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return strings.TrimSpace(node.text), nil
+	}
+	text := strings.TrimSpace(node.text)
+	switch t.Kind() {
+	case reflect.String:
+		return node.text, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("xml: <%s> must be true or false", node.name)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		_, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("xml: <%s> must be an integer", node.name)
+		}
+		return json.Number(text), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		_, err := strconv.ParseUint(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("xml: <%s> must be a non-negative integer", node.name)
+		}
+		return json.Number(text), nil
+	case reflect.Float32, reflect.Float64:
+		_, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("xml: <%s> must be a number", node.name)
+		}
+		return json.Number(text), nil
+	case reflect.Slice, reflect.Array:
+		list := []interface{}{}
+		for _, child := range node.children {
+			item, err := xmlValue(child, t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	case reflect.Map:
+		object := map[string]interface{}{}
+		for _, child := range node.children {
+			item, err := xmlValue(child, t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			object[child.elementKey()] = item
+		}
+		return object, nil
+	case reflect.Struct:
+		fields := jsonFields(t)
+		object := map[string]interface{}{}
+		for _, child := range node.children {
+			// JSONと同じく知らないフィールドは無視する
+			fieldType, ok := fields[child.elementKey()]
+			if !ok {
+				continue
+			}
+			item, err := xmlValue(child, fieldType)
+			if err != nil {
+				return nil, err
+			}
+			object[child.elementKey()] = item
+		}
+		return object, nil
+	}
+	return node.text, nil
+}
+
+// <entry key="...">の場合はkey属性をキーにする
+func (n *xmlNode) elementKey() string {
+	if n.name == "entry" && n.key != "" {
+		return n.key
+	}
+	return n.name
+}
+
+// Types of the fields of the struct by the name in JSON, including the fields of the embedded structs
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	embedded := []reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				embedded = append(embedded, fieldType)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	// 埋め込まれた構造体のフィールドは外側のフィールドより優先度が低い
+	for _, fieldType := range embedded {
+		for name, embeddedType := range jsonFields(fieldType) {
+			if _, ok := fields[name]; !ok {
+				fields[name] = embeddedType
+			}
+		}
+	}
+	return fields
+}
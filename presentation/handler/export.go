@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+)
+
+// Writer of the exported ToDo in a format
+type exportWriter interface {
+	Write(*service.ToDoObject) error
+	// write the data buffered by the writer
+	Flush() error
+}
+
+// Format of GET /todo/export
+type exportFormat struct {
+	contentType string
+	extension   string
+	newWriter   func(io.Writer) (exportWriter, error)
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv":     {contentType: "text/csv; charset=utf-8", extension: "csv", newWriter: newCSVExportWriter},
+	"ndjson":  {contentType: "application/x-ndjson", extension: "ndjson", newWriter: newNDJSONExportWriter},
+	"md":      {contentType: "text/markdown; charset=utf-8", extension: "md", newWriter: newMarkdownExportWriter},
+	"todotxt": {contentType: "text/plain; charset=utf-8", extension: "txt", newWriter: newToDoTxtExportWriter},
+}
+
+func (h *toDoHandler) Export() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("format")
+		if name == "" {
+			name = "csv"
+		}
+		format, ok := exportFormats[name]
+		if !ok {
+			http.Error(w, "format must be csv, ndjson, md or todotxt", http.StatusBadRequest)
+			return
+		}
+		listOption := &service.ListOption{
+			Done:    r.URL.Query().Get("done"),
+			Include: r.URL.Query().Get("include"),
+		}
+
+		// 最初の1件を書き込むまではエラーをステータスコードで返せるよう、レスポンスの書き込みを遅らせる
+		var writer exportWriter
+		start := func() error {
+			w.Header().Set("Content-Type", format.contentType)
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"todo.%s\"", format.extension))
+			w.WriteHeader(http.StatusOK)
+			var err error
+			writer, err = format.newWriter(w)
+			return err
+		}
+		err := h.service.Export(listOption, func(toDo *service.ToDoObject) error {
+			if writer == nil {
+				err := start()
+				if err != nil {
+					return err
+				}
+			}
+			return writer.Write(toDo)
+		})
+		if err != nil && writer == nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+		if err != nil {
+			// ステータスコードは送信済みのため、途中で打ち切ったことはログにだけ残す
+			log.Println("failed to export ToDo:", err)
+			writer.Flush()
+			return
+		}
+		if writer == nil {
+			err = start()
+		}
+		if err == nil {
+			err = writer.Flush()
+		}
+		if err != nil {
+			log.Println("failed to export ToDo:", err)
+		}
+	}
+}
+
+// header of the CSV, readable by POST /todo/import
+var exportCSVHeader = []string{"id", "title", "status", "done", "archived", "created_at", "updated_at"}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVExportWriter(w io.Writer) (exportWriter, error) {
+	writer := csv.NewWriter(w)
+	return &csvExportWriter{writer: writer}, writer.Write(exportCSVHeader)
+}
+
+func (c *csvExportWriter) Write(toDo *service.ToDoObject) error {
+	return c.writer.Write([]string{
+		strconv.FormatInt(toDo.Id, 10),
+		toDo.Title,
+		toDo.Status,
+		strconv.FormatBool(toDo.Done),
+		strconv.FormatBool(toDo.Archived),
+		toDo.CreatedAt.Format(time.RFC3339),
+		toDo.UpdatedAt.Format(time.RFC3339),
+	})
+}
+
+func (c *csvExportWriter) Flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// one JSON object per line
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+}
+
+func newNDJSONExportWriter(w io.Writer) (exportWriter, error) {
+	return &ndjsonExportWriter{encoder: json.NewEncoder(w)}, nil
+}
+
+func (n *ndjsonExportWriter) Write(toDo *service.ToDoObject) error {
+	return n.encoder.Encode(toDo)
+}
+
+func (n *ndjsonExportWriter) Flush() error {
+	return nil
+}
+
+// checklist, e.g. "- [x] Buy a new pencil"
+type markdownExportWriter struct {
+	w io.Writer
+}
+
+func newMarkdownExportWriter(w io.Writer) (exportWriter, error) {
+	return &markdownExportWriter{w: w}, nil
+}
+
+// markdown characters escaped in the title
+var markdownEscaper = strings.NewReplacer(
+	"\\", "\\\\", "`", "\\`", "*", "\\*", "_", "\\_", "[", "\\[", "]", "\\]", "<", "\\<", ">", "\\>", "#", "\\#", "|", "\\|",
+)
+
+func (m *markdownExportWriter) Write(toDo *service.ToDoObject) error {
+	check := " "
+	if toDo.Done {
+		check = "x"
+	}
+	_, err := fmt.Fprintf(m.w, "- [%s] %s\n", check, markdownEscaper.Replace(toDo.Title))
+	return err
+}
+
+func (m *markdownExportWriter) Flush() error {
+	return nil
+}
+
+// todo.txt format, readable by POST /todo/import
+type toDoTxtExportWriter struct {
+	w io.Writer
+}
+
+func newToDoTxtExportWriter(w io.Writer) (exportWriter, error) {
+	return &toDoTxtExportWriter{w: w}, nil
+}
+
+func (t *toDoTxtExportWriter) Write(toDo *service.ToDoObject) error {
+	// 完了済みの場合は最終更新日を完了日とする
+	line := toDo.CreatedAt.Format("2006-01-02") + " " + strings.Join(strings.Fields(toDo.Title), " ")
+	if toDo.Done {
+		line = "x " + toDo.UpdatedAt.Format("2006-01-02") + " " + line
+	}
+	_, err := fmt.Fprintln(t.w, line)
+	return err
+}
+
+func (t *toDoTxtExportWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service/mock_service"
+)
+
+func TestExport(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	createdAt := time.Date(2021, 6, 1, 0, 35, 7, 0, time.UTC)
+	updatedAt := time.Date(2021, 6, 15, 0, 40, 10, 0, time.UTC)
+	toDoList := []service.ToDoObject{
+		{Id: 123, Title: "Buy a new pencil", Status: "done", Done: true, CreatedAt: createdAt, UpdatedAt: updatedAt},
+		{Id: 456, Title: "Go to the *cinema*, with Bob", Status: "in_progress", CreatedAt: createdAt, UpdatedAt: createdAt},
+	}
+	tests := []struct {
+		name                string
+		target              string
+		exportTimes         int
+		exportError         error
+		wantListOption      service.ListOption
+		expectedStatusCode  int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "01_CSVのケース",
+			target:              "http://hogehoge/todo/export?done=true&include=archived",
+			exportTimes:         1,
+			wantListOption:      service.ListOption{Done: "true", Include: "archived"},
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody: "id,title,status,done,archived,created_at,updated_at\n" +
+				"123,Buy a new pencil,done,true,false,2021-06-01T00:35:07Z,2021-06-15T00:40:10Z\n" +
+				"456,\"Go to the *cinema*, with Bob\",in_progress,false,false,2021-06-01T00:35:07Z,2021-06-01T00:35:07Z\n",
+		},
+		{
+			name:                "02_NDJSONのケース",
+			target:              "http://hogehoge/todo/export?format=ndjson",
+			exportTimes:         1,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody: `{"id":123,"title":"Buy a new pencil","status":"done","done":true,"archived":false,"created_at":"2021-06-01T00:35:07Z","updated_at":"2021-06-15T00:40:10Z"}` + "\n" +
+				`{"id":456,"title":"Go to the *cinema*, with Bob","status":"in_progress","done":false,"archived":false,"created_at":"2021-06-01T00:35:07Z","updated_at":"2021-06-01T00:35:07Z"}` + "\n",
+		},
+		{
+			name:                "03_Markdownのケース",
+			target:              "http://hogehoge/todo/export?format=md",
+			exportTimes:         1,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "text/markdown; charset=utf-8",
+			expectedBody:        "- [x] Buy a new pencil\n- [ ] Go to the \\*cinema\\*, with Bob\n",
+		},
+		{
+			name:                "04_todo.txtのケース",
+			target:              "http://hogehoge/todo/export?format=todotxt",
+			exportTimes:         1,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        "x 2021-06-15 2021-06-01 Buy a new pencil\n2021-06-01 Go to the *cinema*, with Bob\n",
+		},
+		{
+			name:               "05_formatが不正なケース",
+			target:             "http://hogehoge/todo/export?format=xlsx",
+			exportTimes:        0,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "06_Exportが失敗するケース",
+			target:             "http://hogehoge/todo/export",
+			exportTimes:        1,
+			exportError:        errors.New("Export ERROR"),
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			mockToDoService.EXPECT().Export(gomock.Any(), gomock.Any()).DoAndReturn(func(option *service.ListOption, fn func(*service.ToDoObject) error) error {
+				if *option != tt.wantListOption {
+					t.Errorf("expected: %+v, actual: %+v", tt.wantListOption, *option)
+				}
+				if tt.exportError != nil {
+					return tt.exportError
+				}
+				for i := range toDoList {
+					err := fn(&toDoList[i])
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}).Times(tt.exportTimes)
+			toDoHandler := NewToDoHandler(mockToDoService)
+
+			r := mux.NewRouter()
+			r.HandleFunc("/todo/export", toDoHandler.Export()).Methods(http.MethodGet)
+			w := httptest.NewRecorder()
+
+			// Act
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			// Assert
+			if w.Result().StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Result().StatusCode)
+			}
+			if tt.expectedStatusCode != http.StatusOK {
+				return
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != tt.expectedContentType {
+				t.Errorf("expected: %s, actual: %s", tt.expectedContentType, contentType)
+			}
+			if body := w.Body.String(); body != tt.expectedBody {
+				t.Errorf("expected: %q, actual: %q", tt.expectedBody, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+)
+
+// format of DATE-TIME values in UTC
+const iCalendarTimeFormat = "20060102T150405Z"
+
+// max length of a content line in octets, excluding CRLF
+const iCalendarLineLength = 75
+
+// first lines of an iCalendar object, followed by the components and END:VCALENDAR
+var iCalendarHeader = []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//" + service.ICalendarUidDomain + "//ToDo//EN"}
+
+// Feed of ToDo as VTODO components (RFC 5545) for calendar apps
+func (h *toDoHandler) ICalendar() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listOption := &service.ListOption{
+			Done:    r.URL.Query().Get("done"),
+			Include: r.URL.Query().Get("include"),
+		}
+
+		// 最初の1件を書き込むまではエラーをステータスコードで返せるよう、レスポンスの書き込みを遅らせる
+		started := false
+		start := func() error {
+			started = true
+			w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			return writeICalendarLines(w, iCalendarHeader...)
+		}
+		err := h.service.ExportICalendar(listOption, func(toDo *service.ToDoObject, uid string) error {
+			if !started {
+				err := start()
+				if err != nil {
+					return err
+				}
+			}
+			return writeVTodo(w, toDo, uid)
+		})
+		if err != nil && !started {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+		if err != nil {
+			// ステータスコードは送信済みのため、途中で打ち切ったことはログにだけ残す
+			log.Println("failed to export iCalendar:", err)
+			return
+		}
+		if !started {
+			err = start()
+		}
+		if err == nil {
+			err = writeICalendarLines(w, "END:VCALENDAR")
+		}
+		if err != nil {
+			log.Println("failed to export iCalendar:", err)
+		}
+	}
+}
+
+// Create or update ToDo by the UID of the VTODO components
+func (h *toDoHandler) ImportICalendar() http.HandlerFunc {
+	return h.importToDo(service.ImportFormatICal)
+}
+
+func writeVTodo(w io.Writer, toDo *service.ToDoObject, uid string) error {
+	// ToDoには期日がないためDUEは出力しない
+	status := "NEEDS-ACTION"
+	lines := []string{
+		"BEGIN:VTODO",
+		"UID:" + escapeICalendarText(uid),
+		"DTSTAMP:" + toDo.UpdatedAt.UTC().Format(iCalendarTimeFormat),
+		"SUMMARY:" + escapeICalendarText(toDo.Title),
+		"CREATED:" + toDo.CreatedAt.UTC().Format(iCalendarTimeFormat),
+		"LAST-MODIFIED:" + toDo.UpdatedAt.UTC().Format(iCalendarTimeFormat),
+	}
+	if toDo.Done {
+		status = "COMPLETED"
+		lines = append(lines, "COMPLETED:"+toDo.UpdatedAt.UTC().Format(iCalendarTimeFormat))
+	}
+	lines = append(lines, "STATUS:"+status, "END:VTODO")
+	return writeICalendarLines(w, lines...)
+}
+
+// 75オクテットを超える行を折り返してCRLFで書き込む
+func writeICalendarLines(w io.Writer, lines ...string) error {
+	var b strings.Builder
+	for _, line := range lines {
+		length := 0
+		for _, c := range line {
+			// マルチバイト文字の途中では折り返さない
+			size := len(string(c))
+			if length+size > iCalendarLineLength {
+				b.WriteString("\r\n ")
+				length = 1
+			}
+			b.WriteRune(c)
+			length += size
+		}
+		b.WriteString("\r\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// TEXT型の値をエスケープする
+var iCalendarTextEscaper = strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", "\r\n", "\\n", "\n", "\\n")
+
+func escapeICalendarText(value string) string {
+	return iCalendarTextEscaper.Replace(value)
+}
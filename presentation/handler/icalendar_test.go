@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service/mock_service"
+)
+
+func TestICalendar(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	createdAt := time.Date(2021, 6, 1, 0, 35, 7, 0, time.UTC)
+	updatedAt := time.Date(2021, 6, 15, 0, 40, 10, 0, time.UTC)
+	tests := []struct {
+		name               string
+		toDoList           []service.ToDoObject
+		exportError        error
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name: "01_VTODOを出力するケース",
+			toDoList: []service.ToDoObject{
+				{Id: 123, Title: "Buy a new pencil; an eraser, and a notebook", Done: true, CreatedAt: createdAt, UpdatedAt: updatedAt},
+				{Id: 456, Title: strings.Repeat("あ", 30), CreatedAt: createdAt, UpdatedAt: createdAt},
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody: "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//todo-rest-api-golang//ToDo//EN\r\n" +
+				"BEGIN:VTODO\r\nUID:123@todo-rest-api-golang\r\nDTSTAMP:20210615T004010Z\r\n" +
+				"SUMMARY:Buy a new pencil\\; an eraser\\, and a notebook\r\n" +
+				"CREATED:20210601T003507Z\r\nLAST-MODIFIED:20210615T004010Z\r\nCOMPLETED:20210615T004010Z\r\nSTATUS:COMPLETED\r\nEND:VTODO\r\n" +
+				"BEGIN:VTODO\r\nUID:456@todo-rest-api-golang\r\nDTSTAMP:20210601T003507Z\r\n" +
+				"SUMMARY:" + strings.Repeat("あ", 22) + "\r\n " + strings.Repeat("あ", 8) + "\r\n" +
+				"CREATED:20210601T003507Z\r\nLAST-MODIFIED:20210601T003507Z\r\nSTATUS:NEEDS-ACTION\r\nEND:VTODO\r\n" +
+				"END:VCALENDAR\r\n",
+		},
+		{
+			name:               "02_ToDoがないケース",
+			toDoList:           []service.ToDoObject{},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//todo-rest-api-golang//ToDo//EN\r\nEND:VCALENDAR\r\n",
+		},
+		{
+			name:               "03_ExportICalendarが失敗するケース",
+			exportError:        errors.New("Export ERROR"),
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			mockToDoService.EXPECT().ExportICalendar(gomock.Any(), gomock.Any()).DoAndReturn(func(option *service.ListOption, fn func(*service.ToDoObject, string) error) error {
+				if tt.exportError != nil {
+					return tt.exportError
+				}
+				for i := range tt.toDoList {
+					err := fn(&tt.toDoList[i], service.ICalendarUid(tt.toDoList[i].Id, nil))
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			toDoHandler := NewToDoHandler(mockToDoService)
+
+			r := mux.NewRouter()
+			r.HandleFunc("/todo.ics", toDoHandler.ICalendar()).Methods(http.MethodGet)
+			w := httptest.NewRecorder()
+
+			// Act
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://hogehoge/todo.ics", nil))
+
+			// Assert
+			if w.Result().StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Result().StatusCode)
+			}
+			if tt.expectedStatusCode != http.StatusOK {
+				return
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != "text/calendar; charset=utf-8" {
+				t.Errorf("unexpected Content-Type: %s", contentType)
+			}
+			if body := w.Body.String(); body != tt.expectedBody {
+				t.Errorf("expected: %q, actual: %q", tt.expectedBody, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+)
+
+const (
+	// header of the key to make a request idempotent
+	idempotencyKeyHeader = "Idempotency-Key"
+	// header set when the stored response is returned
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	// max length of Idempotency-Key
+	maxIdempotencyKeyLength = 255
+)
+
+// Return the stored response for a retried request with the same Idempotency-Key instead of processing it again
+func Idempotency(idempotencyService service.IdempotencyService) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			body, err := readRequestBody(r, maxRequestBodySize(r))
+			if err != nil {
+				http.Error(w, err.Error(), requestBodyStatusCode(err, http.StatusBadRequest))
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			stored, err := idempotencyService.Start(key, requestHash(r, body))
+			switch {
+			case errors.Is(err, service.ErrIdempotencyKeyReused):
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			case errors.Is(err, service.ErrIdempotencyInProgress):
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			case err != nil:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			case stored != nil:
+				// 保存したレスポンスをそのまま返す
+				w.Header().Set("Content-Type", stored.ContentType)
+				w.Header().Set(idempotentReplayedHeader, "true")
+				w.WriteHeader(stored.StatusCode)
+				w.Write(stored.Body)
+				return
+			}
+
+			// ハンドラがパニックしても処理中のままにせず、キーを消してからパニックを続ける
+			defer func() {
+				if p := recover(); p != nil {
+					cancelIdempotencyKey(idempotencyService, key)
+					panic(p)
+				}
+			}()
+			recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			// サーバーエラーの場合はリトライできるようにキーを消す
+			if recorder.statusCode >= http.StatusInternalServerError {
+				cancelIdempotencyKey(idempotencyService, key)
+				return
+			}
+			err = idempotencyService.Complete(key, &service.IdempotentResponse{
+				StatusCode:  recorder.statusCode,
+				ContentType: w.Header().Get("Content-Type"),
+				Body:        recorder.body.Bytes(),
+			})
+			if err != nil {
+				// レスポンスを保存できなかったキーはTTLまで409にならないよう消す
+				log.Println("failed to store the response for the idempotency key:", err)
+				cancelIdempotencyKey(idempotencyService, key)
+			}
+		})
+	}
+}
+
+// リトライできるようにキーを消す
+func cancelIdempotencyKey(idempotencyService service.IdempotencyService, key string) {
+	err := idempotencyService.Cancel(key)
+	if err != nil {
+		log.Println("failed to release the idempotency key:", err)
+	}
+}
+
+// リクエストのメソッド、パス、ボディの形式、レスポンスの形式、ボディのハッシュ
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write([]byte(requestMediaType(r) + "\n" + responseMediaType(r) + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// 別名のメディアタイプやパラメータが違っても同じ形式なら同じ値にする
+func requestMediaType(r *http.Request) string {
+	c, err := requestCodec(r)
+	if err == nil {
+		return c.mediaType
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType
+}
+
+// Acceptで選ばれる形式(どれも選べなければ空)
+func responseMediaType(r *http.Request) string {
+	c := responseCodec(r)
+	if c == nil {
+		return ""
+	}
+	return c.mediaType
+}
+
+// http.ResponseWriter that keeps a copy of the status code and the body
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
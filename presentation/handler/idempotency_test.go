@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service/mock_service"
+)
+
+func TestIdempotency(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	tests := []struct {
+		name               string
+		key                string
+		body               string
+		startResponse      *service.IdempotentResponse
+		startError         error
+		startTimes         int
+		nextStatusCode     int
+		nextPanic          bool
+		completeError      error
+		completeTimes      int
+		cancelTimes        int
+		wantNextCalled     bool
+		wantReplayed       bool
+		expectedStatusCode int
+	}{
+		{
+			name:               "01_Idempotency-Keyが指定されていないケース",
+			key:                "",
+			startTimes:         0,
+			nextStatusCode:     http.StatusOK,
+			wantNextCalled:     true,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "02_初めてのリクエストのレスポンスを保存するケース",
+			key:                "key-1",
+			startTimes:         1,
+			nextStatusCode:     http.StatusOK,
+			completeTimes:      1,
+			wantNextCalled:     true,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "03_保存したレスポンスを返すケース",
+			key:                "key-1",
+			startResponse:      &service.IdempotentResponse{StatusCode: http.StatusOK, ContentType: "application/json", Body: []byte(`{"id":1}`)},
+			startTimes:         1,
+			wantNextCalled:     false,
+			wantReplayed:       true,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "04_別のリクエストでキーが使われているケース",
+			key:                "key-1",
+			startError:         service.ErrIdempotencyKeyReused,
+			startTimes:         1,
+			wantNextCalled:     false,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:               "05_同じキーのリクエストが処理中のケース",
+			key:                "key-1",
+			startError:         service.ErrIdempotencyInProgress,
+			startTimes:         1,
+			wantNextCalled:     false,
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name:               "06_サーバーエラーの場合はキーを消すケース",
+			key:                "key-1",
+			startTimes:         1,
+			nextStatusCode:     http.StatusInternalServerError,
+			cancelTimes:        1,
+			wantNextCalled:     true,
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "07_Startが失敗するケース",
+			key:                "key-1",
+			startError:         errors.New("Start ERROR"),
+			startTimes:         1,
+			wantNextCalled:     false,
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "08_Idempotency-Keyが長すぎるケース",
+			key:                strings.Repeat("k", maxIdempotencyKeyLength+1),
+			startTimes:         0,
+			wantNextCalled:     false,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "09_レスポンスの保存に失敗した場合はキーを消すケース",
+			key:                "key-1",
+			startTimes:         1,
+			nextStatusCode:     http.StatusOK,
+			completeError:      errors.New("Complete ERROR"),
+			completeTimes:      1,
+			cancelTimes:        1,
+			wantNextCalled:     true,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "10_ハンドラがパニックした場合はキーを消すケース",
+			key:                "key-1",
+			startTimes:         1,
+			nextPanic:          true,
+			cancelTimes:        1,
+			wantNextCalled:     true,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "11_ボディが大きすぎるケース",
+			key:                "key-1",
+			body:               strings.Repeat("a", maxRequestSize+1),
+			startTimes:         0,
+			wantNextCalled:     false,
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			mockIdempotencyService := mock_service.NewMockIdempotencyService(ctrl)
+			mockIdempotencyService.EXPECT().Start(tt.key, gomock.Any()).Return(tt.startResponse, tt.startError).Times(tt.startTimes)
+			mockIdempotencyService.EXPECT().Complete(tt.key, gomock.Any()).Return(tt.completeError).Times(tt.completeTimes)
+			mockIdempotencyService.EXPECT().Cancel(tt.key).Return(nil).Times(tt.cancelTimes)
+			nextCalled := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nextCalled = true
+				if tt.nextPanic {
+					panic("next PANIC")
+				}
+				w.WriteHeader(tt.nextStatusCode)
+			})
+			body := tt.body
+			if body == "" {
+				body = `{"title": "test-ToDo"}`
+			}
+			request := httptest.NewRequest(http.MethodPost, "http://hogehoge/todo", strings.NewReader(body))
+			if tt.key != "" {
+				request.Header.Set("Idempotency-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+
+			// Act
+			panicked := false
+			func() {
+				defer func() {
+					panicked = recover() != nil
+				}()
+				Idempotency(mockIdempotencyService)(next).ServeHTTP(w, request)
+			}()
+
+			// Assert
+			if w.Result().StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Result().StatusCode)
+			}
+			// パニックはキーを消した後にそのまま伝える
+			if panicked != tt.nextPanic {
+				t.Errorf("expected panic: %v, actual: %v", tt.nextPanic, panicked)
+			}
+			if nextCalled != tt.wantNextCalled {
+				t.Errorf("expected next to be called: %v, actual: %v", tt.wantNextCalled, nextCalled)
+			}
+			if replayed := w.Result().Header.Get("Idempotent-Replayed") == "true"; replayed != tt.wantReplayed {
+				t.Errorf("expected replayed: %v, actual: %v", tt.wantReplayed, replayed)
+			}
+		})
+	}
+}
+
+func TestRequestHash(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	newRequest := func(target string, contentType string, accept string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "http://hogehoge"+target, nil)
+		r.Header.Set("Content-Type", contentType)
+		r.Header.Set("Accept", accept)
+		return r
+	}
+	base := requestHash(newRequest("/todo", "application/json", ""), []byte(`{"title": "a"}`))
+	tests := []struct {
+		name     string
+		request  *http.Request
+		body     string
+		wantSame bool
+	}{
+		{
+			name:     "01_同じリクエストのケース",
+			request:  newRequest("/todo", "application/json", ""),
+			body:     `{"title": "a"}`,
+			wantSame: true,
+		},
+		{
+			name:     "02_同じ形式を別の書き方で指定したケース",
+			request:  newRequest("/todo", "application/json; charset=utf-8", "application/json, */*;q=0.1"),
+			body:     `{"title": "a"}`,
+			wantSame: true,
+		},
+		{
+			name:     "03_ボディが違うケース",
+			request:  newRequest("/todo", "application/json", ""),
+			body:     `{"title": "b"}`,
+			wantSame: false,
+		},
+		{
+			name:     "04_パスが違うケース",
+			request:  newRequest("/todo/bulk", "application/json", ""),
+			body:     `{"title": "a"}`,
+			wantSame: false,
+		},
+		{
+			name:     "05_Content-Typeが違うケース",
+			request:  newRequest("/todo", "application/yaml", ""),
+			body:     `{"title": "a"}`,
+			wantSame: false,
+		},
+		{
+			name:     "06_Acceptが違うケース",
+			request:  newRequest("/todo", "application/json", "application/xml"),
+			body:     `{"title": "a"}`,
+			wantSame: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Act
+			actual := requestHash(tt.request, []byte(tt.body))
+
+			// Assert
+			if (actual == base) != tt.wantSame {
+				t.Errorf("expected the same hash: %v, base: %s, actual: %s", tt.wantSame, base, actual)
+			}
+		})
+	}
+}
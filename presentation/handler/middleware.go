@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+const (
+	// header of the user who made the request
+	actorHeader = "X-Actor"
+	// header of the ID of the request
+	requestIdHeader = "X-Request-Id"
+)
+
+// Give each request an ID (unless the client sent one) and return it in the response header
+func RequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIdHeader)
+		if id == "" {
+			id = newRequestId()
+			r.Header.Set(requestIdHeader, id)
+		}
+		w.Header().Set(requestIdHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
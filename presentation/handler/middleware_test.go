@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestId(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name      string
+		requestId string
+	}{
+		{
+			name:      "01_リクエストIDが指定されていないケース",
+			requestId: "",
+		},
+		{
+			name:      "02_リクエストIDが指定されているケース",
+			requestId: "req-1",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			var received string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r.Header.Get("X-Request-Id")
+			})
+			request := httptest.NewRequest(http.MethodGet, "http://hogehoge/todo", nil)
+			if tt.requestId != "" {
+				request.Header.Set("X-Request-Id", tt.requestId)
+			}
+			w := httptest.NewRecorder()
+
+			// Act
+			RequestId(next).ServeHTTP(w, request)
+
+			// Assert
+			returned := w.Result().Header.Get("X-Request-Id")
+			if received == "" || received != returned {
+				t.Errorf("request ID does not match. received: %s, returned: %s", received, returned)
+			}
+			if tt.requestId != "" && returned != tt.requestId {
+				t.Errorf("expected: %s, actual: %s", tt.requestId, returned)
+			}
+		})
+	}
+}
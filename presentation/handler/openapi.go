@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/openapi"
+)
+
+// Serve the OpenAPI document of the API
+func OpenAPI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(openapi.Spec)
+	}
+}
+
+// Serve the page rendering the OpenAPI document
+func OpenAPIDocs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(openapi.Page)
+	}
+}
+
+// Body of the response to a request that does not match the OpenAPI document
+type validationErrorResponse struct {
+	Message string                    `json:"message"`
+	Errors  []openapi.ValidationError `json:"errors"`
+}
+
+// Validate the requests against the OpenAPI document and reject the invalid ones with 400 and the list of the errors.
+// The responses are also validated if validateResponses, but the errors are only logged because the response is already sent
+func ValidateOpenAPI(validator *openapi.Validator, validateRequests bool, validateResponses bool) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if !validateRequests && !validateResponses {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := mux.CurrentRoute(r)
+			if route == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			template, err := route.GetPathTemplate()
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if validateRequests {
+				// 定義にボディのない操作(CalDAVのREPORTなど)はボディを読まずにハンドラに任せる
+				var body []byte
+				if validator.HasRequestBody(r.Method, template) {
+					body, err = peekRequestBody(r, maxRequestBodySize(r))
+					if err != nil {
+						http.Error(w, err.Error(), requestBodyStatusCode(err, http.StatusBadRequest))
+						return
+					}
+				}
+				errs := validateRequest(validator, r, template, body)
+				if len(errs) > 0 {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusBadRequest)
+					json.NewEncoder(w).Encode(validationErrorResponse{
+						Message: "the request does not match the OpenAPI document",
+						Errors:  errs,
+					})
+					return
+				}
+			}
+			if !validateResponses {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			recorder := &validationRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+			if recorder.hijacked {
+				return
+			}
+			var body []byte
+			if !recorder.skipped {
+				body = recorder.body.Bytes()
+			}
+			for _, err := range validator.ValidateResponse(r.Method, template, recorder.statusCode, w.Header().Get("Content-Type"), body) {
+				log.Printf("response of %s %s does not match the OpenAPI document: %v", r.Method, r.URL.Path, err)
+			}
+		})
+	}
+}
+
+// JSON以外のボディはJSONにしてから検証する(どの形式もJSONを経由して読むので、スキーマはJSONと同じ)
+func validateRequest(validator *openapi.Validator, r *http.Request, template string, body []byte) []openapi.ValidationError {
+	c, err := requestCodec(r)
+	// JSONと対応していない形式はそのまま検証する(対応していない形式にはハンドラが415を返す)
+	if body == nil || err != nil || c == codecs[0] {
+		return validator.ValidateRequest(r, template, mux.Vars(r), body)
+	}
+	// XMLの値は構造体に入れるときにハンドラが検証する
+	if c.untyped {
+		return validator.ValidateRequest(r, template, mux.Vars(r), nil)
+	}
+	converted := body
+	if len(bytes.TrimSpace(body)) > 0 {
+		var value interface{}
+		err = c.decode(body, &value)
+		if err == nil {
+			converted, err = json.Marshal(value)
+		}
+		if err != nil {
+			return []openapi.ValidationError{{In: "body", Message: "invalid " + c.mediaType + ": " + err.Error()}}
+		}
+	}
+	jsonRequest := r.Clone(r.Context())
+	jsonRequest.Header.Set("Content-Type", "application/json")
+	return validator.ValidateRequest(jsonRequest, template, mux.Vars(r), converted)
+}
+
+// ハンドラが読めるようにボディを戻す(操作の上限より大きいボディはエラー)
+func peekRequestBody(r *http.Request, limit int64) ([]byte, error) {
+	body, err := readRequestBody(r, limit)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{bytes.NewReader(body), r.Body}
+	return body, nil
+}
+
+// http.ResponseWriter that keeps a copy of the status code and the JSON body,
+// which also supports the streaming (Server-Sent Events) and the upgrade to WebSocket
+type validationRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+	// the body is not JSON or too large to be validated
+	skipped  bool
+	hijacked bool
+	written  bool
+}
+
+func (r *validationRecorder) WriteHeader(statusCode int) {
+	if !r.written {
+		r.written = true
+		r.statusCode = statusCode
+		media, _, _ := mime.ParseMediaType(r.Header().Get("Content-Type"))
+		r.skipped = media != "application/json"
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *validationRecorder) Write(b []byte) (int, error) {
+	if !r.written {
+		r.WriteHeader(http.StatusOK)
+	}
+	if !r.skipped {
+		r.body.Write(b)
+		if r.body.Len() > maxImportSize {
+			r.skipped = true
+			r.body.Reset()
+		}
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *validationRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *validationRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the connection cannot be hijacked")
+	}
+	r.hijacked = true
+	return hijacker.Hijack()
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/openapi"
+)
+
+func TestOpenAPI(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	tests := []struct {
+		name                string
+		handler             http.HandlerFunc
+		expectedContentType string
+	}{
+		{
+			name:                "01_OpenAPIの定義を返すケース",
+			handler:             OpenAPI(),
+			expectedContentType: "application/json",
+		},
+		{
+			name:                "02_定義を表示するページを返すケース",
+			handler:             OpenAPIDocs(),
+			expectedContentType: "text/html; charset=utf-8",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			w := httptest.NewRecorder()
+
+			// Act
+			tt.handler(w, httptest.NewRequest(http.MethodGet, "http://hogehoge/openapi.json", nil))
+
+			// Assert
+			if w.Code != http.StatusOK {
+				t.Errorf("expected: %d, actual: %d", http.StatusOK, w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != tt.expectedContentType {
+				t.Errorf("expected: %s, actual: %s", tt.expectedContentType, contentType)
+			}
+			if tt.expectedContentType == "application/json" {
+				var spec map[string]interface{}
+				err := json.Unmarshal(w.Body.Bytes(), &spec)
+				if err != nil || !strings.HasPrefix(spec["openapi"].(string), "3.") {
+					t.Errorf("invalid OpenAPI document: %v", err)
+				}
+			} else if !strings.Contains(w.Body.String(), "/openapi.json") {
+				t.Errorf("the page does not load /openapi.json")
+			}
+		})
+	}
+}
+
+func TestValidateOpenAPI(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	validator, err := openapi.NewValidator(openapi.Spec)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tests := []struct {
+		name               string
+		validateRequests   bool
+		method             string
+		target             string
+		contentType        string
+		body               string
+		expectedStatusCode int
+		expectedResponse   *validationErrorResponse
+	}{
+		{
+			name:               "01_正しいリクエストをハンドラに渡すケース",
+			validateRequests:   true,
+			target:             "/todo/123",
+			contentType:        "application/json",
+			body:               `{"title": "Buy a new pencil"}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "02_定義に合わないリクエストを400で返すケース",
+			validateRequests:   true,
+			target:             "/todo/abc",
+			contentType:        "application/json",
+			body:               `{"done": "yes"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse: &validationErrorResponse{
+				Message: "the request does not match the OpenAPI document",
+				Errors: []openapi.ValidationError{
+					{In: "path", Name: "id", Message: "must be an integer"},
+					{In: "body", Name: "done", Message: "must be a boolean"},
+				},
+			},
+		},
+		{
+			name:               "03_検証しない設定のケース",
+			validateRequests:   false,
+			target:             "/todo/abc",
+			contentType:        "application/json",
+			body:               `{"done": "yes"}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "04_YAMLのボディも検証するケース",
+			validateRequests:   true,
+			target:             "/todo/123",
+			contentType:        "application/yaml",
+			body:               "done: \"yes\"\n",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse: &validationErrorResponse{
+				Message: "the request does not match the OpenAPI document",
+				Errors:  []openapi.ValidationError{{In: "body", Name: "done", Message: "must be a boolean"}},
+			},
+		},
+		{
+			name:               "05_MessagePackのボディも検証するケース",
+			validateRequests:   true,
+			target:             "/todo/123",
+			contentType:        "application/msgpack",
+			body:               "\x81\xa5title\xc3",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse: &validationErrorResponse{
+				Message: "the request does not match the OpenAPI document",
+				Errors:  []openapi.ValidationError{{In: "body", Name: "title", Message: "must be a string"}},
+			},
+		},
+		{
+			name:               "06_読めないMessagePackを400で返すケース",
+			validateRequests:   true,
+			target:             "/todo/123",
+			contentType:        "application/msgpack",
+			body:               "\x81\xa5title",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse: &validationErrorResponse{
+				Message: "the request does not match the OpenAPI document",
+				Errors:  []openapi.ValidationError{{In: "body", Message: "invalid application/msgpack: msgpack: unexpected end of data"}},
+			},
+		},
+		{
+			name:               "07_型のないXMLはハンドラに任せるケース",
+			validateRequests:   true,
+			target:             "/todo/123",
+			contentType:        "application/xml",
+			body:               "<request><done>yes</done></request>",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "08_操作の上限より大きいボディを413で返すケース",
+			validateRequests:   true,
+			target:             "/todo/123",
+			contentType:        "application/json",
+			body:               `{"title": "` + strings.Repeat("a", maxRequestSize) + `"}`,
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:               "09_インポートは大きなボディも受け付けるケース",
+			validateRequests:   true,
+			method:             http.MethodPost,
+			target:             "/todo/import",
+			contentType:        "text/csv",
+			body:               "title\n" + strings.Repeat("a\n", maxRequestSize),
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			var received string
+			router := mux.NewRouter()
+			router.Use(ValidateOpenAPI(validator, tt.validateRequests, false))
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				received = string(body)
+				w.WriteHeader(http.StatusOK)
+			}
+			router.HandleFunc("/todo/{id}", handler).Methods(http.MethodPatch)
+			router.HandleFunc("/todo/import", handler).Methods(http.MethodPost)
+			method := tt.method
+			if method == "" {
+				method = http.MethodPatch
+			}
+			w := httptest.NewRecorder()
+
+			// Act
+			request := httptest.NewRequest(method, "http://hogehoge"+tt.target, strings.NewReader(tt.body))
+			request.Header.Set("Content-Type", tt.contentType)
+			router.ServeHTTP(w, request)
+
+			// Assert
+			if w.Code != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Code)
+			}
+			if tt.expectedStatusCode == http.StatusRequestEntityTooLarge {
+				// ハンドラは呼ばれない
+				if received != "" {
+					t.Errorf("the handler must not be called: %d bytes", len(received))
+				}
+				return
+			}
+			if tt.expectedResponse == nil {
+				// ハンドラがボディをそのまま読めること
+				if received != tt.body {
+					t.Errorf("expected: %s, actual: %s", tt.body, received)
+				}
+				return
+			}
+			var actual validationErrorResponse
+			err := json.Unmarshal(w.Body.Bytes(), &actual)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if !reflect.DeepEqual(&actual, tt.expectedResponse) {
+				t.Errorf("expected: %+v, actual: %+v", tt.expectedResponse, &actual)
+			}
+		})
+	}
+}
@@ -1,10 +1,15 @@
 package handler
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
@@ -16,6 +21,61 @@ type ToDoHandler interface {
 	Update() http.HandlerFunc
 	Delete() http.HandlerFunc
 	List() http.HandlerFunc
+	Trash() http.HandlerFunc
+	Restore() http.HandlerFunc
+	Archive() http.HandlerFunc
+	Unarchive() http.HandlerFunc
+	ArchiveDone() http.HandlerFunc
+	History() http.HandlerFunc
+	Revisions() http.HandlerFunc
+	Revert() http.HandlerFunc
+	Batch() http.HandlerFunc
+	Import() http.HandlerFunc
+	Export() http.HandlerFunc
+	ICalendar() http.HandlerFunc
+	ImportICalendar() http.HandlerFunc
+	Events() http.HandlerFunc
+	WebSocket() http.HandlerFunc
+}
+
+// max number of operations in one batch request
+const maxBatchOperations = 1000
+
+// number of ToDo in a page of GET /todo if only after is given, and the max of limit
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// max size of the data in one import request
+const maxImportSize = 10 << 20
+
+// max size of the body of the other requests
+const maxRequestSize = 1 << 20
+
+// operations that accept up to maxImportSize, by "METHOD path template"
+var importOperations = map[string]bool{
+	"POST /todo/import": true,
+	"POST /todo.ics":    true,
+}
+
+// インポートだけ大きなボディを受け付ける(ルートが分からなければmaxRequestSize)
+func maxRequestBodySize(r *http.Request) int64 {
+	if route := mux.CurrentRoute(r); route != nil {
+		template, err := route.GetPathTemplate()
+		if err == nil && importOperations[r.Method+" "+template] {
+			return maxImportSize
+		}
+	}
+	return maxRequestSize
+}
+
+// import format of each Content-Type, used if the format parameter is not given
+var importFormats = map[string]string{
+	"text/csv":         service.ImportFormatCSV,
+	"application/json": service.ImportFormatJSON,
+	"text/plain":       service.ImportFormatToDoTxt,
+	"text/calendar":    service.ImportFormatICal,
 }
 
 type toDoHandler struct {
@@ -29,26 +89,25 @@ func NewToDoHandler(service service.ToDoService) ToDoHandler {
 }
 
 func (h *toDoHandler) Create() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		requestToDo, err := parseRequestJSON(r)
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		requestToDo, err := parseRequestToDo(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), requestBodyStatusCode(err, http.StatusInternalServerError))
 			return
 		}
+		setRequestInfo(r, requestToDo)
 
 		resultToDo, err := h.service.Create(requestToDo)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatusCode(err))
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(resultToDo)
-	}
+		writeResponse(w, r, http.StatusOK, resultToDo)
+	})
 }
 
 func (h *toDoHandler) Read() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
 		id, err := getPathParamId(r)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -60,47 +119,44 @@ func (h *toDoHandler) Read() http.HandlerFunc {
 
 		resultToDo, err := h.service.Read(requestToDo)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatusCode(err))
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(resultToDo)
-	}
+		writeResponse(w, r, http.StatusOK, resultToDo)
+	})
 }
 
 func (h *toDoHandler) Update() http.HandlerFunc {
 	// WIP
-	return func(w http.ResponseWriter, r *http.Request) {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
 		id, err := getPathParamId(r)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		requestToDo, err := parseRequestJSON(r)
+		requestToDo, err := parseRequestToDo(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), requestBodyStatusCode(err, http.StatusInternalServerError))
 			return
 		}
 		requestToDo.Id = id
+		setRequestInfo(r, requestToDo)
 
 		// DBのレコードを更新
 		resultToDo, err := h.service.Update(requestToDo)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatusCode(err))
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(resultToDo)
-	}
+		writeResponse(w, r, http.StatusOK, resultToDo)
+	})
 }
 
 func (h *toDoHandler) Delete() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
 		id, err := getPathParamId(r)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -109,37 +165,422 @@ func (h *toDoHandler) Delete() http.HandlerFunc {
 		requestToDo := &service.ToDoObject{
 			Id: id,
 		}
+		setRequestInfo(r, requestToDo)
 
 		// DBのレコードを削除
 		resultToDo, err := h.service.Delete(requestToDo)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatusCode(err))
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(resultToDo)
-	}
+		writeResponse(w, r, http.StatusOK, resultToDo)
+	})
 }
 
 func (h *toDoHandler) List() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
 		done := r.FormValue("done")
+		include := r.FormValue("include")
 		listOption := &service.ListOption{
-			Done: done,
+			Done:    done,
+			Include: include,
+		}
+		if value := r.FormValue("as_of"); value != "" {
+			asOf, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "as_of must be an RFC 3339 timestamp", http.StatusBadRequest)
+				return
+			}
+			listOption.AsOf = &asOf
+		}
+		if r.FormValue("after") != "" || r.FormValue("limit") != "" {
+			h.listPage(w, r, listOption)
+			return
 		}
 		todoList, err := h.service.List(listOption)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+
+		resultList := []service.ToDoObject{}
+		resultList = append(resultList, todoList...)
+		writeResponse(w, r, http.StatusOK, resultList)
+	})
+}
+
+// IDがafterより大きいToDoを1ページ分だけ返し、続きがあれば次のページをLinkヘッダで示す
+func (h *toDoHandler) listPage(w http.ResponseWriter, r *http.Request, listOption *service.ListOption) {
+	var after int64
+	if value := r.FormValue("after"); value != "" {
+		var err error
+		after, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || after < 0 {
+			http.Error(w, "after must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := defaultListLimit
+	if value := r.FormValue("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxListLimit), http.StatusBadRequest)
+			return
+		}
+	}
+	page, err := h.service.ListPage(listOption, after, limit)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatusCode(err))
+		return
+	}
+
+	if page.HasNext {
+		next := *r.URL
+		query := next.Query()
+		query.Set("after", strconv.FormatInt(page.ToDo[len(page.ToDo)-1].Id, 10))
+		query.Set("limit", strconv.Itoa(limit))
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
+	writeResponse(w, r, http.StatusOK, page.ToDo)
+}
+
+func (h *toDoHandler) Trash() http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		todoList, err := h.service.Trash()
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
 			return
 		}
 
 		resultList := []service.ToDoObject{}
 		resultList = append(resultList, todoList...)
-		w.Header().Set("Content-Type", "application/json")
+		writeResponse(w, r, http.StatusOK, resultList)
+	})
+}
+
+func (h *toDoHandler) Restore() http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getPathParamId(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		requestToDo := &service.ToDoObject{
+			Id: id,
+		}
+		setRequestInfo(r, requestToDo)
+
+		// ゴミ箱から復元
+		resultToDo, err := h.service.Restore(requestToDo)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, resultToDo)
+	})
+}
+
+func (h *toDoHandler) Archive() http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getPathParamId(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		requestToDo := &service.ToDoObject{
+			Id: id,
+		}
+		setRequestInfo(r, requestToDo)
+
+		resultToDo, err := h.service.Archive(requestToDo)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, resultToDo)
+	})
+}
+
+func (h *toDoHandler) Unarchive() http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getPathParamId(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		requestToDo := &service.ToDoObject{
+			Id: id,
+		}
+		setRequestInfo(r, requestToDo)
+
+		resultToDo, err := h.service.Unarchive(requestToDo)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, resultToDo)
+	})
+}
+
+func (h *toDoHandler) ArchiveDone() http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		days, err := strconv.Atoi(r.FormValue("older_than_days"))
+		if err != nil || days < 0 {
+			http.Error(w, "older_than_days must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+
+		// 完了してからdays日以上経過したToDoをまとめてアーカイブ
+		archived, err := h.service.ArchiveDone(time.Duration(days) * 24 * time.Hour)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, map[string]int64{"archived": archived})
+	})
+}
+
+func (h *toDoHandler) History() http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getPathParamId(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		requestToDo := &service.ToDoObject{
+			Id: id,
+		}
+
+		historyList, err := h.service.History(requestToDo)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+
+		resultList := []service.HistoryObject{}
+		resultList = append(resultList, historyList...)
+		writeResponse(w, r, http.StatusOK, resultList)
+	})
+}
+
+func (h *toDoHandler) Revisions() http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getPathParamId(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		requestToDo := &service.ToDoObject{
+			Id: id,
+		}
+
+		revisionList, err := h.service.Revisions(requestToDo)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+
+		resultList := []service.RevisionObject{}
+		resultList = append(resultList, revisionList...)
+		writeResponse(w, r, http.StatusOK, resultList)
+	})
+}
+
+func (h *toDoHandler) Revert() http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getPathParamId(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		revision, err := strconv.ParseInt(r.FormValue("revision"), 10, 64)
+		if err != nil || revision < 1 {
+			http.Error(w, "revision must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		requestToDo := &service.ToDoObject{
+			Id: id,
+		}
+		setRequestInfo(r, requestToDo)
+
+		// 指定したリビジョンの内容に戻す
+		resultToDo, err := h.service.Revert(requestToDo, revision)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, resultToDo)
+	})
+}
+
+func (h *toDoHandler) Batch() http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		batch := &service.BatchObject{}
+		err := decodeRequest(r, batch)
+		if err != nil {
+			http.Error(w, err.Error(), requestBodyStatusCode(err, http.StatusBadRequest))
+			return
+		}
+		if len(batch.Operations) > maxBatchOperations {
+			http.Error(w, fmt.Sprintf("too many operations (max %d)", maxBatchOperations), http.StatusBadRequest)
+			return
+		}
+		batch.Actor = r.Header.Get(actorHeader)
+		batch.RequestId = r.Header.Get(requestIdHeader)
+
+		results, err := h.service.Batch(batch)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+
+		resultList := []service.BatchResultObject{}
+		resultList = append(resultList, results...)
+		writeResponse(w, r, http.StatusOK, map[string][]service.BatchResultObject{"results": resultList})
+	})
+}
+
+func (h *toDoHandler) Import() http.HandlerFunc {
+	return h.importToDo("")
+}
+
+// formatが空の場合はパラメータかContent-Typeから決める
+func (h *toDoHandler) importToDo(format string) http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		toDoImport := &service.ImportObject{
+			Format:    format,
+			Actor:     r.Header.Get(actorHeader),
+			RequestId: r.Header.Get(requestIdHeader),
+		}
+		if toDoImport.Format == "" {
+			toDoImport.Format = r.URL.Query().Get("format")
+		}
+		if toDoImport.Format == "" {
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			toDoImport.Format = importFormats[mediaType]
+		}
+		if value := r.URL.Query().Get("dry_run"); value != "" {
+			dryRun, err := strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, "dry_run must be true or false", http.StatusBadRequest)
+				return
+			}
+			toDoImport.DryRun = dryRun
+		}
+		data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("too large data (max %d bytes)", maxImportSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		toDoImport.Data = data
+
+		result, err := h.service.Import(toDoImport)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+
+		// エラーがあって作成しなかった場合は結果をエラーとして返す
+		statusCode := http.StatusOK
+		if !result.DryRun && len(result.Errors) > 0 {
+			statusCode = http.StatusBadRequest
+		}
+		writeResponse(w, r, statusCode, result)
+	})
+}
+
+// interval of the comments sent to keep the event stream alive
+const eventKeepAliveInterval = 30 * time.Second
+
+func (h *toDoHandler) Events() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+		var done *bool
+		if value := r.FormValue("done"); value != "" {
+			d, err := strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, "done must be true or false", http.StatusBadRequest)
+				return
+			}
+			done = &d
+		}
+		// 再接続の場合は前回受け取ったイベントの次から送る
+		lastEventId, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+
+		subscription := h.service.Subscribe(lastEventId)
+		defer subscription.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(resultList)
+		if subscription.Gap {
+			// 取りこぼしたイベントがあるため、クライアントに一覧を取り直させる
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		}
+		for _, event := range subscription.Missed {
+			writeEvent(w, event, done)
+		}
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(eventKeepAliveInterval)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case event, ok := <-subscription.Events:
+				if !ok {
+					return
+				}
+				writeEvent(w, event, done)
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case <-r.Context().Done():
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// イベントをSSEの形式で書き込む(doneが指定されている場合は一致するToDoのみ)
+func writeEvent(w http.ResponseWriter, event service.EventObject, done *bool) {
+	if done != nil && event.ToDo.Done != *done {
+		return
+	}
+	data, err := json.Marshal(event.ToDo)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data)
+}
+
+// サービスのエラーに対応するステータスコードを返す
+func errorStatusCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidStatus), errors.Is(err, service.ErrInvalidBatch), errors.Is(err, service.ErrInvalidWebhook), errors.Is(err, service.ErrInvalidImport):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrInvalidTransition):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, service.ErrTemporalQueryUnsupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, sql.ErrNoRows):
+		// ToDoがない、または操作できる状態にない
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
 	}
 }
 
@@ -153,14 +594,16 @@ func getPathParamId(r *http.Request) (int64, error) {
 	return int64(id), nil
 }
 
-// リクエストボディをJSONにパースする
-func parseRequestJSON(r *http.Request) (*service.ToDoObject, error) {
-	reqBody, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		return nil, err
-	}
+// 履歴に記録する操作者とリクエストIDをヘッダから取得する
+func setRequestInfo(r *http.Request, toDo *service.ToDoObject) {
+	toDo.Actor = r.Header.Get(actorHeader)
+	toDo.RequestId = r.Header.Get(requestIdHeader)
+}
+
+// リクエストボディをContent-Typeの形式でパースする
+func parseRequestToDo(r *http.Request) (*service.ToDoObject, error) {
 	reqObject := &service.ToDoObject{}
-	err = json.Unmarshal(reqBody, reqObject)
+	err := decodeRequest(r, reqObject)
 	if err != nil {
 		return nil, err
 	}
@@ -3,11 +3,16 @@ package handler
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
@@ -67,6 +72,22 @@ func TestCreate(t *testing.T) {
 			expectedStatusCode: http.StatusInternalServerError,
 			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo", bytes.NewBuffer(j)),
 		},
+		{
+			name:               "05_対応していないContent-Typeのケース",
+			createError:        nil,
+			createResult:       nil,
+			createTimes:        0,
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+			request:            newRequestWithHeader(http.MethodPost, "http://hogehoge/todo", "title=test-ToDo", "Content-Type", "application/x-www-form-urlencoded"),
+		},
+		{
+			name:               "06_対応していないAcceptのケース",
+			createError:        nil,
+			createResult:       nil,
+			createTimes:        0,
+			expectedStatusCode: http.StatusNotAcceptable,
+			request:            newRequestWithHeader(http.MethodPost, "http://hogehoge/todo", string(j), "Accept", "text/html"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -220,6 +241,22 @@ func TestUpdate(t *testing.T) {
 			expectedStatusCode: http.StatusInternalServerError,
 			request:            httptest.NewRequest(http.MethodPut, "http://hogehoge/todo/100", bytes.NewBuffer(j)),
 		},
+		{
+			name:               "07_Updateが不正なステータスで失敗するケース",
+			updateError:        fmt.Errorf("%w: unknown", service.ErrInvalidStatus),
+			updateResult:       nil,
+			updateTimes:        1,
+			expectedStatusCode: http.StatusBadRequest,
+			request:            httptest.NewRequest(http.MethodPut, "http://hogehoge/todo/100", bytes.NewBuffer(j)),
+		},
+		{
+			name:               "08_Updateが許可されていない遷移で失敗するケース",
+			updateError:        fmt.Errorf("%w: done -> review", service.ErrInvalidTransition),
+			updateResult:       nil,
+			updateTimes:        1,
+			expectedStatusCode: http.StatusConflict,
+			request:            httptest.NewRequest(http.MethodPut, "http://hogehoge/todo/100", bytes.NewBuffer(j)),
+		},
 	}
 
 	for _, tt := range tests {
@@ -342,6 +379,30 @@ func TestList(t *testing.T) {
 			expectedStatusCode: http.StatusInternalServerError,
 			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/todo", nil),
 		},
+		{
+			name:               "04_as_ofを指定するケース",
+			listError:          nil,
+			listResult:         []service.ToDoObject{{Id: 100}},
+			listTimes:          1,
+			expectedStatusCode: http.StatusOK,
+			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/todo?as_of=2021-01-01T00:00:00Z", nil),
+		},
+		{
+			name:               "05_as_ofが不正なケース",
+			listError:          nil,
+			listResult:         nil,
+			listTimes:          0,
+			expectedStatusCode: http.StatusBadRequest,
+			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/todo?as_of=yesterday", nil),
+		},
+		{
+			name:               "06_as_ofに対応していないケース",
+			listError:          service.ErrTemporalQueryUnsupported,
+			listResult:         nil,
+			listTimes:          1,
+			expectedStatusCode: http.StatusNotImplemented,
+			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/todo?as_of=2021-01-01T00:00:00Z", nil),
+		},
 	}
 
 	for _, tt := range tests {
@@ -369,3 +430,856 @@ func TestList(t *testing.T) {
 		})
 	}
 }
+
+func TestListPage(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	tests := []struct {
+		name               string
+		target             string
+		pageResult         *service.ToDoPageObject
+		pageError          error
+		expectedAfter      int64
+		expectedLimit      int
+		expectedStatusCode int
+		expectedLink       string
+	}{
+		{
+			name:               "01_続きのページがあるケース",
+			target:             "/todo?done=false&limit=2",
+			pageResult:         &service.ToDoPageObject{ToDo: []service.ToDoObject{{Id: 100}, {Id: 200}}, HasNext: true},
+			expectedAfter:      0,
+			expectedLimit:      2,
+			expectedStatusCode: http.StatusOK,
+			expectedLink:       `</todo?after=200&done=false&limit=2>; rel="next"`,
+		},
+		{
+			name:               "02_最後のページのケース",
+			target:             "/todo?after=200",
+			pageResult:         &service.ToDoPageObject{ToDo: []service.ToDoObject{{Id: 300}}},
+			expectedAfter:      200,
+			expectedLimit:      defaultListLimit,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "03_limitが大きすぎるケース",
+			target:             "/todo?limit=1001",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "04_afterが不正なケース",
+			target:             "/todo?after=abc",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "05_ListPageが失敗するケース",
+			target:             "/todo?limit=10",
+			pageError:          errors.New("ListPage ERROR"),
+			expectedLimit:      10,
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			if tt.expectedLimit > 0 {
+				mockToDoService.EXPECT().ListPage(gomock.Any(), tt.expectedAfter, tt.expectedLimit).Return(tt.pageResult, tt.pageError)
+			}
+			toDoHandler := NewToDoHandler(mockToDoService)
+
+			r := mux.NewRouter()
+			r.HandleFunc("/todo", toDoHandler.List()).Methods(http.MethodGet)
+			w := httptest.NewRecorder()
+
+			// Act
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://hogehoge"+tt.target, nil))
+
+			// Assert
+			if w.Result().StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Result().StatusCode)
+			}
+			if link := w.Result().Header.Get("Link"); link != tt.expectedLink {
+				t.Errorf("expected: %s, actual: %s", tt.expectedLink, link)
+			}
+		})
+	}
+}
+
+func TestTrash(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	tests := []struct {
+		name               string
+		trashError         error
+		trashResult        []service.ToDoObject
+		trashTimes         int
+		request            *http.Request
+		expectedStatusCode int
+	}{
+		{
+			name:               "01_正常にレスポンスが返せるケース",
+			trashError:         nil,
+			trashResult:        []service.ToDoObject{{Id: 100}},
+			trashTimes:         1,
+			expectedStatusCode: http.StatusOK,
+			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/trash", nil),
+		},
+		{
+			name:               "02_Trashが失敗するケース",
+			trashError:         errors.New("Trash ERROR"),
+			trashResult:        nil,
+			trashTimes:         1,
+			expectedStatusCode: http.StatusInternalServerError,
+			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/trash", nil),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			mockToDoService.EXPECT().Trash().Return(tt.trashResult, tt.trashError).Times(tt.trashTimes)
+			toDoHandler := NewToDoHandler(mockToDoService)
+
+			r := mux.NewRouter()
+			r.HandleFunc("/trash", toDoHandler.Trash()).Methods(http.MethodGet)
+			w := httptest.NewRecorder()
+
+			// Act
+			r.ServeHTTP(w, tt.request)
+
+			// Assert
+			if w.Result().StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Result().StatusCode)
+			}
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	tests := []struct {
+		name               string
+		restoreError       error
+		restoreResult      *service.ToDoObject
+		restoreTimes       int
+		request            *http.Request
+		expectedStatusCode int
+	}{
+		{
+			name:               "01_正常にレスポンスが返せるケース",
+			restoreError:       nil,
+			restoreResult:      &service.ToDoObject{},
+			restoreTimes:       1,
+			expectedStatusCode: http.StatusOK,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/100/restore", nil),
+		},
+		{
+			name:               "02_getPathParamIdが失敗(Atoiでエラー)するケース",
+			restoreError:       nil,
+			restoreResult:      nil,
+			restoreTimes:       0,
+			expectedStatusCode: http.StatusInternalServerError,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/invalidId/restore", nil),
+		},
+		{
+			name:               "03_Restoreが失敗するケース",
+			restoreError:       errors.New("Restore ERROR"),
+			restoreResult:      nil,
+			restoreTimes:       1,
+			expectedStatusCode: http.StatusInternalServerError,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/100/restore", nil),
+		},
+		{
+			name:               "04_ゴミ箱にないToDoを復元するケース",
+			restoreError:       fmt.Errorf("RESTORE FAILED: %w", sql.ErrNoRows),
+			restoreResult:      nil,
+			restoreTimes:       1,
+			expectedStatusCode: http.StatusNotFound,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/100/restore", nil),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			mockToDoService.EXPECT().Restore(gomock.Any()).Return(tt.restoreResult, tt.restoreError).Times(tt.restoreTimes)
+			toDoHandler := NewToDoHandler(mockToDoService)
+
+			r := mux.NewRouter()
+			r.HandleFunc("/todo/{id}/restore", toDoHandler.Restore()).Methods(http.MethodPost)
+			w := httptest.NewRecorder()
+
+			// Act
+			r.ServeHTTP(w, tt.request)
+
+			// Assert
+			if w.Result().StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Result().StatusCode)
+			}
+		})
+	}
+}
+
+func TestArchive(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	tests := []struct {
+		name               string
+		archiveError       error
+		archiveResult      *service.ToDoObject
+		archiveTimes       int
+		request            *http.Request
+		expectedStatusCode int
+	}{
+		{
+			name:               "01_正常にレスポンスが返せるケース",
+			archiveError:       nil,
+			archiveResult:      &service.ToDoObject{},
+			archiveTimes:       1,
+			expectedStatusCode: http.StatusOK,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/100/archive", nil),
+		},
+		{
+			name:               "02_getPathParamIdが失敗(Atoiでエラー)するケース",
+			archiveError:       nil,
+			archiveResult:      nil,
+			archiveTimes:       0,
+			expectedStatusCode: http.StatusInternalServerError,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/invalidId/archive", nil),
+		},
+		{
+			name:               "03_Archiveが失敗するケース",
+			archiveError:       errors.New("Archive ERROR"),
+			archiveResult:      nil,
+			archiveTimes:       1,
+			expectedStatusCode: http.StatusInternalServerError,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/100/archive", nil),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			mockToDoService.EXPECT().Archive(gomock.Any()).Return(tt.archiveResult, tt.archiveError).Times(tt.archiveTimes)
+			toDoHandler := NewToDoHandler(mockToDoService)
+
+			r := mux.NewRouter()
+			r.HandleFunc("/todo/{id}/archive", toDoHandler.Archive()).Methods(http.MethodPost)
+			w := httptest.NewRecorder()
+
+			// Act
+			r.ServeHTTP(w, tt.request)
+
+			// Assert
+			if w.Result().StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Result().StatusCode)
+			}
+		})
+	}
+}
+
+func TestArchiveDone(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	tests := []struct {
+		name               string
+		archiveError       error
+		archiveTimes       int
+		request            *http.Request
+		expectedStatusCode int
+	}{
+		{
+			name:               "01_正常にレスポンスが返せるケース",
+			archiveError:       nil,
+			archiveTimes:       1,
+			expectedStatusCode: http.StatusOK,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/archive?older_than_days=30", nil),
+		},
+		{
+			name:               "02_older_than_daysが指定されていないケース",
+			archiveError:       nil,
+			archiveTimes:       0,
+			expectedStatusCode: http.StatusBadRequest,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/archive", nil),
+		},
+		{
+			name:               "03_older_than_daysが負の値のケース",
+			archiveError:       nil,
+			archiveTimes:       0,
+			expectedStatusCode: http.StatusBadRequest,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/archive?older_than_days=-1", nil),
+		},
+		{
+			name:               "04_ArchiveDoneが失敗するケース",
+			archiveError:       errors.New("ArchiveDone ERROR"),
+			archiveTimes:       1,
+			expectedStatusCode: http.StatusInternalServerError,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/archive?older_than_days=30", nil),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			mockToDoService.EXPECT().ArchiveDone(30*24*time.Hour).Return(int64(3), tt.archiveError).Times(tt.archiveTimes)
+			toDoHandler := NewToDoHandler(mockToDoService)
+
+			r := mux.NewRouter()
+			r.HandleFunc("/todo/archive", toDoHandler.ArchiveDone()).Methods(http.MethodPost)
+			w := httptest.NewRecorder()
+
+			// Act
+			r.ServeHTTP(w, tt.request)
+
+			// Assert
+			if w.Result().StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Result().StatusCode)
+			}
+		})
+	}
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	tests := []struct {
+		name               string
+		historyError       error
+		historyResult      []service.HistoryObject
+		historyTimes       int
+		request            *http.Request
+		expectedStatusCode int
+	}{
+		{
+			name:               "01_正常にレスポンスが返せるケース",
+			historyError:       nil,
+			historyResult:      []service.HistoryObject{{Id: 1, ToDoId: 100, Operation: "create"}},
+			historyTimes:       1,
+			expectedStatusCode: http.StatusOK,
+			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/todo/100/history", nil),
+		},
+		{
+			name:               "02_getPathParamIdが失敗(Atoiでエラー)するケース",
+			historyError:       nil,
+			historyResult:      nil,
+			historyTimes:       0,
+			expectedStatusCode: http.StatusInternalServerError,
+			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/todo/invalidId/history", nil),
+		},
+		{
+			name:               "03_Historyが失敗するケース",
+			historyError:       errors.New("History ERROR"),
+			historyResult:      nil,
+			historyTimes:       1,
+			expectedStatusCode: http.StatusInternalServerError,
+			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/todo/100/history", nil),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			mockToDoService.EXPECT().History(gomock.Any()).Return(tt.historyResult, tt.historyError).Times(tt.historyTimes)
+			toDoHandler := NewToDoHandler(mockToDoService)
+
+			r := mux.NewRouter()
+			r.HandleFunc("/todo/{id}/history", toDoHandler.History()).Methods(http.MethodGet)
+			w := httptest.NewRecorder()
+
+			// Act
+			r.ServeHTTP(w, tt.request)
+
+			// Assert
+			if w.Result().StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Result().StatusCode)
+			}
+		})
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	tests := []struct {
+		name               string
+		revisionsError     error
+		revisionsResult    []service.RevisionObject
+		revisionsTimes     int
+		request            *http.Request
+		expectedStatusCode int
+	}{
+		{
+			name:               "01_正常にレスポンスが返せるケース",
+			revisionsError:     nil,
+			revisionsResult:    []service.RevisionObject{{ToDoId: 100, Revision: 1}},
+			revisionsTimes:     1,
+			expectedStatusCode: http.StatusOK,
+			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/todo/100/revisions", nil),
+		},
+		{
+			name:               "02_getPathParamIdが失敗(Atoiでエラー)するケース",
+			revisionsError:     nil,
+			revisionsResult:    nil,
+			revisionsTimes:     0,
+			expectedStatusCode: http.StatusInternalServerError,
+			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/todo/invalidId/revisions", nil),
+		},
+		{
+			name:               "03_Revisionsが失敗するケース",
+			revisionsError:     errors.New("Revisions ERROR"),
+			revisionsResult:    nil,
+			revisionsTimes:     1,
+			expectedStatusCode: http.StatusInternalServerError,
+			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/todo/100/revisions", nil),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			mockToDoService.EXPECT().Revisions(gomock.Any()).Return(tt.revisionsResult, tt.revisionsError).Times(tt.revisionsTimes)
+			toDoHandler := NewToDoHandler(mockToDoService)
+
+			r := mux.NewRouter()
+			r.HandleFunc("/todo/{id}/revisions", toDoHandler.Revisions()).Methods(http.MethodGet)
+			w := httptest.NewRecorder()
+
+			// Act
+			r.ServeHTTP(w, tt.request)
+
+			// Assert
+			if w.Result().StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Result().StatusCode)
+			}
+		})
+	}
+}
+
+func TestRevert(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	tests := []struct {
+		name               string
+		revertError        error
+		revertTimes        int
+		request            *http.Request
+		expectedStatusCode int
+	}{
+		{
+			name:               "01_正常にレスポンスが返せるケース",
+			revertError:        nil,
+			revertTimes:        1,
+			expectedStatusCode: http.StatusOK,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/100/revert?revision=2", nil),
+		},
+		{
+			name:               "02_getPathParamIdが失敗(Atoiでエラー)するケース",
+			revertError:        nil,
+			revertTimes:        0,
+			expectedStatusCode: http.StatusInternalServerError,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/invalidId/revert?revision=2", nil),
+		},
+		{
+			name:               "03_revisionが指定されていないケース",
+			revertError:        nil,
+			revertTimes:        0,
+			expectedStatusCode: http.StatusBadRequest,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/100/revert", nil),
+		},
+		{
+			name:               "04_revisionが0のケース",
+			revertError:        nil,
+			revertTimes:        0,
+			expectedStatusCode: http.StatusBadRequest,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/100/revert?revision=0", nil),
+		},
+		{
+			name:               "05_リビジョンのステータスが不正なケース",
+			revertError:        service.ErrInvalidStatus,
+			revertTimes:        1,
+			expectedStatusCode: http.StatusBadRequest,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/100/revert?revision=2", nil),
+		},
+		{
+			name:               "06_Revertが失敗するケース",
+			revertError:        errors.New("Revert ERROR"),
+			revertTimes:        1,
+			expectedStatusCode: http.StatusInternalServerError,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/100/revert?revision=2", nil),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			mockToDoService.EXPECT().Revert(gomock.Any(), int64(2)).Return(&service.ToDoObject{Id: 100}, tt.revertError).Times(tt.revertTimes)
+			toDoHandler := NewToDoHandler(mockToDoService)
+
+			r := mux.NewRouter()
+			r.HandleFunc("/todo/{id}/revert", toDoHandler.Revert()).Methods(http.MethodPost)
+			w := httptest.NewRecorder()
+
+			// Act
+			r.ServeHTTP(w, tt.request)
+
+			// Assert
+			if w.Result().StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Result().StatusCode)
+			}
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	tooMany := `{"operations": [` + strings.Repeat(`{"op": "delete", "id": 1},`, maxBatchOperations) + `{"op": "delete", "id": 1}]}`
+	tests := []struct {
+		name               string
+		batchError         error
+		batchTimes         int
+		request            *http.Request
+		expectedStatusCode int
+	}{
+		{
+			name:               "01_正常にレスポンスが返せるケース",
+			batchError:         nil,
+			batchTimes:         1,
+			expectedStatusCode: http.StatusOK,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/bulk", strings.NewReader(`{"mode": "best_effort", "operations": [{"op": "create", "title": "test-ToDo"}, {"op": "delete", "id": 1}]}`)),
+		},
+		{
+			name:               "02_リクエストボディがJSONでないケース",
+			batchError:         nil,
+			batchTimes:         0,
+			expectedStatusCode: http.StatusBadRequest,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/bulk", strings.NewReader(`invalid`)),
+		},
+		{
+			name:               "03_操作が多すぎるケース",
+			batchError:         nil,
+			batchTimes:         0,
+			expectedStatusCode: http.StatusBadRequest,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/bulk", strings.NewReader(tooMany)),
+		},
+		{
+			name:               "04_modeが不正なケース",
+			batchError:         service.ErrInvalidBatch,
+			batchTimes:         1,
+			expectedStatusCode: http.StatusBadRequest,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/bulk", strings.NewReader(`{"mode": "unknown", "operations": []}`)),
+		},
+		{
+			name:               "05_Batchが失敗するケース",
+			batchError:         errors.New("Batch ERROR"),
+			batchTimes:         1,
+			expectedStatusCode: http.StatusInternalServerError,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/todo/bulk", strings.NewReader(`{"operations": [{"op": "delete", "id": 1}]}`)),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			mockToDoService.EXPECT().Batch(gomock.Any()).Return([]service.BatchResultObject{{Index: 0, Op: "delete", Id: 1, Ok: true}}, tt.batchError).Times(tt.batchTimes)
+			toDoHandler := NewToDoHandler(mockToDoService)
+
+			r := mux.NewRouter()
+			r.HandleFunc("/todo/bulk", toDoHandler.Batch()).Methods(http.MethodPost)
+			w := httptest.NewRecorder()
+
+			// Act
+			r.ServeHTTP(w, tt.request)
+
+			// Assert
+			if w.Result().StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Result().StatusCode)
+			}
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	newRequest := func(target string, contentType string, body string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		return request
+	}
+	tests := []struct {
+		name               string
+		importResult       *service.ImportResultObject
+		importError        error
+		importTimes        int
+		wantFormat         string
+		wantDryRun         bool
+		request            *http.Request
+		expectedStatusCode int
+	}{
+		{
+			name:               "01_Content-Typeから形式を決めるケース",
+			importResult:       &service.ImportResultObject{Total: 1, Valid: 1, Imported: 1, Ids: []int64{1}},
+			importTimes:        1,
+			wantFormat:         "csv",
+			expectedStatusCode: http.StatusOK,
+			request:            newRequest("http://hogehoge/todo/import", "text/csv; charset=utf-8", "title\nBuy a new pencil\n"),
+		},
+		{
+			name:               "02_formatを指定したdry-runのケース",
+			importResult:       &service.ImportResultObject{DryRun: true, Total: 1, Errors: []service.ImportErrorObject{{Line: 1, Error: "title is required"}}},
+			importTimes:        1,
+			wantFormat:         "todotxt",
+			wantDryRun:         true,
+			expectedStatusCode: http.StatusOK,
+			request:            newRequest("http://hogehoge/todo/import?format=todotxt&dry_run=true", "application/octet-stream", "x 2021-06-15\n"),
+		},
+		{
+			name:               "03_エラーがあり作成しなかったケース",
+			importResult:       &service.ImportResultObject{Total: 1, Errors: []service.ImportErrorObject{{Line: 1, Error: "title is required"}}},
+			importTimes:        1,
+			wantFormat:         "json",
+			expectedStatusCode: http.StatusBadRequest,
+			request:            newRequest("http://hogehoge/todo/import", "application/json", `[{"title": ""}]`),
+		},
+		{
+			name:               "04_dry_runが不正なケース",
+			importTimes:        0,
+			expectedStatusCode: http.StatusBadRequest,
+			request:            newRequest("http://hogehoge/todo/import?dry_run=maybe", "text/csv", "title\n"),
+		},
+		{
+			name:               "05_形式が不明なケース",
+			importError:        service.ErrInvalidImport,
+			importTimes:        1,
+			wantFormat:         "",
+			expectedStatusCode: http.StatusBadRequest,
+			request:            newRequest("http://hogehoge/todo/import", "application/xml", "<todo/>"),
+		},
+		{
+			name:               "06_データが大きすぎるケース",
+			importTimes:        0,
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+			request:            newRequest("http://hogehoge/todo/import", "text/plain", strings.Repeat("a", maxImportSize+1)),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			mockToDoService.EXPECT().Import(gomock.Any()).DoAndReturn(func(toDoImport *service.ImportObject) (*service.ImportResultObject, error) {
+				if toDoImport.Format != tt.wantFormat || toDoImport.DryRun != tt.wantDryRun {
+					t.Errorf("unexpected import: %+v", toDoImport)
+				}
+				return tt.importResult, tt.importError
+			}).Times(tt.importTimes)
+			toDoHandler := NewToDoHandler(mockToDoService)
+
+			r := mux.NewRouter()
+			r.HandleFunc("/todo/import", toDoHandler.Import()).Methods(http.MethodPost)
+			w := httptest.NewRecorder()
+
+			// Act
+			r.ServeHTTP(w, tt.request)
+
+			// Assert
+			if w.Result().StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Result().StatusCode)
+			}
+		})
+	}
+}
+
+func TestEvents(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	tests := []struct {
+		name               string
+		request            *http.Request
+		lastEventId        string
+		missed             []service.EventObject
+		gap                bool
+		subscribeTimes     int
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "01_イベントを送るケース",
+			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/todo/events", nil),
+			subscribeTimes:     1,
+			expectedStatusCode: http.StatusOK,
+			expectedBody: "id: 3\nevent: updated\ndata: {\"id\":100,\"title\":\"\",\"status\":\"done\",\"done\":true,\"archived\":false,\"created_at\":\"0001-01-01T00:00:00Z\",\"updated_at\":\"0001-01-01T00:00:00Z\"}\n\n" +
+				"id: 4\nevent: deleted\ndata: {\"id\":200,\"title\":\"\",\"status\":\"\",\"done\":false,\"archived\":false,\"created_at\":\"0001-01-01T00:00:00Z\",\"updated_at\":\"0001-01-01T00:00:00Z\"}\n\n",
+		},
+		{
+			name:               "02_Last-Event-IDから再開するケース",
+			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/todo/events?done=true", nil),
+			lastEventId:        "1",
+			missed:             []service.EventObject{{Id: 2, Type: "created", ToDo: service.ToDoObject{Id: 300, Done: true}}},
+			subscribeTimes:     1,
+			expectedStatusCode: http.StatusOK,
+			expectedBody: "id: 2\nevent: created\ndata: {\"id\":300,\"title\":\"\",\"status\":\"\",\"done\":true,\"archived\":false,\"created_at\":\"0001-01-01T00:00:00Z\",\"updated_at\":\"0001-01-01T00:00:00Z\"}\n\n" +
+				"id: 3\nevent: updated\ndata: {\"id\":100,\"title\":\"\",\"status\":\"done\",\"done\":true,\"archived\":false,\"created_at\":\"0001-01-01T00:00:00Z\",\"updated_at\":\"0001-01-01T00:00:00Z\"}\n\n",
+		},
+		{
+			name:               "03_取りこぼしたイベントがあるケース",
+			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/todo/events?done=false", nil),
+			lastEventId:        "1",
+			gap:                true,
+			subscribeTimes:     1,
+			expectedStatusCode: http.StatusOK,
+			expectedBody: "event: reset\ndata: {}\n\n" +
+				"id: 4\nevent: deleted\ndata: {\"id\":200,\"title\":\"\",\"status\":\"\",\"done\":false,\"archived\":false,\"created_at\":\"0001-01-01T00:00:00Z\",\"updated_at\":\"0001-01-01T00:00:00Z\"}\n\n",
+		},
+		{
+			name:               "04_doneが不正なケース",
+			request:            httptest.NewRequest(http.MethodGet, "http://hogehoge/todo/events?done=hoge", nil),
+			subscribeTimes:     0,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			// 2件のイベントを送った後に切断される
+			events := make(chan service.EventObject, 2)
+			events <- service.EventObject{Id: 3, Type: "updated", ToDo: service.ToDoObject{Id: 100, Status: "done", Done: true}}
+			events <- service.EventObject{Id: 4, Type: "deleted", ToDo: service.ToDoObject{Id: 200}}
+			close(events)
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			lastEventId, _ := strconv.ParseInt(tt.lastEventId, 10, 64)
+			mockToDoService.EXPECT().Subscribe(lastEventId).Return(&service.Subscription{Events: events, Missed: tt.missed, Gap: tt.gap}).Times(tt.subscribeTimes)
+			toDoHandler := NewToDoHandler(mockToDoService)
+
+			r := mux.NewRouter()
+			r.HandleFunc("/todo/events", toDoHandler.Events()).Methods(http.MethodGet)
+			if tt.lastEventId != "" {
+				tt.request.Header.Set("Last-Event-ID", tt.lastEventId)
+			}
+			w := httptest.NewRecorder()
+
+			// Act
+			r.ServeHTTP(w, tt.request)
+
+			// Assert
+			if w.Result().StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Result().StatusCode)
+			}
+			if tt.expectedStatusCode == http.StatusOK && w.Body.String() != tt.expectedBody {
+				t.Errorf("expected: %q, actual: %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeleteWithRequestInfo(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	mockToDoService := mock_service.NewMockToDoService(ctrl)
+	mockToDoService.EXPECT().Delete(gomock.Any()).DoAndReturn(func(toDo *service.ToDoObject) (*service.ToDoObject, error) {
+		if toDo.Actor != "tester" || toDo.RequestId == "" {
+			t.Errorf("request info is not set: %+v", toDo)
+		}
+		return toDo, nil
+	}).Times(1)
+	toDoHandler := NewToDoHandler(mockToDoService)
+
+	r := mux.NewRouter()
+	r.Use(RequestId)
+	r.HandleFunc("/todo/{id}", toDoHandler.Delete()).Methods(http.MethodDelete)
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodDelete, "http://hogehoge/todo/100", nil)
+	request.Header.Set("X-Actor", "tester")
+
+	// Act
+	r.ServeHTTP(w, request)
+
+	// Assert
+	if w.Result().StatusCode != http.StatusOK {
+		t.Errorf("expected: %d, actual: %d", http.StatusOK, w.Result().StatusCode)
+	}
+	if w.Result().Header.Get("X-Request-Id") == "" {
+		t.Error("X-Request-Id IS NOT SET")
+	}
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+)
+
+type WebhookHandler interface {
+	Create() http.HandlerFunc
+	Read() http.HandlerFunc
+	Update() http.HandlerFunc
+	Delete() http.HandlerFunc
+	List() http.HandlerFunc
+	DeadLetters() http.HandlerFunc
+}
+
+type webhookHandler struct {
+	service service.WebhookService
+}
+
+func NewWebhookHandler(service service.WebhookService) WebhookHandler {
+	return &webhookHandler{
+		service: service,
+	}
+}
+
+func (h *webhookHandler) Create() http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		requestWebhook := &service.WebhookObject{}
+		err := decodeRequest(r, requestWebhook)
+		if err != nil {
+			http.Error(w, err.Error(), requestBodyStatusCode(err, http.StatusBadRequest))
+			return
+		}
+
+		resultWebhook, err := h.service.Create(requestWebhook)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+		writeResponse(w, r, http.StatusOK, resultWebhook)
+	})
+}
+
+func (h *webhookHandler) Read() http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getPathParamId(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		resultWebhook, err := h.service.Read(&service.WebhookObject{Id: id})
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+		writeResponse(w, r, http.StatusOK, resultWebhook)
+	})
+}
+
+func (h *webhookHandler) Update() http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getPathParamId(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		requestWebhook := &service.WebhookObject{}
+		err = decodeRequest(r, requestWebhook)
+		if err != nil {
+			http.Error(w, err.Error(), requestBodyStatusCode(err, http.StatusBadRequest))
+			return
+		}
+		requestWebhook.Id = id
+
+		resultWebhook, err := h.service.Update(requestWebhook)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+		writeResponse(w, r, http.StatusOK, resultWebhook)
+	})
+}
+
+func (h *webhookHandler) Delete() http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getPathParamId(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		resultWebhook, err := h.service.Delete(&service.WebhookObject{Id: id})
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+		writeResponse(w, r, http.StatusOK, resultWebhook)
+	})
+}
+
+func (h *webhookHandler) List() http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		webhooks, err := h.service.List()
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+		writeResponse(w, r, http.StatusOK, webhooks)
+	})
+}
+
+func (h *webhookHandler) DeadLetters() http.HandlerFunc {
+	return negotiate(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getPathParamId(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		deadLetters, err := h.service.DeadLetters(&service.WebhookObject{Id: id})
+		if err != nil {
+			http.Error(w, err.Error(), errorStatusCode(err))
+			return
+		}
+		writeResponse(w, r, http.StatusOK, deadLetters)
+	})
+}
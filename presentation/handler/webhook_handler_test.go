@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service/mock_service"
+)
+
+func TestWebhookCreate(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	ctrl := gomock.NewController(t)
+	tests := []struct {
+		name               string
+		createError        error
+		createTimes        int
+		request            *http.Request
+		expectedStatusCode int
+	}{
+		{
+			name:               "01_正常にレスポンスが返せるケース",
+			createError:        nil,
+			createTimes:        1,
+			expectedStatusCode: http.StatusOK,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/webhooks", strings.NewReader(`{"url": "https://example.com/hook", "events": ["completed"]}`)),
+		},
+		{
+			name:               "02_リクエストボディがJSONでないケース",
+			createError:        nil,
+			createTimes:        0,
+			expectedStatusCode: http.StatusBadRequest,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/webhooks", strings.NewReader(`invalid`)),
+		},
+		{
+			name:               "03_Webhookが不正なケース",
+			createError:        service.ErrInvalidWebhook,
+			createTimes:        1,
+			expectedStatusCode: http.StatusBadRequest,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/webhooks", strings.NewReader(`{"url": "example.com"}`)),
+		},
+		{
+			name:               "04_Createが失敗するケース",
+			createError:        errors.New("Create ERROR"),
+			createTimes:        1,
+			expectedStatusCode: http.StatusInternalServerError,
+			request:            httptest.NewRequest(http.MethodPost, "http://hogehoge/webhooks", strings.NewReader(`{"url": "https://example.com/hook"}`)),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			mockWebhookService := mock_service.NewMockWebhookService(ctrl)
+			mockWebhookService.EXPECT().Create(gomock.Any()).Return(&service.WebhookObject{Id: 1}, tt.createError).Times(tt.createTimes)
+			webhookHandler := NewWebhookHandler(mockWebhookService)
+
+			r := mux.NewRouter()
+			r.HandleFunc("/webhooks", webhookHandler.Create()).Methods(http.MethodPost)
+			w := httptest.NewRecorder()
+
+			// Act
+			r.ServeHTTP(w, tt.request)
+
+			// Assert
+			if w.Result().StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected: %d, actual: %d", tt.expectedStatusCode, w.Result().StatusCode)
+			}
+		})
+	}
+}
+
+func TestWebhookDeadLetters(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	mockWebhookService := mock_service.NewMockWebhookService(ctrl)
+	mockWebhookService.EXPECT().DeadLetters(&service.WebhookObject{Id: 1}).Return([]service.DeadLetterObject{{Id: 1, WebhookId: 1, Event: "completed", Payload: []byte(`{"id":2}`)}}, nil).Times(1)
+	webhookHandler := NewWebhookHandler(mockWebhookService)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/webhooks/{id}/dead-letters", webhookHandler.DeadLetters()).Methods(http.MethodGet)
+	w := httptest.NewRecorder()
+
+	// Act
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://hogehoge/webhooks/1/dead-letters", nil))
+
+	// Assert
+	if w.Result().StatusCode != http.StatusOK {
+		t.Errorf("expected: %d, actual: %d", http.StatusOK, w.Result().StatusCode)
+	}
+	if !strings.Contains(w.Body.String(), `"payload":{"id":2}`) {
+		t.Errorf("payload is not embedded: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+)
+
+const (
+	// time allowed to write a message to the client
+	webSocketWriteWait = 10 * time.Second
+	// time allowed to read the next pong from the client
+	webSocketPongWait = 60 * time.Second
+	// interval of pings, must be shorter than webSocketPongWait
+	webSocketPingInterval = webSocketPongWait * 9 / 10
+	// number of messages waiting to be written to a client
+	webSocketSendBufferSize = 64
+)
+
+var upgrader = websocket.Upgrader{}
+
+// Message sent from the client
+type clientMessage struct {
+	// "subscribe", "unsubscribe", "create", "update" or "delete"
+	Type string `json:"type"`
+	// returned as is in the result
+	Ref string `json:"ref,omitempty"`
+	// IDs of ToDo to (un)subscribe
+	Ids []int64 `json:"ids,omitempty"`
+	// lists to (un)subscribe: "all", "done" or "undone"
+	Lists []string `json:"lists,omitempty"`
+	// ToDo to create, update or delete
+	ToDo *service.ToDoObject `json:"todo,omitempty"`
+}
+
+// Change of a ToDo sent to the client
+type eventMessage struct {
+	Type  string             `json:"type"`
+	Id    int64              `json:"id"`
+	Event string             `json:"event"`
+	ToDo  service.ToDoObject `json:"todo"`
+}
+
+// Result of a clientMessage sent to the client
+type resultMessage struct {
+	Type  string              `json:"type"`
+	Ref   string              `json:"ref,omitempty"`
+	Ok    bool                `json:"ok"`
+	ToDo  *service.ToDoObject `json:"todo,omitempty"`
+	Error string              `json:"error,omitempty"`
+}
+
+// ToDo and lists a client subscribes
+type webSocketFilter struct {
+	mu    sync.Mutex
+	ids   map[int64]bool
+	lists map[string]bool
+}
+
+func (f *webSocketFilter) update(message *clientMessage, subscribe bool) error {
+	for _, list := range message.Lists {
+		if list != "all" && list != "done" && list != "undone" {
+			return fmt.Errorf("unknown list: %s", list)
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range message.Ids {
+		f.ids[id] = subscribe
+	}
+	for _, list := range message.Lists {
+		f.lists[list] = subscribe
+	}
+	return nil
+}
+
+func (f *webSocketFilter) matches(event *service.EventObject) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ids[event.ToDo.Id] ||
+		f.lists["all"] ||
+		(f.lists["done"] && event.ToDo.Done) ||
+		(f.lists["undone"] && !event.ToDo.Done)
+}
+
+func (h *toDoHandler) WebSocket() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgradeがエラーレスポンスを返している
+			return
+		}
+		defer conn.Close()
+
+		subscription := h.service.Subscribe(0)
+		defer subscription.Close()
+		filter := &webSocketFilter{ids: map[int64]bool{}, lists: map[string]bool{}}
+		send := make(chan interface{}, webSocketSendBufferSize)
+		// closed when the handler returns
+		done := make(chan struct{})
+		defer close(done)
+		// closed when the writer stops
+		stopped := make(chan struct{})
+
+		// 書き込みは1つのgoroutineから行う
+		go func() {
+			defer close(stopped)
+			writeWebSocket(conn, subscription, filter, send, done)
+		}()
+
+		conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+		})
+		for {
+			message := &clientMessage{}
+			err := conn.ReadJSON(message)
+			if err != nil {
+				if _, ok := err.(*websocket.CloseError); !ok {
+					log.Println("failed to read WebSocket message:", err)
+				}
+				return
+			}
+			result := h.handleWebSocketMessage(r, message, filter)
+			select {
+			case send <- result:
+			case <-stopped:
+				return
+			}
+		}
+	}
+}
+
+// クライアントからのメッセージを処理し、結果を返す
+func (h *toDoHandler) handleWebSocketMessage(r *http.Request, message *clientMessage, filter *webSocketFilter) *resultMessage {
+	result := &resultMessage{Type: "result", Ref: message.Ref}
+	var err error
+	switch message.Type {
+	case "subscribe", "unsubscribe":
+		err = filter.update(message, message.Type == "subscribe")
+	case "create", "update", "delete":
+		if message.ToDo == nil {
+			err = fmt.Errorf("todo is required for %s", message.Type)
+			break
+		}
+		setRequestInfo(r, message.ToDo)
+		switch message.Type {
+		case "create":
+			result.ToDo, err = h.service.Create(message.ToDo)
+		case "update":
+			result.ToDo, err = h.service.Update(message.ToDo)
+		case "delete":
+			result.ToDo, err = h.service.Delete(message.ToDo)
+		}
+	default:
+		err = fmt.Errorf("unknown message type: %s", message.Type)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Ok = true
+	return result
+}
+
+// 購読しているイベントとメッセージの結果をクライアントに送る
+func writeWebSocket(conn *websocket.Conn, subscription *service.Subscription, filter *webSocketFilter, send <-chan interface{}, done <-chan struct{}) {
+	ping := time.NewTicker(webSocketPingInterval)
+	defer ping.Stop()
+	for {
+		var message interface{}
+		select {
+		case event, ok := <-subscription.Events:
+			if !ok {
+				// 追いつけずに切断されたため、クライアントに再接続させる
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"), time.Now().Add(webSocketWriteWait))
+				conn.Close()
+				return
+			}
+			if !filter.matches(&event) {
+				continue
+			}
+			message = &eventMessage{Type: "event", Id: event.Id, Event: event.Type, ToDo: event.ToDo}
+		case message = <-send:
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(webSocketWriteWait)); err != nil {
+				conn.Close()
+				return
+			}
+			continue
+		case <-done:
+			return
+		}
+		conn.SetWriteDeadline(time.Now().Add(webSocketWriteWait))
+		if err := conn.WriteJSON(message); err != nil {
+			conn.Close()
+			return
+		}
+	}
+}
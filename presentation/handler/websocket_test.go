@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service/mock_service"
+)
+
+// WebSocketのエンドポイントにつないだクライアントを返す
+func dialWebSocket(t *testing.T, toDoService service.ToDoService) *websocket.Conn {
+	r := mux.NewRouter()
+	r.HandleFunc("/todo/ws", NewToDoHandler(toDoService).WebSocket()).Methods(http.MethodGet)
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	header := http.Header{}
+	header.Set("X-Actor", "tester")
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/todo/ws", header)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func TestWebSocketSubscribe(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	events := make(chan service.EventObject, 10)
+	mockToDoService := mock_service.NewMockToDoService(ctrl)
+	mockToDoService.EXPECT().Subscribe(int64(0)).Return(&service.Subscription{Events: events}).Times(1)
+	conn := dialWebSocket(t, mockToDoService)
+
+	// Act
+	conn.WriteJSON(clientMessage{Type: "subscribe", Ref: "1", Ids: []int64{100}, Lists: []string{"done"}})
+	subscribed := resultMessage{}
+	conn.ReadJSON(&subscribed)
+	events <- service.EventObject{Id: 1, Type: "updated", ToDo: service.ToDoObject{Id: 200, Done: false}}
+	events <- service.EventObject{Id: 2, Type: "updated", ToDo: service.ToDoObject{Id: 100, Done: false}}
+	events <- service.EventObject{Id: 3, Type: "created", ToDo: service.ToDoObject{Id: 300, Done: true}}
+
+	// Assert
+	if !subscribed.Ok || subscribed.Ref != "1" {
+		t.Errorf("unexpected result: %+v", subscribed)
+	}
+	for _, expected := range []int64{2, 3} {
+		event := eventMessage{}
+		err := conn.ReadJSON(&event)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if event.Type != "event" || event.Id != expected {
+			t.Errorf("expected event: %d, actual: %+v", expected, event)
+		}
+	}
+}
+
+func TestWebSocketMessage(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name        string
+		message     clientMessage
+		updateError error
+		updateTimes int
+		wantOk      bool
+	}{
+		{
+			name:        "01_ToDoを更新するケース",
+			message:     clientMessage{Type: "update", Ref: "u1", ToDo: &service.ToDoObject{Id: 100, Status: "done"}},
+			updateTimes: 1,
+			wantOk:      true,
+		},
+		{
+			name:        "02_更新が失敗するケース",
+			message:     clientMessage{Type: "update", Ref: "u2", ToDo: &service.ToDoObject{Id: 100, Status: "done"}},
+			updateError: service.ErrInvalidTransition,
+			updateTimes: 1,
+			wantOk:      false,
+		},
+		{
+			name:        "03_todoが指定されていないケース",
+			message:     clientMessage{Type: "update", Ref: "u3"},
+			updateTimes: 0,
+			wantOk:      false,
+		},
+		{
+			name:        "04_不明なメッセージのケース",
+			message:     clientMessage{Type: "hoge", Ref: "u4"},
+			updateTimes: 0,
+			wantOk:      false,
+		},
+		{
+			name:        "05_不明なリストを購読するケース",
+			message:     clientMessage{Type: "subscribe", Ref: "u5", Lists: []string{"hoge"}},
+			updateTimes: 0,
+			wantOk:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoService := mock_service.NewMockToDoService(ctrl)
+			mockToDoService.EXPECT().Subscribe(int64(0)).Return(&service.Subscription{Events: make(chan service.EventObject)}).Times(1)
+			var actor string
+			mockToDoService.EXPECT().Update(gomock.Any()).DoAndReturn(func(toDo *service.ToDoObject) (*service.ToDoObject, error) {
+				actor = toDo.Actor
+				if tt.updateError != nil {
+					return nil, tt.updateError
+				}
+				return toDo, nil
+			}).Times(tt.updateTimes)
+			conn := dialWebSocket(t, mockToDoService)
+
+			// Act
+			conn.WriteJSON(tt.message)
+			result := resultMessage{}
+			err := conn.ReadJSON(&result)
+
+			// Assert
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if result.Type != "result" || result.Ref != tt.message.Ref || result.Ok != tt.wantOk {
+				t.Errorf("unexpected result: %+v", result)
+			}
+			if !tt.wantOk && result.Error == "" {
+				t.Errorf("error is not set: %+v", result)
+			}
+			if tt.updateTimes > 0 && actor != "tester" {
+				t.Errorf("expected actor: tester, actual: %s", actor)
+			}
+		})
+	}
+}
+
+func TestWebSocketSlowClient(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	events := make(chan service.EventObject)
+	mockToDoService := mock_service.NewMockToDoService(ctrl)
+	mockToDoService.EXPECT().Subscribe(int64(0)).Return(&service.Subscription{Events: events}).Times(1)
+	conn := dialWebSocket(t, mockToDoService)
+
+	// Act
+	// ブローカーが購読を打ち切ったケース
+	close(events)
+	_, _, err := conn.ReadMessage()
+
+	// Assert
+	var closeError *websocket.CloseError
+	if !errors.As(err, &closeError) || closeError.Code != websocket.CloseTryAgainLater {
+		t.Errorf("expected close with %d, actual: %v", websocket.CloseTryAgainLater, err)
+	}
+}
@@ -0,0 +1,14 @@
+// Package openapi embeds the OpenAPI document of the API and the page rendering it
+package openapi
+
+import _ "embed"
+
+// OpenAPI 3 document describing all routes of router.NewToDoRouter
+//
+//go:embed openapi.json
+var Spec []byte
+
+// HTML page that renders Spec with Swagger UI
+//
+//go:embed index.html
+var Page []byte
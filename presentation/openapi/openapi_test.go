@@ -0,0 +1,90 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/uzimihsr/todo-rest-api-golang/usecase/service"
+)
+
+// JSONに出力されるフィールド名(埋め込まれた構造体のフィールドを含む)
+func jsonFields(typ reflect.Type) []string {
+	fields := []string{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.Anonymous && name == "" {
+			fields = append(fields, jsonFields(field.Type)...)
+			continue
+		}
+		if name == "-" || field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestSchemas(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	var spec struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	err := json.Unmarshal(Spec, &spec)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tests := []struct {
+		name   string
+		object interface{}
+		schema string
+	}{
+		{name: "01_ToDoのケース", object: service.ToDoObject{}, schema: "ToDo"},
+		{name: "02_履歴のケース", object: service.HistoryObject{}, schema: "History"},
+		{name: "03_変更のケース", object: service.ChangeObject{}, schema: "Change"},
+		{name: "04_リビジョンのケース", object: service.RevisionObject{}, schema: "Revision"},
+		{name: "05_一括操作の結果のケース", object: service.BatchResultObject{}, schema: "BatchResult"},
+		{name: "06_インポートの結果のケース", object: service.ImportResultObject{}, schema: "ImportResult"},
+		{name: "07_インポートのエラーのケース", object: service.ImportErrorObject{}, schema: "ImportError"},
+		{name: "08_Webhookのケース", object: service.WebhookObject{}, schema: "Webhook"},
+		{name: "09_デッドレターのケース", object: service.DeadLetterObject{}, schema: "DeadLetter"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Act
+			expected := jsonFields(reflect.TypeOf(tt.object))
+			schema, ok := spec.Components.Schemas[tt.schema]
+			actual := []string{}
+			for name := range schema.Properties {
+				actual = append(actual, name)
+			}
+			sort.Strings(actual)
+
+			// Assert
+			if !ok {
+				t.Fatalf("schema %s is not in openapi.json", tt.schema)
+			}
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("expected: %v, actual: %v", expected, actual)
+			}
+		})
+	}
+}
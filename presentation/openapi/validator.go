@@ -0,0 +1,470 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Violation of the OpenAPI document found in a request or a response
+type ValidationError struct {
+	// "path", "query", "header", "body" or "response"
+	In string `json:"in"`
+	// name of the parameter or location in the body, e.g. "operations[0].op"
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Name == "" {
+		return e.In + ": " + e.Message
+	}
+	return e.In + " " + e.Name + ": " + e.Message
+}
+
+// Validator of the requests and the responses against an OpenAPI document.
+// Only the part of JSON Schema used by the document of this API is supported,
+// and NewValidator fails if a schema has any other keyword
+type Validator struct {
+	schemas map[string]*schema
+	// operations by "METHOD path", e.g. "GET /todo/{id}"
+	operations map[string]*operation
+}
+
+type operation struct {
+	Parameters  []*parameter         `json:"parameters"`
+	RequestBody *requestBody         `json:"requestBody"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Ref     string               `json:"$ref"`
+	Content map[string]mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Format     string             `json:"format"`
+	Enum       []interface{}      `json:"enum"`
+	Required   []string           `json:"required"`
+	Properties map[string]*schema `json:"properties"`
+	Items      *schema            `json:"items"`
+	MaxItems   *int               `json:"maxItems"`
+	MaxLength  *int               `json:"maxLength"`
+	Minimum    *float64           `json:"minimum"`
+	Nullable   bool               `json:"nullable"`
+}
+
+// keywords of the schema that are validated (see validateValue)
+var supportedKeywords = map[string]bool{
+	"$ref": true, "type": true, "format": true, "enum": true, "required": true, "properties": true,
+	"items": true, "maxItems": true, "maxLength": true, "minimum": true, "nullable": true,
+}
+
+// keywords that do not change what is valid
+var annotationKeywords = map[string]bool{
+	"title": true, "description": true, "example": true, "default": true, "deprecated": true,
+}
+
+// types that validateValue checks, a schema without type accepts any value
+var supportedTypes = map[string]bool{
+	"": true, "object": true, "array": true, "string": true, "integer": true, "number": true, "boolean": true,
+}
+
+// 検証しないキーワードを読み飛ばすと、通るはずのないリクエストを通してしまうため読み込みを失敗させる
+func (s *schema) UnmarshalJSON(data []byte) error {
+	var keywords map[string]json.RawMessage
+	err := json.Unmarshal(data, &keywords)
+	if err != nil {
+		return err
+	}
+	unsupported := []string{}
+	for keyword := range keywords {
+		if !supportedKeywords[keyword] && !annotationKeywords[keyword] {
+			unsupported = append(unsupported, keyword)
+		}
+	}
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+		return fmt.Errorf("unsupported keyword %s in the schema", strings.Join(unsupported, ", "))
+	}
+	type plain schema
+	err = json.Unmarshal(data, (*plain)(s))
+	if err != nil {
+		return err
+	}
+	if !supportedTypes[s.Type] {
+		return fmt.Errorf("unsupported type %q in the schema", s.Type)
+	}
+	return nil
+}
+
+// HTTP methods of the operations in a path item
+var operationMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+func NewValidator(spec []byte) (*Validator, error) {
+	var document struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas    map[string]json.RawMessage `json:"schemas"`
+			Parameters map[string]*parameter      `json:"parameters"`
+			Responses  map[string]*response       `json:"responses"`
+		} `json:"components"`
+	}
+	err := json.Unmarshal(spec, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Validator{
+		schemas:    map[string]*schema{},
+		operations: map[string]*operation{},
+	}
+	// どのスキーマが読めなかったかを分かるようにする
+	for name, raw := range document.Components.Schemas {
+		s := &schema{}
+		err := json.Unmarshal(raw, s)
+		if err != nil {
+			return nil, fmt.Errorf("schema %s: %v", name, err)
+		}
+		v.schemas[name] = s
+	}
+	// $refを読み込み時に解決しておく
+	resolveParameter := func(p *parameter) (*parameter, error) {
+		if p.Ref == "" {
+			return p, nil
+		}
+		resolved, ok := document.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+		if !ok {
+			return nil, fmt.Errorf("unknown parameter %s", p.Ref)
+		}
+		return resolved, nil
+	}
+	for path, item := range document.Paths {
+		var common []*parameter
+		if raw, ok := item["parameters"]; ok {
+			err := json.Unmarshal(raw, &common)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+		}
+		for _, method := range operationMethods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			o := &operation{}
+			err := json.Unmarshal(raw, o)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %v", method, path, err)
+			}
+			o.Parameters = append(append([]*parameter{}, common...), o.Parameters...)
+			for i, p := range o.Parameters {
+				o.Parameters[i], err = resolveParameter(p)
+				if err != nil {
+					return nil, fmt.Errorf("%s %s: %v", method, path, err)
+				}
+			}
+			for code, r := range o.Responses {
+				if r.Ref == "" {
+					continue
+				}
+				resolved, ok := document.Components.Responses[strings.TrimPrefix(r.Ref, "#/components/responses/")]
+				if !ok {
+					return nil, fmt.Errorf("%s %s: unknown response %s", method, path, r.Ref)
+				}
+				o.Responses[code] = resolved
+			}
+			v.operations[strings.ToUpper(method)+" "+path] = o
+		}
+	}
+	return v, nil
+}
+
+// Report whether the document defines a request body for the route (path template, e.g. "/todo/{id}")
+func (v *Validator) HasRequestBody(method string, route string) bool {
+	o, ok := v.operations[method+" "+route]
+	return ok && o.RequestBody != nil
+}
+
+// Validate the parameters and the JSON body of the request to the route (path template, e.g. "/todo/{id}").
+// The body is not validated if nil, and nothing is validated if the document has no operation for the route
+func (v *Validator) ValidateRequest(r *http.Request, route string, pathParams map[string]string, body []byte) []ValidationError {
+	o, ok := v.operations[r.Method+" "+route]
+	if !ok {
+		return nil
+	}
+
+	var errs []ValidationError
+	query := r.URL.Query()
+	for _, p := range o.Parameters {
+		var value string
+		var present bool
+		switch p.In {
+		case "path":
+			value, present = pathParams[p.Name]
+		case "query":
+			// ハンドラと同じく空の値は指定されていないものとして扱う
+			value = query.Get(p.Name)
+			present = value != ""
+		case "header":
+			_, present = r.Header[http.CanonicalHeaderKey(p.Name)]
+			value = r.Header.Get(p.Name)
+		default:
+			continue
+		}
+		errs = append(errs, v.validateParameter(p, value, present)...)
+	}
+	if o.RequestBody != nil && body != nil {
+		errs = append(errs, v.validateRequestBody(o.RequestBody, r.Header.Get("Content-Type"), body)...)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Validate the status code, the Content-Type and the JSON body of the response.
+// The body is not validated if empty
+func (v *Validator) ValidateResponse(method string, route string, statusCode int, contentType string, body []byte) []ValidationError {
+	o, ok := v.operations[method+" "+route]
+	if !ok {
+		return nil
+	}
+	r, ok := o.Responses[strconv.Itoa(statusCode)]
+	if !ok {
+		r, ok = o.Responses["default"]
+	}
+	if !ok {
+		return []ValidationError{{In: "response", Message: fmt.Sprintf("status %d is not documented", statusCode)}}
+	}
+	if len(r.Content) == 0 {
+		return nil
+	}
+	media, _, _ := mime.ParseMediaType(contentType)
+	content, ok := r.Content[media]
+	if !ok {
+		return []ValidationError{{In: "response", Message: fmt.Sprintf("Content-Type %q is not documented for status %d", contentType, statusCode)}}
+	}
+	if media != "application/json" || content.Schema == nil || len(body) == 0 {
+		return nil
+	}
+	return v.validateJSON(content.Schema, body, "response")
+}
+
+// 文字列のパラメータをスキーマの型に変換してから検証する
+func (v *Validator) validateParameter(p *parameter, value string, present bool) []ValidationError {
+	if !present {
+		if p.Required {
+			return []ValidationError{{In: p.In, Name: p.Name, Message: "is required"}}
+		}
+		return nil
+	}
+	if p.Schema == nil {
+		return nil
+	}
+	s := v.resolve(p.Schema)
+	var typed interface{} = value
+	switch s.Type {
+	case "integer", "number":
+		_, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return []ValidationError{{In: p.In, Name: p.Name, Message: "must be " + article(s.Type)}}
+		}
+		typed = json.Number(value)
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return []ValidationError{{In: p.In, Name: p.Name, Message: "must be a boolean"}}
+		}
+		typed = b
+	}
+	return v.validateValue(s, typed, p.In, p.Name)
+}
+
+func (v *Validator) validateRequestBody(b *requestBody, contentType string, body []byte) []ValidationError {
+	if len(bytes.TrimSpace(body)) == 0 {
+		if b.Required {
+			return []ValidationError{{In: "body", Message: "is required"}}
+		}
+		return nil
+	}
+	media, _, _ := mime.ParseMediaType(contentType)
+	content, ok := b.Content[media]
+	if !ok {
+		// Content-Typeを見ないハンドラもあるため、JSONしか受け付けない操作ではJSONとして検証する
+		content, ok = b.Content["application/json"]
+		if !ok || len(b.Content) != 1 {
+			return nil
+		}
+		media = "application/json"
+	}
+	if media != "application/json" || content.Schema == nil {
+		return nil
+	}
+	return v.validateJSON(content.Schema, body, "body")
+}
+
+func (v *Validator) validateJSON(s *schema, body []byte, in string) []ValidationError {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	err := decoder.Decode(&value)
+	if err != nil {
+		return []ValidationError{{In: in, Message: "invalid JSON: " + err.Error()}}
+	}
+	return v.validateValue(s, value, in, "")
+}
+
+func (v *Validator) resolve(s *schema) *schema {
+	for s.Ref != "" {
+		resolved, ok := v.schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if !ok {
+			// 型のないスキーマとして何でも受け付ける
+			return &schema{}
+		}
+		s = resolved
+	}
+	return s
+}
+
+// JSONの値をスキーマで検証する(nameはボディ内の位置)
+func (v *Validator) validateValue(s *schema, value interface{}, in string, name string) []ValidationError {
+	s = v.resolve(s)
+	fail := func(format string, args ...interface{}) []ValidationError {
+		return []ValidationError{{In: in, Name: name, Message: fmt.Sprintf(format, args...)}}
+	}
+	if value == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return fail("must not be null")
+	}
+
+	var errs []ValidationError
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fail("must be an object")
+		}
+		for _, key := range s.Required {
+			if _, ok := object[key]; !ok {
+				errs = append(errs, ValidationError{In: in, Name: joinName(name, key), Message: "is required"})
+			}
+		}
+		// エラーの順序を一定にする
+		keys := []string{}
+		for key := range s.Properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if field, ok := object[key]; ok {
+				errs = append(errs, v.validateValue(s.Properties[key], field, in, joinName(name, key))...)
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return fail("must be an array")
+		}
+		if s.MaxItems != nil && len(array) > *s.MaxItems {
+			return fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range array {
+				errs = append(errs, v.validateValue(s.Items, item, in, fmt.Sprintf("%s[%d]", name, i))...)
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		if s.MaxLength != nil && utf8.RuneCountInString(str) > *s.MaxLength {
+			return fail("must be at most %d characters", *s.MaxLength)
+		}
+		if s.Format == "date-time" {
+			_, err := time.Parse(time.RFC3339, str)
+			if err != nil {
+				return fail("must be an RFC 3339 date-time")
+			}
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return fail("must be %s", article(s.Type))
+		}
+		f, err := number.Float64()
+		if err == nil && s.Type == "integer" {
+			_, err = strconv.ParseInt(number.String(), 10, 64)
+		}
+		if err != nil {
+			return fail("must be %s", article(s.Type))
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fail("must be at least %v", *s.Minimum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fail("must be a boolean")
+		}
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		values := []string{}
+		for _, e := range s.Enum {
+			values = append(values, fmt.Sprint(e))
+		}
+		errs = append(errs, ValidationError{In: in, Name: name, Message: "must be one of " + strings.Join(values, ", ")})
+	}
+	return errs
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinName(name string, key string) string {
+	if name == "" {
+		return key
+	}
+	return name + "." + key
+}
+
+func article(typ string) string {
+	if typ == "integer" {
+		return "an integer"
+	}
+	return "a " + typ
+}
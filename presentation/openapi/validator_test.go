@@ -0,0 +1,318 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewValidator(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	tests := []struct {
+		name          string
+		spec          string
+		expectedError string
+	}{
+		{
+			name: "01_検証できるキーワードだけのケース",
+			spec: `{"paths": {}, "components": {"schemas": {"ToDo": {"type": "object", "description": "ToDo", "properties": {"title": {"type": "string", "maxLength": 100, "example": "Buy a new pencil"}}}}}}`,
+		},
+		{
+			name:          "02_コンポーネントのスキーマに検証しないキーワードがあるケース",
+			spec:          `{"paths": {}, "components": {"schemas": {"ToDo": {"type": "object", "properties": {"title": {"type": "string", "pattern": "^a", "minLength": 1}}}}}}`,
+			expectedError: "schema ToDo: unsupported keyword minLength, pattern in the schema",
+		},
+		{
+			name:          "03_リクエストボディのスキーマに検証しないキーワードがあるケース",
+			spec:          `{"paths": {"/todo": {"post": {"requestBody": {"content": {"application/json": {"schema": {"oneOf": [{"type": "object"}]}}}}}}}}`,
+			expectedError: "post /todo: unsupported keyword oneOf in the schema",
+		},
+		{
+			name:          "04_検証しない型のケース",
+			spec:          `{"paths": {"/todo": {"get": {"parameters": [{"name": "done", "in": "query", "schema": {"type": "null"}}]}}}}`,
+			expectedError: `get /todo: unsupported type "null" in the schema`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Act
+			_, err := NewValidator([]byte(tt.spec))
+
+			// Assert
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expectedError {
+				t.Errorf("expected: %s, actual: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	validator, err := NewValidator(Spec)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		route          string
+		pathParams     map[string]string
+		headers        map[string]string
+		body           string
+		expectedErrors []ValidationError
+	}{
+		{
+			name:   "01_正しいリクエストのケース",
+			method: http.MethodPost,
+			target: "/todo",
+			route:  "/todo",
+			body:   `{"title": "Buy a new pencil", "done": true}`,
+		},
+		{
+			name:           "02_必須のフィールドがないケース",
+			method:         http.MethodPost,
+			target:         "/todo",
+			route:          "/todo",
+			headers:        map[string]string{"Content-Type": "application/json"},
+			body:           `{"done": true}`,
+			expectedErrors: []ValidationError{{In: "body", Name: "title", Message: "is required"}},
+		},
+		{
+			name:    "03_フィールドの型が違うケース",
+			method:  http.MethodPatch,
+			target:  "/todo/123",
+			route:   "/todo/{id}",
+			headers: map[string]string{"Content-Type": "application/json"},
+			body:    `{"title": 1, "done": "yes"}`,
+			expectedErrors: []ValidationError{
+				{In: "body", Name: "done", Message: "must be a boolean"},
+				{In: "body", Name: "title", Message: "must be a string"},
+			},
+			pathParams: map[string]string{"id": "123"},
+		},
+		{
+			name:           "04_JSONとして読めないケース",
+			method:         http.MethodPost,
+			target:         "/todo",
+			route:          "/todo",
+			headers:        map[string]string{"Content-Type": "application/json"},
+			body:           `{"title": `,
+			expectedErrors: []ValidationError{{In: "body", Message: "invalid JSON: unexpected EOF"}},
+		},
+		{
+			name:           "05_ボディがないケース",
+			method:         http.MethodPost,
+			target:         "/todo",
+			route:          "/todo",
+			expectedErrors: []ValidationError{{In: "body", Message: "is required"}},
+		},
+		{
+			name:           "06_クエリパラメータの型が違うケース",
+			method:         http.MethodGet,
+			target:         "/todo?done=hoge&as_of=yesterday",
+			route:          "/todo",
+			expectedErrors: []ValidationError{{In: "query", Name: "done", Message: "must be a boolean"}, {In: "query", Name: "as_of", Message: "must be an RFC 3339 date-time"}},
+		},
+		{
+			name:   "07_空のクエリパラメータのケース",
+			method: http.MethodGet,
+			target: "/todo?done=",
+			route:  "/todo",
+		},
+		{
+			name:           "08_パスパラメータの型が違うケース",
+			method:         http.MethodGet,
+			target:         "/todo/abc",
+			route:          "/todo/{id}",
+			pathParams:     map[string]string{"id": "abc"},
+			expectedErrors: []ValidationError{{In: "path", Name: "id", Message: "must be an integer"}},
+		},
+		{
+			name:           "09_必須のクエリパラメータがないケース",
+			method:         http.MethodPost,
+			target:         "/todo/archive",
+			route:          "/todo/archive",
+			expectedErrors: []ValidationError{{In: "query", Name: "older_than_days", Message: "is required"}},
+		},
+		{
+			name:           "10_最小値より小さいケース",
+			method:         http.MethodPost,
+			target:         "/todo/archive?older_than_days=-1",
+			route:          "/todo/archive",
+			expectedErrors: []ValidationError{{In: "query", Name: "older_than_days", Message: "must be at least 0"}},
+		},
+		{
+			name:    "11_列挙された値以外のケース",
+			method:  http.MethodPost,
+			target:  "/todo/bulk",
+			route:   "/todo/bulk",
+			headers: map[string]string{"Content-Type": "application/json"},
+			body:    `{"mode": "hoge", "operations": [{"op": "create", "title": "Buy a new pencil"}, {"op": "read", "id": 1}]}`,
+			expectedErrors: []ValidationError{
+				{In: "body", Name: "mode", Message: "must be one of atomic, best_effort"},
+				{In: "body", Name: "operations[1].op", Message: "must be one of create, update, delete"},
+			},
+		},
+		{
+			name:           "12_ヘッダが長すぎるケース",
+			method:         http.MethodPost,
+			target:         "/todo",
+			route:          "/todo",
+			headers:        map[string]string{"Idempotency-Key": strings.Repeat("a", 256)},
+			body:           `{"title": "Buy a new pencil"}`,
+			expectedErrors: []ValidationError{{In: "header", Name: "Idempotency-Key", Message: "must be at most 255 characters"}},
+		},
+		{
+			name:    "13_JSON以外のボディは検証しないケース",
+			method:  http.MethodPost,
+			target:  "/todo/import?format=csv",
+			route:   "/todo/import",
+			headers: map[string]string{"Content-Type": "text/csv"},
+			body:    "title\nBuy a new pencil\n",
+		},
+		{
+			name:           "14_Content-Typeがなくても操作がJSONしか受け付けないケース",
+			method:         http.MethodPost,
+			target:         "/graphql",
+			route:          "/graphql",
+			headers:        map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:           `{"variables": []}`,
+			expectedErrors: []ValidationError{{In: "body", Name: "query", Message: "is required"}, {In: "body", Name: "variables", Message: "must be an object"}},
+		},
+		{
+			name:    "15_JSON以外の形式で受け付けるボディは検証しないケース",
+			method:  http.MethodPost,
+			target:  "/webhooks",
+			route:   "/webhooks",
+			headers: map[string]string{"Content-Type": "application/yaml"},
+			body:    "events: [hoge]\n",
+		},
+		{
+			name:   "16_定義にない操作のケース",
+			method: "PROPFIND",
+			target: "/caldav/todo/",
+			route:  "/caldav/todo/",
+			body:   "<propfind",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			r := httptest.NewRequest(tt.method, "http://hogehoge"+tt.target, strings.NewReader(tt.body))
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+
+			// Act
+			errs := validator.ValidateRequest(r, tt.route, tt.pathParams, []byte(tt.body))
+
+			// Assert
+			if !reflect.DeepEqual(errs, tt.expectedErrors) {
+				t.Errorf("expected: %+v, actual: %+v", tt.expectedErrors, errs)
+			}
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	validator, err := NewValidator(Spec)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	toDo := `{"id":123,"title":"Buy a new pencil","status":"todo","done":false,"archived":false,"created_at":"2021-06-15T00:35:07Z","updated_at":"2021-06-15T00:35:07Z"}`
+	tests := []struct {
+		name           string
+		method         string
+		route          string
+		statusCode     int
+		contentType    string
+		body           string
+		expectedErrors []ValidationError
+	}{
+		{
+			name:        "01_正しいレスポンスのケース",
+			method:      http.MethodGet,
+			route:       "/todo/{id}",
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			body:        toDo,
+		},
+		{
+			name:        "02_配列のレスポンスのケース",
+			method:      http.MethodGet,
+			route:       "/todo",
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			body:        "[" + toDo + "," + strings.Replace(toDo, `"updated_at"`, `"updatedAt"`, 1) + "]",
+			expectedErrors: []ValidationError{
+				{In: "response", Name: "[1].updated_at", Message: "is required"},
+			},
+		},
+		{
+			name:        "03_エラーのレスポンスのケース",
+			method:      http.MethodPatch,
+			route:       "/todo/{id}",
+			statusCode:  http.StatusConflict,
+			contentType: "text/plain; charset=utf-8",
+			body:        "invalid status transition",
+		},
+		{
+			name:           "04_定義にないステータスコードのケース",
+			method:         http.MethodGet,
+			route:          "/todo/{id}",
+			statusCode:     http.StatusTeapot,
+			contentType:    "text/plain; charset=utf-8",
+			expectedErrors: []ValidationError{{In: "response", Message: "status 418 is not documented"}},
+		},
+		{
+			name:           "05_定義にないContent-Typeのケース",
+			method:         http.MethodGet,
+			route:          "/todo/{id}",
+			statusCode:     http.StatusOK,
+			contentType:    "text/html",
+			expectedErrors: []ValidationError{{In: "response", Message: `Content-Type "text/html" is not documented for status 200`}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Act
+			errs := validator.ValidateResponse(tt.method, tt.route, tt.statusCode, tt.contentType, []byte(tt.body))
+
+			// Assert
+			if !reflect.DeepEqual(errs, tt.expectedErrors) {
+				t.Errorf("expected: %+v, actual: %+v", tt.expectedErrors, errs)
+			}
+		})
+	}
+}
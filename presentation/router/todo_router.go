@@ -4,23 +4,66 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/graphql"
 	"github.com/uzimihsr/todo-rest-api-golang/presentation/handler"
 )
 
 type ToDoRouter struct {
-	handler handler.ToDoHandler
-	router  *mux.Router
+	handler        handler.ToDoHandler
+	webhookHandler handler.WebhookHandler
+	calDAVHandler  handler.CalDAVHandler
+	graphQLHandler graphql.Handler
+	router         *mux.Router
 }
 
-func NewToDoRouter(h handler.ToDoHandler) *ToDoRouter {
+func NewToDoRouter(h handler.ToDoHandler, webhookHandler handler.WebhookHandler, calDAVHandler handler.CalDAVHandler, graphQLHandler graphql.Handler, idempotency mux.MiddlewareFunc, validation mux.MiddlewareFunc) *ToDoRouter {
 	r := new(ToDoRouter)
 	r.handler = h
+	r.webhookHandler = webhookHandler
+	r.calDAVHandler = calDAVHandler
+	r.graphQLHandler = graphQLHandler
 	r.router = mux.NewRouter()
-	r.router.HandleFunc("/todo", r.handler.Create()).Methods(http.MethodPost)
+	r.router.Use(handler.RequestId, validation)
+	r.router.Handle("/todo", idempotency(r.handler.Create())).Methods(http.MethodPost)
+	r.router.HandleFunc("/todo/archive", r.handler.ArchiveDone()).Methods(http.MethodPost)
+	r.router.HandleFunc("/todo/bulk", r.handler.Batch()).Methods(http.MethodPost)
+	r.router.HandleFunc("/todo/import", r.handler.Import()).Methods(http.MethodPost)
+	r.router.HandleFunc("/todo/export", r.handler.Export()).Methods(http.MethodGet)
+	r.router.HandleFunc("/todo.ics", r.handler.ICalendar()).Methods(http.MethodGet)
+	r.router.HandleFunc("/todo.ics", r.handler.ImportICalendar()).Methods(http.MethodPost)
+	r.router.HandleFunc("/todo/events", r.handler.Events()).Methods(http.MethodGet)
+	r.router.HandleFunc("/todo/ws", r.handler.WebSocket()).Methods(http.MethodGet)
 	r.router.HandleFunc("/todo/{id}", r.handler.Read()).Methods(http.MethodGet)
 	r.router.HandleFunc("/todo/{id}", r.handler.Update()).Methods(http.MethodPatch)
 	r.router.HandleFunc("/todo/{id}", r.handler.Delete()).Methods(http.MethodDelete)
 	r.router.HandleFunc("/todo", r.handler.List()).Methods(http.MethodGet)
+	r.router.HandleFunc("/todo/{id}/restore", r.handler.Restore()).Methods(http.MethodPost)
+	r.router.HandleFunc("/todo/{id}/archive", r.handler.Archive()).Methods(http.MethodPost)
+	r.router.HandleFunc("/todo/{id}/unarchive", r.handler.Unarchive()).Methods(http.MethodPost)
+	r.router.HandleFunc("/todo/{id}/history", r.handler.History()).Methods(http.MethodGet)
+	r.router.HandleFunc("/todo/{id}/revisions", r.handler.Revisions()).Methods(http.MethodGet)
+	r.router.HandleFunc("/todo/{id}/revert", r.handler.Revert()).Methods(http.MethodPost)
+	r.router.HandleFunc("/trash", r.handler.Trash()).Methods(http.MethodGet)
+	r.router.HandleFunc("/webhooks", r.webhookHandler.Create()).Methods(http.MethodPost)
+	r.router.HandleFunc("/webhooks", r.webhookHandler.List()).Methods(http.MethodGet)
+	r.router.HandleFunc("/webhooks/{id}", r.webhookHandler.Read()).Methods(http.MethodGet)
+	r.router.HandleFunc("/webhooks/{id}", r.webhookHandler.Update()).Methods(http.MethodPatch)
+	r.router.HandleFunc("/webhooks/{id}", r.webhookHandler.Delete()).Methods(http.MethodDelete)
+	r.router.HandleFunc("/webhooks/{id}/dead-letters", r.webhookHandler.DeadLetters()).Methods(http.MethodGet)
+	r.router.Handle("/.well-known/caldav", http.RedirectHandler("/caldav/", http.StatusMovedPermanently))
+	r.router.PathPrefix("/caldav/").HandlerFunc(r.calDAVHandler.Options()).Methods(http.MethodOptions)
+	r.router.HandleFunc("/caldav/", r.calDAVHandler.PropFind()).Methods("PROPFIND")
+	r.router.HandleFunc("/caldav/todo/", r.calDAVHandler.PropFind()).Methods("PROPFIND")
+	r.router.HandleFunc("/caldav/todo/", r.calDAVHandler.Report()).Methods("REPORT")
+	r.router.HandleFunc("/caldav/todo/{name}", r.calDAVHandler.PropFind()).Methods("PROPFIND")
+	r.router.HandleFunc("/caldav/todo/{name}", r.calDAVHandler.Get()).Methods(http.MethodGet, http.MethodHead)
+	r.router.HandleFunc("/caldav/todo/{name}", r.calDAVHandler.Put()).Methods(http.MethodPut)
+	r.router.HandleFunc("/caldav/todo/{name}", r.calDAVHandler.Delete()).Methods(http.MethodDelete)
+	r.router.HandleFunc("/graphql", r.graphQLHandler.Subscribe()).Methods(http.MethodGet).HeadersRegexp("Upgrade", "(?i)^websocket$")
+	r.router.HandleFunc("/graphql", r.graphQLHandler.Query()).Methods(http.MethodGet, http.MethodPost)
+	r.router.HandleFunc("/graphql/schema", r.graphQLHandler.Schema()).Methods(http.MethodGet)
+	r.router.HandleFunc("/openapi.json", handler.OpenAPI()).Methods(http.MethodGet)
+	r.router.HandleFunc("/docs", handler.OpenAPIDocs()).Methods(http.MethodGet)
 	return r
 }
 
@@ -0,0 +1,97 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/graphql"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/handler"
+	"github.com/uzimihsr/todo-rest-api-golang/presentation/openapi"
+)
+
+// OpenAPIで書けるHTTPメソッド(それ以外はx-webdav-methodsに書く)
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// ルーティングとOpenAPIの定義を"METHOD path"の集合として比べる
+func TestOpenAPIRoutes(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	passThrough := func(h http.Handler) http.Handler { return h }
+	r := NewToDoRouter(handler.NewToDoHandler(nil), handler.NewWebhookHandler(nil), handler.NewCalDAVHandler(nil), graphql.NewHandler(nil), passThrough, passThrough)
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	err := json.Unmarshal(openapi.Spec, &spec)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Act
+	routes := map[string]bool{}
+	err = r.GetRouter().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// メソッドを限定しないルートはパスがあればよい
+			routes["* "+path] = true
+			return nil
+		}
+		for _, method := range methods {
+			routes[method+" "+path] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	documented := map[string]bool{}
+	for path, item := range spec.Paths {
+		for _, method := range openAPIMethods {
+			if _, ok := item[method]; ok {
+				documented[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+		var webDAVMethods []string
+		if raw, ok := item["x-webdav-methods"]; ok {
+			err := json.Unmarshal(raw, &webDAVMethods)
+			if err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+		}
+		for _, method := range webDAVMethods {
+			documented[method+" "+path] = true
+		}
+	}
+
+	// Assert
+	for route := range routes {
+		if strings.HasPrefix(route, "* ") {
+			if _, ok := spec.Paths[strings.TrimPrefix(route, "* ")]; !ok {
+				t.Errorf("%s is not in openapi.json", route)
+			}
+			continue
+		}
+		if !documented[route] {
+			t.Errorf("%s is not in openapi.json", route)
+		}
+	}
+	missing := []string{}
+	for operation := range documented {
+		path := operation[strings.Index(operation, " ")+1:]
+		if !routes[operation] && !routes["* "+path] {
+			missing = append(missing, operation)
+		}
+	}
+	sort.Strings(missing)
+	for _, operation := range missing {
+		t.Errorf("%s in openapi.json is not routed", operation)
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// Change of a ToDo notified to the subscribers
+type EventObject struct {
+	// sequence number of the event
+	Id int64
+	// "created", "updated" or "deleted"
+	Type string
+	ToDo ToDoObject
+	// true if the update moved the ToDo to the done status
+	Completed bool
+	CreatedAt time.Time
+}
+
+// Events for a subscriber
+type Subscription struct {
+	// events published after Subscribe
+	// closed when the subscriber cannot keep up or Close is called
+	Events <-chan EventObject
+	// events after the last event ID still kept in the buffer
+	Missed []EventObject
+	// true if some events after the last event ID are no longer in the buffer
+	Gap bool
+
+	close func()
+}
+
+// Stop receiving events
+func (s *Subscription) Close() {
+	if s.close != nil {
+		s.close()
+	}
+}
+
+// number of events a subscriber can fall behind before it is disconnected
+const subscriberBufferSize = 64
+
+// Delivers events to the subscribers and keeps the latest events in a ring buffer for resuming
+type EventBroker struct {
+	mu     sync.Mutex
+	lastId int64
+	buffer []EventObject
+	// index of the oldest event in buffer
+	start       int
+	count       int
+	subscribers map[chan EventObject]struct{}
+}
+
+func NewEventBroker(bufferSize int) *EventBroker {
+	return &EventBroker{
+		buffer:      make([]EventObject, bufferSize),
+		subscribers: map[chan EventObject]struct{}{},
+	}
+}
+
+// Notify the event to the subscribers of this server (implementation of EventPublisher).
+// The events must come in the order of the IDs, which OutboxFeed reads from the outbox
+func (b *EventBroker) PublishEvent(e *EventObject) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := *e
+	// 既に配信したイベントは送らない
+	if event.Id <= b.lastId {
+		return nil
+	}
+	b.lastId = event.Id
+	if len(b.buffer) > 0 {
+		// 古いイベントから上書きする
+		b.buffer[(b.start+b.count)%len(b.buffer)] = event
+		if b.count < len(b.buffer) {
+			b.count++
+		} else {
+			b.start = (b.start + 1) % len(b.buffer)
+		}
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// 追いつけない購読者は切断し、Last-Event-IDで再開させる
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return nil
+}
+
+// Start receiving events published after the last event ID (0 to receive only new events)
+func (b *EventBroker) Subscribe(lastEventId int64) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan EventObject, subscriberBufferSize)
+	b.subscribers[ch] = struct{}{}
+	subscription := &Subscription{
+		Events: ch,
+		close: func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subscribers[ch]; ok {
+				delete(b.subscribers, ch)
+				close(ch)
+			}
+		},
+	}
+	if lastEventId <= 0 || lastEventId == b.lastId {
+		return subscription
+	}
+	// 受け取っていないイベントより先のIDは再起動前などのもので、その後のイベントが分からない
+	if lastEventId > b.lastId {
+		subscription.Gap = true
+		return subscription
+	}
+
+	for i := 0; i < b.count; i++ {
+		event := b.buffer[(b.start+i)%len(b.buffer)]
+		if event.Id > lastEventId {
+			subscription.Missed = append(subscription.Missed, event)
+		}
+	}
+	// lastEventIdの次のイベントがバッファに残っていない
+	if len(subscription.Missed) == 0 || subscription.Missed[0].Id > lastEventId+1 {
+		subscription.Gap = true
+	}
+	return subscription
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestEventBrokerSubscribe(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name        string
+		bufferSize  int
+		published   int
+		lastEventId int64
+		wantMissed  []int64
+		wantGap     bool
+	}{
+		{
+			name:        "01_新しいイベントだけを受け取るケース",
+			bufferSize:  5,
+			published:   3,
+			lastEventId: 0,
+			wantMissed:  nil,
+			wantGap:     false,
+		},
+		{
+			name:        "02_バッファから再開するケース",
+			bufferSize:  5,
+			published:   3,
+			lastEventId: 1,
+			wantMissed:  []int64{2, 3},
+			wantGap:     false,
+		},
+		{
+			name:        "03_リングバッファが一周したケース",
+			bufferSize:  3,
+			published:   7,
+			lastEventId: 4,
+			wantMissed:  []int64{5, 6, 7},
+			wantGap:     false,
+		},
+		{
+			name:        "04_再開位置がバッファに残っていないケース",
+			bufferSize:  3,
+			published:   7,
+			lastEventId: 2,
+			wantMissed:  []int64{5, 6, 7},
+			wantGap:     true,
+		},
+		{
+			name:        "05_最新のイベントまで受け取っているケース",
+			bufferSize:  3,
+			published:   7,
+			lastEventId: 7,
+			wantMissed:  nil,
+			wantGap:     false,
+		},
+		{
+			name:        "06_再起動前のイベントIDで再開するケース",
+			bufferSize:  3,
+			published:   2,
+			lastEventId: 10,
+			wantMissed:  nil,
+			wantGap:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			broker := NewEventBroker(tt.bufferSize)
+			for i := 0; i < tt.published; i++ {
+				broker.PublishEvent(&EventObject{Id: int64(i + 1), Type: "created", ToDo: ToDoObject{Id: int64(i + 1)}})
+			}
+
+			// Act
+			subscription := broker.Subscribe(tt.lastEventId)
+			defer subscription.Close()
+			broker.PublishEvent(&EventObject{Id: int64(tt.published + 1), Type: "updated", ToDo: ToDoObject{Id: 100}})
+
+			// Assert
+			if len(subscription.Missed) != len(tt.wantMissed) {
+				t.Fatalf("expected: %v, actual: %v", tt.wantMissed, subscription.Missed)
+			}
+			for i, id := range tt.wantMissed {
+				if subscription.Missed[i].Id != id {
+					t.Errorf("expected: %v, actual: %v", id, subscription.Missed[i].Id)
+				}
+			}
+			if subscription.Gap != tt.wantGap {
+				t.Errorf("expected gap: %v, actual: %v", tt.wantGap, subscription.Gap)
+			}
+			event := <-subscription.Events
+			if event.Id != int64(tt.published+1) || event.Type != "updated" || event.ToDo.Id != 100 {
+				t.Errorf("unexpected event: %+v", event)
+			}
+		})
+	}
+}
+
+func TestEventBrokerSlowSubscriber(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	broker := NewEventBroker(10)
+	subscription := broker.Subscribe(0)
+
+	// Act
+	for i := 0; i <= subscriberBufferSize; i++ {
+		broker.PublishEvent(&EventObject{Id: int64(i + 1), Type: "created", ToDo: ToDoObject{Id: int64(i + 1)}})
+	}
+
+	// Assert
+	received := 0
+	for range subscription.Events {
+		received++
+	}
+	if received != subscriberBufferSize {
+		t.Errorf("expected: %d, actual: %d", subscriberBufferSize, received)
+	}
+	// 切断済みでも閉じられる
+	subscription.Close()
+}
+
+func TestEventBrokerPublishEvent(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	broker := NewEventBroker(10)
+	for _, id := range []int64{41, 42, 43} {
+		broker.PublishEvent(&EventObject{Id: id, Type: "created", ToDo: ToDoObject{Id: id}})
+	}
+
+	// Act
+	subscription := broker.Subscribe(41)
+	defer subscription.Close()
+	// 読み直したイベントは送らない
+	broker.PublishEvent(&EventObject{Id: 43, Type: "created", ToDo: ToDoObject{Id: 43}})
+	broker.PublishEvent(&EventObject{Id: 44, Type: "updated", ToDo: ToDoObject{Id: 100}})
+
+	// Assert
+	// outboxの配信順の番号をそのままイベントIDとして使う
+	if len(subscription.Missed) != 2 || subscription.Missed[0].Id != 42 || subscription.Missed[1].Id != 43 {
+		t.Errorf("expected: [42 43], actual: %+v", subscription.Missed)
+	}
+	if subscription.Gap {
+		t.Error("unexpected gap")
+	}
+	if event := <-subscription.Events; event.Id != 44 {
+		t.Errorf("expected: %d, actual: %d", 44, event.Id)
+	}
+}
@@ -0,0 +1,37 @@
+package service
+
+import "log"
+
+// Destination of the events relayed from the outbox
+type EventPublisher interface {
+	// Publish the event, an error makes the relay retry it later
+	PublishEvent(*EventObject) error
+}
+
+// Writes the events to the log
+type LogEventPublisher struct {
+	logger *log.Logger
+}
+
+func NewLogEventPublisher(logger *log.Logger) *LogEventPublisher {
+	return &LogEventPublisher{logger: logger}
+}
+
+func (p *LogEventPublisher) PublishEvent(event *EventObject) error {
+	p.logger.Printf("event %d: %s ToDo %d (completed: %v)", event.Id, event.Type, event.ToDo.Id, event.Completed)
+	return nil
+}
+
+// Publishes the events to every publisher in order
+type EventPublishers []EventPublisher
+
+func (p EventPublishers) PublishEvent(event *EventObject) error {
+	for _, publisher := range p {
+		// 途中で失敗した場合は全体を再送するため、先の配信先には重複して届く
+		err := publisher.PublishEvent(event)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
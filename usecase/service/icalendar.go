@@ -0,0 +1,181 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// domain of the iCalendar UIDs of ToDo created by this API, e.g. "123@todo-rest-api-golang"
+const ICalendarUidDomain = "todo-rest-api-golang"
+
+var ownICalendarUid = regexp.MustCompile(`^(\d+)@` + regexp.QuoteMeta(ICalendarUidDomain) + `$`)
+
+// Return the iCalendar UID of the ToDo, the UID recorded at the import if any
+func ICalendarUid(id int64, uids map[int64]string) string {
+	if uid, ok := uids[id]; ok {
+		return uid
+	}
+	return fmt.Sprintf("%d@%s", id, ICalendarUidDomain)
+}
+
+// Property of an iCalendar component, e.g. "SUMMARY:Buy a new pencil"
+type iCalendarProperty struct {
+	name  string
+	value string
+}
+
+// VTODOコンポーネントを1件ずつのToDoとして読む(RFC 5545)
+func parseImportICalendar(data []byte) ([]importLine, error) {
+	lines := []importLine{}
+	var current *importLine
+	var properties []iCalendarProperty
+	found := false
+	err := scanICalendar(data, func(number int, property iCalendarProperty) {
+		switch {
+		case property.name == "BEGIN" && property.value == "VCALENDAR":
+			found = true
+		case property.name == "BEGIN" && property.value == "VTODO":
+			current = &importLine{line: number}
+			properties = nil
+		case property.name == "END" && property.value == "VTODO" && current != nil:
+			current.toDo, current.uid, current.err = iCalendarToDo(properties)
+			lines = append(lines, *current)
+			current = nil
+		case current != nil:
+			properties = append(properties, property)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: the data is not an iCalendar (BEGIN:VCALENDAR is missing)", ErrInvalidImport)
+	}
+	return lines, nil
+}
+
+// 折り返された行を戻して1プロパティずつfnに渡す(numberはプロパティの開始行)
+func scanICalendar(data []byte, fn func(int, iCalendarProperty)) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	var content strings.Builder
+	start := 0
+	flush := func() error {
+		if content.Len() == 0 {
+			return nil
+		}
+		property, err := parseICalendarProperty(content.String())
+		if err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrInvalidImport, start, err)
+		}
+		fn(start, property)
+		content.Reset()
+		return nil
+	}
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t") {
+			content.WriteString(text[1:])
+			continue
+		}
+		err := flush()
+		if err != nil {
+			return err
+		}
+		if text != "" {
+			content.WriteString(text)
+			start = number
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// "NAME;PARAM=VALUE:value"をプロパティ名と値に分ける(パラメータは使わない)
+func parseICalendarProperty(content string) (iCalendarProperty, error) {
+	colon := -1
+	quoted := false
+	for i, c := range content {
+		if c == '"' {
+			quoted = !quoted
+		}
+		if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return iCalendarProperty{}, fmt.Errorf("invalid content line %q", content)
+	}
+	name := content[:colon]
+	if i := strings.Index(name, ";"); i >= 0 {
+		name = name[:i]
+	}
+	return iCalendarProperty{name: strings.ToUpper(name), value: content[colon+1:]}, nil
+}
+
+// VTODOのプロパティからToDoとUIDを組み立てる
+func iCalendarToDo(properties []iCalendarProperty) (ToDoObject, string, error) {
+	toDo := ToDoObject{}
+	var uid, status string
+	completed := false
+	for _, p := range properties {
+		switch p.name {
+		case "UID":
+			uid = p.value
+		case "SUMMARY":
+			toDo.Title = unescapeICalendarText(p.value)
+		case "STATUS":
+			status = strings.ToUpper(p.value)
+		case "COMPLETED":
+			completed = true
+		}
+	}
+	if uid == "" {
+		return ToDoObject{}, "", fmt.Errorf("UID is required")
+	}
+	switch status {
+	case "COMPLETED":
+		toDo.Done = true
+	case "", "NEEDS-ACTION", "IN-PROCESS", "CANCELLED":
+		toDo.Done = status == "" && completed
+	default:
+		return ToDoObject{}, "", fmt.Errorf("unknown STATUS %q", status)
+	}
+	return toDo, uid, nil
+}
+
+// TEXT型の値のエスケープ(\\ \; \, \n)を戻す
+func unescapeICalendarText(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// UIDがこのAPIで作成したToDoのものならそのIDを返す
+func ownICalendarId(uid string) (int64, bool) {
+	match := ownICalendarUid.FindStringSubmatch(uid)
+	if match == nil {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(match[1], 10, 64)
+	return id, err == nil
+}
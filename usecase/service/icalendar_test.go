@@ -0,0 +1,504 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository/mock_repository"
+)
+
+func TestParseImportICalendar(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name      string
+		data      string
+		want      []importLine
+		wantError bool
+	}{
+		{
+			name: "01_折り返しとエスケープを含むケース",
+			data: "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+				"BEGIN:VTODO\r\nUID:abc@example.com\r\nSUMMARY;LANGUAGE=en:Buy a new pencil\\, an eraser \r\n and a notebook\r\nSTATUS:COMPLETED\r\nEND:VTODO\r\n" +
+				"BEGIN:VTODO\r\nUID:123@todo-rest-api-golang\r\nSUMMARY:Go to the cinema\r\nSTATUS:NEEDS-ACTION\r\nEND:VTODO\r\n" +
+				"END:VCALENDAR\r\n",
+			want: []importLine{
+				{line: 3, uid: "abc@example.com", toDo: ToDoObject{Title: "Buy a new pencil, an eraser and a notebook", Done: true}},
+				{line: 9, uid: "123@todo-rest-api-golang", toDo: ToDoObject{Title: "Go to the cinema"}},
+			},
+		},
+		{
+			name: "02_STATUSがなくCOMPLETEDがあるケース",
+			data: "BEGIN:VCALENDAR\nBEGIN:VTODO\nUID:abc\nSUMMARY:Call Mom\nCOMPLETED:20210615T004010Z\nEND:VTODO\nEND:VCALENDAR\n",
+			want: []importLine{
+				{line: 2, uid: "abc", toDo: ToDoObject{Title: "Call Mom", Done: true}},
+			},
+		},
+		{
+			name: "03_UIDがないケース",
+			data: "BEGIN:VCALENDAR\nBEGIN:VTODO\nSUMMARY:Call Mom\nEND:VTODO\nEND:VCALENDAR\n",
+			want: []importLine{
+				{line: 2, err: errors.New("UID is required")},
+			},
+		},
+		{
+			name:      "04_iCalendarでないケース",
+			data:      "title,done\nCall Mom,true\n",
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Act
+			lines, err := parseImportICalendar([]byte(tt.data))
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if tt.wantError {
+				return
+			}
+			if len(lines) != len(tt.want) {
+				t.Fatalf("expected: %+v, actual: %+v", tt.want, lines)
+			}
+			for i := range lines {
+				if lines[i].line != tt.want[i].line || lines[i].uid != tt.want[i].uid || !reflect.DeepEqual(lines[i].toDo, tt.want[i].toDo) {
+					t.Errorf("expected: %+v, actual: %+v", tt.want[i], lines[i])
+				}
+				if (lines[i].err == nil) != (tt.want[i].err == nil) || (lines[i].err != nil && lines[i].err.Error() != tt.want[i].err.Error()) {
+					t.Errorf("expected error: %v, actual: %v", tt.want[i].err, lines[i].err)
+				}
+			}
+		})
+	}
+}
+
+func TestImportICalendar(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	data := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VTODO\r\nUID:100@todo-rest-api-golang\r\nSUMMARY:Buy a new pencil\r\nSTATUS:COMPLETED\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nUID:known@example.com\r\nSUMMARY:Go to the cinema\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nUID:new@example.com\r\nSUMMARY:Call Mom\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nUID:purged@example.com\r\nSUMMARY:Water the plants\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nUID:500@todo-rest-api-golang\r\nSUMMARY:Pay the rent\r\nEND:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+	ctrl := gomock.NewController(t)
+	mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+	mockToDoRepository.EXPECT().SelectIdByUid("100@todo-rest-api-golang").Return(int64(0), nil)
+	mockToDoRepository.EXPECT().SelectIdByUid("known@example.com").Return(int64(200), nil)
+	mockToDoRepository.EXPECT().SelectIdByUid("new@example.com").Return(int64(0), nil)
+	mockToDoRepository.EXPECT().SelectIdByUid("purged@example.com").Return(int64(400), nil)
+	mockToDoRepository.EXPECT().SelectIdByUid("500@todo-rest-api-golang").Return(int64(0), nil)
+	mockToDoRepository.EXPECT().SelectByIds([]int64{100}).Return([]model.ToDo{{Id: 100}}, nil)
+	mockToDoRepository.EXPECT().SelectByIds([]int64{200}).Return([]model.ToDo{{Id: 200}}, nil)
+	// 削除されたToDoのUIDは新しいToDoとして作成する
+	mockToDoRepository.EXPECT().SelectByIds([]int64{400}).Return(nil, nil)
+	mockToDoRepository.EXPECT().SelectByIds([]int64{500}).Return(nil, nil)
+	mockToDoRepository.EXPECT().SelectByIdForUpdate(int64(100)).Return(&model.ToDo{Id: 100, Title: "Buy a pencil", Status: "todo"}, nil)
+	mockToDoRepository.EXPECT().SelectByIdForUpdate(int64(200)).Return(&model.ToDo{Id: 200, Title: "Go to the cinema", Status: "in_progress"}, nil)
+	var operations []model.BatchOperation
+	mockToDoRepository.EXPECT().Batch(gomock.Any(), true).DoAndReturn(func(o []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+		operations = o
+		return []model.BatchResult{{Id: 100}, {Id: 200}, {Id: 300}, {Id: 301}, {Id: 302}}, nil
+	})
+	mockToDoRepository.EXPECT().InsertUid(int64(300), "new@example.com").Return(nil)
+	mockToDoRepository.EXPECT().InsertUid(int64(301), "purged@example.com").Return(nil)
+	mockToDoRepository.EXPECT().InsertUid(int64(302), "500@todo-rest-api-golang").Return(nil)
+	toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+	// Act
+	result, err := toDoService.Import(&ImportObject{Format: "ics", Data: []byte(data)})
+
+	// Assert
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := &ImportResultObject{Total: 5, Valid: 5, Imported: 3, Updated: 2, Ids: []int64{100, 200, 300, 301, 302}, Errors: []ImportErrorObject{}}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("expected: %+v, actual: %+v", want, result)
+	}
+	wantOperations := []struct {
+		operation string
+		title     string
+		status    string
+	}{
+		{"update", "Buy a new pencil", "done"},
+		{"update", "Go to the cinema", "in_progress"},
+		{"create", "Call Mom", "todo"},
+		{"create", "Water the plants", "todo"},
+		{"create", "Pay the rent", "todo"},
+	}
+	for i, o := range operations {
+		if o.Operation != wantOperations[i].operation || o.ToDo.Title != wantOperations[i].title || o.ToDo.Status != wantOperations[i].status {
+			t.Errorf("expected: %+v, actual: %+v", wantOperations[i], o.ToDo)
+		}
+	}
+}
+
+func TestImportICalendarDuplicateUid(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	vtodo := "BEGIN:VTODO\r\nUID:new@example.com\r\nSUMMARY:Call Mom\r\nEND:VTODO\r\n"
+	data := "BEGIN:VCALENDAR\r\n" + vtodo + vtodo + "END:VCALENDAR\r\n"
+	ctrl := gomock.NewController(t)
+	mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+	mockToDoRepository.EXPECT().SelectIdByUid("new@example.com").Return(int64(0), nil)
+	// エラーがあるので何も書き込まない
+	mockToDoRepository.EXPECT().Batch(gomock.Any(), gomock.Any()).Times(0)
+	toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+	// Act
+	result, err := toDoService.Import(&ImportObject{Format: "ics", Data: []byte(data)})
+
+	// Assert
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := &ImportResultObject{Total: 2, Valid: 1, Ids: []int64{}, Errors: []ImportErrorObject{
+		{Line: 6, Error: `duplicate UID "new@example.com" (the same as line 2)`},
+	}}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("expected: %+v, actual: %+v", want, result)
+	}
+}
+
+func TestPutICalendar(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	vtodo := func(uid string) string {
+		return "BEGIN:VTODO\r\nUID:" + uid + "\r\nSUMMARY:Buy a new pencil\r\nEND:VTODO\r\n"
+	}
+	tests := []struct {
+		name            string
+		uid             string
+		data            string
+		selectedId      int64
+		selectedExists  bool
+		batchResult     []model.BatchResult
+		expectedCreated bool
+		expectedError   error
+	}{
+		{
+			name:            "01_新しいUIDのToDoを作成するケース",
+			uid:             "new@example.com",
+			data:            "BEGIN:VCALENDAR\r\n" + vtodo("new@example.com") + "END:VCALENDAR\r\n",
+			selectedId:      0,
+			batchResult:     []model.BatchResult{{Id: 300}},
+			expectedCreated: true,
+		},
+		{
+			name:           "02_記録されたUIDのToDoを更新するケース",
+			uid:            "known@example.com",
+			data:           "BEGIN:VCALENDAR\r\n" + vtodo("known@example.com") + "END:VCALENDAR\r\n",
+			selectedId:     300,
+			selectedExists: true,
+			batchResult:    []model.BatchResult{{Id: 300}},
+		},
+		{
+			name:          "03_UIDがリソースと一致しないケース",
+			uid:           "other@example.com",
+			data:          "BEGIN:VCALENDAR\r\n" + vtodo("new@example.com") + "END:VCALENDAR\r\n",
+			expectedError: ErrInvalidImport,
+		},
+		{
+			name:          "04_VTODOが2件あるケース",
+			uid:           "new@example.com",
+			data:          "BEGIN:VCALENDAR\r\n" + vtodo("new@example.com") + vtodo("new@example.com") + "END:VCALENDAR\r\n",
+			expectedError: ErrInvalidImport,
+		},
+		{
+			name:            "05_記録されたUIDのToDoが削除されているケース",
+			uid:             "purged@example.com",
+			data:            "BEGIN:VCALENDAR\r\n" + vtodo("purged@example.com") + "END:VCALENDAR\r\n",
+			selectedId:      400,
+			selectedExists:  false,
+			batchResult:     []model.BatchResult{{Id: 300}},
+			expectedCreated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			if tt.batchResult != nil {
+				mockToDoRepository.EXPECT().SelectIdByUid(tt.uid).Return(tt.selectedId, nil)
+				if tt.selectedId != 0 {
+					var selected []model.ToDo
+					if tt.selectedExists {
+						selected = []model.ToDo{{Id: tt.selectedId}}
+						mockToDoRepository.EXPECT().SelectByIdForUpdate(tt.selectedId).Return(&model.ToDo{Id: tt.selectedId, Title: "Buy a pencil", Status: "todo"}, nil)
+					}
+					mockToDoRepository.EXPECT().SelectByIds([]int64{tt.selectedId}).Return(selected, nil)
+				}
+				mockToDoRepository.EXPECT().Batch(gomock.Any(), true).Return(tt.batchResult, nil)
+				if tt.expectedCreated {
+					mockToDoRepository.EXPECT().InsertUid(int64(300), tt.uid).Return(nil)
+				}
+				mockToDoRepository.EXPECT().SelectById(int64(300)).Return(&model.ToDo{Id: 300, Title: "Buy a new pencil", Status: "todo"}, nil)
+			}
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			toDo, created, err := toDoService.PutICalendar(&ICalendarObject{Uid: tt.uid, Data: []byte(tt.data)})
+
+			// Assert
+			if tt.expectedError != nil {
+				if !errors.Is(err, tt.expectedError) {
+					t.Errorf("expected: %v, actual: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if toDo.Id != 300 || toDo.Title != "Buy a new pencil" || created != tt.expectedCreated {
+				t.Errorf("unexpected result: %+v, created: %v", toDo, created)
+			}
+		})
+	}
+}
+
+func TestPutICalendarPrecondition(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	data := "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:known@example.com\r\nSUMMARY:Buy a new pencil\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+	tests := []struct {
+		name              string
+		preconditionError error
+		expectedError     error
+	}{
+		{
+			name: "01_条件を満たすケース",
+		},
+		{
+			name:              "02_条件を満たさないケース",
+			preconditionError: fmt.Errorf("%w: the resource has been changed", ErrPreconditionFailed),
+			expectedError:     ErrPreconditionFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			current := &model.ToDo{Id: 300, Title: "Buy a pencil", Status: "todo"}
+			// 条件の確認でロックし、更新するときにもう一度読む
+			writes := 1
+			if tt.preconditionError != nil {
+				writes = 0
+			}
+			mockToDoRepository.EXPECT().SelectIdByUid("known@example.com").Return(int64(300), nil).Times(1 + writes)
+			mockToDoRepository.EXPECT().SelectByIds([]int64{300}).Return([]model.ToDo{*current}, nil).Times(1 + writes)
+			mockToDoRepository.EXPECT().SelectByIdForUpdate(int64(300)).Return(current, nil).Times(1 + writes)
+			mockToDoRepository.EXPECT().Batch(gomock.Any(), true).Return([]model.BatchResult{{Id: 300}}, nil).Times(writes)
+			mockToDoRepository.EXPECT().SelectById(int64(300)).Return(&model.ToDo{Id: 300, Title: "Buy a new pencil", Status: "todo"}, nil).Times(writes)
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+			var checked *ToDoObject
+
+			// Act
+			_, _, err := toDoService.PutICalendar(&ICalendarObject{
+				Uid:  "known@example.com",
+				Data: []byte(data),
+				Precondition: func(toDo *ToDoObject) error {
+					checked = toDo
+					return tt.preconditionError
+				},
+			})
+
+			// Assert
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected: %v, actual: %v", tt.expectedError, err)
+			}
+			if checked == nil || checked.Title != "Buy a pencil" {
+				t.Errorf("unexpected current ToDo: %+v", checked)
+			}
+		})
+	}
+}
+
+func TestDeleteICalendar(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	tests := []struct {
+		name              string
+		uid               string
+		recordedId        int64
+		preconditionError error
+		deleteError       error
+		expectedDelete    bool
+		expectedError     error
+	}{
+		{
+			name:           "01_UIDのToDoを削除するケース",
+			uid:            "known@example.com",
+			recordedId:     300,
+			expectedDelete: true,
+		},
+		{
+			name:              "02_条件を満たさないケース",
+			uid:               "known@example.com",
+			recordedId:        300,
+			preconditionError: fmt.Errorf("%w: the resource has been changed", ErrPreconditionFailed),
+			expectedError:     ErrPreconditionFailed,
+		},
+		{
+			name:          "03_UIDのToDoがないケース",
+			uid:           "unknown@example.com",
+			expectedError: sql.ErrNoRows,
+		},
+		{
+			name:           "04_DeleteByIdが失敗するケース",
+			uid:            "known@example.com",
+			recordedId:     300,
+			deleteError:    sql.ErrNoRows,
+			expectedDelete: true,
+			expectedError:  sql.ErrNoRows,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			current := &model.ToDo{Id: 300, Title: "Buy a pencil", Status: "todo"}
+			mockToDoRepository.EXPECT().SelectIdByUid(tt.uid).Return(tt.recordedId, nil)
+			if tt.recordedId != 0 {
+				mockToDoRepository.EXPECT().SelectByIds([]int64{tt.recordedId}).Return([]model.ToDo{*current}, nil)
+				mockToDoRepository.EXPECT().SelectByIdForUpdate(tt.recordedId).Return(current, nil)
+			}
+			if tt.expectedDelete {
+				history := &model.ToDoHistory{ToDoId: 300, Operation: "delete", Changes: []model.FieldChange{{Field: "deleted", Before: false, After: true}}, Actor: "alice"}
+				mockToDoRepository.EXPECT().DeleteById(int64(300), history).Return(tt.deleteError)
+			}
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+			var checked *ToDoObject
+
+			// Act
+			toDo, err := toDoService.DeleteICalendar(&ICalendarObject{
+				Uid: tt.uid,
+				Precondition: func(toDo *ToDoObject) error {
+					checked = toDo
+					return tt.preconditionError
+				},
+				Actor: "alice",
+			})
+
+			// Assert
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected: %v, actual: %v", tt.expectedError, err)
+			}
+			if tt.recordedId != 0 && (checked == nil || checked.Title != "Buy a pencil") {
+				t.Errorf("unexpected current ToDo: %+v", checked)
+			}
+			if tt.expectedError == nil && (toDo == nil || toDo.Id != 300) {
+				t.Errorf("unexpected result: %+v", toDo)
+			}
+		})
+	}
+}
+
+func TestReadICalendar(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	// Prepare
+	archivedAt := time.Date(2021, 6, 15, 0, 40, 10, 0, time.UTC)
+	tests := []struct {
+		name         string
+		uid          string
+		recordedId   int64
+		selected     []model.ToDo
+		expectedToDo bool
+	}{
+		{
+			name:         "01_記録されたUIDのToDoを読むケース",
+			uid:          "known@example.com",
+			recordedId:   300,
+			selected:     []model.ToDo{{Id: 300, Title: "Buy a new pencil", Status: "todo"}},
+			expectedToDo: true,
+		},
+		{
+			name:         "02_このAPIが払い出したUIDのToDoを読むケース",
+			uid:          "300@todo-rest-api-golang",
+			selected:     []model.ToDo{{Id: 300, Title: "Buy a new pencil", Status: "todo"}},
+			expectedToDo: true,
+		},
+		{
+			name:       "03_アーカイブされたToDoのケース",
+			uid:        "known@example.com",
+			recordedId: 300,
+			selected:   []model.ToDo{{Id: 300, Title: "Buy a new pencil", Status: "todo", ArchivedAt: &archivedAt}},
+		},
+		{
+			name:       "04_ToDoが削除されているケース",
+			uid:        "known@example.com",
+			recordedId: 300,
+			selected:   []model.ToDo{},
+		},
+		{
+			name: "05_UIDがないケース",
+			uid:  "unknown@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			mockToDoRepository.EXPECT().SelectIdByUid(tt.uid).Return(tt.recordedId, nil)
+			if tt.selected != nil {
+				mockToDoRepository.EXPECT().SelectByIds([]int64{300}).Return(tt.selected, nil)
+			}
+			toDoService := NewToDoService(mockToDoRepository, nil, model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			toDo, err := toDoService.ReadICalendar(tt.uid)
+
+			// Assert
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if (toDo != nil) != tt.expectedToDo || (toDo != nil && toDo.Id != 300) {
+				t.Errorf("unexpected result: %+v", toDo)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"log"
+	"time"
+)
+
+// Periodically deletes the Idempotency-Key older than the TTL
+type IdempotencyPurger struct {
+	*periodicJob
+	service IdempotencyService
+}
+
+func NewIdempotencyPurger(service IdempotencyService, interval time.Duration) *IdempotencyPurger {
+	p := &IdempotencyPurger{
+		service: service,
+	}
+	p.periodicJob = newPeriodicJob(interval, p.Purge)
+	return p
+}
+
+// Purge once
+func (p *IdempotencyPurger) Purge() {
+	purged, err := p.service.Purge()
+	if err != nil {
+		log.Println("failed to purge idempotency keys:", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("purged %d idempotency keys", purged)
+	}
+}
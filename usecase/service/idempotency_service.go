@@ -0,0 +1,98 @@
+//go:generate mockgen -source=$GOFILE -destination=mock_$GOPACKAGE/mock_$GOFILE -package=mock_$GOPACKAGE
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
+)
+
+type IdempotencyService interface {
+	// Start the request with the key and the hash of the request.
+	// If the request with the key has already completed, its response is returned and the request must not be processed again.
+	Start(string, string) (*IdempotentResponse, error)
+	// Store the response of the request with the key
+	Complete(string, *IdempotentResponse) error
+	// Forget the key so that the request can be retried
+	Cancel(string) error
+	// Delete the keys older than the TTL
+	Purge() (int64, error)
+}
+
+var (
+	ErrIdempotencyKeyReused  = errors.New("idempotency key is already used for a different request")
+	ErrIdempotencyInProgress = errors.New("request with the idempotency key is in progress")
+)
+
+type idempotencyService struct {
+	repository repository.IdempotencyRepository
+	ttl        time.Duration
+}
+
+func NewIdempotencyService(repository repository.IdempotencyRepository, ttl time.Duration) IdempotencyService {
+	return &idempotencyService{
+		repository: repository,
+		ttl:        ttl,
+	}
+}
+
+func (s *idempotencyService) Start(key string, requestHash string) (*IdempotentResponse, error) {
+	record := &model.IdempotencyRecord{Key: key, RequestHash: requestHash}
+	inserted, err := s.repository.Insert(record)
+	if err != nil {
+		return nil, err
+	}
+	if inserted {
+		return nil, nil
+	}
+
+	existing, err := s.repository.SelectByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	// 期限切れのキーは新しいリクエストとして使い直す
+	if existing == nil || existing.CreatedAt.Before(time.Now().Add(-s.ttl)) {
+		err = s.repository.DeleteByKey(key)
+		if err != nil {
+			return nil, err
+		}
+		inserted, err = s.repository.Insert(record)
+		if err != nil {
+			return nil, err
+		}
+		if !inserted {
+			return nil, ErrIdempotencyInProgress
+		}
+		return nil, nil
+	}
+	if existing.RequestHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if existing.StatusCode == 0 {
+		return nil, ErrIdempotencyInProgress
+	}
+	return &IdempotentResponse{
+		StatusCode:  existing.StatusCode,
+		ContentType: existing.ContentType,
+		Body:        existing.Body,
+	}, nil
+}
+
+func (s *idempotencyService) Complete(key string, response *IdempotentResponse) error {
+	return s.repository.Update(&model.IdempotencyRecord{
+		Key:         key,
+		StatusCode:  response.StatusCode,
+		ContentType: response.ContentType,
+		Body:        response.Body,
+	})
+}
+
+func (s *idempotencyService) Cancel(key string) error {
+	return s.repository.DeleteByKey(key)
+}
+
+func (s *idempotencyService) Purge() (int64, error) {
+	return s.repository.DeleteCreatedBefore(time.Now().Add(-s.ttl))
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository/mock_repository"
+)
+
+func TestIdempotencyStart(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	ttl := 24 * time.Hour
+	tests := []struct {
+		name         string
+		inserted     []bool
+		existing     *model.IdempotencyRecord
+		deleteTimes  int
+		wantResponse bool
+		wantError    error
+	}{
+		{
+			name:         "01_新しいキーのケース",
+			inserted:     []bool{true},
+			wantResponse: false,
+			wantError:    nil,
+		},
+		{
+			name:         "02_完了したリクエストのキーのケース",
+			inserted:     []bool{false},
+			existing:     &model.IdempotencyRecord{Key: "key-1", RequestHash: "hash-1", StatusCode: 200, Body: []byte(`{"id":1}`), CreatedAt: time.Now()},
+			wantResponse: true,
+			wantError:    nil,
+		},
+		{
+			name:      "03_別のリクエストで使われたキーのケース",
+			inserted:  []bool{false},
+			existing:  &model.IdempotencyRecord{Key: "key-1", RequestHash: "hash-2", StatusCode: 200, CreatedAt: time.Now()},
+			wantError: ErrIdempotencyKeyReused,
+		},
+		{
+			name:      "04_処理中のリクエストのキーのケース",
+			inserted:  []bool{false},
+			existing:  &model.IdempotencyRecord{Key: "key-1", RequestHash: "hash-1", StatusCode: 0, CreatedAt: time.Now()},
+			wantError: ErrIdempotencyInProgress,
+		},
+		{
+			name:         "05_期限切れのキーを使い直すケース",
+			inserted:     []bool{false, true},
+			existing:     &model.IdempotencyRecord{Key: "key-1", RequestHash: "hash-2", StatusCode: 200, CreatedAt: time.Now().Add(-2 * ttl)},
+			deleteTimes:  1,
+			wantResponse: false,
+			wantError:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockIdempotencyRepository := mock_repository.NewMockIdempotencyRepository(ctrl)
+			calls := []*gomock.Call{}
+			for _, inserted := range tt.inserted {
+				calls = append(calls, mockIdempotencyRepository.EXPECT().Insert(gomock.Any()).Return(inserted, nil))
+			}
+			gomock.InOrder(calls...)
+			mockIdempotencyRepository.EXPECT().SelectByKey("key-1").Return(tt.existing, nil).AnyTimes()
+			mockIdempotencyRepository.EXPECT().DeleteByKey("key-1").Return(nil).Times(tt.deleteTimes)
+			idempotencyService := NewIdempotencyService(mockIdempotencyRepository, ttl)
+
+			// Act
+			response, err := idempotencyService.Start("key-1", "hash-1")
+
+			// Assert
+			if !errors.Is(err, tt.wantError) {
+				t.Errorf("expected: %v, actual: %v", tt.wantError, err)
+			}
+			if (response != nil) != tt.wantResponse {
+				t.Errorf("expected response: %v, actual: %v", tt.wantResponse, response)
+			}
+			if response != nil && (response.StatusCode != tt.existing.StatusCode || string(response.Body) != string(tt.existing.Body)) {
+				t.Errorf("response does not match. expected: %v, actual: %v", tt.existing, response)
+			}
+		})
+	}
+}
+
+func TestIdempotencyComplete(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	mockIdempotencyRepository := mock_repository.NewMockIdempotencyRepository(ctrl)
+	var stored *model.IdempotencyRecord
+	mockIdempotencyRepository.EXPECT().Update(gomock.Any()).DoAndReturn(func(record *model.IdempotencyRecord) error {
+		stored = record
+		return nil
+	}).Times(1)
+	idempotencyService := NewIdempotencyService(mockIdempotencyRepository, time.Hour)
+
+	// Act
+	err := idempotencyService.Complete("key-1", &IdempotentResponse{StatusCode: 200, ContentType: "application/json", Body: []byte(`{"id":1}`)})
+
+	// Assert
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if stored.Key != "key-1" || stored.StatusCode != 200 || stored.ContentType != "application/json" {
+		t.Errorf("unexpected record: %+v", stored)
+	}
+}
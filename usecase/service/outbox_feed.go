@@ -0,0 +1,62 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
+)
+
+// Periodically reads the events published by the relay of any server from the outbox,
+// and notifies them to the subscribers of this server through the broker
+type OutboxFeed struct {
+	*periodicJob
+	repository repository.OutboxRepository
+	broker     *EventBroker
+	// sequence number of the last event read
+	last    int64
+	started bool
+}
+
+func NewOutboxFeed(repository repository.OutboxRepository, broker *EventBroker, interval time.Duration) *OutboxFeed {
+	f := &OutboxFeed{
+		repository: repository,
+		broker:     broker,
+	}
+	f.periodicJob = newPeriodicJob(interval, func() { f.Read() })
+	return f
+}
+
+// Notify the events published after the last read and return the number of them
+func (f *OutboxFeed) Read() int {
+	if !f.started {
+		last, err := f.repository.LastSequence()
+		if err != nil {
+			log.Println("failed to read the outbox:", err)
+			return 0
+		}
+		// 再接続した購読者に再送できるよう、バッファに入るだけ前のイベントから読む
+		f.last = last - int64(len(f.broker.buffer))
+		if f.last < 0 {
+			f.last = 0
+		}
+		f.started = true
+	}
+
+	read := 0
+	for {
+		events, err := f.repository.ListPublished(f.last, outboxBatchSize)
+		if err != nil {
+			log.Println("failed to read the outbox:", err)
+			return read
+		}
+		for i := range events {
+			f.broker.PublishEvent(outboxEventToObject(&events[i]))
+			f.last = events[i].Sequence
+		}
+		read += len(events)
+		if len(events) < outboxBatchSize || f.stopping() {
+			return read
+		}
+	}
+}
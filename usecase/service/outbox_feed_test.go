@@ -0,0 +1,49 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository/mock_repository"
+)
+
+func TestOutboxFeed(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	mockOutboxRepository := mock_repository.NewMockOutboxRepository(ctrl)
+	published := func(sequence int64) model.OutboxEvent {
+		return model.OutboxEvent{Id: sequence + 10, EventType: "updated", ToDo: model.ToDo{Id: 100}, Sequence: sequence}
+	}
+	gomock.InOrder(
+		mockOutboxRepository.EXPECT().LastSequence().Return(int64(0), errors.New("SELECT FAILED")),
+		mockOutboxRepository.EXPECT().LastSequence().Return(int64(5), nil),
+		// 起動前のイベントもバッファに入るだけ読む
+		mockOutboxRepository.EXPECT().ListPublished(int64(2), outboxBatchSize).Return([]model.OutboxEvent{published(3), published(4), published(5)}, nil),
+		mockOutboxRepository.EXPECT().ListPublished(int64(5), outboxBatchSize).Return([]model.OutboxEvent{published(6)}, nil),
+	)
+	broker := NewEventBroker(3)
+	feed := NewOutboxFeed(mockOutboxRepository, broker, time.Second)
+
+	// Act
+	failed := feed.Read()
+	read := feed.Read()
+	subscription := broker.Subscribe(3)
+	defer subscription.Close()
+	next := feed.Read()
+
+	// Assert
+	if failed != 0 || read != 3 || next != 1 {
+		t.Errorf("expected: 0, 3, 1, actual: %d, %d, %d", failed, read, next)
+	}
+	if len(subscription.Missed) != 2 || subscription.Missed[0].Id != 4 || subscription.Missed[1].Id != 5 || subscription.Gap {
+		t.Errorf("expected: [4 5], actual: %+v (gap: %v)", subscription.Missed, subscription.Gap)
+	}
+	if event := <-subscription.Events; event.Id != 6 || event.ToDo.Id != 100 {
+		t.Errorf("unexpected event: %+v", event)
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
+)
+
+// number of events read from the outbox at once
+const outboxBatchSize = 100
+
+// Periodically publishes the events written to the outbox, in the order they were written.
+// The relays of all servers share the outbox, and each event is published by one of them
+type OutboxRelay struct {
+	repository repository.OutboxRepository
+	publisher  EventPublisher
+	retention  time.Duration
+	relayJob   *periodicJob
+	purgeJob   *periodicJob
+}
+
+func NewOutboxRelay(repository repository.OutboxRepository, publisher EventPublisher, interval time.Duration, retention time.Duration, purgeInterval time.Duration) *OutboxRelay {
+	r := &OutboxRelay{
+		repository: repository,
+		publisher:  publisher,
+		retention:  retention,
+	}
+	r.relayJob = newPeriodicJob(interval, func() { r.Relay() })
+	r.purgeJob = newPeriodicJob(purgeInterval, r.Purge)
+	return r
+}
+
+// Start relaying and purging in the background
+func (r *OutboxRelay) Start() {
+	r.relayJob.Start()
+	r.purgeJob.Start()
+}
+
+// Stop relaying and purging, and wait for the events being published
+func (r *OutboxRelay) Stop() {
+	r.relayJob.Stop()
+	r.purgeJob.Stop()
+}
+
+// Publish the events not published yet and return the number of published events
+// 失敗したイベント以降は次回に回し、順序を保ったまま少なくとも1回は配信する
+func (r *OutboxRelay) Relay() int {
+	published := 0
+	for {
+		n, err := r.repository.RelayUnpublished(outboxBatchSize, r.publish)
+		published += n
+		if err != nil {
+			log.Println("failed to relay the outbox:", err)
+			return published
+		}
+		if n < outboxBatchSize || r.relayJob.stopping() {
+			return published
+		}
+	}
+}
+
+func (r *OutboxRelay) publish(e *model.OutboxEvent) error {
+	err := r.publisher.PublishEvent(outboxEventToObject(e))
+	if err != nil {
+		return fmt.Errorf("publish the event %d: %w", e.Sequence, err)
+	}
+	return nil
+}
+
+// Delete the events published before the retention period
+func (r *OutboxRelay) Purge() {
+	purged, err := r.repository.DeletePublishedBefore(time.Now().Add(-r.retention))
+	if err != nil {
+		log.Println("failed to purge the outbox:", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("purged %d published events", purged)
+	}
+}
+
+// 配信の順序をイベントIDにする
+func outboxEventToObject(e *model.OutboxEvent) *EventObject {
+	return &EventObject{
+		Id:        e.Sequence,
+		Type:      e.EventType,
+		ToDo:      *modelToObject(&e.ToDo),
+		Completed: e.Completed,
+		CreatedAt: e.CreatedAt,
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository/mock_repository"
+)
+
+// publisher recording the events, failing with the errors in order
+type recordingPublisher struct {
+	events []EventObject
+	errors []error
+}
+
+func (p *recordingPublisher) PublishEvent(event *EventObject) error {
+	if len(p.errors) > 0 {
+		err := p.errors[0]
+		p.errors = p.errors[1:]
+		if err != nil {
+			return err
+		}
+	}
+	p.events = append(p.events, *event)
+	return nil
+}
+
+// outboxのイベントに配信順の番号を付けて渡し、失敗したところで止めるリポジトリ
+func relayOutbox(outbox []model.OutboxEvent, relayError error) func(int, func(*model.OutboxEvent) error) (int, error) {
+	return func(limit int, publish func(*model.OutboxEvent) error) (int, error) {
+		if relayError != nil {
+			return 0, relayError
+		}
+		for i := range outbox {
+			event := outbox[i]
+			event.Sequence = int64(i + 1)
+			err := publish(&event)
+			if err != nil {
+				return i, err
+			}
+		}
+		return len(outbox), nil
+	}
+}
+
+func TestOutboxRelay(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	outbox := []model.OutboxEvent{
+		{Id: 11, EventType: "created", ToDo: model.ToDo{Id: 100, Title: "test-ToDo", Status: "todo"}},
+		{Id: 12, EventType: "updated", Completed: true, ToDo: model.ToDo{Id: 100, Title: "test-ToDo", Status: "done", Done: true}},
+		{Id: 14, EventType: "deleted", ToDo: model.ToDo{Id: 100, Title: "test-ToDo", Status: "done", Done: true}},
+	}
+	tests := []struct {
+		name          string
+		relayError    error
+		publishErrors []error
+		wantPublished []int64
+	}{
+		{
+			name:          "01_全件配信するケース",
+			wantPublished: []int64{1, 2, 3},
+		},
+		{
+			name:          "02_途中で配信に失敗するケース",
+			publishErrors: []error{nil, errors.New("Publish ERROR")},
+			wantPublished: []int64{1},
+		},
+		{
+			name:          "03_outboxの読み込みに失敗するケース",
+			relayError:    errors.New("Relay ERROR"),
+			wantPublished: []int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockOutboxRepository := mock_repository.NewMockOutboxRepository(ctrl)
+			mockOutboxRepository.EXPECT().RelayUnpublished(outboxBatchSize, gomock.Any()).DoAndReturn(relayOutbox(outbox, tt.relayError)).Times(1)
+			publisher := &recordingPublisher{errors: tt.publishErrors}
+			relay := NewOutboxRelay(mockOutboxRepository, publisher, time.Second, time.Hour, time.Hour)
+
+			// Act
+			published := relay.Relay()
+
+			// Assert
+			if published != len(tt.wantPublished) || len(publisher.events) != len(tt.wantPublished) {
+				t.Fatalf("expected published: %v, actual: %d, %+v", tt.wantPublished, published, publisher.events)
+			}
+			// outboxのIDではなく配信順の番号がイベントIDになる
+			for i, id := range tt.wantPublished {
+				if publisher.events[i].Id != id {
+					t.Errorf("expected order: %v, actual: %+v", tt.wantPublished, publisher.events)
+				}
+			}
+			if len(publisher.events) > 1 && (publisher.events[1].Type != "updated" || !publisher.events[1].Completed || !publisher.events[1].ToDo.Done) {
+				t.Errorf("unexpected event: %+v", publisher.events[1])
+			}
+		})
+	}
+}
+
+func TestOutboxRelayBatches(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	mockOutboxRepository := mock_repository.NewMockOutboxRepository(ctrl)
+	// 一度に読める数だけ配信した場合は続けて読む
+	gomock.InOrder(
+		mockOutboxRepository.EXPECT().RelayUnpublished(outboxBatchSize, gomock.Any()).Return(outboxBatchSize, nil),
+		mockOutboxRepository.EXPECT().RelayUnpublished(outboxBatchSize, gomock.Any()).Return(1, nil),
+	)
+	relay := NewOutboxRelay(mockOutboxRepository, &recordingPublisher{}, time.Second, time.Hour, time.Hour)
+
+	// Act
+	published := relay.Relay()
+
+	// Assert
+	if published != outboxBatchSize+1 {
+		t.Errorf("expected: %d, actual: %d", outboxBatchSize+1, published)
+	}
+}
+
+func TestEventPublishers(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	broker := NewEventBroker(10)
+	subscription := broker.Subscribe(0)
+	defer subscription.Close()
+	failing := &recordingPublisher{errors: []error{errors.New("Publish ERROR")}}
+	publishers := EventPublishers{broker, failing}
+
+	// Act
+	err := publishers.PublishEvent(&EventObject{Id: 10, Type: "updated", ToDo: ToDoObject{Id: 100}, Completed: true})
+
+	// Assert
+	if err == nil {
+		t.Error("expected the error of the failing publisher")
+	}
+	event := <-subscription.Events
+	if event.Type != "updated" || event.ToDo.Id != 100 || !event.Completed {
+		t.Errorf("unexpected event: %+v", event)
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import "time"
+
+// Runs a job at a fixed interval in the background
+type periodicJob struct {
+	interval time.Duration
+	run      func()
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+func newPeriodicJob(interval time.Duration, run func()) *periodicJob {
+	return &periodicJob{
+		interval: interval,
+		run:      run,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// Start running the job in the background
+func (j *periodicJob) Start() {
+	go func() {
+		defer close(j.stopped)
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				j.run()
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop running the job and wait for the running one
+func (j *periodicJob) Stop() {
+	close(j.stop)
+	<-j.stopped
+}
+
+// 停止を求められているか(長く続く処理を途中で切り上げるため)
+func (j *periodicJob) stopping() bool {
+	select {
+	case <-j.stop:
+		return true
+	default:
+		return false
+	}
+}
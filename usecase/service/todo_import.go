@@ -0,0 +1,183 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats accepted by Import
+const (
+	ImportFormatCSV     = "csv"
+	ImportFormatJSON    = "json"
+	ImportFormatToDoTxt = "todotxt"
+	ImportFormatICal    = "ics"
+)
+
+// ToDo read from a line of the imported data
+type importLine struct {
+	// line number (CSV, todo.txt) or 1-based index of the array (JSON)
+	line int
+	toDo ToDoObject
+	// iCalendar UID, the ToDo of the UID is updated if it exists
+	uid string
+	// fields of the line that are read but not stored
+	warning string
+	err     error
+}
+
+// 形式に応じてデータを1件ずつのToDoに分ける
+func parseImport(format string, data []byte) ([]importLine, error) {
+	switch format {
+	case ImportFormatCSV:
+		return parseImportCSV(data)
+	case ImportFormatJSON:
+		return parseImportJSON(data)
+	case ImportFormatToDoTxt:
+		return parseImportToDoTxt(data), nil
+	case ImportFormatICal:
+		return parseImportICalendar(data)
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidImport, format)
+	}
+}
+
+// 1行目をヘッダ(title, status, done)として読む。それ以外の列は無視する
+func parseImportCSV(data []byte) ([]importLine, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot read the CSV header: %v", ErrInvalidImport, err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, fmt.Errorf("%w: the CSV header must contain title", ErrInvalidImport)
+	}
+	value := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	lines := []importLine{}
+	for number := 2; ; number++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line := importLine{line: number}
+		var parseError *csv.ParseError
+		if errors.As(err, &parseError) {
+			line.err = parseError.Err
+			lines = append(lines, line)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		line.toDo.Title = value(record, "title")
+		line.toDo.Status = value(record, "status")
+		if done := value(record, "done"); done != "" {
+			line.toDo.Done, err = strconv.ParseBool(done)
+			if err != nil {
+				line.err = fmt.Errorf("done must be true or false: %q", done)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// ToDoの配列として読む(idなど作成時に指定できない項目は無視する)
+func parseImportJSON(data []byte) ([]importLine, error) {
+	var items []json.RawMessage
+	err := json.Unmarshal(data, &items)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the JSON must be an array of ToDo: %v", ErrInvalidImport, err)
+	}
+	lines := []importLine{}
+	for i, item := range items {
+		line := importLine{line: i + 1}
+		line.err = json.Unmarshal(item, &line.toDo)
+		line.toDo = ToDoObject{Title: line.toDo.Title, Status: line.toDo.Status, Done: line.toDo.Done}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+var (
+	toDoTxtPriority = regexp.MustCompile(`^\([A-Z]\)$`)
+	toDoTxtDate     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// todo.txt形式(https://github.com/todotxt/todo.txt)として1行ずつ読む
+// 優先度と日付は検証するが保存せず、警告として返す。+projectと@contextはタイトルに残す
+func parseImportToDoTxt(data []byte) []importLine {
+	lines := []importLine{}
+	for i, text := range strings.Split(string(data), "\n") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		line := importLine{line: i + 1}
+		toDo, warning, err := parseToDoTxtLine(text)
+		if err != nil {
+			line.err = err
+		} else {
+			line.toDo = *toDo
+			line.warning = warning
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// e.g. "x 2021-06-15 2021-06-01 Buy a new pencil +shopping @store"
+// 保存しない優先度と日付があれば警告も返す
+func parseToDoTxtLine(text string) (*ToDoObject, string, error) {
+	fields := strings.Fields(text)
+	toDo := &ToDoObject{}
+	dropped := []string{}
+	// 完了済みの場合は完了日、作成日の順に2つ、未完了の場合は作成日だけ
+	dateNames := []string{"creation date"}
+	if fields[0] == "x" {
+		toDo.Done = true
+		dateNames = []string{"completion date", "creation date"}
+		fields = fields[1:]
+	} else if toDoTxtPriority.MatchString(fields[0]) {
+		dropped = append(dropped, "priority "+fields[0])
+		fields = fields[1:]
+	}
+	var dates []time.Time
+	for len(fields) > 0 && len(dates) < len(dateNames) && toDoTxtDate.MatchString(fields[0]) {
+		date, err := time.Parse("2006-01-02", fields[0])
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid date %q", fields[0])
+		}
+		dropped = append(dropped, dateNames[len(dates)]+" "+fields[0])
+		dates = append(dates, date)
+		fields = fields[1:]
+	}
+	if len(dates) == 2 && dates[0].Before(dates[1]) {
+		return nil, "", errors.New("completion date is before the creation date")
+	}
+	toDo.Title = strings.Join(fields, " ")
+	if len(dropped) == 0 {
+		return toDo, "", nil
+	}
+	return toDo, "not stored: " + strings.Join(dropped, ", "), nil
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository/mock_repository"
+)
+
+func TestImport(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name         string
+		toDoImport   *ImportObject
+		batchTimes   int
+		batchError   error
+		wantTitles   []string
+		wantStatuses []string
+		wantResult   *ImportResultObject
+		wantError    error
+	}{
+		{
+			name: "01_CSVをインポートするケース",
+			toDoImport: &ImportObject{Format: "csv", Data: []byte(
+				"title,done,notes\n" +
+					"Buy a new pencil,true,HB\n" +
+					"\"Go to the cinema, with Bob\",false,\n",
+			)},
+			batchTimes:   1,
+			wantTitles:   []string{"Buy a new pencil", "Go to the cinema, with Bob"},
+			wantStatuses: []string{"done", "todo"},
+			wantResult:   &ImportResultObject{Total: 2, Valid: 2, Imported: 2, Ids: []int64{1, 2}, Errors: []ImportErrorObject{}},
+		},
+		{
+			name:         "02_JSONをインポートするケース",
+			toDoImport:   &ImportObject{Format: "json", Data: []byte(`[{"id": 100, "title": "Buy a new pencil", "status": "in_progress"}, {"title": "Go to the cinema"}]`)},
+			batchTimes:   1,
+			wantTitles:   []string{"Buy a new pencil", "Go to the cinema"},
+			wantStatuses: []string{"in_progress", "todo"},
+			wantResult:   &ImportResultObject{Total: 2, Valid: 2, Imported: 2, Ids: []int64{1, 2}, Errors: []ImportErrorObject{}},
+		},
+		{
+			name: "03_todo.txtをインポートするケース",
+			toDoImport: &ImportObject{Format: "todotxt", Data: []byte(
+				"x 2021-06-15 2021-06-01 Buy a new pencil +shopping @store\n" +
+					"\n" +
+					"(A) 2021-06-01 Go to the cinema @town\n",
+			)},
+			batchTimes:   1,
+			wantTitles:   []string{"Buy a new pencil +shopping @store", "Go to the cinema @town"},
+			wantStatuses: []string{"done", "todo"},
+			wantResult: &ImportResultObject{Total: 2, Valid: 2, Imported: 2, Ids: []int64{1, 2}, Errors: []ImportErrorObject{}, Warnings: []ImportWarningObject{
+				{Line: 1, Warning: "not stored: completion date 2021-06-15, creation date 2021-06-01"},
+				{Line: 3, Warning: "not stored: priority (A), creation date 2021-06-01"},
+			}},
+		},
+		{
+			name:       "04_dry-runのケース",
+			toDoImport: &ImportObject{Format: "todotxt", Data: []byte("Buy a new pencil\n(B) 2021-13-01 Go to the cinema\n"), DryRun: true},
+			batchTimes: 0,
+			wantResult: &ImportResultObject{DryRun: true, Total: 2, Valid: 1, Ids: []int64{}, Errors: []ImportErrorObject{{Line: 2, Error: `invalid date "2021-13-01"`}}},
+		},
+		{
+			name:       "05_エラーがあり何も作成しないケース",
+			toDoImport: &ImportObject{Format: "csv", Data: []byte("title,status,done\nBuy a new pencil,,yes please\n,,\nGo to the cinema,hoge,\n")},
+			batchTimes: 0,
+			wantResult: &ImportResultObject{Total: 3, Valid: 0, Ids: []int64{}, Errors: []ImportErrorObject{
+				{Line: 2, Error: `done must be true or false: "yes please"`},
+				{Line: 3, Error: "title is required"},
+				{Line: 4, Error: "invalid status: hoge"},
+			}},
+		},
+		{
+			name:       "06_不明な形式のケース",
+			toDoImport: &ImportObject{Format: "xml", Data: []byte("<todo/>")},
+			batchTimes: 0,
+			wantError:  ErrInvalidImport,
+		},
+		{
+			name:       "07_CSVにtitle列がないケース",
+			toDoImport: &ImportObject{Format: "csv", Data: []byte("name,done\nBuy a new pencil,true\n")},
+			batchTimes: 0,
+			wantError:  ErrInvalidImport,
+		},
+		{
+			name:       "08_書き込みが失敗するケース",
+			toDoImport: &ImportObject{Format: "json", Data: []byte(`[{"title": "Buy a new pencil"}]`)},
+			batchTimes: 1,
+			batchError: errors.New("INSERT FAILED"),
+			wantError:  errors.New("INSERT FAILED"),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			var operations []model.BatchOperation
+			mockToDoRepository.EXPECT().Batch(gomock.Any(), true).DoAndReturn(func(o []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+				operations = o
+				if tt.batchError != nil {
+					return nil, tt.batchError
+				}
+				results := []model.BatchResult{}
+				for i := range o {
+					results = append(results, model.BatchResult{Id: int64(i + 1)})
+				}
+				return results, nil
+			}).Times(tt.batchTimes)
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			result, err := toDoService.Import(tt.toDoImport)
+
+			// Assert
+			if tt.wantError != nil {
+				if err == nil || (!errors.Is(err, tt.wantError) && err.Error() != tt.wantError.Error()) {
+					t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if !reflect.DeepEqual(result, tt.wantResult) {
+				t.Errorf("expected: %+v, actual: %+v", tt.wantResult, result)
+			}
+			for i, o := range operations {
+				if o.Operation != "create" || o.ToDo.Title != tt.wantTitles[i] || o.ToDo.Status != tt.wantStatuses[i] {
+					t.Errorf("unexpected operation: %+v", o.ToDo)
+				}
+			}
+		})
+	}
+}
+
+func TestParseToDoTxtLine(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name        string
+		text        string
+		want        *ToDoObject
+		wantWarning string
+		wantError   bool
+	}{
+		{
+			name:        "01_優先度と作成日があるケース",
+			text:        "(A) 2021-06-01 Call Mom @phone +family",
+			want:        &ToDoObject{Title: "Call Mom @phone +family"},
+			wantWarning: "not stored: priority (A), creation date 2021-06-01",
+		},
+		{
+			name:        "02_完了日と作成日があるケース",
+			text:        "x 2021-06-15 2021-06-01 Call Mom",
+			want:        &ToDoObject{Title: "Call Mom", Done: true},
+			wantWarning: "not stored: completion date 2021-06-15, creation date 2021-06-01",
+		},
+		{
+			name:        "03_未完了で日付がタイトルの先頭にあるケース",
+			text:        "2021-06-01 2021-06-02 is the deadline",
+			want:        &ToDoObject{Title: "2021-06-02 is the deadline"},
+			wantWarning: "not stored: creation date 2021-06-01",
+		},
+		{
+			name: "04_小文字のxで始まる未完了のケース",
+			text: "xylophone lesson",
+			want: &ToDoObject{Title: "xylophone lesson"},
+		},
+		{
+			name:      "05_完了日が作成日より前のケース",
+			text:      "x 2021-06-01 2021-06-15 Call Mom",
+			wantError: true,
+		},
+		{
+			name:      "06_存在しない日付のケース",
+			text:      "x 2021-02-30 Call Mom",
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Act
+			got, warning, err := parseToDoTxtLine(tt.text)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected: %+v, actual: %+v", tt.want, got)
+			}
+			if warning != tt.wantWarning {
+				t.Errorf("expected warning: %q, actual: %q", tt.wantWarning, warning)
+			}
+		})
+	}
+}
@@ -2,7 +2,13 @@
 package service
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
 	"strconv"
+	"time"
+	"unicode/utf8"
 
 	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
 	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
@@ -11,31 +17,76 @@ import (
 type ToDoService interface {
 	Create(*ToDoObject) (*ToDoObject, error)
 	Read(*ToDoObject) (*ToDoObject, error)
+	ReadByIds([]int64) (map[int64]*ToDoObject, error)
 	Update(*ToDoObject) (*ToDoObject, error)
+	Patch(*ToDoPatchObject) (*ToDoObject, error)
+	Toggle(*ToDoObject) (*ToDoObject, error)
 	Delete(*ToDoObject) (*ToDoObject, error)
 	List(*ListOption) ([]ToDoObject, error)
+	ListPage(*ListOption, int64, int) (*ToDoPageObject, error)
+	Count(*ListOption) (int64, error)
+	Export(*ListOption, func(*ToDoObject) error) error
+	Trash() ([]ToDoObject, error)
+	Restore(*ToDoObject) (*ToDoObject, error)
+	PurgeTrash(time.Time) (int64, error)
+	Archive(*ToDoObject) (*ToDoObject, error)
+	Unarchive(*ToDoObject) (*ToDoObject, error)
+	ArchiveDone(time.Duration) (int64, error)
+	History(*ToDoObject) ([]HistoryObject, error)
+	Revisions(*ToDoObject) ([]RevisionObject, error)
+	Revert(*ToDoObject, int64) (*ToDoObject, error)
+	Batch(*BatchObject) ([]BatchResultObject, error)
+	Import(*ImportObject) (*ImportResultObject, error)
+	ExportICalendar(*ListOption, func(*ToDoObject, string) error) error
+	ReadICalendar(string) (*ToDoObject, error)
+	PutICalendar(*ICalendarObject) (*ToDoObject, bool, error)
+	DeleteICalendar(*ICalendarObject) (*ToDoObject, error)
+	Subscribe(int64) *Subscription
 }
 
+var (
+	ErrInvalidStatus     = errors.New("invalid status")
+	ErrInvalidTransition = errors.New("invalid status transition")
+	ErrInvalidBatch      = errors.New("invalid batch")
+	ErrBatchAborted      = errors.New("rolled back because another operation failed")
+	ErrInvalidImport     = errors.New("invalid import")
+	// the precondition of ICalendarObject is not met
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// the repository cannot read ToDo at a point in time
+	ErrTemporalQueryUnsupported = errors.New("temporal query is not supported")
+)
+
 type toDoService struct {
 	repository repository.ToDoRepository
+	unitOfWork repository.UnitOfWork
+	workflow   *model.Workflow
+	events     *EventBroker
 }
 
-func NewToDoService(repository repository.ToDoRepository) ToDoService {
-	return &toDoService{repository: repository}
+func NewToDoService(repository repository.ToDoRepository, unitOfWork repository.UnitOfWork, workflow *model.Workflow, events *EventBroker) ToDoService {
+	return &toDoService{
+		repository: repository,
+		unitOfWork: unitOfWork,
+		workflow:   workflow,
+		events:     events,
+	}
 }
 
 func (s *toDoService) Create(toDo *ToDoObject) (*ToDoObject, error) {
 
-	createToDo := &model.ToDo{
-		Title: toDo.Title,
-		Done:  toDo.Done,
-	}
-	id, err := s.repository.Insert(createToDo)
+	createToDo, history, err := s.prepareCreate(toDo)
 	if err != nil {
 		return nil, err
 	}
-
-	result, err := s.repository.SelectById(id)
+	var result *model.ToDo
+	err = s.unitOfWork.Do(func(repository repository.ToDoRepository) error {
+		id, err := repository.Insert(createToDo, history)
+		if err != nil {
+			return err
+		}
+		result, err = repository.SelectById(id)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -54,30 +105,71 @@ func (s *toDoService) Read(toDo *ToDoObject) (*ToDoObject, error) {
 	return modelToObject(result), nil
 }
 
-func (s *toDoService) Update(toDo *ToDoObject) (*ToDoObject, error) {
-
-	before, err := s.repository.SelectById(toDo.Id)
+// まとめて読み込み、IDごとに返す(存在しないIDは含まない)
+func (s *toDoService) ReadByIds(ids []int64) (map[int64]*ToDoObject, error) {
+	result, err := s.repository.SelectByIds(ids)
 	if err != nil {
 		return nil, err
 	}
-
-	// 対象のToDoを更新
-	updateToDo := &model.ToDo{
-		Id:    toDo.Id,
-		Title: toDo.Title,
-		Done:  toDo.Done,
+	toDoMap := map[int64]*ToDoObject{}
+	for i := range result {
+		toDoMap[result[i].Id] = modelToObject(&result[i])
 	}
+	return toDoMap, nil
+}
 
-	if updateToDo.Title == "" {
-		updateToDo.Title = before.Title
-	}
-	err = s.repository.Update(updateToDo)
-	if err != nil {
-		return nil, err
-	}
+func (s *toDoService) Update(toDo *ToDoObject) (*ToDoObject, error) {
+	return s.update(toDo.Id, func(*model.ToDo) *ToDoObject { return toDo })
+}
 
-	// 更新されたToDoを取得
-	result, err := s.repository.SelectById(toDo.Id)
+// 指定されなかったフィールドはロックした時点の値のままにする
+func (s *toDoService) Patch(patch *ToDoPatchObject) (*ToDoObject, error) {
+	return s.update(patch.Id, func(before *model.ToDo) *ToDoObject {
+		toDo := &ToDoObject{Id: patch.Id, Done: before.Done, Actor: patch.Actor, RequestId: patch.RequestId}
+		if patch.Title != nil {
+			toDo.Title = *patch.Title
+		}
+		if patch.Status != nil {
+			toDo.Status = *patch.Status
+		}
+		if patch.Done != nil {
+			toDo.Done = *patch.Done
+		}
+		return toDo
+	})
+}
+
+// 完了と未完了を切り替える(ステータスはワークフローに従って決まる)
+func (s *toDoService) Toggle(toDo *ToDoObject) (*ToDoObject, error) {
+	return s.update(toDo.Id, func(before *model.ToDo) *ToDoObject {
+		return &ToDoObject{Id: toDo.Id, Done: !before.Done, Actor: toDo.Actor, RequestId: toDo.RequestId}
+	})
+}
+
+// 対象のToDoをロックし、その時点の値からmergeで決めた内容で更新する
+func (s *toDoService) update(id int64, merge func(before *model.ToDo) *ToDoObject) (*ToDoObject, error) {
+
+	var result *model.ToDo
+	err := s.unitOfWork.Do(func(repository repository.ToDoRepository) error {
+		before, err := repository.SelectByIdForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		// 対象のToDoを更新
+		updateToDo, history, err := s.updatedToDo(before, merge(before))
+		if err != nil {
+			return err
+		}
+		err = repository.Update(updateToDo, history)
+		if err != nil {
+			return err
+		}
+
+		// 更新されたToDoを取得
+		result, err = repository.SelectById(id)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -87,13 +179,18 @@ func (s *toDoService) Update(toDo *ToDoObject) (*ToDoObject, error) {
 
 func (s *toDoService) Delete(toDo *ToDoObject) (*ToDoObject, error) {
 
-	before, err := s.repository.SelectById(toDo.Id)
-	if err != nil {
-		return nil, err
-	}
+	var before *model.ToDo
+	err := s.unitOfWork.Do(func(repository repository.ToDoRepository) error {
+		var err error
+		before, err = repository.SelectByIdForUpdate(toDo.Id)
+		if err != nil {
+			return err
+		}
 
-	// 対象のToDoを削除
-	err = s.repository.DeleteById(toDo.Id)
+		// 対象のToDoを削除
+		history := newHistory(toDo, "delete", []model.FieldChange{{Field: "deleted", Before: false, After: true}})
+		return repository.DeleteById(toDo.Id, history)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -102,37 +199,776 @@ func (s *toDoService) Delete(toDo *ToDoObject) (*ToDoObject, error) {
 }
 
 func (s *toDoService) List(option *ListOption) ([]ToDoObject, error) {
-
 	var result []model.ToDo
-	if option.Done != "" {
+	includeArchived := option.includes("archived")
+	if option.AsOf != nil {
+		r, err := s.listAsOf(option)
+		if err != nil {
+			return nil, err
+		}
+		result = r
+	} else if option.Done != "" {
 		done, _ := strconv.ParseBool(option.Done)
-		r, err := s.repository.ListFilteredByDone(done)
+		r, err := s.repository.ListFilteredByDone(done, includeArchived)
 		if err != nil {
 			return nil, err
 		}
 		result = r
 	} else {
-		r, err := s.repository.ListAll()
+		r, err := s.repository.ListAll(includeArchived)
 		if err != nil {
 			return nil, err
 		}
 		result = r
 	}
-
 	toDoList := []ToDoObject{}
 	for _, t := range result {
 		toDoList = append(toDoList, *modelToObject(&t))
 	}
+	return toDoList, nil
+}
+
+// IDがafterより大きいToDoをIDの順にlimit件まで返す
+func (s *toDoService) ListPage(option *ListOption, after int64, limit int) (*ToDoPageObject, error) {
+	var result []model.ToDo
+	if option.AsOf != nil {
+		// 指定時刻の状態はまとめて組み立てるしかないため、ページはメモリ上で切り出す
+		r, err := s.listAsOf(option)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(r, func(i, j int) bool { return r[i].Id < r[j].Id })
+		start := sort.Search(len(r), func(i int) bool { return r[i].Id > after })
+		end := start + limit + 1
+		if end > len(r) {
+			end = len(r)
+		}
+		result = r[start:end]
+	} else {
+		// 次のページがあるかを知るため1件多く読む
+		r, err := s.repository.ListPage(option.done(), option.includes("archived"), after, limit+1)
+		if err != nil {
+			return nil, err
+		}
+		result = r
+	}
+	page := &ToDoPageObject{ToDo: []ToDoObject{}, HasNext: len(result) > limit}
+	if page.HasNext {
+		result = result[:limit]
+	}
+	for i := range result {
+		page.ToDo = append(page.ToDo, *modelToObject(&result[i]))
+	}
+	return page, nil
+}
+
+// ListPageで読めるToDoの全ページ分の件数
+func (s *toDoService) Count(option *ListOption) (int64, error) {
+	if option.AsOf != nil {
+		r, err := s.listAsOf(option)
+		if err != nil {
+			return 0, err
+		}
+		return int64(len(r)), nil
+	}
+	return s.repository.Count(option.done(), option.includes("archived"))
+}
+
+// 指定時刻の状態の一覧をdoneで絞り込む
+func (s *toDoService) listAsOf(option *ListOption) ([]model.ToDo, error) {
+	// 指定時刻の状態はイベントから組み立てられるリポジトリでのみ読める
+	temporal, ok := s.repository.(repository.TemporalToDoRepository)
+	if !ok {
+		return nil, ErrTemporalQueryUnsupported
+	}
+	r, err := temporal.ListAsOf(*option.AsOf, option.includes("archived"))
+	if err != nil {
+		return nil, err
+	}
+	done := option.done()
+	var result []model.ToDo
+	for _, t := range r {
+		if done != nil && t.Done != *done {
+			continue
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
 
+// 一覧をまとめて読み込まずに1件ずつfnに渡す(fnがエラーを返した場合は中断する)
+func (s *toDoService) Export(option *ListOption, fn func(*ToDoObject) error) error {
+	iterator, err := s.repository.Iterate(option.done(), option.includes("archived"))
+	if err != nil {
+		return err
+	}
+	defer iterator.Close()
+
+	for iterator.Next() {
+		err := fn(modelToObject(iterator.ToDo()))
+		if err != nil {
+			return err
+		}
+	}
+	return iterator.Err()
+}
+
+// ゴミ箱のToDoを一覧する
+func (s *toDoService) Trash() ([]ToDoObject, error) {
+	result, err := s.repository.ListDeleted()
+	if err != nil {
+		return nil, err
+	}
+	toDoList := []ToDoObject{}
+	for _, t := range result {
+		toDoList = append(toDoList, *modelToObject(&t))
+	}
 	return toDoList, nil
 }
 
+func (s *toDoService) Restore(toDo *ToDoObject) (*ToDoObject, error) {
+
+	var result *model.ToDo
+	err := s.unitOfWork.Do(func(repository repository.ToDoRepository) error {
+		// 対象のToDoをゴミ箱から戻す
+		history := newHistory(toDo, "restore", []model.FieldChange{{Field: "deleted", Before: true, After: false}})
+		err := repository.Restore(toDo.Id, history)
+		if err != nil {
+			return err
+		}
+		// 復元されたToDoを取得
+		result, err = repository.SelectById(toDo.Id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return modelToObject(result), nil
+}
+
+// 指定時刻より前にゴミ箱へ移動したToDoを完全に削除する
+func (s *toDoService) PurgeTrash(before time.Time) (int64, error) {
+	// 削除したToDoそれぞれの履歴に記録する
+	history := newHistory(&ToDoObject{}, "purge", []model.FieldChange{{Field: "purged", Before: false, After: true}})
+	return s.repository.PurgeDeletedBefore(before, history)
+}
+
+func (s *toDoService) Archive(toDo *ToDoObject) (*ToDoObject, error) {
+
+	var result *model.ToDo
+	err := s.unitOfWork.Do(func(repository repository.ToDoRepository) error {
+		history := newHistory(toDo, "archive", []model.FieldChange{{Field: "archived", Before: false, After: true}})
+		err := repository.Archive(toDo.Id, history)
+		if err != nil {
+			return err
+		}
+		// アーカイブされたToDoを取得
+		result, err = repository.SelectById(toDo.Id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return modelToObject(result), nil
+}
+
+func (s *toDoService) Unarchive(toDo *ToDoObject) (*ToDoObject, error) {
+
+	var result *model.ToDo
+	err := s.unitOfWork.Do(func(repository repository.ToDoRepository) error {
+		history := newHistory(toDo, "unarchive", []model.FieldChange{{Field: "archived", Before: true, After: false}})
+		err := repository.Unarchive(toDo.Id, history)
+		if err != nil {
+			return err
+		}
+		// アーカイブを解除したToDoを取得
+		result, err = repository.SelectById(toDo.Id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return modelToObject(result), nil
+}
+
+// 完了してから指定期間が経過したToDoをまとめてアーカイブする
+func (s *toDoService) ArchiveDone(olderThan time.Duration) (int64, error) {
+	history := newHistory(&ToDoObject{}, "archive", []model.FieldChange{{Field: "archived", Before: false, After: true}})
+	return s.repository.ArchiveDoneBefore(time.Now().Add(-olderThan), history)
+}
+
+func (s *toDoService) History(toDo *ToDoObject) ([]HistoryObject, error) {
+	result, err := s.repository.ListHistory(toDo.Id)
+	if err != nil {
+		return nil, err
+	}
+	historyList := []HistoryObject{}
+	for _, h := range result {
+		historyList = append(historyList, *historyToObject(&h))
+	}
+	return historyList, nil
+}
+
+func (s *toDoService) Revisions(toDo *ToDoObject) ([]RevisionObject, error) {
+	result, err := s.repository.ListRevisions(toDo.Id)
+	if err != nil {
+		return nil, err
+	}
+	revisionList := []RevisionObject{}
+	for _, r := range result {
+		revisionList = append(revisionList, *revisionToObject(&r))
+	}
+	return revisionList, nil
+}
+
+// 指定したリビジョンの内容にToDoを戻す(戻した結果も新しいリビジョンになる)
+func (s *toDoService) Revert(toDo *ToDoObject, revision int64) (*ToDoObject, error) {
+
+	var result *model.ToDo
+	err := s.unitOfWork.Do(func(repository repository.ToDoRepository) error {
+		var err error
+		result, err = s.revert(repository, toDo, revision)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return modelToObject(result), nil
+}
+
+func (s *toDoService) revert(repository repository.ToDoRepository, toDo *ToDoObject, revision int64) (*model.ToDo, error) {
+	before, err := repository.SelectByIdForUpdate(toDo.Id)
+	if err != nil {
+		return nil, err
+	}
+	target, err := repository.SelectRevision(toDo.Id, revision)
+	if err != nil {
+		return nil, err
+	}
+	// ワークフローの設定が変わっている場合や、今のステータスから遷移できない場合は戻せない
+	status, err := s.nextStatus(before, &ToDoObject{Status: target.Status})
+	if err != nil {
+		return nil, err
+	}
+
+	revertToDo := *before
+	revertToDo.Title = target.Title
+	revertToDo.Status = status
+	revertToDo.Done = target.Done
+	changes := diffToDo(before, &revertToDo)
+	archived := before.ArchivedAt != nil
+	if target.Archived != archived {
+		revertToDo.ArchivedAt = nil
+		if target.Archived {
+			now := time.Now()
+			revertToDo.ArchivedAt = &now
+		}
+		changes = append(changes, model.FieldChange{Field: "archived", Before: archived, After: target.Archived})
+	}
+	history := newHistory(toDo, "revert", changes)
+	err = repository.Update(&revertToDo, history)
+	if err != nil {
+		return nil, err
+	}
+
+	// 戻したToDoを取得
+	return repository.SelectById(toDo.Id)
+}
+
+// 複数の作成・更新・削除をまとめて実行する
+// atomic(デフォルト)では1件でも失敗すると全件を取り消し、best_effortでは失敗した操作だけを取り消す
+func (s *toDoService) Batch(batch *BatchObject) ([]BatchResultObject, error) {
+	atomic, err := batch.atomic()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResultObject, len(batch.Operations))
+	for i, o := range batch.Operations {
+		results[i] = BatchResultObject{Index: i, Op: o.Op, Id: o.Id}
+	}
+	// 検証のための読み込みと書き込みを同じトランザクションで行う
+	err = s.unitOfWork.Do(func(repository repository.ToDoRepository) error {
+		return s.batch(repository, batch, atomic, results)
+	})
+	if err != nil && !errors.Is(err, ErrBatchAborted) {
+		return nil, err
+	}
+	return results, nil
+}
+
+// データの全件を検証し、エラーがなければ1つのトランザクションで作成する(UIDが既存のToDoのものなら更新する)
+func (s *toDoService) Import(toDoImport *ImportObject) (*ImportResultObject, error) {
+	lines, err := parseImport(toDoImport.Format, toDoImport.Data)
+	if err != nil {
+		return nil, err
+	}
+	return s.importLines(lines, toDoImport, nil)
+}
+
+// iCalendarのUIDを持つToDoを1件ずつfnに渡す(see Export)
+func (s *toDoService) ExportICalendar(option *ListOption, fn func(*ToDoObject, string) error) error {
+	uids, err := s.repository.ListUids()
+	if err != nil {
+		return err
+	}
+	return s.Export(option, func(toDo *ToDoObject) error {
+		return fn(toDo, ICalendarUid(toDo.Id, uids))
+	})
+}
+
+// UIDのToDoを返す(ないか、アーカイブされている場合はnil)
+func (s *toDoService) ReadICalendar(uid string) (*ToDoObject, error) {
+	toDo, err := importedToDo(s.repository, uid)
+	if err != nil || toDo == nil || toDo.ArchivedAt != nil {
+		return nil, err
+	}
+	return modelToObject(toDo), nil
+}
+
+// VTODOを1件だけ含むiCalendarでUIDのToDoを作成または更新し、作成した場合はtrueを返す(CalDAVのPUT)
+func (s *toDoService) PutICalendar(object *ICalendarObject) (*ToDoObject, bool, error) {
+	lines, err := parseImportICalendar(object.Data)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(lines) != 1 {
+		return nil, false, fmt.Errorf("%w: the data must contain exactly one VTODO", ErrInvalidImport)
+	}
+	if lines[0].err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrInvalidImport, lines[0].err)
+	}
+	if lines[0].uid != object.Uid {
+		return nil, false, fmt.Errorf("%w: UID %q does not match the resource %q", ErrInvalidImport, lines[0].uid, object.Uid)
+	}
+
+	result, err := s.importLines(lines, &ImportObject{Actor: object.Actor, RequestId: object.RequestId}, object.Precondition)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(result.Errors) > 0 {
+		return nil, false, fmt.Errorf("%w: %s", ErrInvalidImport, result.Errors[0].Error)
+	}
+	toDo, err := s.repository.SelectById(result.Ids[0])
+	if err != nil {
+		return nil, false, err
+	}
+	return modelToObject(toDo), result.Imported > 0, nil
+}
+
+// UIDのToDoをゴミ箱へ移動し、削除前のToDoを返す(CalDAVのDELETE、Dataは使わない)
+func (s *toDoService) DeleteICalendar(object *ICalendarObject) (*ToDoObject, error) {
+	var before *ToDoObject
+	err := s.unitOfWork.Do(func(repository repository.ToDoRepository) error {
+		var err error
+		before, err = lockedToDo(repository, object.Uid)
+		if err != nil {
+			return err
+		}
+		if object.Precondition != nil {
+			err = object.Precondition(before)
+			if err != nil {
+				return err
+			}
+		}
+		if before == nil {
+			return fmt.Errorf("%w: no ToDo has UID %q", sql.ErrNoRows, object.Uid)
+		}
+
+		history := newHistory(&ToDoObject{Id: before.Id, Actor: object.Actor, RequestId: object.RequestId}, "delete", []model.FieldChange{{Field: "deleted", Before: false, After: true}})
+		return repository.DeleteById(before.Id, history)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return before, nil
+}
+
+// 読み込んだ行を検証し、エラーがなければ1つのトランザクションで書き込む
+// preconditionは1行目のUIDのToDoをロックしてから呼び出す(see ICalendarObject)
+func (s *toDoService) importLines(lines []importLine, toDoImport *ImportObject, precondition func(*ToDoObject) error) (*ImportResultObject, error) {
+	result := &ImportResultObject{
+		DryRun: toDoImport.DryRun,
+		Total:  len(lines),
+		Ids:    []int64{},
+		Errors: []ImportErrorObject{},
+	}
+	// 検証のための読み込みと書き込みを同じトランザクションで行う
+	err := s.unitOfWork.Do(func(repository repository.ToDoRepository) error {
+		if precondition != nil {
+			current, err := lockedToDo(repository, lines[0].uid)
+			if err != nil {
+				return err
+			}
+			err = precondition(current)
+			if err != nil {
+				return err
+			}
+		}
+		operations := []model.BatchOperation{}
+		// operationsの各要素に対応するUID
+		uids := []string{}
+		// UIDごとの最初の行番号
+		uidLines := map[string]int{}
+		for _, line := range lines {
+			err := line.err
+			if first, ok := uidLines[line.uid]; ok && err == nil && line.uid != "" {
+				err = fmt.Errorf("duplicate UID %q (the same as line %d)", line.uid, first)
+			} else if line.uid != "" {
+				uidLines[line.uid] = line.line
+			}
+			if err == nil {
+				toDo := line.toDo
+				toDo.Actor = toDoImport.Actor
+				toDo.RequestId = toDoImport.RequestId
+				var operation *model.BatchOperation
+				operation, err = s.prepareImport(repository, &toDo, line.uid)
+				if err == nil {
+					operations = append(operations, *operation)
+					uids = append(uids, line.uid)
+					if line.warning != "" {
+						result.Warnings = append(result.Warnings, ImportWarningObject{Line: line.line, Warning: line.warning})
+					}
+				}
+			}
+			if err != nil {
+				result.Errors = append(result.Errors, ImportErrorObject{Line: line.line, Error: err.Error()})
+			}
+		}
+		result.Valid = len(operations)
+		// 1件でもエラーがあれば何も書き込まない
+		if toDoImport.DryRun || len(result.Errors) > 0 || len(operations) == 0 {
+			return nil
+		}
+
+		written, err := repository.Batch(operations, true)
+		if err != nil {
+			return err
+		}
+		for i, w := range written {
+			result.Ids = append(result.Ids, w.Id)
+			if operations[i].Operation == "update" {
+				result.Updated++
+				continue
+			}
+			result.Imported++
+			// 次に同じUIDをインポートしたときに更新できるよう記録する
+			if uids[i] != "" {
+				err := repository.InsertUid(w.Id, uids[i])
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Start receiving the changes of ToDo (see EventBroker.Subscribe)
+func (s *toDoService) Subscribe(lastEventId int64) *Subscription {
+	return s.events.Subscribe(lastEventId)
+}
+
+// 操作を検証してまとめて書き込み、resultsに結果を詰める
+// atomicで失敗した操作があった場合はErrBatchAbortedを返し、トランザクションごと取り消させる
+func (s *toDoService) batch(repository repository.ToDoRepository, batch *BatchObject, atomic bool, results []BatchResultObject) error {
+	operations := []model.BatchOperation{}
+	// operationsの各要素に対応するresultsの添字
+	indexes := []int{}
+	for i, o := range batch.Operations {
+		toDo := o.ToDoObject
+		toDo.Actor = batch.Actor
+		toDo.RequestId = batch.RequestId
+		operation, err := s.prepareOperation(repository, o.Op, &toDo)
+		if err != nil {
+			results[i].Error = err.Error()
+			if atomic {
+				abortBatch(results, i)
+				return ErrBatchAborted
+			}
+			continue
+		}
+		operations = append(operations, *operation)
+		indexes = append(indexes, i)
+	}
+	if len(operations) == 0 {
+		return nil
+	}
+
+	written, err := repository.Batch(operations, atomic)
+	if written == nil && err != nil {
+		return err
+	}
+	for j, w := range written {
+		result := &results[indexes[j]]
+		switch {
+		case w.Err != nil:
+			result.Error = w.Err.Error()
+		case err != nil:
+			result.Error = ErrBatchAborted.Error()
+		default:
+			result.Ok = true
+			result.Id = w.Id
+		}
+	}
+	if err != nil {
+		return ErrBatchAborted
+	}
+	return nil
+}
+
+// max length of the title (see the todo table)
+const maxTitleLength = 100
+
+// インポートするToDoを検証して書き込む操作を組み立てる(UIDが既存のToDoのものなら更新する)
+func (s *toDoService) prepareImport(repository repository.ToDoRepository, toDo *ToDoObject, uid string) (*model.BatchOperation, error) {
+	if toDo.Title == "" {
+		return nil, errors.New("title is required")
+	}
+	if utf8.RuneCountInString(toDo.Title) > maxTitleLength {
+		return nil, fmt.Errorf("title is too long (max %d characters)", maxTitleLength)
+	}
+	if uid != "" {
+		imported, err := importedToDo(repository, uid)
+		if err != nil {
+			return nil, err
+		}
+		if imported != nil {
+			toDo.Id = imported.Id
+			return s.prepareOperation(repository, "update", toDo)
+		}
+	}
+	return s.prepareOperation(repository, "create", toDo)
+}
+
+// UIDのToDoを返す(ToDoが削除されているかゴミ箱にある場合はnil)
+// 記録されたUIDを優先し、なければこのAPIが払い出したUIDとして読む
+func importedToDo(repository repository.ToDoRepository, uid string) (*model.ToDo, error) {
+	id, err := repository.SelectIdByUid(uid)
+	if err != nil {
+		return nil, err
+	}
+	if id == 0 {
+		id, _ = ownICalendarId(uid)
+	}
+	if id == 0 {
+		return nil, nil
+	}
+	// 削除されたToDoのUIDは新しく作成するToDoに付け直す
+	toDoList, err := repository.SelectByIds([]int64{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(toDoList) == 0 {
+		return nil, nil
+	}
+	return &toDoList[0], nil
+}
+
+// UIDのToDoをロックして返す(ないか、アーカイブされている場合はnil、see ReadICalendar)
+func lockedToDo(repository repository.ToDoRepository, uid string) (*ToDoObject, error) {
+	imported, err := importedToDo(repository, uid)
+	if err != nil || imported == nil {
+		return nil, err
+	}
+	toDo, err := repository.SelectByIdForUpdate(imported.Id)
+	if err != nil {
+		return nil, err
+	}
+	if toDo.ArchivedAt != nil {
+		return nil, nil
+	}
+	return modelToObject(toDo), nil
+}
+
+// 作成するToDoと履歴を組み立てる
+func (s *toDoService) prepareCreate(toDo *ToDoObject) (*model.ToDo, *model.ToDoHistory, error) {
+	// statusが指定されていない場合はdoneから決める
+	status := toDo.Status
+	if status == "" {
+		status = s.workflow.Initial
+		if toDo.Done {
+			status = s.workflow.Done
+		}
+	}
+	if !s.workflow.IsValid(status) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidStatus, status)
+	}
+	createToDo := &model.ToDo{
+		Title:  toDo.Title,
+		Status: status,
+		Done:   s.workflow.IsDone(status),
+	}
+	return createToDo, newHistory(toDo, "create", diffToDo(nil, createToDo)), nil
+}
+
+// 更新後のToDoと履歴を組み立てる(更新が終わるまで対象のToDoをロックする)
+func (s *toDoService) prepareUpdate(repository repository.ToDoRepository, toDo *ToDoObject) (*model.ToDo, *model.ToDoHistory, error) {
+	before, err := repository.SelectByIdForUpdate(toDo.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.updatedToDo(before, toDo)
+}
+
+// ロックしたToDoに更新内容を反映したToDoと履歴を組み立てる
+func (s *toDoService) updatedToDo(before *model.ToDo, toDo *ToDoObject) (*model.ToDo, *model.ToDoHistory, error) {
+	status, err := s.nextStatus(before, toDo)
+	if err != nil {
+		return nil, nil, err
+	}
+	updateToDo := *before
+	updateToDo.Status = status
+	updateToDo.Done = s.workflow.IsDone(status)
+	if toDo.Title != "" {
+		updateToDo.Title = toDo.Title
+	}
+	return &updateToDo, newHistory(toDo, "update", diffToDo(before, &updateToDo)), nil
+}
+
+// バッチの1件分の操作を組み立てる
+func (s *toDoService) prepareOperation(repository repository.ToDoRepository, op string, toDo *ToDoObject) (*model.BatchOperation, error) {
+	switch op {
+	case "create":
+		createToDo, history, err := s.prepareCreate(toDo)
+		if err != nil {
+			return nil, err
+		}
+		return &model.BatchOperation{Operation: op, ToDo: createToDo, History: history}, nil
+	case "update":
+		updateToDo, history, err := s.prepareUpdate(repository, toDo)
+		if err != nil {
+			return nil, err
+		}
+		return &model.BatchOperation{Operation: op, ToDo: updateToDo, History: history}, nil
+	case "delete":
+		before, err := repository.SelectByIdForUpdate(toDo.Id)
+		if err != nil {
+			return nil, err
+		}
+		history := newHistory(toDo, "delete", []model.FieldChange{{Field: "deleted", Before: false, After: true}})
+		return &model.BatchOperation{Operation: op, ToDo: before, History: history}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidBatch, op)
+	}
+}
+
+// failedの操作が失敗したため、それ以外の操作を取り消し扱いにする
+func abortBatch(results []BatchResultObject, failed int) {
+	for i := range results {
+		if i != failed {
+			results[i].Error = ErrBatchAborted.Error()
+		}
+	}
+}
+
+// 更新後のステータスを決定し、ワークフロー上許可された遷移かを確認する
+func (s *toDoService) nextStatus(before *model.ToDo, toDo *ToDoObject) (string, error) {
+	next := toDo.Status
+	if next == "" {
+		// statusが指定されていない場合はdoneから決める
+		switch {
+		case toDo.Done && !s.workflow.IsDone(before.Status):
+			next = s.workflow.Done
+		case !toDo.Done && s.workflow.IsDone(before.Status):
+			next = s.workflow.Initial
+		default:
+			next = before.Status
+		}
+	}
+	if !s.workflow.IsValid(next) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidStatus, next)
+	}
+	if !s.workflow.CanTransition(before.Status, next) {
+		return "", fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, before.Status, next)
+	}
+	return next, nil
+}
+
+// 操作者やリクエストIDとともに履歴を作る
+func newHistory(toDo *ToDoObject, operation string, changes []model.FieldChange) *model.ToDoHistory {
+	return &model.ToDoHistory{
+		ToDoId:    toDo.Id,
+		Operation: operation,
+		Changes:   changes,
+		Actor:     toDo.Actor,
+		RequestId: toDo.RequestId,
+	}
+}
+
+// 履歴に記録する項目
+var historyFields = []string{"title", "status", "done"}
+
+func historyValues(toDo *model.ToDo) map[string]interface{} {
+	if toDo == nil {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"title":  toDo.Title,
+		"status": toDo.Status,
+		"done":   toDo.Done,
+	}
+}
+
+// 変更前後のToDoを比較し、変更された項目を列挙する(作成時はbeforeがnil)
+func diffToDo(before *model.ToDo, after *model.ToDo) []model.FieldChange {
+	b := historyValues(before)
+	a := historyValues(after)
+	changes := []model.FieldChange{}
+	for _, field := range historyFields {
+		if b[field] != a[field] {
+			changes = append(changes, model.FieldChange{Field: field, Before: b[field], After: a[field]})
+		}
+	}
+	return changes
+}
+
 func modelToObject(model *model.ToDo) *ToDoObject {
 	return &ToDoObject{
-		Id:        model.Id,
-		Title:     model.Title,
-		Done:      model.Done,
-		CreatedAt: model.CreatedAt,
-		UpdatedAt: model.UpdatedAt,
+		Id:         model.Id,
+		Title:      model.Title,
+		Status:     model.Status,
+		Done:       model.Done,
+		Archived:   model.ArchivedAt != nil,
+		CreatedAt:  model.CreatedAt,
+		UpdatedAt:  model.UpdatedAt,
+		ArchivedAt: model.ArchivedAt,
+		DeletedAt:  model.DeletedAt,
+	}
+}
+
+func historyToObject(history *model.ToDoHistory) *HistoryObject {
+	changes := []ChangeObject{}
+	for _, c := range history.Changes {
+		changes = append(changes, ChangeObject{Field: c.Field, Before: c.Before, After: c.After})
+	}
+	return &HistoryObject{
+		Id:        history.Id,
+		ToDoId:    history.ToDoId,
+		Operation: history.Operation,
+		Changes:   changes,
+		Actor:     history.Actor,
+		RequestId: history.RequestId,
+		CreatedAt: history.CreatedAt,
+	}
+}
+
+func revisionToObject(revision *model.ToDoRevision) *RevisionObject {
+	return &RevisionObject{
+		ToDoId:    revision.ToDoId,
+		Revision:  revision.Revision,
+		Title:     revision.Title,
+		Status:    revision.Status,
+		Done:      revision.Done,
+		Archived:  revision.Archived,
+		CreatedAt: revision.CreatedAt,
 	}
 }
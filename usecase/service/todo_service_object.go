@@ -1,16 +1,249 @@
 package service
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Request/Response object
 type ToDoObject struct {
-	Id        int64     `json:"id"`
+	Id         int64      `json:"id"`
+	Title      string     `json:"title"`
+	Status     string     `json:"status"`
+	Done       bool       `json:"done"`
+	Archived   bool       `json:"archived"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+
+	// who made the request, recorded in the history
+	Actor string `json:"-"`
+	// ID of the request, recorded in the history
+	RequestId string `json:"-"`
+}
+
+// Fields of the ToDo to update, nil fields are kept as they are when the ToDo is locked
+type ToDoPatchObject struct {
+	Id     int64
+	Title  *string
+	Status *string
+	// ignored if Status is set
+	Done *bool
+
+	// who made the request, recorded in the history
+	Actor string
+	// ID of the request, recorded in the history
+	RequestId string
+}
+
+// Page of the ToDo list in the order of the ID
+type ToDoPageObject struct {
+	ToDo []ToDoObject
+	// true if more ToDo follow the page
+	HasNext bool
+}
+
+// Change history of a ToDo
+type HistoryObject struct {
+	Id        int64          `json:"id"`
+	ToDoId    int64          `json:"todo_id"`
+	Operation string         `json:"operation"`
+	Changes   []ChangeObject `json:"changes"`
+	Actor     string         `json:"actor"`
+	RequestId string         `json:"request_id"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Snapshot of a ToDo at a revision
+type RevisionObject struct {
+	ToDoId    int64     `json:"todo_id"`
+	Revision  int64     `json:"revision"`
 	Title     string    `json:"title"`
+	Status    string    `json:"status"`
 	Done      bool      `json:"done"`
+	Archived  bool      `json:"archived"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ChangeObject struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Request of the bulk create, update and delete
+type BatchObject struct {
+	// "atomic" (default) or "best_effort"
+	Mode       string                 `json:"mode"`
+	Operations []BatchOperationObject `json:"operations"`
+
+	// who made the request, recorded in the history
+	Actor string `json:"-"`
+	// ID of the request, recorded in the history
+	RequestId string `json:"-"`
+}
+
+// One operation of the batch, e.g. {"op": "update", "id": 1, "status": "done"}
+type BatchOperationObject struct {
+	// "create", "update" or "delete"
+	Op string `json:"op"`
+	ToDoObject
+}
+
+// Result of each operation of the batch
+type BatchResultObject struct {
+	Index int    `json:"index"`
+	Op    string `json:"op"`
+	Id    int64  `json:"id,omitempty"`
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// modeが全件を取り消すものか
+func (b *BatchObject) atomic() (bool, error) {
+	switch b.Mode {
+	case "", "atomic":
+		return true, nil
+	case "best_effort":
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: unknown mode %q", ErrInvalidBatch, b.Mode)
+	}
+}
+
+// Request of the bulk import
+type ImportObject struct {
+	// "csv", "json", "todotxt" or "ics"
+	Format string
+	Data   []byte
+	// only validate the data if true
+	DryRun bool
+
+	// who made the request, recorded in the history
+	Actor string
+	// ID of the request, recorded in the history
+	RequestId string
+}
+
+// Result of the bulk import
+type ImportResultObject struct {
+	DryRun bool `json:"dry_run"`
+	// number of ToDo in the data
+	Total int `json:"total"`
+	// number of ToDo without errors
+	Valid int `json:"valid"`
+	// number of ToDo created, 0 if dry run or if any ToDo has errors
+	Imported int `json:"imported"`
+	// number of ToDo updated by the iCalendar UID, 0 if dry run or if any ToDo has errors
+	Updated int `json:"updated"`
+	// IDs of the ToDo created or updated
+	Ids    []int64             `json:"ids"`
+	Errors []ImportErrorObject `json:"errors"`
+	// fields of the valid ToDo that are not stored, e.g. the priority of todo.txt
+	Warnings []ImportWarningObject `json:"warnings,omitempty"`
+}
+
+// VTODO resource written or deleted by a CalDAV client
+type ICalendarObject struct {
+	// UID of the resource, which must match the UID of the VTODO
+	Uid string
+	// iCalendar with exactly one VTODO
+	Data []byte
+	// called in the transaction with the current ToDo of the UID (nil if there is none) after it is locked,
+	// and nothing is written if it returns an error, e.g. ErrPreconditionFailed
+	Precondition func(*ToDoObject) error
+
+	// who made the request, recorded in the history
+	Actor string
+	// ID of the request, recorded in the history
+	RequestId string
+}
+
+// Validation error of a ToDo in the imported data
+type ImportErrorObject struct {
+	// line number (CSV, todo.txt) or 1-based index of the array (JSON)
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// Fields of a ToDo in the imported data that are read but not stored
+type ImportWarningObject struct {
+	// line number (CSV, todo.txt) or 1-based index of the array (JSON)
+	Line    int    `json:"line"`
+	Warning string `json:"warning"`
 }
 
 type ListOption struct {
 	Done string
+	// comma separated, e.g. "archived"
+	Include string
+	// list ToDo as they were at the time if set
+	AsOf *time.Time
+}
+
+// doneで絞り込む場合はその値(絞り込まない場合はnil)
+func (o *ListOption) done() *bool {
+	if o.Done == "" {
+		return nil
+	}
+	done, _ := strconv.ParseBool(o.Done)
+	return &done
+}
+
+// includeに指定した値が含まれているか
+func (o *ListOption) includes(value string) bool {
+	for _, v := range strings.Split(o.Include, ",") {
+		if strings.TrimSpace(v) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Response replayed for a retried request with the same Idempotency-Key
+type IdempotentResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// Request/Response object of a webhook
+type WebhookObject struct {
+	Id  int64  `json:"id"`
+	Url string `json:"url"`
+	// returned only when the webhook is created
+	Secret string `json:"secret,omitempty"`
+	// "created", "updated", "completed" or "deleted", all events if empty
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Payload that could not be delivered to a webhook
+type DeadLetterObject struct {
+	Id        int64           `json:"id"`
+	WebhookId int64           `json:"webhook_id"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Settings of the webhook delivery
+type WebhookDeliveryOption struct {
+	// number of deliveries sent at the same time
+	Workers int
+	// attempts before the payload is stored as a dead letter
+	MaxAttempts int
+	// wait before the first retry, doubled on each retry
+	InitialBackoff time.Duration
+	// timeout of each request
+	Timeout time.Duration
+	// interval of reading the payloads to send
+	PollInterval time.Duration
 }
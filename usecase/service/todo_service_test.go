@@ -2,11 +2,14 @@ package service
 
 import (
 	"errors"
+	"reflect"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
 	"github.com/uzimihsr/todo-rest-api-golang/domain/repository/mock_repository"
 )
 
@@ -69,9 +72,9 @@ func TestCreate(t *testing.T) {
 			// Arrange
 			ctrl := gomock.NewController(t)
 			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
-			mockToDoRepository.EXPECT().Insert(gomock.Any()).Return(tt.createId, tt.createError).Times(tt.createTimes)
+			mockToDoRepository.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(tt.createId, tt.createError).Times(tt.createTimes)
 			mockToDoRepository.EXPECT().SelectById(gomock.Any()).Return(tt.readResult, tt.readError).Times(tt.readTimes)
-			toDoService := NewToDoService(mockToDoRepository)
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
 
 			// Act
 			result, err := toDoService.Create(toDoObject)
@@ -87,6 +90,77 @@ func TestCreate(t *testing.T) {
 	}
 }
 
+func TestCreateStatus(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name       string
+		request    *ToDoObject
+		wantStatus string
+		wantDone   bool
+		wantError  error
+	}{
+		{
+			name:       "01_statusもdoneも指定されていないケース",
+			request:    &ToDoObject{Title: "test-ToDo"},
+			wantStatus: "todo",
+			wantDone:   false,
+			wantError:  nil,
+		},
+		{
+			name:       "02_done=trueが指定されているケース",
+			request:    &ToDoObject{Title: "test-ToDo", Done: true},
+			wantStatus: "done",
+			wantDone:   true,
+			wantError:  nil,
+		},
+		{
+			name:       "03_statusが指定されているケース",
+			request:    &ToDoObject{Title: "test-ToDo", Status: "review"},
+			wantStatus: "review",
+			wantDone:   false,
+			wantError:  nil,
+		},
+		{
+			name:      "04_存在しないステータスで失敗するケース",
+			request:   &ToDoObject{Title: "test-ToDo", Status: "unknown"},
+			wantError: ErrInvalidStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			var created *model.ToDo
+			mockToDoRepository.EXPECT().Insert(gomock.Any(), gomock.Any()).DoAndReturn(func(toDo *model.ToDo, history *model.ToDoHistory) (int64, error) {
+				created = toDo
+				return 100, nil
+			}).AnyTimes()
+			mockToDoRepository.EXPECT().SelectById(int64(100)).DoAndReturn(func(id int64) (*model.ToDo, error) {
+				return created, nil
+			}).AnyTimes()
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			result, err := toDoService.Create(tt.request)
+
+			// Assert
+			if !errors.Is(err, tt.wantError) {
+				t.Errorf("expected error: %v, actual error: %v", tt.wantError, err)
+			}
+			if (result != nil) && (result.Status != tt.wantStatus || result.Done != tt.wantDone) {
+				t.Errorf("values do not match.\n expected(status): %v, actual(status): %v \n expected(done): %v, actual(done): %v", tt.wantStatus, result.Status, tt.wantDone, result.Done)
+			}
+		})
+	}
+}
+
 func TestRead(t *testing.T) {
 	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
 
@@ -126,7 +200,7 @@ func TestRead(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
 			mockToDoRepository.EXPECT().SelectById(gomock.Any()).Return(tt.readResult, tt.readError).Times(tt.readTimes)
-			toDoService := NewToDoService(mockToDoRepository)
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
 
 			// Act
 			result, err := toDoService.Read(toDoObject)
@@ -142,6 +216,59 @@ func TestRead(t *testing.T) {
 	}
 }
 
+func TestReadByIds(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name       string
+		readError  error
+		readResult []model.ToDo
+		want       []int64
+		wantError  bool
+	}{
+		{
+			name:       "01_存在するToDoだけを返すケース",
+			readResult: []model.ToDo{{Id: 1}, {Id: 3}},
+			want:       []int64{1, 3},
+		},
+		{
+			name:      "02_Readが失敗するケース",
+			readError: errors.New("Read ERROR"),
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			mockToDoRepository.EXPECT().SelectByIds([]int64{1, 2, 3}).Return(tt.readResult, tt.readError).Times(1)
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			result, err := toDoService.ReadByIds([]int64{1, 2, 3})
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if len(result) != len(tt.want) {
+				t.Errorf("expected: %v, actual: %v", tt.want, result)
+			}
+			for _, id := range tt.want {
+				if result[id] == nil || result[id].Id != id {
+					t.Errorf("%d is not read: %v", id, result)
+				}
+			}
+		})
+	}
+}
+
 func TestUpdate(t *testing.T) {
 	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
 
@@ -166,12 +293,12 @@ func TestUpdate(t *testing.T) {
 		{
 			name:        "01_Read(1回目)+Update+Read(2回目)が成功するケース",
 			readError1:  nil,
-			readResult1: &model.ToDo{},
+			readResult1: &model.ToDo{Status: "todo"},
 			readTimes1:  1,
 			updateError: nil,
 			updateTimes: 1,
 			readError2:  nil,
-			readResult2: &model.ToDo{Id: toDoObject.Id, Title: toDoObject.Title, Done: toDoObject.Done},
+			readResult2: &model.ToDo{Id: toDoObject.Id, Title: toDoObject.Title, Status: "done", Done: toDoObject.Done},
 			readTimes2:  1,
 			wantError:   false,
 		},
@@ -190,7 +317,7 @@ func TestUpdate(t *testing.T) {
 		{
 			name:        "03_Updateが失敗するケース",
 			readError1:  nil,
-			readResult1: &model.ToDo{},
+			readResult1: &model.ToDo{Status: "todo"},
 			readTimes1:  1,
 			updateError: errors.New("Update ERROR"),
 			updateTimes: 1,
@@ -202,7 +329,7 @@ func TestUpdate(t *testing.T) {
 		{
 			name:        "04_Read(2回目)が失敗するケース",
 			readError1:  nil,
-			readResult1: &model.ToDo{},
+			readResult1: &model.ToDo{Status: "todo"},
 			readTimes1:  1,
 			updateError: nil,
 			updateTimes: 1,
@@ -222,12 +349,10 @@ func TestUpdate(t *testing.T) {
 			// Arrange
 			ctrl := gomock.NewController(t)
 			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
-			gomock.InOrder(
-				mockToDoRepository.EXPECT().SelectById(gomock.Any()).Return(tt.readResult1, tt.readError1).Times(tt.readTimes1),
-				mockToDoRepository.EXPECT().SelectById(gomock.Any()).Return(tt.readResult2, tt.readError2).Times(tt.readTimes2),
-			)
-			mockToDoRepository.EXPECT().Update(gomock.Any()).Return(tt.updateError).Times(tt.updateTimes)
-			toDoService := NewToDoService(mockToDoRepository)
+			mockToDoRepository.EXPECT().SelectByIdForUpdate(gomock.Any()).Return(tt.readResult1, tt.readError1).Times(tt.readTimes1)
+			mockToDoRepository.EXPECT().SelectById(gomock.Any()).Return(tt.readResult2, tt.readError2).Times(tt.readTimes2)
+			mockToDoRepository.EXPECT().Update(gomock.Any(), gomock.Any()).Return(tt.updateError).Times(tt.updateTimes)
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
 
 			// Act
 			result, err := toDoService.Update(toDoObject)
@@ -243,6 +368,253 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestUpdateStatus(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name         string
+		beforeStatus string
+		request      *ToDoObject
+		wantStatus   string
+		wantDone     bool
+		wantError    error
+	}{
+		{
+			name:         "01_statusを指定して遷移するケース",
+			beforeStatus: "todo",
+			request:      &ToDoObject{Id: 100, Status: "in_progress"},
+			wantStatus:   "in_progress",
+			wantDone:     false,
+			wantError:    nil,
+		},
+		{
+			name:         "02_done=trueで完了ステータスに遷移するケース",
+			beforeStatus: "review",
+			request:      &ToDoObject{Id: 100, Done: true},
+			wantStatus:   "done",
+			wantDone:     true,
+			wantError:    nil,
+		},
+		{
+			name:         "03_done=falseで初期ステータスに戻るケース",
+			beforeStatus: "done",
+			request:      &ToDoObject{Id: 100, Done: false},
+			wantStatus:   "todo",
+			wantDone:     false,
+			wantError:    nil,
+		},
+		{
+			name:         "04_done=falseで未完了のステータスが維持されるケース",
+			beforeStatus: "review",
+			request:      &ToDoObject{Id: 100, Done: false},
+			wantStatus:   "review",
+			wantDone:     false,
+			wantError:    nil,
+		},
+		{
+			name:         "05_許可されていない遷移で失敗するケース",
+			beforeStatus: "done",
+			request:      &ToDoObject{Id: 100, Status: "review"},
+			wantError:    ErrInvalidTransition,
+		},
+		{
+			name:         "06_存在しないステータスで失敗するケース",
+			beforeStatus: "todo",
+			request:      &ToDoObject{Id: 100, Status: "unknown"},
+			wantError:    ErrInvalidStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			var updated *model.ToDo
+			selectById := func(id int64) (*model.ToDo, error) {
+				if updated != nil {
+					return updated, nil
+				}
+				return &model.ToDo{Id: id, Title: "test-ToDo", Status: tt.beforeStatus}, nil
+			}
+			mockToDoRepository.EXPECT().SelectByIdForUpdate(tt.request.Id).DoAndReturn(selectById).AnyTimes()
+			mockToDoRepository.EXPECT().SelectById(tt.request.Id).DoAndReturn(selectById).AnyTimes()
+			mockToDoRepository.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(toDo *model.ToDo, history *model.ToDoHistory) error {
+				updated = toDo
+				return nil
+			}).AnyTimes()
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			result, err := toDoService.Update(tt.request)
+
+			// Assert
+			if !errors.Is(err, tt.wantError) {
+				t.Errorf("expected error: %v, actual error: %v", tt.wantError, err)
+			}
+			if (result != nil) && (result.Status != tt.wantStatus || result.Done != tt.wantDone) {
+				t.Errorf("values do not match.\n expected(status): %v, actual(status): %v \n expected(done): %v, actual(done): %v", tt.wantStatus, result.Status, tt.wantDone, result.Done)
+			}
+		})
+	}
+}
+
+func TestPatch(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	title := "renamed"
+	status := "review"
+	notDone := false
+
+	tests := []struct {
+		name         string
+		beforeStatus string
+		patch        *ToDoPatchObject
+		wantTitle    string
+		wantStatus   string
+		wantDone     bool
+		wantError    error
+	}{
+		{
+			name:         "01_タイトルのみ更新して完了を保つケース",
+			beforeStatus: "done",
+			patch:        &ToDoPatchObject{Id: 100, Title: &title},
+			wantTitle:    "renamed",
+			wantStatus:   "done",
+			wantDone:     true,
+		},
+		{
+			name:         "02_statusを指定して遷移するケース",
+			beforeStatus: "in_progress",
+			patch:        &ToDoPatchObject{Id: 100, Status: &status},
+			wantTitle:    "test-ToDo",
+			wantStatus:   "review",
+			wantDone:     false,
+		},
+		{
+			name:         "03_doneを指定して未完了に戻すケース",
+			beforeStatus: "done",
+			patch:        &ToDoPatchObject{Id: 100, Done: &notDone},
+			wantTitle:    "test-ToDo",
+			wantStatus:   "todo",
+			wantDone:     false,
+		},
+		{
+			name:         "04_許可されていない遷移で失敗するケース",
+			beforeStatus: "done",
+			patch:        &ToDoPatchObject{Id: 100, Status: &status},
+			wantError:    ErrInvalidTransition,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			before := &model.ToDo{Id: tt.patch.Id, Title: "test-ToDo", Status: tt.beforeStatus, Done: tt.beforeStatus == "done"}
+			var updated *model.ToDo
+			// 更新前の値はロックして読んだものを使う
+			mockToDoRepository.EXPECT().SelectByIdForUpdate(tt.patch.Id).Return(before, nil).Times(1)
+			mockToDoRepository.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(toDo *model.ToDo, history *model.ToDoHistory) error {
+				updated = toDo
+				return nil
+			}).AnyTimes()
+			mockToDoRepository.EXPECT().SelectById(tt.patch.Id).DoAndReturn(func(id int64) (*model.ToDo, error) {
+				return updated, nil
+			}).AnyTimes()
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			result, err := toDoService.Patch(tt.patch)
+
+			// Assert
+			if !errors.Is(err, tt.wantError) {
+				t.Errorf("expected error: %v, actual error: %v", tt.wantError, err)
+			}
+			if (result != nil) && (result.Title != tt.wantTitle || result.Status != tt.wantStatus || result.Done != tt.wantDone) {
+				t.Errorf("values do not match.\n expected: %v %v %v, actual: %v %v %v", tt.wantTitle, tt.wantStatus, tt.wantDone, result.Title, result.Status, result.Done)
+			}
+		})
+	}
+}
+
+func TestToggle(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name        string
+		before      *model.ToDo
+		readError   error
+		wantStatus  string
+		wantDone    bool
+		wantError   bool
+		updateTimes int
+	}{
+		{
+			name:        "01_未完了のToDoを完了にするケース",
+			before:      &model.ToDo{Id: 100, Title: "test-ToDo", Status: "in_progress"},
+			wantStatus:  "done",
+			wantDone:    true,
+			updateTimes: 1,
+		},
+		{
+			name:        "02_完了したToDoを未完了に戻すケース",
+			before:      &model.ToDo{Id: 100, Title: "test-ToDo", Status: "done", Done: true},
+			wantStatus:  "todo",
+			wantDone:    false,
+			updateTimes: 1,
+		},
+		{
+			name:        "03_ToDoが読めないケース",
+			readError:   errors.New("Read ERROR"),
+			wantError:   true,
+			updateTimes: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			var updated *model.ToDo
+			mockToDoRepository.EXPECT().SelectByIdForUpdate(int64(100)).Return(tt.before, tt.readError).Times(1)
+			mockToDoRepository.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(toDo *model.ToDo, history *model.ToDoHistory) error {
+				updated = toDo
+				return nil
+			}).Times(tt.updateTimes)
+			mockToDoRepository.EXPECT().SelectById(int64(100)).DoAndReturn(func(id int64) (*model.ToDo, error) {
+				return updated, nil
+			}).Times(tt.updateTimes)
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			result, err := toDoService.Toggle(&ToDoObject{Id: 100, Actor: "alice"})
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if (result != nil) && (result.Status != tt.wantStatus || result.Done != tt.wantDone) {
+				t.Errorf("values do not match.\n expected(status): %v, actual(status): %v \n expected(done): %v, actual(done): %v", tt.wantStatus, result.Status, tt.wantDone, result.Done)
+			}
+		})
+	}
+}
+
 func TestDelete(t *testing.T) {
 	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
 
@@ -297,9 +669,9 @@ func TestDelete(t *testing.T) {
 			// Arrange
 			ctrl := gomock.NewController(t)
 			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
-			mockToDoRepository.EXPECT().SelectById(gomock.Any()).Return(tt.readResult, tt.readError).Times(tt.readTimes)
-			mockToDoRepository.EXPECT().DeleteById(gomock.Any()).Return(tt.deleteError).Times(tt.deleteTimes)
-			toDoService := NewToDoService(mockToDoRepository)
+			mockToDoRepository.EXPECT().SelectByIdForUpdate(gomock.Any()).Return(tt.readResult, tt.readError).Times(tt.readTimes)
+			mockToDoRepository.EXPECT().DeleteById(gomock.Any(), gomock.Any()).Return(tt.deleteError).Times(tt.deleteTimes)
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
 
 			// Act
 			result, err := toDoService.Delete(toDoObject)
@@ -368,7 +740,16 @@ func TestList(t *testing.T) {
 			wantError:               true,
 		},
 		{
-			name:                    "04_Listが失敗するケース_doneが指定されていない場合",
+			name:                    "04_Listが成功するケース_アーカイブ済みを含む場合",
+			listOption:              ListOption{Done: "true", Include: "archived"},
+			listError:               nil,
+			listResult:              toDoList,
+			listAllTimes:            0,
+			listFilteredByDoneTimes: 1,
+			wantError:               false,
+		},
+		{
+			name:                    "05_Listが失敗するケース_doneが指定されていない場合",
 			listOption:              ListOption{Done: ""},
 			listError:               errors.New("List ERROR"),
 			listResult:              nil,
@@ -391,9 +772,10 @@ func TestList(t *testing.T) {
 			}
 			ctrl := gomock.NewController(t)
 			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
-			mockToDoRepository.EXPECT().ListAll().Return(tt.listResult, tt.listError).Times(tt.listAllTimes)
-			mockToDoRepository.EXPECT().ListFilteredByDone(done).Return(tt.listResult, tt.listError).Times(tt.listFilteredByDoneTimes)
-			toDoService := NewToDoService(mockToDoRepository)
+			includeArchived := tt.listOption.Include == "archived"
+			mockToDoRepository.EXPECT().ListAll(includeArchived).Return(tt.listResult, tt.listError).Times(tt.listAllTimes)
+			mockToDoRepository.EXPECT().ListFilteredByDone(done, includeArchived).Return(tt.listResult, tt.listError).Times(tt.listFilteredByDoneTimes)
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
 
 			// Act
 			result, err := toDoService.List(&tt.listOption)
@@ -408,3 +790,1115 @@ func TestList(t *testing.T) {
 		})
 	}
 }
+
+// 過去の時点の一覧を読めるリポジトリ
+type temporalToDoRepository struct {
+	*mock_repository.MockToDoRepository
+	*mock_repository.MockTemporalToDoRepository
+}
+
+func TestListAsOf(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	asOf := time.Now().Add(-time.Hour)
+	toDoList := []model.ToDo{
+		{Id: 100, Title: "test-ToDo01", Done: false},
+		{Id: 101, Title: "test-ToDo02", Done: true},
+	}
+
+	tests := []struct {
+		name       string
+		temporal   bool
+		listOption ListOption
+		listError  error
+		wantLength int
+		wantError  error
+	}{
+		{
+			name:       "01_指定時刻の一覧を取得するケース",
+			temporal:   true,
+			listOption: ListOption{AsOf: &asOf},
+			wantLength: 2,
+		},
+		{
+			name:       "02_指定時刻の一覧をdoneで絞り込むケース",
+			temporal:   true,
+			listOption: ListOption{Done: "true", AsOf: &asOf},
+			wantLength: 1,
+		},
+		{
+			name:       "03_一覧の取得が失敗するケース",
+			temporal:   true,
+			listOption: ListOption{AsOf: &asOf},
+			listError:  errors.New("List ERROR"),
+		},
+		{
+			name:       "04_リポジトリが対応していないケース",
+			temporal:   false,
+			listOption: ListOption{AsOf: &asOf},
+			wantError:  ErrTemporalQueryUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			var toDoRepository repository.ToDoRepository = mockToDoRepository
+			if tt.temporal {
+				mockTemporalRepository := mock_repository.NewMockTemporalToDoRepository(ctrl)
+				mockTemporalRepository.EXPECT().ListAsOf(asOf, false).Return(toDoList, tt.listError)
+				toDoRepository = &temporalToDoRepository{mockToDoRepository, mockTemporalRepository}
+			}
+			toDoService := NewToDoService(toDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			result, err := toDoService.List(&tt.listOption)
+
+			// Assert
+			if tt.listError != nil {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantError) {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if len(result) != tt.wantLength {
+				t.Errorf("lengths do not match. expected: %v, actual: %v", tt.wantLength, len(result))
+			}
+		})
+	}
+}
+
+func TestListPage(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	asOf := time.Now().Add(-time.Hour)
+	done := true
+	toDoList := []model.ToDo{
+		{Id: 103, Title: "test-ToDo04", Done: true},
+		{Id: 100, Title: "test-ToDo01", Done: true},
+		{Id: 102, Title: "test-ToDo03", Done: false},
+		{Id: 101, Title: "test-ToDo02", Done: true},
+	}
+
+	tests := []struct {
+		name        string
+		listOption  ListOption
+		after       int64
+		prepare     func(m *mock_repository.MockToDoRepository)
+		wantIds     []int64
+		wantHasNext bool
+		wantError   bool
+	}{
+		{
+			name:       "01_次のページがあるケース",
+			listOption: ListOption{Done: "true"},
+			after:      100,
+			prepare: func(m *mock_repository.MockToDoRepository) {
+				// 次のページがあるかを知るため1件多く読む
+				m.EXPECT().ListPage(&done, false, int64(100), 3).Return([]model.ToDo{toDoList[3], toDoList[0], {Id: 104, Done: true}}, nil).Times(1)
+			},
+			wantIds:     []int64{101, 103},
+			wantHasNext: true,
+		},
+		{
+			name:       "02_最後のページのケース",
+			listOption: ListOption{Include: "archived"},
+			after:      102,
+			prepare: func(m *mock_repository.MockToDoRepository) {
+				m.EXPECT().ListPage(nil, true, int64(102), 3).Return([]model.ToDo{toDoList[0]}, nil).Times(1)
+			},
+			wantIds:     []int64{103},
+			wantHasNext: false,
+		},
+		{
+			name:        "03_指定時刻の一覧をメモリ上でページに分けるケース",
+			listOption:  ListOption{Done: "true", AsOf: &asOf},
+			after:       0,
+			prepare:     func(m *mock_repository.MockToDoRepository) {},
+			wantIds:     []int64{100, 101},
+			wantHasNext: true,
+		},
+		{
+			name:       "04_一覧の取得が失敗するケース",
+			listOption: ListOption{},
+			prepare: func(m *mock_repository.MockToDoRepository) {
+				m.EXPECT().ListPage(nil, false, int64(0), 3).Return(nil, errors.New("List ERROR")).Times(1)
+			},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			tt.prepare(mockToDoRepository)
+			mockTemporalRepository := mock_repository.NewMockTemporalToDoRepository(ctrl)
+			mockTemporalRepository.EXPECT().ListAsOf(asOf, false).DoAndReturn(func(time.Time, bool) ([]model.ToDo, error) {
+				return append([]model.ToDo{}, toDoList...), nil
+			}).AnyTimes()
+			toDoRepository := &temporalToDoRepository{mockToDoRepository, mockTemporalRepository}
+			toDoService := NewToDoService(toDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			result, err := toDoService.ListPage(&tt.listOption, tt.after, 2)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if err != nil {
+				return
+			}
+			ids := []int64{}
+			for _, toDo := range result.ToDo {
+				ids = append(ids, toDo.Id)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIds) || result.HasNext != tt.wantHasNext {
+				t.Errorf("expected: %v %v, actual: %v %v", tt.wantIds, tt.wantHasNext, ids, result.HasNext)
+			}
+		})
+	}
+}
+
+func TestCount(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	asOf := time.Now().Add(-time.Hour)
+	done := false
+
+	tests := []struct {
+		name       string
+		listOption ListOption
+		prepare    func(m *mock_repository.MockToDoRepository)
+		wantCount  int64
+		wantError  bool
+	}{
+		{
+			name:       "01_件数を数えるケース",
+			listOption: ListOption{Done: "false", Include: "archived"},
+			prepare: func(m *mock_repository.MockToDoRepository) {
+				m.EXPECT().Count(&done, true).Return(int64(5), nil).Times(1)
+			},
+			wantCount: 5,
+		},
+		{
+			name:       "02_指定時刻の件数を数えるケース",
+			listOption: ListOption{Done: "false", AsOf: &asOf},
+			prepare:    func(m *mock_repository.MockToDoRepository) {},
+			wantCount:  1,
+		},
+		{
+			name:       "03_件数の取得が失敗するケース",
+			listOption: ListOption{},
+			prepare: func(m *mock_repository.MockToDoRepository) {
+				m.EXPECT().Count(nil, false).Return(int64(0), errors.New("Count ERROR")).Times(1)
+			},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			tt.prepare(mockToDoRepository)
+			mockTemporalRepository := mock_repository.NewMockTemporalToDoRepository(ctrl)
+			mockTemporalRepository.EXPECT().ListAsOf(asOf, false).Return([]model.ToDo{{Id: 100, Done: false}, {Id: 101, Done: true}}, nil).AnyTimes()
+			toDoRepository := &temporalToDoRepository{mockToDoRepository, mockTemporalRepository}
+			toDoService := NewToDoService(toDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			count, err := toDoService.Count(&tt.listOption)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if count != tt.wantCount {
+				t.Errorf("expected: %v, actual: %v", tt.wantCount, count)
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	done := true
+	tests := []struct {
+		name          string
+		listOption    ListOption
+		wantDone      *bool
+		wantArchived  bool
+		iterateError  error
+		iteratorError error
+		fnError       error
+		wantIds       []int64
+		wantError     bool
+	}{
+		{
+			name:       "01_全件を渡すケース",
+			listOption: ListOption{},
+			wantIds:    []int64{100, 101},
+		},
+		{
+			name:         "02_doneとアーカイブ済みを指定するケース",
+			listOption:   ListOption{Done: "true", Include: "archived"},
+			wantDone:     &done,
+			wantArchived: true,
+			wantIds:      []int64{100, 101},
+		},
+		{
+			name:         "03_Iterateが失敗するケース",
+			listOption:   ListOption{},
+			iterateError: errors.New("SELECT FAILED"),
+			wantIds:      []int64{},
+			wantError:    true,
+		},
+		{
+			name:          "04_途中で読み込みが失敗するケース",
+			listOption:    ListOption{},
+			iteratorError: errors.New("SCAN FAILED"),
+			wantIds:       []int64{100, 101},
+			wantError:     true,
+		},
+		{
+			name:       "05_fnが失敗し中断するケース",
+			listOption: ListOption{},
+			fnError:    errors.New("WRITE FAILED"),
+			wantIds:    []int64{100},
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			toDoList := []model.ToDo{{Id: 100, Title: "test-ToDo01"}, {Id: 101, Title: "test-ToDo02"}}
+			position := -1
+			mockIterator := mock_repository.NewMockToDoIterator(ctrl)
+			mockIterator.EXPECT().Next().DoAndReturn(func() bool {
+				position++
+				return position < len(toDoList)
+			}).AnyTimes()
+			mockIterator.EXPECT().ToDo().DoAndReturn(func() *model.ToDo { return &toDoList[position] }).AnyTimes()
+			mockIterator.EXPECT().Err().Return(tt.iteratorError).AnyTimes()
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			if tt.iterateError != nil {
+				mockToDoRepository.EXPECT().Iterate(tt.wantDone, tt.wantArchived).Return(nil, tt.iterateError)
+			} else {
+				mockToDoRepository.EXPECT().Iterate(tt.wantDone, tt.wantArchived).Return(mockIterator, nil)
+				// 途中で中断した場合も閉じる
+				mockIterator.EXPECT().Close().Return(nil).Times(1)
+			}
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			ids := []int64{}
+			err := toDoService.Export(&tt.listOption, func(toDo *ToDoObject) error {
+				ids = append(ids, toDo.Id)
+				return tt.fnError
+			})
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if len(ids) != len(tt.wantIds) {
+				t.Errorf("expected: %v, actual: %v", tt.wantIds, ids)
+			}
+		})
+	}
+}
+
+func TestTrash(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	deletedAt := time.Now()
+	toDoList := []model.ToDo{
+		{
+			Id:        100,
+			Title:     "test-ToDo01",
+			DeletedAt: &deletedAt,
+		},
+	}
+
+	tests := []struct {
+		name       string
+		listError  error
+		listResult []model.ToDo
+		wantError  bool
+	}{
+		{
+			name:       "01_Trashが成功するケース",
+			listError:  nil,
+			listResult: toDoList,
+			wantError:  false,
+		},
+		{
+			name:       "02_Trashが失敗するケース",
+			listError:  errors.New("List ERROR"),
+			listResult: nil,
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			mockToDoRepository.EXPECT().ListDeleted().Return(tt.listResult, tt.listError).Times(1)
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			result, err := toDoService.Trash()
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			if (result != nil) && (len(result) != len(toDoList) || result[0].DeletedAt == nil) {
+				t.Errorf("values do not match. expected: %v, actual: %v", toDoList, result)
+			}
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	toDoObject := &ToDoObject{
+		Id: 100,
+	}
+
+	tests := []struct {
+		name         string
+		restoreError error
+		restoreTimes int
+		readError    error
+		readResult   *model.ToDo
+		readTimes    int
+		wantError    bool
+	}{
+		{
+			name:         "01_Restore+Readが成功するケース",
+			restoreError: nil,
+			restoreTimes: 1,
+			readError:    nil,
+			readResult:   &model.ToDo{Id: toDoObject.Id},
+			readTimes:    1,
+			wantError:    false,
+		},
+		{
+			name:         "02_Restoreが失敗するケース",
+			restoreError: errors.New("Restore ERROR"),
+			restoreTimes: 1,
+			readError:    nil,
+			readResult:   nil,
+			readTimes:    0,
+			wantError:    true,
+		},
+		{
+			name:         "03_Readが失敗するケース",
+			restoreError: nil,
+			restoreTimes: 1,
+			readError:    errors.New("Read ERROR"),
+			readResult:   nil,
+			readTimes:    1,
+			wantError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			mockToDoRepository.EXPECT().Restore(toDoObject.Id, gomock.Any()).Return(tt.restoreError).Times(tt.restoreTimes)
+			mockToDoRepository.EXPECT().SelectById(toDoObject.Id).Return(tt.readResult, tt.readError).Times(tt.readTimes)
+			// トランザクションの外のリポジトリは使わないこと
+			toDoService := NewToDoService(mock_repository.NewMockToDoRepository(ctrl), passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			result, err := toDoService.Restore(toDoObject)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			if (result != nil) && (result.Id != toDoObject.Id) {
+				t.Errorf("value does not match.\n expected(id): %v, actual(id): %v", toDoObject.Id, result.Id)
+			}
+		})
+	}
+}
+
+func TestArchive(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	toDoObject := &ToDoObject{
+		Id: 100,
+	}
+
+	tests := []struct {
+		name         string
+		archiveError error
+		archiveTimes int
+		readError    error
+		readResult   *model.ToDo
+		readTimes    int
+		wantError    bool
+	}{
+		{
+			name:         "01_Archive+Readが成功するケース",
+			archiveError: nil,
+			archiveTimes: 1,
+			readError:    nil,
+			readResult:   &model.ToDo{Id: toDoObject.Id},
+			readTimes:    1,
+			wantError:    false,
+		},
+		{
+			name:         "02_Archiveが失敗するケース",
+			archiveError: errors.New("Archive ERROR"),
+			archiveTimes: 1,
+			readError:    nil,
+			readResult:   nil,
+			readTimes:    0,
+			wantError:    true,
+		},
+		{
+			name:         "03_Readが失敗するケース",
+			archiveError: nil,
+			archiveTimes: 1,
+			readError:    errors.New("Read ERROR"),
+			readResult:   nil,
+			readTimes:    1,
+			wantError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			mockToDoRepository.EXPECT().Archive(toDoObject.Id, gomock.Any()).Return(tt.archiveError).Times(tt.archiveTimes)
+			mockToDoRepository.EXPECT().SelectById(toDoObject.Id).Return(tt.readResult, tt.readError).Times(tt.readTimes)
+			// トランザクションの外のリポジトリは使わないこと
+			toDoService := NewToDoService(mock_repository.NewMockToDoRepository(ctrl), passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			result, err := toDoService.Archive(toDoObject)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			if (result != nil) && (result.Id != toDoObject.Id) {
+				t.Errorf("value does not match.\n expected(id): %v, actual(id): %v", toDoObject.Id, result.Id)
+			}
+		})
+	}
+}
+
+func TestUnarchive(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	toDoObject := &ToDoObject{
+		Id: 100,
+	}
+
+	tests := []struct {
+		name         string
+		archiveError error
+		archiveTimes int
+		readError    error
+		readResult   *model.ToDo
+		readTimes    int
+		wantError    bool
+	}{
+		{
+			name:         "01_Unarchive+Readが成功するケース",
+			archiveError: nil,
+			archiveTimes: 1,
+			readError:    nil,
+			readResult:   &model.ToDo{Id: toDoObject.Id},
+			readTimes:    1,
+			wantError:    false,
+		},
+		{
+			name:         "02_Unarchiveが失敗するケース",
+			archiveError: errors.New("Unarchive ERROR"),
+			archiveTimes: 1,
+			readError:    nil,
+			readResult:   nil,
+			readTimes:    0,
+			wantError:    true,
+		},
+		{
+			name:         "03_Readが失敗するケース",
+			archiveError: nil,
+			archiveTimes: 1,
+			readError:    errors.New("Read ERROR"),
+			readResult:   nil,
+			readTimes:    1,
+			wantError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			mockToDoRepository.EXPECT().Unarchive(toDoObject.Id, gomock.Any()).Return(tt.archiveError).Times(tt.archiveTimes)
+			mockToDoRepository.EXPECT().SelectById(toDoObject.Id).Return(tt.readResult, tt.readError).Times(tt.readTimes)
+			// トランザクションの外のリポジトリは使わないこと
+			toDoService := NewToDoService(mock_repository.NewMockToDoRepository(ctrl), passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			result, err := toDoService.Unarchive(toDoObject)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			if (result != nil) && (result.Id != toDoObject.Id) {
+				t.Errorf("value does not match.\n expected(id): %v, actual(id): %v", toDoObject.Id, result.Id)
+			}
+		})
+	}
+}
+
+func TestArchiveDone(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+	mockToDoRepository.EXPECT().ArchiveDoneBefore(gomock.Any(), gomock.Any()).DoAndReturn(func(before time.Time, history *model.ToDoHistory) (int64, error) {
+		// 30日前より前に更新された完了済みのToDoが対象
+		if d := time.Since(before); d < 30*24*time.Hour || d > 30*24*time.Hour+time.Minute {
+			t.Errorf("unexpected time: %v", before)
+		}
+		// 対象のToDoそれぞれにアーカイブの履歴を残す
+		if history.Operation != "archive" || len(history.Changes) != 1 || history.Changes[0] != (model.FieldChange{Field: "archived", Before: false, After: true}) {
+			t.Errorf("unexpected history: %+v", history)
+		}
+		return 5, nil
+	}).Times(1)
+	toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+	// Act
+	archived, err := toDoService.ArchiveDone(30 * 24 * time.Hour)
+
+	// Assert
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if archived != 5 {
+		t.Errorf("expected: %v, actual: %v", 5, archived)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	before := time.Now()
+	ctrl := gomock.NewController(t)
+	mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+	mockToDoRepository.EXPECT().PurgeDeletedBefore(before, gomock.Any()).DoAndReturn(func(before time.Time, history *model.ToDoHistory) (int64, error) {
+		// 削除したToDoそれぞれに完全削除の履歴を残す
+		if history.Operation != "purge" || len(history.Changes) != 1 || history.Changes[0] != (model.FieldChange{Field: "purged", Before: false, After: true}) {
+			t.Errorf("unexpected history: %+v", history)
+		}
+		return 3, nil
+	}).Times(1)
+	toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+	// Act
+	purged, err := toDoService.PurgeTrash(before)
+
+	// Assert
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if purged != 3 {
+		t.Errorf("expected: %v, actual: %v", 3, purged)
+	}
+}
+
+func TestUpdateHistory(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	before := &model.ToDo{Id: 100, Title: "before", Status: "todo", Done: false}
+	request := &ToDoObject{Id: 100, Title: "after", Done: true, Actor: "tester", RequestId: "req-1"}
+	ctrl := gomock.NewController(t)
+	mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+	mockToDoRepository.EXPECT().SelectByIdForUpdate(request.Id).Return(before, nil).Times(1)
+	mockToDoRepository.EXPECT().SelectById(request.Id).Return(before, nil).Times(1)
+	var recorded *model.ToDoHistory
+	mockToDoRepository.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(toDo *model.ToDo, history *model.ToDoHistory) error {
+		recorded = history
+		return nil
+	}).Times(1)
+	toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+	// Act
+	_, err := toDoService.Update(request)
+
+	// Assert
+	if err != nil {
+		t.Error(err.Error())
+	}
+	expected := []model.FieldChange{
+		{Field: "title", Before: "before", After: "after"},
+		{Field: "status", Before: "todo", After: "done"},
+		{Field: "done", Before: false, After: true},
+	}
+	if recorded.Operation != "update" || recorded.Actor != request.Actor || recorded.RequestId != request.RequestId || recorded.ToDoId != request.Id {
+		t.Errorf("history does not match the request: %+v", recorded)
+	}
+	if len(recorded.Changes) != len(expected) {
+		t.Fatalf("lengths do not match. expected: %v, actual: %v", expected, recorded.Changes)
+	}
+	for i := range expected {
+		if recorded.Changes[i] != expected[i] {
+			t.Errorf("expected: %v, actual: %v", expected[i], recorded.Changes[i])
+		}
+	}
+}
+
+func TestUpdateUnitOfWorkError(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+	mockUnitOfWork := mock_repository.NewMockUnitOfWork(ctrl)
+	mockUnitOfWork.EXPECT().Do(gomock.Any()).Return(errors.New("BEGIN FAILED")).Times(1)
+	toDoService := NewToDoService(mockToDoRepository, mockUnitOfWork, model.DefaultWorkflow(), NewEventBroker(10))
+
+	// Act
+	result, err := toDoService.Update(&ToDoObject{Id: 100, Done: true})
+
+	// Assert
+	if err == nil || result != nil {
+		t.Errorf("expected an error, actual: %v, %v", result, err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	historyList := []model.ToDoHistory{
+		{
+			Id:        1,
+			ToDoId:    100,
+			Operation: "create",
+			Changes:   []model.FieldChange{{Field: "title", Before: nil, After: "test-ToDo"}},
+		},
+		{
+			Id:        2,
+			ToDoId:    100,
+			Operation: "delete",
+			Changes:   []model.FieldChange{{Field: "deleted", Before: false, After: true}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		listError  error
+		listResult []model.ToDoHistory
+		wantError  bool
+	}{
+		{
+			name:       "01_Historyが成功するケース",
+			listError:  nil,
+			listResult: historyList,
+			wantError:  false,
+		},
+		{
+			name:       "02_Historyが失敗するケース",
+			listError:  errors.New("List ERROR"),
+			listResult: nil,
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			mockToDoRepository.EXPECT().ListHistory(int64(100)).Return(tt.listResult, tt.listError).Times(1)
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			result, err := toDoService.History(&ToDoObject{Id: 100})
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			if (result != nil) && (len(result) != len(historyList) || result[1].Operation != "delete") {
+				t.Errorf("values do not match. expected: %v, actual: %v", historyList, result)
+			}
+		})
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	revisionList := []model.ToDoRevision{
+		{ToDoId: 100, Revision: 1, Title: "test-ToDo", Status: "todo", Done: false},
+		{ToDoId: 100, Revision: 2, Title: "test-ToDo", Status: "done", Done: true},
+	}
+
+	tests := []struct {
+		name       string
+		listError  error
+		listResult []model.ToDoRevision
+		wantError  bool
+	}{
+		{
+			name:       "01_Revisionsが成功するケース",
+			listError:  nil,
+			listResult: revisionList,
+			wantError:  false,
+		},
+		{
+			name:       "02_Revisionsが失敗するケース",
+			listError:  errors.New("List ERROR"),
+			listResult: nil,
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			mockToDoRepository.EXPECT().ListRevisions(int64(100)).Return(tt.listResult, tt.listError).Times(1)
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			result, err := toDoService.Revisions(&ToDoObject{Id: 100})
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			if (result != nil) && (len(result) != len(revisionList) || result[1].Revision != 2) {
+				t.Errorf("values do not match. expected: %v, actual: %v", revisionList, result)
+			}
+		})
+	}
+}
+
+func TestRevert(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	archivedAt := time.Now()
+	tests := []struct {
+		name           string
+		before         *model.ToDo
+		revision       *model.ToDoRevision
+		revisionError  error
+		updateError    error
+		wantChanges    []model.FieldChange
+		wantArchived   bool
+		wantUpdateCall int
+		wantError      bool
+		expectedError  error
+	}{
+		{
+			name:           "01_Revertが成功するケース",
+			before:         &model.ToDo{Id: 100, Title: "after", Status: "done", Done: true},
+			revision:       &model.ToDoRevision{ToDoId: 100, Revision: 1, Title: "before", Status: "todo", Done: false},
+			wantChanges:    []model.FieldChange{{Field: "title", Before: "after", After: "before"}, {Field: "status", Before: "done", After: "todo"}, {Field: "done", Before: true, After: false}},
+			wantArchived:   false,
+			wantUpdateCall: 1,
+			wantError:      false,
+		},
+		{
+			name:           "02_アーカイブ状態も戻すケース",
+			before:         &model.ToDo{Id: 100, Title: "test-ToDo", Status: "done", Done: true, ArchivedAt: &archivedAt},
+			revision:       &model.ToDoRevision{ToDoId: 100, Revision: 2, Title: "test-ToDo", Status: "done", Done: true, Archived: false},
+			wantChanges:    []model.FieldChange{{Field: "archived", Before: true, After: false}},
+			wantArchived:   false,
+			wantUpdateCall: 1,
+			wantError:      false,
+		},
+		{
+			name:           "03_リビジョンが存在しないケース",
+			before:         &model.ToDo{Id: 100, Title: "test-ToDo", Status: "todo"},
+			revisionError:  errors.New("SelectRevision ERROR"),
+			wantUpdateCall: 0,
+			wantError:      true,
+		},
+		{
+			name:           "04_リビジョンのステータスが現在のワークフローにないケース",
+			before:         &model.ToDo{Id: 100, Title: "test-ToDo", Status: "todo"},
+			revision:       &model.ToDoRevision{ToDoId: 100, Revision: 1, Title: "test-ToDo", Status: "unknown"},
+			wantUpdateCall: 0,
+			wantError:      true,
+			expectedError:  ErrInvalidStatus,
+		},
+		{
+			name:           "05_Updateが失敗するケース",
+			before:         &model.ToDo{Id: 100, Title: "after", Status: "todo"},
+			revision:       &model.ToDoRevision{ToDoId: 100, Revision: 1, Title: "before", Status: "todo"},
+			updateError:    errors.New("Update ERROR"),
+			wantUpdateCall: 1,
+			wantError:      true,
+		},
+		{
+			name:           "06_今のステータスからリビジョンのステータスに遷移できないケース",
+			before:         &model.ToDo{Id: 100, Title: "test-ToDo", Status: "done", Done: true},
+			revision:       &model.ToDoRevision{ToDoId: 100, Revision: 1, Title: "test-ToDo", Status: "review"},
+			wantUpdateCall: 0,
+			wantError:      true,
+			expectedError:  ErrInvalidTransition,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			mockToDoRepository.EXPECT().SelectByIdForUpdate(int64(100)).Return(tt.before, nil).Times(1)
+			mockToDoRepository.EXPECT().SelectById(int64(100)).Return(tt.before, nil).AnyTimes()
+			mockToDoRepository.EXPECT().SelectRevision(int64(100), int64(1)).Return(tt.revision, tt.revisionError).Times(1)
+			var updated *model.ToDo
+			var recorded *model.ToDoHistory
+			mockToDoRepository.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(toDo *model.ToDo, history *model.ToDoHistory) error {
+				updated = toDo
+				recorded = history
+				return tt.updateError
+			}).Times(tt.wantUpdateCall)
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			_, err := toDoService.Revert(&ToDoObject{Id: 100}, 1)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Error(err.Error())
+			}
+			if tt.expectedError != nil && !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected: %v, actual: %v", tt.expectedError, err)
+			}
+			if tt.wantChanges == nil {
+				return
+			}
+			if recorded.Operation != "revert" || len(recorded.Changes) != len(tt.wantChanges) {
+				t.Fatalf("expected: %v, actual: %+v", tt.wantChanges, recorded)
+			}
+			for i := range tt.wantChanges {
+				if recorded.Changes[i] != tt.wantChanges[i] {
+					t.Errorf("expected: %v, actual: %v", tt.wantChanges[i], recorded.Changes[i])
+				}
+			}
+			if (updated.ArchivedAt != nil) != tt.wantArchived {
+				t.Errorf("expected archived: %v, actual: %v", tt.wantArchived, updated.ArchivedAt)
+			}
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	operations := []BatchOperationObject{
+		{Op: "create", ToDoObject: ToDoObject{Title: "test-ToDo"}},
+		{Op: "update", ToDoObject: ToDoObject{Id: 100, Status: "done"}},
+		{Op: "delete", ToDoObject: ToDoObject{Id: 200}},
+	}
+
+	tests := []struct {
+		name          string
+		batch         *BatchObject
+		selectResult  *model.ToDo
+		batchTimes    int
+		batchResults  []model.BatchResult
+		batchError    error
+		wantOk        []bool
+		wantIds       []int64
+		wantError     bool
+		wantAtomic    bool
+		wantWriteSize int
+	}{
+		{
+			name:          "01_atomicで全件成功するケース",
+			batch:         &BatchObject{Operations: operations},
+			selectResult:  &model.ToDo{Id: 100, Title: "test-ToDo", Status: "todo"},
+			batchTimes:    1,
+			batchResults:  []model.BatchResult{{Id: 300}, {Id: 100}, {Id: 200}},
+			wantOk:        []bool{true, true, true},
+			wantIds:       []int64{300, 100, 200},
+			wantAtomic:    true,
+			wantWriteSize: 3,
+		},
+		{
+			name:          "02_atomicで書き込みが失敗し全件取り消されるケース",
+			batch:         &BatchObject{Mode: "atomic", Operations: operations},
+			selectResult:  &model.ToDo{Id: 100, Title: "test-ToDo", Status: "todo"},
+			batchTimes:    1,
+			batchResults:  []model.BatchResult{{Id: 300}, {Id: 100, Err: errors.New("UPDATE FAILED")}, {}},
+			batchError:    errors.New("UPDATE FAILED"),
+			wantOk:        []bool{false, false, false},
+			wantIds:       []int64{0, 100, 200},
+			wantAtomic:    true,
+			wantWriteSize: 3,
+		},
+		{
+			name:         "03_atomicで検証が失敗し書き込まないケース",
+			batch:        &BatchObject{Operations: operations},
+			selectResult: &model.ToDo{Id: 100, Title: "test-ToDo", Status: "cancelled"},
+			batchTimes:   0,
+			wantOk:       []bool{false, false, false},
+			wantIds:      []int64{0, 100, 200},
+		},
+		{
+			name:          "04_best_effortで検証が失敗した操作だけ除くケース",
+			batch:         &BatchObject{Mode: "best_effort", Operations: operations},
+			selectResult:  &model.ToDo{Id: 100, Title: "test-ToDo", Status: "cancelled"},
+			batchTimes:    1,
+			batchResults:  []model.BatchResult{{Id: 300}, {Id: 200, Err: errors.New("DELETE FAILED")}},
+			wantOk:        []bool{true, false, false},
+			wantIds:       []int64{300, 100, 200},
+			wantAtomic:    false,
+			wantWriteSize: 2,
+		},
+		{
+			name:       "05_modeが不正なケース",
+			batch:      &BatchObject{Mode: "unknown", Operations: operations},
+			batchTimes: 0,
+			wantError:  true,
+		},
+		{
+			name:          "06_書き込みがトランザクションごと失敗するケース",
+			batch:         &BatchObject{Operations: operations},
+			selectResult:  &model.ToDo{Id: 100, Title: "test-ToDo", Status: "todo"},
+			batchTimes:    1,
+			batchResults:  nil,
+			batchError:    errors.New("BEGIN FAILED"),
+			wantAtomic:    true,
+			wantWriteSize: 3,
+			wantError:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			mockToDoRepository.EXPECT().SelectByIdForUpdate(int64(100)).Return(tt.selectResult, nil).AnyTimes()
+			mockToDoRepository.EXPECT().SelectByIdForUpdate(int64(200)).Return(&model.ToDo{Id: 200, Title: "test-ToDo", Status: "todo"}, nil).AnyTimes()
+			mockToDoRepository.EXPECT().Batch(gomock.Any(), gomock.Any()).DoAndReturn(func(written []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+				if len(written) != tt.wantWriteSize || atomic != tt.wantAtomic {
+					t.Errorf("unexpected batch. size: %d, atomic: %v", len(written), atomic)
+				}
+				return tt.batchResults, tt.batchError
+			}).Times(tt.batchTimes)
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+
+			// Act
+			results, err := toDoService.Batch(tt.batch)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if tt.wantError {
+				return
+			}
+			if len(results) != len(operations) {
+				t.Fatalf("expected: %d results, actual: %v", len(operations), results)
+			}
+			for i := range results {
+				if results[i].Ok != tt.wantOk[i] || results[i].Id != tt.wantIds[i] || results[i].Index != i {
+					t.Errorf("unexpected result %d: %+v", i, results[i])
+				}
+				if !results[i].Ok && results[i].Error == "" {
+					t.Errorf("error is not set: %+v", results[i])
+				}
+			}
+		})
+	}
+}
+
+// 渡されたリポジトリでそのまま処理を実行するUnitOfWorkのモック
+func passThroughUnitOfWork(ctrl *gomock.Controller, toDoRepository repository.ToDoRepository) repository.UnitOfWork {
+	mockUnitOfWork := mock_repository.NewMockUnitOfWork(ctrl)
+	mockUnitOfWork.EXPECT().Do(gomock.Any()).DoAndReturn(func(fn func(repository.ToDoRepository) error) error {
+		return fn(toDoRepository)
+	}).AnyTimes()
+	return mockUnitOfWork
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"log"
+	"time"
+)
+
+// Periodically purges ToDo that have been in the trash longer than the retention period
+type TrashPurger struct {
+	*periodicJob
+	service   ToDoService
+	retention time.Duration
+}
+
+func NewTrashPurger(service ToDoService, retention time.Duration, interval time.Duration) *TrashPurger {
+	p := &TrashPurger{
+		service:   service,
+		retention: retention,
+	}
+	p.periodicJob = newPeriodicJob(interval, p.Purge)
+	return p
+}
+
+// Purge once
+func (p *TrashPurger) Purge() {
+	purged, err := p.service.PurgeTrash(time.Now().Add(-p.retention))
+	if err != nil {
+		log.Println("failed to purge trash:", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("purged %d ToDo from the trash", purged)
+	}
+}
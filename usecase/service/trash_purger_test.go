@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository/mock_repository"
+)
+
+func TestTrashPurgerPurge(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name        string
+		purgeResult int64
+		purgeError  error
+	}{
+		{
+			name:        "01_ゴミ箱のToDoを削除するケース",
+			purgeResult: 3,
+			purgeError:  nil,
+		},
+		{
+			name:        "02_削除するToDoがないケース",
+			purgeResult: 0,
+			purgeError:  nil,
+		},
+		{
+			name:        "03_削除に失敗するケース",
+			purgeResult: 0,
+			purgeError:  errors.New("Purge ERROR"),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			retention := 30 * 24 * time.Hour
+			ctrl := gomock.NewController(t)
+			mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+			var before time.Time
+			mockToDoRepository.EXPECT().PurgeDeletedBefore(gomock.Any(), gomock.Any()).DoAndReturn(func(purgeBefore time.Time, history *model.ToDoHistory) (int64, error) {
+				before = purgeBefore
+				return tt.purgeResult, tt.purgeError
+			}).Times(1)
+			toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+			purger := NewTrashPurger(toDoService, retention, time.Hour)
+
+			// Act
+			start := time.Now()
+			purger.Purge()
+			end := time.Now()
+
+			// Assert
+			// 保持期間より前にゴミ箱へ移動したToDoだけを削除する
+			if before.Before(start.Add(-retention)) || before.After(end.Add(-retention)) {
+				t.Errorf("expected: between %v and %v, actual: %v", start.Add(-retention), end.Add(-retention), before)
+			}
+		})
+	}
+}
+
+func TestTrashPurgerStart(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	mockToDoRepository := mock_repository.NewMockToDoRepository(ctrl)
+	purged := make(chan struct{}, 1)
+	mockToDoRepository.EXPECT().PurgeDeletedBefore(gomock.Any(), gomock.Any()).DoAndReturn(func(time.Time, *model.ToDoHistory) (int64, error) {
+		select {
+		case purged <- struct{}{}:
+		default:
+		}
+		return 1, nil
+	}).MinTimes(1)
+	toDoService := NewToDoService(mockToDoRepository, passThroughUnitOfWork(ctrl, mockToDoRepository), model.DefaultWorkflow(), NewEventBroker(10))
+	purger := NewTrashPurger(toDoService, time.Hour, 10*time.Millisecond)
+
+	// Act
+	purger.Start()
+	defer purger.Stop()
+
+	// Assert
+	select {
+	case <-purged:
+	case <-time.After(time.Second):
+		t.Error("the trash is not purged periodically")
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
+)
+
+// Body of the request sent to a webhook
+type webhookPayload struct {
+	// ID of the event in the outbox, the same for the redeliveries
+	Id        int64      `json:"id"`
+	Event     string     `json:"event"`
+	ToDo      ToDoObject `json:"todo"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Sends the changes of ToDo to the registered webhooks in the background (implementation of EventPublisher)
+type WebhookDispatcher struct {
+	*periodicJob
+	repository repository.WebhookRepository
+	client     *http.Client
+	option     WebhookDeliveryOption
+}
+
+func NewWebhookDispatcher(repository repository.WebhookRepository, option WebhookDeliveryOption) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		repository: repository,
+		client:     &http.Client{Timeout: option.Timeout},
+		option:     option,
+	}
+	d.periodicJob = newPeriodicJob(option.PollInterval, func() { d.Dispatch() })
+	return d
+}
+
+// Store the payload of the event for each webhook receiving it.
+// The payloads are sent by Dispatch, so the relay does not wait for the webhooks and no payload is lost when the server stops
+func (d *WebhookDispatcher) PublishEvent(event *EventObject) error {
+	eventTypes := []string{event.Type}
+	if event.Completed {
+		eventTypes = append(eventTypes, "completed")
+	}
+	for _, eventType := range eventTypes {
+		payload, err := json.Marshal(&webhookPayload{Id: event.Id, Event: eventType, ToDo: event.ToDo, CreatedAt: event.CreatedAt})
+		if err != nil {
+			return err
+		}
+		_, err = d.repository.InsertDeliveries(&model.WebhookDelivery{
+			EventId:       event.Id,
+			Event:         eventType,
+			Payload:       payload,
+			NextAttemptAt: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Send the payloads due by now and return the number of the attempts
+// webhooks.workers件ずつ同時に送り、送信中は他のワーカーやサーバーが同じ配信を取らないよう借りておく
+func (d *WebhookDispatcher) Dispatch() int {
+	attempted := 0
+	for !d.stopping() {
+		deliveries, err := d.repository.ClaimDeliveries(d.option.Workers, 2*d.option.Timeout)
+		if err != nil {
+			log.Println("failed to read the webhook deliveries:", err)
+			return attempted
+		}
+		var wg sync.WaitGroup
+		for i := range deliveries {
+			wg.Add(1)
+			go func(delivery *model.WebhookDelivery) {
+				defer wg.Done()
+				d.deliver(delivery)
+			}(&deliveries[i])
+		}
+		wg.Wait()
+		attempted += len(deliveries)
+		if len(deliveries) < d.option.Workers {
+			break
+		}
+	}
+	return attempted
+}
+
+// 2xxが返るまでは削除せず、失敗した場合は間隔を倍にしながら再送し、最後まで失敗したらデッドレターに移す
+func (d *WebhookDispatcher) deliver(delivery *model.WebhookDelivery) {
+	err := d.send(delivery)
+	if err == nil {
+		err = d.repository.DeleteDelivery(delivery.Id)
+		if err != nil {
+			// 借りた期間が過ぎた後にもう一度送られる
+			log.Printf("failed to delete the delivery of the event %d to the webhook %d: %v", delivery.EventId, delivery.Webhook.Id, err)
+		}
+		return
+	}
+
+	delivery.Attempts++
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.option.MaxAttempts {
+		log.Printf("failed to send the event %d to the webhook %d: %v", delivery.EventId, delivery.Webhook.Id, err)
+		err = d.repository.DeadLetter(delivery)
+		if err != nil {
+			log.Printf("failed to store the dead letter of the event %d: %v", delivery.EventId, err)
+		}
+		return
+	}
+	delivery.NextAttemptAt = time.Now().Add(d.option.InitialBackoff << uint(delivery.Attempts-1))
+	err = d.repository.RetryDelivery(delivery)
+	if err != nil {
+		log.Printf("failed to schedule the retry of the event %d to the webhook %d: %v", delivery.EventId, delivery.Webhook.Id, err)
+	}
+}
+
+func (d *WebhookDispatcher) send(delivery *model.WebhookDelivery) error {
+	request, err := http.NewRequest(http.MethodPost, delivery.Webhook.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Webhook-Event", delivery.Event)
+	request.Header.Set("X-Webhook-Id", strconv.FormatInt(delivery.EventId, 10))
+	request.Header.Set("X-Webhook-Signature", "sha256="+SignWebhookPayload(delivery.Webhook.Secret, delivery.Payload))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	// コネクションを再利用できるよう読み捨てる
+	io.Copy(ioutil.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", response.Status)
+	}
+	return nil
+}
+
+// Hex encoded HMAC-SHA256 of the payload, sent as "X-Webhook-Signature: sha256=<signature>"
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository/mock_repository"
+)
+
+// request received by the test webhook
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+// 指定したステータスコードを順に返すWebhookの受信側
+func webhookReceiver(t *testing.T, statusCodes ...int) (*httptest.Server, <-chan receivedWebhook) {
+	received := make(chan receivedWebhook, 10)
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		statusCode := http.StatusOK
+		if count < len(statusCodes) {
+			statusCode = statusCodes[count]
+		}
+		count++
+		w.WriteHeader(statusCode)
+		received <- receivedWebhook{header: r.Header, body: body}
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+var testWebhookOption = WebhookDeliveryOption{
+	Workers:        1,
+	MaxAttempts:    3,
+	InitialBackoff: time.Minute,
+	Timeout:        time.Second,
+	PollInterval:   time.Hour,
+}
+
+func TestWebhookDispatcherPublishEvent(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name        string
+		event       *EventObject
+		insertError error
+		wantEvents  []string
+		wantError   bool
+	}{
+		{
+			name:       "01_イベントを受け取るWebhookへの配信を保存するケース",
+			event:      &EventObject{Id: 1, Type: "created", ToDo: ToDoObject{Id: 100}},
+			wantEvents: []string{"created"},
+			wantError:  false,
+		},
+		{
+			name:       "02_完了した場合はcompletedの配信も保存するケース",
+			event:      &EventObject{Id: 2, Type: "updated", ToDo: ToDoObject{Id: 100, Status: "done", Done: true}, Completed: true},
+			wantEvents: []string{"updated", "completed"},
+			wantError:  false,
+		},
+		{
+			name:        "03_配信の保存に失敗するケース",
+			event:       &EventObject{Id: 3, Type: "deleted", ToDo: ToDoObject{Id: 100}},
+			insertError: errors.New("Insert ERROR"),
+			wantEvents:  []string{"deleted"},
+			wantError:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockWebhookRepository := mock_repository.NewMockWebhookRepository(ctrl)
+			inserted := []*model.WebhookDelivery{}
+			mockWebhookRepository.EXPECT().InsertDeliveries(gomock.Any()).DoAndReturn(func(delivery *model.WebhookDelivery) (int64, error) {
+				inserted = append(inserted, delivery)
+				return 1, tt.insertError
+			}).Times(len(tt.wantEvents))
+			dispatcher := NewWebhookDispatcher(mockWebhookRepository, testWebhookOption)
+
+			// Act
+			err := dispatcher.PublishEvent(tt.event)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			for i, event := range tt.wantEvents {
+				payload := webhookPayload{}
+				err := json.Unmarshal(inserted[i].Payload, &payload)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				if inserted[i].EventId != tt.event.Id || inserted[i].Event != event || payload.Id != tt.event.Id || payload.Event != event || payload.ToDo.Id != tt.event.ToDo.Id {
+					t.Errorf("unexpected delivery: %+v, payload: %+v", inserted[i], payload)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookDispatcherDispatch(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name           string
+		statusCode     int
+		attempts       int
+		claimError     error
+		wantSent       bool
+		wantDeleted    bool
+		wantRetry      bool
+		wantDeadLetter bool
+	}{
+		{
+			name:        "01_配信に成功したら削除するケース",
+			statusCode:  http.StatusOK,
+			attempts:    0,
+			wantSent:    true,
+			wantDeleted: true,
+		},
+		{
+			name:       "02_配信に失敗したら再送を予約するケース",
+			statusCode: http.StatusServiceUnavailable,
+			attempts:   1,
+			wantSent:   true,
+			wantRetry:  true,
+		},
+		{
+			name:           "03_最後の再送も失敗したらデッドレターに移すケース",
+			statusCode:     http.StatusInternalServerError,
+			attempts:       2,
+			wantSent:       true,
+			wantDeadLetter: true,
+		},
+		{
+			name:       "04_配信待ちの読み込みに失敗するケース",
+			claimError: errors.New("Claim ERROR"),
+			wantSent:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			server, received := webhookReceiver(t, tt.statusCode)
+			payload := []byte(`{"id":2,"event":"completed"}`)
+			delivery := model.WebhookDelivery{
+				Id:       10,
+				Webhook:  model.Webhook{Id: 1, Url: server.URL, Secret: "secret"},
+				EventId:  2,
+				Event:    "completed",
+				Payload:  payload,
+				Attempts: tt.attempts,
+			}
+			ctrl := gomock.NewController(t)
+			mockWebhookRepository := mock_repository.NewMockWebhookRepository(ctrl)
+			// 1回目で取れた件数がワーカー数と同じなので、空になるまで読む
+			claimed := [][]model.WebhookDelivery{{delivery}, {}}
+			mockWebhookRepository.EXPECT().ClaimDeliveries(testWebhookOption.Workers, 2*testWebhookOption.Timeout).DoAndReturn(func(int, time.Duration) ([]model.WebhookDelivery, error) {
+				deliveries := claimed[0]
+				claimed = claimed[1:]
+				return deliveries, tt.claimError
+			}).MinTimes(1)
+			mockWebhookRepository.EXPECT().DeleteDelivery(delivery.Id).Return(nil).Times(boolToTimes(tt.wantDeleted))
+			var retried *model.WebhookDelivery
+			mockWebhookRepository.EXPECT().RetryDelivery(gomock.Any()).DoAndReturn(func(d *model.WebhookDelivery) error {
+				retried = d
+				return nil
+			}).Times(boolToTimes(tt.wantRetry))
+			var deadLetter *model.WebhookDelivery
+			mockWebhookRepository.EXPECT().DeadLetter(gomock.Any()).DoAndReturn(func(d *model.WebhookDelivery) error {
+				deadLetter = d
+				return nil
+			}).Times(boolToTimes(tt.wantDeadLetter))
+			dispatcher := NewWebhookDispatcher(mockWebhookRepository, testWebhookOption)
+
+			// Act
+			start := time.Now()
+			dispatcher.Dispatch()
+
+			// Assert
+			select {
+			case r := <-received:
+				if !tt.wantSent {
+					t.Fatalf("unexpected delivery: %s", r.body)
+				}
+				if r.header.Get("X-Webhook-Event") != "completed" || r.header.Get("X-Webhook-Id") != "2" {
+					t.Errorf("unexpected headers: %v", r.header)
+				}
+				if r.header.Get("X-Webhook-Signature") != "sha256="+SignWebhookPayload("secret", payload) || string(r.body) != string(payload) {
+					t.Errorf("invalid signature: %s", r.header.Get("X-Webhook-Signature"))
+				}
+			default:
+				if tt.wantSent {
+					t.Fatal("webhook is not delivered")
+				}
+			}
+			// 再送の間隔は試行ごとに倍になる
+			if tt.wantRetry {
+				backoff := testWebhookOption.InitialBackoff << uint(tt.attempts)
+				if retried.Attempts != tt.attempts+1 || retried.LastError == "" || retried.NextAttemptAt.Before(start.Add(backoff)) || retried.NextAttemptAt.After(time.Now().Add(backoff)) {
+					t.Errorf("unexpected retry: %+v", retried)
+				}
+			}
+			if tt.wantDeadLetter && (deadLetter.Webhook.Id != 1 || deadLetter.Event != "completed" || deadLetter.Attempts != testWebhookOption.MaxAttempts || deadLetter.LastError == "") {
+				t.Errorf("unexpected dead letter: %+v", deadLetter)
+			}
+		})
+	}
+}
+
+// trueなら1回、falseなら0回
+func boolToTimes(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,199 @@
+//go:generate mockgen -source=$GOFILE -destination=mock_$GOPACKAGE/mock_$GOFILE -package=mock_$GOPACKAGE
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository"
+)
+
+type WebhookService interface {
+	Create(*WebhookObject) (*WebhookObject, error)
+	Read(*WebhookObject) (*WebhookObject, error)
+	Update(*WebhookObject) (*WebhookObject, error)
+	Delete(*WebhookObject) (*WebhookObject, error)
+	List() ([]WebhookObject, error)
+	DeadLetters(*WebhookObject) ([]DeadLetterObject, error)
+}
+
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// events a webhook can receive
+var webhookEvents = map[string]bool{
+	"created":   true,
+	"updated":   true,
+	"completed": true,
+	"deleted":   true,
+}
+
+type webhookService struct {
+	repository repository.WebhookRepository
+}
+
+func NewWebhookService(repository repository.WebhookRepository) WebhookService {
+	return &webhookService{
+		repository: repository,
+	}
+}
+
+func (s *webhookService) Create(webhook *WebhookObject) (*WebhookObject, error) {
+	err := validateWebhook(webhook)
+	if err != nil {
+		return nil, err
+	}
+	secret := webhook.Secret
+	if secret == "" {
+		// 指定されていない場合は署名の鍵を生成する
+		secret, err = generateWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	id, err := s.repository.Insert(&model.Webhook{Url: webhook.Url, Secret: secret, Events: webhook.Events})
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.repository.SelectById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// 鍵は作成時にだけ返す
+	resultWebhook := webhookModelToObject(result)
+	resultWebhook.Secret = result.Secret
+	return resultWebhook, nil
+}
+
+func (s *webhookService) Read(webhook *WebhookObject) (*WebhookObject, error) {
+	result, err := s.repository.SelectById(webhook.Id)
+	if err != nil {
+		return nil, err
+	}
+	return webhookModelToObject(result), nil
+}
+
+// 指定された項目だけを変更する(eventsは空の配列で全イベントになる)
+func (s *webhookService) Update(webhook *WebhookObject) (*WebhookObject, error) {
+	before, err := s.repository.SelectById(webhook.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	updateWebhook := *before
+	if webhook.Url != "" {
+		updateWebhook.Url = webhook.Url
+	}
+	if webhook.Secret != "" {
+		updateWebhook.Secret = webhook.Secret
+	}
+	if webhook.Events != nil {
+		updateWebhook.Events = webhook.Events
+	}
+	err = validateWebhook(webhookModelToObject(&updateWebhook))
+	if err != nil {
+		return nil, err
+	}
+	err = s.repository.Update(&updateWebhook)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.repository.SelectById(webhook.Id)
+	if err != nil {
+		return nil, err
+	}
+	return webhookModelToObject(result), nil
+}
+
+func (s *webhookService) Delete(webhook *WebhookObject) (*WebhookObject, error) {
+	before, err := s.repository.SelectById(webhook.Id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.repository.DeleteById(webhook.Id)
+	if err != nil {
+		return nil, err
+	}
+	return webhookModelToObject(before), nil
+}
+
+func (s *webhookService) List() ([]WebhookObject, error) {
+	result, err := s.repository.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	webhooks := []WebhookObject{}
+	for i := range result {
+		webhooks = append(webhooks, *webhookModelToObject(&result[i]))
+	}
+	return webhooks, nil
+}
+
+func (s *webhookService) DeadLetters(webhook *WebhookObject) ([]DeadLetterObject, error) {
+	result, err := s.repository.ListDeadLetters(webhook.Id)
+	if err != nil {
+		return nil, err
+	}
+	deadLetters := []DeadLetterObject{}
+	for _, d := range result {
+		deadLetters = append(deadLetters, DeadLetterObject{
+			Id:        d.Id,
+			WebhookId: d.WebhookId,
+			Event:     d.Event,
+			Payload:   d.Payload,
+			Attempts:  d.Attempts,
+			LastError: d.LastError,
+			CreatedAt: d.CreatedAt,
+		})
+	}
+	return deadLetters, nil
+}
+
+// URLとイベントを検証する
+func validateWebhook(webhook *WebhookObject) error {
+	u, err := url.Parse(webhook.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if len(webhook.Url) > 2048 {
+		return fmt.Errorf("%w: url is longer than 2048 characters", ErrInvalidWebhook)
+	}
+	if len(webhook.Secret) > 255 {
+		return fmt.Errorf("%w: secret is longer than 255 characters", ErrInvalidWebhook)
+	}
+	for _, event := range webhook.Events {
+		if !webhookEvents[event] {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+	}
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+func webhookModelToObject(webhook *model.Webhook) *WebhookObject {
+	events := webhook.Events
+	if events == nil {
+		events = []string{}
+	}
+	return &WebhookObject{
+		Id:        webhook.Id,
+		Url:       webhook.Url,
+		Events:    events,
+		CreatedAt: webhook.CreatedAt,
+		UpdatedAt: webhook.UpdatedAt,
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/model"
+	"github.com/uzimihsr/todo-rest-api-golang/domain/repository/mock_repository"
+)
+
+func TestWebhookCreate(t *testing.T) {
+	t.Parallel() // https://github.com/golang/go/wiki/TableDrivenTests
+
+	tests := []struct {
+		name          string
+		webhook       *WebhookObject
+		insertTimes   int
+		wantGenerated bool
+		wantError     error
+	}{
+		{
+			name:          "01_鍵を指定して作成するケース",
+			webhook:       &WebhookObject{Url: "https://example.com/hook", Secret: "secret", Events: []string{"completed"}},
+			insertTimes:   1,
+			wantGenerated: false,
+			wantError:     nil,
+		},
+		{
+			name:          "02_鍵を生成して作成するケース",
+			webhook:       &WebhookObject{Url: "http://localhost:8081/hook"},
+			insertTimes:   1,
+			wantGenerated: true,
+			wantError:     nil,
+		},
+		{
+			name:        "03_URLが不正なケース",
+			webhook:     &WebhookObject{Url: "example.com/hook"},
+			insertTimes: 0,
+			wantError:   ErrInvalidWebhook,
+		},
+		{
+			name:        "04_不明なイベントのケース",
+			webhook:     &WebhookObject{Url: "https://example.com/hook", Events: []string{"archived"}},
+			insertTimes: 0,
+			wantError:   ErrInvalidWebhook,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			t.Log(tt.name)
+
+			// Arrange
+			ctrl := gomock.NewController(t)
+			mockWebhookRepository := mock_repository.NewMockWebhookRepository(ctrl)
+			var inserted *model.Webhook
+			mockWebhookRepository.EXPECT().Insert(gomock.Any()).DoAndReturn(func(webhook *model.Webhook) (int64, error) {
+				inserted = webhook
+				return 1, nil
+			}).Times(tt.insertTimes)
+			mockWebhookRepository.EXPECT().SelectById(int64(1)).DoAndReturn(func(id int64) (*model.Webhook, error) {
+				result := *inserted
+				result.Id = id
+				return &result, nil
+			}).Times(tt.insertTimes)
+			webhookService := NewWebhookService(mockWebhookRepository)
+
+			// Act
+			result, err := webhookService.Create(tt.webhook)
+
+			// Assert
+			if !errors.Is(err, tt.wantError) {
+				t.Errorf("expected error: %v, actual: %v", tt.wantError, err)
+			}
+			if err != nil {
+				return
+			}
+			if result.Id != 1 || result.Url != tt.webhook.Url {
+				t.Errorf("unexpected webhook: %+v", result)
+			}
+			if tt.wantGenerated && len(result.Secret) != 64 {
+				t.Errorf("secret is not generated: %q", result.Secret)
+			}
+			if !tt.wantGenerated && result.Secret != tt.webhook.Secret {
+				t.Errorf("expected secret: %s, actual: %s", tt.webhook.Secret, result.Secret)
+			}
+		})
+	}
+}
+
+func TestWebhookRead(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	ctrl := gomock.NewController(t)
+	mockWebhookRepository := mock_repository.NewMockWebhookRepository(ctrl)
+	mockWebhookRepository.EXPECT().SelectById(int64(1)).Return(&model.Webhook{Id: 1, Url: "https://example.com/hook", Secret: "secret"}, nil).Times(1)
+	webhookService := NewWebhookService(mockWebhookRepository)
+
+	// Act
+	result, err := webhookService.Read(&WebhookObject{Id: 1})
+
+	// Assert
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// 鍵は作成時以外は返さない
+	if result.Secret != "" || result.Events == nil {
+		t.Errorf("unexpected webhook: %+v", result)
+	}
+}